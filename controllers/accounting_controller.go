@@ -0,0 +1,591 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var accountMappingCollection = database.OpenCollection(database.Client, "account_mapping")
+var accountingPeriodCollection = database.OpenCollection(database.Client, "accounting_periods")
+
+var errPeriodLocked = errors.New("the accounting period is locked because it has already been exported")
+
+// GetAccountMapping godoc
+// @Summary Get the chart-of-accounts mapping
+// @Description Retrieve the accounts the journal export posts sales, tenders, taxes and tips to
+// @Tags accounting
+// @Produce json
+// @Success 200 {object} models.Account_Mapping
+// @Router /accounting/accounts [get]
+func GetAccountMapping() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		c.JSON(http.StatusOK, accountMapping(ctx))
+	}
+}
+
+// UpdateAccountMapping godoc
+// @Summary Update the chart-of-accounts mapping
+// @Description Replace the account names used by the journal export. Tax codes without an entry in tax_accounts post to tax_payable. Requires the manager or admin role; periods already exported keep the accounts they were exported with.
+// @Tags accounting
+// @Accept json
+// @Produce json
+// @Param mapping body models.Account_Mapping true "Account mapping"
+// @Success 200 {object} models.Account_Mapping
+// @Failure 400 {object} object "Invalid input"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 500 {object} object "Error saving account mapping"
+// @Router /accounting/accounts [put]
+func UpdateAccountMapping() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		var mapping models.Account_Mapping
+		if err := c.BindJSON(&mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(mapping); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		mapping.ID = primitive.NilObjectID
+		mapping.Updated_By = c.GetString("user_id")
+		mapping.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		upsert := true
+		_, err := accountMappingCollection.ReplaceOne(ctx, bson.M{}, mapping, &options.ReplaceOptions{Upsert: &upsert})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving account mapping"})
+			return
+		}
+		recordAudit(ctx, "ACCOUNT_MAPPING_UPDATED", "account_mapping", "", mapping.Updated_By, 0, "")
+		c.JSON(http.StatusOK, mapping)
+	}
+}
+
+// GetJournal godoc
+// @Summary Preview the journal for a day
+// @Description Build the double-entry journal for one business day in the branch timezone: invoices issued, payments and tips taken, refunds and voids. Locked days return the entries that were exported.
+// @Tags accounting
+// @Produce json
+// @Param date query string true "Business day (YYYY-MM-DD)"
+// @Param branch_id query string false "Branch ID (default all branches)"
+// @Success 200 {object} object "Entries, totals and lock state"
+// @Failure 400 {object} object "Invalid date"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /accounting/journal [get]
+func GetJournal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		branchId := c.Query("branch_id")
+		day, err := accountingDay(ctx, c.Query("date"), branchId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		date := day.Format("2006-01-02")
+
+		var period models.Accounting_Period
+		err = accountingPeriodCollection.FindOne(ctx, bson.M{"date": date, "branch_id": branchId}).Decode(&period)
+		locked := err == nil
+		if !locked {
+			entries, err := buildJournal(ctx, day, branchId)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			period = models.Accounting_Period{Date: date, Branch_Id: branchId, Entries: entries, Total_Debit: journalDebits(entries)}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"date":        date,
+			"branch_id":   branchId,
+			"locked":      locked,
+			"entries":     period.Entries,
+			"total_debit": period.Total_Debit,
+		})
+	}
+}
+
+// ExportJournal godoc
+// @Summary Export and lock a day's journal
+// @Description Download the journal for a closed business day as CSV or a QuickBooks IIF file. The first export locks the day: payments, refunds, voids and invoice changes dated in it are rejected and later downloads return the same entries. Requires the manager or admin role.
+// @Tags accounting
+// @Produce text/csv
+// @Produce text/plain
+// @Param date query string true "Business day (YYYY-MM-DD)"
+// @Param branch_id query string false "Branch ID (default all branches)"
+// @Param format query string false "csv or iif (default csv)"
+// @Success 200 {file} file "Journal export"
+// @Failure 400 {object} object "Invalid date or format"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 409 {object} object "Day overlaps another export"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /accounting/export [get]
+func ExportJournal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		format := c.DefaultQuery("format", "csv")
+		if format != "csv" && format != "iif" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or iif"})
+			return
+		}
+		branchId := c.Query("branch_id")
+		day, err := accountingDay(ctx, c.Query("date"), branchId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !day.AddDate(0, 0, 1).Before(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only days that have ended can be exported"})
+			return
+		}
+		date := day.Format("2006-01-02")
+
+		var period models.Accounting_Period
+		if err := accountingPeriodCollection.FindOne(ctx, bson.M{"date": date, "branch_id": branchId}).Decode(&period); err != nil {
+			// A day is exported either for all branches or branch by branch,
+			// never both, so no sale reaches the books twice.
+			overlap := bson.M{"date": date, "branch_id": bson.M{"$ne": ""}}
+			if branchId != "" {
+				overlap["branch_id"] = ""
+			}
+			count, err := accountingPeriodCollection.CountDocuments(ctx, overlap)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if count > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "This day has already been exported with a different branch scope"})
+				return
+			}
+
+			entries, err := buildJournal(ctx, day, branchId)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			period.ID = primitive.NewObjectID()
+			period.Period_Id = period.ID.Hex()
+			period.Date = date
+			period.Branch_Id = branchId
+			period.Entries = entries
+			period.Total_Debit = journalDebits(entries)
+			period.Locked_By = c.GetString("user_id")
+			period.Locked_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+			if _, err := accountingPeriodCollection.InsertOne(ctx, period); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error locking accounting period"})
+				return
+			}
+			recordAudit(ctx, "ACCOUNTING_PERIOD_LOCKED", "accounting_period", period.Period_Id, period.Locked_By, period.Total_Debit, date)
+		}
+
+		var file []byte
+		contentType := "text/csv"
+		if format == "iif" {
+			file, err = helpers.JournalIIF(period.Entries)
+			contentType = "text/plain"
+		} else {
+			file, err = helpers.JournalCSV(period.Entries)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		name := "journal-" + date
+		if branchId != "" {
+			name += "-" + branchId
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+name+`.`+format+`"`)
+		c.Data(http.StatusOK, contentType, file)
+	}
+}
+
+// GetAccountingPeriods godoc
+// @Summary List locked accounting periods
+// @Description Retrieve the exported days, newest first, without their entries
+// @Tags accounting
+// @Produce json
+// @Param branch_id query string false "Branch ID"
+// @Success 200 {array} models.Accounting_Period
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /accounting/periods [get]
+func GetAccountingPeriods() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if branchId := c.Query("branch_id"); branchId != "" {
+			filter["branch_id"] = branchId
+		}
+		opts := options.Find().SetSort(bson.D{{Key: "date", Value: -1}}).SetProjection(bson.M{"entries": 0})
+		cursor, err := accountingPeriodCollection.Find(ctx, filter, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		periods := []models.Accounting_Period{}
+		if err = cursor.All(ctx, &periods); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, periods)
+	}
+}
+
+// UnlockAccountingPeriod godoc
+// @Summary Unlock an accounting period
+// @Description Reopen an exported day so corrections can be made. The day must be exported again afterwards. Requires the admin role.
+// @Tags accounting
+// @Produce json
+// @Param period_id path string true "Period ID"
+// @Success 200 {object} object "Period unlocked"
+// @Failure 403 {object} object "Admin role required"
+// @Failure 404 {object} object "Period not found"
+// @Router /accounting/periods/{period_id} [delete]
+func UnlockAccountingPeriod() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin role required"})
+			return
+		}
+		var period models.Accounting_Period
+		periodId := c.Param("period_id")
+		if err := accountingPeriodCollection.FindOneAndDelete(ctx, bson.M{"period_id": periodId}).Decode(&period); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Accounting period not found"})
+			return
+		}
+		recordAudit(ctx, "ACCOUNTING_PERIOD_UNLOCKED", "accounting_period", periodId, c.GetString("user_id"), period.Total_Debit, period.Date)
+		c.JSON(http.StatusOK, gin.H{"message": "Accounting period unlocked", "date": period.Date})
+	}
+}
+
+func accountMapping(ctx context.Context) models.Account_Mapping {
+	var mapping models.Account_Mapping
+	if err := accountMappingCollection.FindOne(ctx, bson.M{}).Decode(&mapping); err != nil {
+		return helpers.DefaultAccountMapping()
+	}
+	return mapping
+}
+
+// accountingDay parses a business day as midnight in the branch timezone.
+func accountingDay(ctx context.Context, value, branchId string) (time.Time, error) {
+	location := helpers.BranchLocation(branchForTable(ctx, models.Table{Branch_Id: branchId}))
+	day, err := time.ParseInLocation("2006-01-02", value, location)
+	if err != nil {
+		return day, errors.New("date must be YYYY-MM-DD")
+	}
+	return day, nil
+}
+
+// ensurePeriodOpen returns errPeriodLocked when the business day containing t
+// has been exported, either for the branch or for all branches.
+func ensurePeriodOpen(ctx context.Context, branchId string, t time.Time) error {
+	location := helpers.BranchLocation(branchForTable(ctx, models.Table{Branch_Id: branchId}))
+	count, err := accountingPeriodCollection.CountDocuments(ctx, bson.M{
+		"date":      t.In(location).Format("2006-01-02"),
+		"branch_id": bson.M{"$in": bson.A{branchId, ""}},
+	})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errPeriodLocked
+	}
+	return nil
+}
+
+// ensureInvoicePeriodOpen checks both the day the invoice was issued and
+// today, since a change is booked on the day it is made.
+func ensureInvoicePeriodOpen(ctx context.Context, invoice models.Invoice) error {
+	if err := ensurePeriodOpen(ctx, invoice.Branch_Id, invoice.Created_At); err != nil {
+		return err
+	}
+	return ensurePeriodOpen(ctx, invoice.Branch_Id, time.Now())
+}
+
+// ensureOrderPeriodOpen checks every invoice of an order before the order's
+// charges are changed.
+func ensureOrderPeriodOpen(ctx context.Context, orderId string) error {
+	cursor, err := invoiceCollection.Find(ctx, bson.M{"order_id": orderId})
+	if err != nil {
+		return err
+	}
+	var invoices []models.Invoice
+	if err = cursor.All(ctx, &invoices); err != nil {
+		return err
+	}
+	for _, invoice := range invoices {
+		if err := ensureInvoicePeriodOpen(ctx, invoice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildJournal turns one day's invoices, payments, credit notes and voids
+// into balanced journal entries. Invoices post to accounts receivable, which
+// payments then clear; tips go to their own liability.
+func buildJournal(ctx context.Context, day time.Time, branchId string) ([]models.Journal_Entry, error) {
+	mapping := accountMapping(ctx)
+	date := day.Format("2006-01-02")
+	inDay := bson.M{"$gte": day, "$lt": day.AddDate(0, 0, 1)}
+	byCreated := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	invoices := map[string]*models.Invoice{}
+	invoiceFor := func(invoiceId string) (*models.Invoice, error) {
+		if invoices[invoiceId] == nil {
+			var invoice models.Invoice
+			if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+				return nil, fmt.Errorf("invoice %s: %w", invoiceId, err)
+			}
+			invoices[invoiceId] = &invoice
+		}
+		return invoices[invoiceId], nil
+	}
+	inBranch := func(invoice *models.Invoice) bool {
+		return branchId == "" || invoice.Branch_Id == branchId
+	}
+
+	var entries []models.Journal_Entry
+	add := func(b *helpers.JournalBuilder) error {
+		entry, err := b.Entry()
+		if err != nil {
+			return err
+		}
+		if len(entry.Lines) > 0 {
+			entries = append(entries, entry)
+		}
+		return nil
+	}
+
+	filter := bson.M{"created_at": inDay}
+	if branchId != "" {
+		filter["branch_id"] = branchId
+	}
+	cursor, err := invoiceCollection.Find(ctx, filter, byCreated)
+	if err != nil {
+		return nil, err
+	}
+	var issued []models.Invoice
+	if err = cursor.All(ctx, &issued); err != nil {
+		return nil, err
+	}
+	for i := range issued {
+		invoice := &issued[i]
+		invoices[invoice.Invoice_Id] = invoice
+		entry, err := invoiceJournal(ctx, mapping, *invoice, date, false)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(entry); err != nil {
+			return nil, err
+		}
+	}
+
+	cursor, err = paymentCollection.Find(ctx, bson.M{"created_at": inDay}, byCreated)
+	if err != nil {
+		return nil, err
+	}
+	var payments []models.Payment
+	if err = cursor.All(ctx, &payments); err != nil {
+		return nil, err
+	}
+	for _, payment := range payments {
+		invoice, err := invoiceFor(payment.Invoice_Id)
+		if err != nil {
+			return nil, err
+		}
+		if !inBranch(invoice) {
+			continue
+		}
+		memo := fmt.Sprintf("%s payment on invoice %s", *payment.Payment_Method, payment.Invoice_Id)
+		err = add(helpers.NewJournalEntry("PAY-"+payment.Payment_Id, date, "PAYMENT", payment.Payment_Id, memo).
+			Debit(helpers.TenderAccount(mapping, *payment.Payment_Method), *payment.Amount+payment.Tip_Amount).
+			Credit(mapping.Accounts_Receivable, *payment.Amount).
+			Credit(mapping.Tips_Payable, payment.Tip_Amount))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cursor, err = creditNoteCollection.Find(ctx, bson.M{"created_at": inDay}, byCreated)
+	if err != nil {
+		return nil, err
+	}
+	var notes []models.Credit_Note
+	if err = cursor.All(ctx, &notes); err != nil {
+		return nil, err
+	}
+	for _, note := range notes {
+		invoice, err := invoiceFor(note.Invoice_Id)
+		if err != nil {
+			return nil, err
+		}
+		if !inBranch(invoice) {
+			continue
+		}
+		method := "CASH"
+		var reversal models.Reversal
+		var payment models.Payment
+		if reversalCollection.FindOne(ctx, bson.M{"reversal_id": note.Reversal_Id}).Decode(&reversal) == nil &&
+			paymentCollection.FindOne(ctx, bson.M{"payment_id": reversal.Payment_Id}).Decode(&payment) == nil {
+			method = *payment.Payment_Method
+		}
+		// The refund takes back its share of the invoice's tax and service
+		// charge; the rest is a sales return.
+		share := 0.0
+		if invoice.Total_Amount > 0 {
+			share = note.Amount / invoice.Total_Amount
+		}
+		if share > 1 {
+			share = 1
+		}
+		entry := helpers.NewJournalEntry("CN-"+note.Credit_Note_Id, date, "REFUND", note.Credit_Note_Id,
+			fmt.Sprintf("Refund on invoice %s (%s)", note.Invoice_Id, note.Reason_Code))
+		returned := note.Amount
+		for _, tax := range invoice.Taxes {
+			amount := toFixed(tax.Amount*share, 2)
+			entry.Debit(helpers.TaxAccount(mapping, tax.Code), amount)
+			returned -= amount
+		}
+		for _, charge := range invoice.Service_Charges {
+			amount := toFixed(charge.Amount*share, 2)
+			entry.Debit(mapping.Service_Charges, amount)
+			returned -= amount
+		}
+		entry.Debit(mapping.Sales_Returns, returned).
+			Credit(helpers.TenderAccount(mapping, method), note.Amount)
+		if err := add(entry); err != nil {
+			return nil, err
+		}
+	}
+
+	cursor, err = reversalCollection.Find(ctx, bson.M{
+		"type":       "VOID",
+		"status":     reversalCompleted,
+		"updated_at": inDay,
+	}, options.Find().SetSort(bson.D{{Key: "updated_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var voids []models.Reversal
+	if err = cursor.All(ctx, &voids); err != nil {
+		return nil, err
+	}
+	for _, void := range voids {
+		cursor, err := invoiceCollection.Find(ctx, bson.M{"order_id": void.Order_Id}, byCreated)
+		if err != nil {
+			return nil, err
+		}
+		var voided []models.Invoice
+		if err = cursor.All(ctx, &voided); err != nil {
+			return nil, err
+		}
+		for _, invoice := range voided {
+			if !inBranch(&invoice) {
+				continue
+			}
+			entry, err := invoiceJournal(ctx, mapping, invoice, date, true)
+			if err != nil {
+				return nil, err
+			}
+			if err := add(entry); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
+
+// invoiceJournal books an invoice to accounts receivable, or takes it back
+// out again when reverse is set. Sales revenue is what remains once tax,
+// service charge and gift card sales are split out, so the entry always
+// balances.
+func invoiceJournal(ctx context.Context, mapping models.Account_Mapping, invoice models.Invoice, date string, reverse bool) (*helpers.JournalBuilder, error) {
+	giftCards, err := invoiceGiftCardSales(ctx, invoice.Order_Id)
+	if err != nil {
+		return nil, err
+	}
+	sign := 1.0
+	id, source, memo := "INV-"+invoice.Invoice_Id, "INVOICE", "Invoice "+invoice.Invoice_Id
+	if reverse {
+		sign = -1
+		id, source, memo = "VOID-"+invoice.Invoice_Id, "VOID", "Void of invoice "+invoice.Invoice_Id
+	}
+
+	entry := helpers.NewJournalEntry(id, date, source, invoice.Invoice_Id, memo).
+		Debit(mapping.Accounts_Receivable, sign*invoice.Total_Amount).
+		Debit(mapping.Sales_Discounts, sign*invoice.Discount_Total).
+		Credit(mapping.Gift_Card_Liability, sign*giftCards)
+	sales := invoice.Total_Amount + invoice.Discount_Total - giftCards
+	for _, tax := range invoice.Taxes {
+		entry.Credit(helpers.TaxAccount(mapping, tax.Code), sign*tax.Amount)
+		sales -= tax.Amount
+	}
+	for _, charge := range invoice.Service_Charges {
+		entry.Credit(mapping.Service_Charges, sign*charge.Amount)
+		sales -= charge.Amount
+	}
+	return entry.Credit(mapping.Sales, sign*sales), nil
+}
+
+// invoiceGiftCardSales totals the gift card lines on an order. Selling a gift
+// card is a liability, not revenue.
+func invoiceGiftCardSales(ctx context.Context, orderId string) (float64, error) {
+	cursor, err := orderItemCollection.Find(ctx, bson.M{"order_id": orderId})
+	if err != nil {
+		return 0, err
+	}
+	var items []models.Ordered_Item
+	if err = cursor.All(ctx, &items); err != nil {
+		return 0, err
+	}
+	var total float64
+	for _, item := range items {
+		if item.Gift_Card_Id != "" {
+			total += item.Price * float64(item.Quantity)
+		}
+	}
+	return toFixed(total, 2), nil
+}
+
+func journalDebits(entries []models.Journal_Entry) float64 {
+	var total float64
+	for _, entry := range entries {
+		for _, line := range entry.Lines {
+			total += line.Debit
+		}
+	}
+	return toFixed(total, 2)
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var auditCollection = database.OpenCollection(database.Client, "audit_logs")
+
+// recordAudit appends an entry to the audit trail. A failed write is logged
+// rather than returned so it never undoes the operation being audited.
+func recordAudit(ctx context.Context, action, entity, entityId, userId string, amount float64, details string) {
+	var entry models.Audit_Log
+	entry.ID = primitive.NewObjectID()
+	entry.Audit_Id = entry.ID.Hex()
+	entry.Action = action
+	entry.Entity = entity
+	entry.Entity_Id = entityId
+	entry.User_Id = userId
+	entry.Amount = amount
+	entry.Details = details
+	entry.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	if _, err := auditCollection.InsertOne(ctx, entry); err != nil {
+		log.Println("Error writing audit log:", err)
+	}
+}
+
+// GetAuditLogs godoc
+// @Summary List audit log entries
+// @Description Retrieve the audit trail, newest first, optionally filtered by entity or entity ID
+// @Tags audit
+// @Accept json
+// @Produce json
+// @Param entity query string false "Entity type (invoice, payment, order, reversal)"
+// @Param entity_id query string false "Entity ID"
+// @Success 200 {array} models.Audit_Log
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /audit_logs [get]
+func GetAuditLogs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if entity := c.Query("entity"); entity != "" {
+			filter["entity"] = entity
+		}
+		if entityId := c.Query("entity_id"); entityId != "" {
+			filter["entity_id"] = entityId
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		cursor, err := auditCollection.Find(ctx, filter, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var entries []models.Audit_Log
+		if err = cursor.All(ctx, &entries); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, entries)
+	}
+}
@@ -0,0 +1,342 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// availabilityRequest is the body of the availability toggles. Until only
+// applies to SOLD_OUT; without it the item stays sold out until switched
+// back.
+type availabilityRequest struct {
+	Status string     `json:"status" validate:"required,oneof=AVAILABLE SOLD_OUT HIDDEN"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
+type foodAvailability struct {
+	Food_Id   string     `json:"food_id"`
+	Food_Name string     `json:"food_name"`
+	Status    string     `json:"status"`
+	Until     *time.Time `json:"until,omitempty"`
+}
+
+type optionAvailability struct {
+	Modifier_Group_Id string     `json:"modifier_group_id"`
+	Option_Id         string     `json:"option_id"`
+	Name              string     `json:"name"`
+	Status            string     `json:"status"`
+	Until             *time.Time `json:"until,omitempty"`
+}
+
+// Event types pushed on the availability stream.
+const (
+	foodAvailabilityEvent   = "food_availability"
+	optionAvailabilityEvent = "modifier_availability"
+)
+
+const streamHeartbeat = 25 * time.Second
+
+// SetFoodAvailability godoc
+// @Summary 86 a food or bring it back
+// @Description Mark a food AVAILABLE, SOLD_OUT (optionally until a time) or HIDDEN. The change is pushed to every client on /availability/stream and orders for the food are rejected until it is available again.
+// @Tags availability
+// @Accept json
+// @Produce json
+// @Param food_id path string true "Food ID"
+// @Param availability body availabilityRequest true "New status"
+// @Success 200 {object} foodAvailability
+// @Failure 400 {object} object "Invalid status or until time"
+// @Failure 404 {object} object "Food not found"
+// @Failure 500 {object} object "Error updating availability"
+// @Router /foods/{food_id}/availability [patch]
+func SetFoodAvailability() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		request, err := bindAvailability(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		foodId := c.Param("food_id")
+		var food models.Food
+		if err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food not found"})
+			return
+		}
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		_, err = foodCollection.UpdateOne(ctx, bson.M{"food_id": foodId}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "availability", Value: request.Status},
+				{Key: "sold_out_until", Value: request.Until},
+				{Key: "updated_at", Value: updatedAt},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating availability"})
+			return
+		}
+		invalidateSearchIndex()
+		recordAudit(ctx, "FOOD_AVAILABILITY", "food", foodId, c.GetString("user_id"), 0, request.Status)
+
+		change := foodAvailability{Food_Id: foodId, Food_Name: food.Food_Name, Status: request.Status, Until: request.Until}
+		helpers.Events.Publish(helpers.Event{Type: foodAvailabilityEvent, Data: change})
+		if request.Until != nil {
+			time.AfterFunc(time.Until(*request.Until), func() { republishFoodAvailability(foodId) })
+		}
+		c.JSON(http.StatusOK, change)
+	}
+}
+
+// SetModifierOptionAvailability godoc
+// @Summary 86 a modifier option or bring it back
+// @Description Mark one option of a modifier group AVAILABLE, SOLD_OUT (optionally until a time) or HIDDEN, e.g. when the kitchen runs out of a sauce. The change is pushed on /availability/stream.
+// @Tags availability
+// @Accept json
+// @Produce json
+// @Param modifier_group_id path string true "Modifier group ID"
+// @Param option_id path string true "Option ID"
+// @Param availability body availabilityRequest true "New status"
+// @Success 200 {object} optionAvailability
+// @Failure 400 {object} object "Invalid status or until time"
+// @Failure 404 {object} object "Modifier option not found"
+// @Failure 500 {object} object "Error updating availability"
+// @Router /modifier_groups/{modifier_group_id}/options/{option_id}/availability [patch]
+func SetModifierOptionAvailability() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		request, err := bindAvailability(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		groupId := c.Param("modifier_group_id")
+		optionId := c.Param("option_id")
+		var group models.Modifier_Group
+		if err := modifierGroupCollection.FindOne(ctx, bson.M{"modifier_group_id": groupId, "options.option_id": optionId}).Decode(&group); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Modifier option not found"})
+			return
+		}
+		_, err = modifierGroupCollection.UpdateOne(ctx, bson.M{"modifier_group_id": groupId, "options.option_id": optionId}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "options.$.availability", Value: request.Status},
+				{Key: "options.$.sold_out_until", Value: request.Until},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating availability"})
+			return
+		}
+		recordAudit(ctx, "MODIFIER_AVAILABILITY", "modifier_group", groupId, c.GetString("user_id"), 0, optionId+" "+request.Status)
+
+		option, _ := helpers.ModifierOption(group, optionId)
+		change := optionAvailability{Modifier_Group_Id: groupId, Option_Id: optionId, Name: option.Name, Status: request.Status, Until: request.Until}
+		helpers.Events.Publish(helpers.Event{Type: optionAvailabilityEvent, Data: change})
+		if request.Until != nil {
+			time.AfterFunc(time.Until(*request.Until), func() { republishOptionAvailability(groupId, optionId) })
+		}
+		c.JSON(http.StatusOK, change)
+	}
+}
+
+// GetAvailability godoc
+// @Summary List what is 86'd
+// @Description Retrieve the foods and modifier options that are sold out or hidden right now. Clients load this when they connect and then follow /availability/stream.
+// @Tags availability
+// @Produce json
+// @Success 200 {object} object "foods and modifier_options"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /availability [get]
+func GetAvailability() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		snapshot, err := availabilitySnapshot(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, snapshot)
+	}
+}
+
+// StreamAvailability godoc
+// @Summary Follow availability changes
+// @Description Server-sent events. A "snapshot" event with everything currently unavailable is sent first, then food_availability and modifier_availability events as items are 86'd or return, and a "ping" every 25 seconds to keep the connection open. A client that falls too far behind is disconnected and should reconnect for a fresh snapshot.
+// @Tags availability
+// @Produce text/event-stream
+// @Success 200 {string} string "Event stream"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /availability/stream [get]
+func StreamAvailability() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Subscribe before the snapshot so no change falls between them.
+		events, unsubscribe := helpers.Events.Subscribe()
+		defer unsubscribe()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		snapshot, err := availabilitySnapshot(ctx)
+		cancel()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.SSEvent("snapshot", snapshot)
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return false
+				}
+				c.SSEvent(event.Type, event.Data)
+				return true
+			case now := <-heartbeat.C:
+				c.SSEvent("ping", now.Unix())
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			}
+		})
+	}
+}
+
+func bindAvailability(c *gin.Context) (availabilityRequest, error) {
+	var request availabilityRequest
+	if err := c.BindJSON(&request); err != nil {
+		return request, err
+	}
+	if err := validate.Struct(request); err != nil {
+		return request, err
+	}
+	if request.Until != nil {
+		if request.Status != helpers.SoldOut {
+			return request, errors.New("until only applies to SOLD_OUT")
+		}
+		if !request.Until.After(time.Now()) {
+			return request, errors.New("until must be in the future")
+		}
+	}
+	return request, nil
+}
+
+// availabilitySnapshot lists the foods and options not available now.
+func availabilitySnapshot(ctx context.Context) (gin.H, error) {
+	now := time.Now()
+	unavailable := bson.M{"$in": bson.A{helpers.SoldOut, helpers.Hidden}}
+
+	var foods []models.Food
+	cursor, err := foodCollection.Find(ctx, bson.M{"availability": unavailable})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(ctx, &foods); err != nil {
+		return nil, err
+	}
+	foodList := []foodAvailability{}
+	for _, food := range foods {
+		status, until := helpers.CurrentAvailability(food.Availability, food.Sold_Out_Until, now)
+		if status != helpers.Available {
+			foodList = append(foodList, foodAvailability{Food_Id: *food.Food_Id, Food_Name: food.Food_Name, Status: status, Until: until})
+		}
+	}
+
+	var groups []models.Modifier_Group
+	cursor, err = modifierGroupCollection.Find(ctx, bson.M{"options.availability": unavailable})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	optionList := []optionAvailability{}
+	for _, group := range groups {
+		for _, option := range group.Options {
+			status, until := helpers.CurrentAvailability(option.Availability, option.Sold_Out_Until, now)
+			if status != helpers.Available {
+				optionList = append(optionList, optionAvailability{
+					Modifier_Group_Id: group.Modifier_Group_Id,
+					Option_Id:         option.Option_Id,
+					Name:              option.Name,
+					Status:            status,
+					Until:             until,
+				})
+			}
+		}
+	}
+	return gin.H{"foods": foodList, "modifier_options": optionList}, nil
+}
+
+// republishFoodAvailability pushes a food's current status once its sold out
+// time has passed. The food is reloaded since it may have changed since.
+func republishFoodAvailability(foodId string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var food models.Food
+	if err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food); err != nil {
+		log.Println("Error reloading food availability:", err)
+		return
+	}
+	status, until := helpers.CurrentAvailability(food.Availability, food.Sold_Out_Until, time.Now())
+	helpers.Events.Publish(helpers.Event{Type: foodAvailabilityEvent, Data: foodAvailability{
+		Food_Id: foodId, Food_Name: food.Food_Name, Status: status, Until: until,
+	}})
+}
+
+func republishOptionAvailability(groupId, optionId string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var group models.Modifier_Group
+	if err := modifierGroupCollection.FindOne(ctx, bson.M{"modifier_group_id": groupId}).Decode(&group); err != nil {
+		log.Println("Error reloading modifier availability:", err)
+		return
+	}
+	option, ok := helpers.ModifierOption(group, optionId)
+	if !ok {
+		return
+	}
+	status, until := helpers.CurrentAvailability(option.Availability, option.Sold_Out_Until, time.Now())
+	helpers.Events.Publish(helpers.Event{Type: optionAvailabilityEvent, Data: optionAvailability{
+		Modifier_Group_Id: groupId, Option_Id: optionId, Name: option.Name, Status: status, Until: until,
+	}})
+}
+
+// foodOrderable rejects foods that are sold out or hidden at t, and foods
+// stored without a price or menu.
+func foodOrderable(food models.Food, t time.Time) error {
+	if food.Food_Price == nil || food.Menu_Id == nil {
+		return fmt.Errorf("%s has no price or menu and cannot be ordered", food.Food_Name)
+	}
+	status, until := helpers.CurrentAvailability(food.Availability, food.Sold_Out_Until, t)
+	switch status {
+	case helpers.SoldOut:
+		if until != nil {
+			return fmt.Errorf("%s is sold out until %s", food.Food_Name, until.Format("15:04 MST"))
+		}
+		return fmt.Errorf("%s is sold out", food.Food_Name)
+	case helpers.Hidden:
+		return fmt.Errorf("%s is not available", food.Food_Name)
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var branchCollection = database.OpenCollection(database.Client, "branches")
+
+// GetBranches godoc
+// @Summary Get all branches
+// @Description Retrieve every restaurant branch
+// @Tags branches
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.Branch
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /branches [get]
+func GetBranches() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		cursor, err := branchCollection.Find(ctx, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var branches []models.Branch
+		if err = cursor.All(ctx, &branches); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, branches)
+	}
+}
+
+// GetBranch godoc
+// @Summary Get a single branch
+// @Description Retrieve a branch by its ID
+// @Tags branches
+// @Accept json
+// @Produce json
+// @Param branch_id path string true "Branch ID"
+// @Success 200 {object} models.Branch
+// @Failure 404 {object} object "Branch not found"
+// @Router /branches/{branch_id} [get]
+func GetBranch() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var branch models.Branch
+		if err := branchCollection.FindOne(ctx, bson.M{"branch_id": c.Param("branch_id")}).Decode(&branch); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Branch not found"})
+			return
+		}
+		c.JSON(http.StatusOK, branch)
+	}
+}
+
+// CreateBranch godoc
+// @Summary Create a branch
+// @Description Add a restaurant branch with its contact details and document template
+// @Tags branches
+// @Accept json
+// @Produce json
+// @Param branch body models.Branch true "Branch data"
+// @Success 200 {object} models.Branch
+// @Failure 400 {object} object "Invalid input"
+// @Failure 500 {object} object "Error creating branch"
+// @Router /branches [post]
+func CreateBranch() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var branch models.Branch
+		if err := c.BindJSON(&branch); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(branch); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		if _, err := time.LoadLocation(branch.Timezone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown timezone " + branch.Timezone})
+			return
+		}
+
+		branch.ID = primitive.NewObjectID()
+		branch.Branch_Id = branch.ID.Hex()
+		branch.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		branch.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if _, err := branchCollection.InsertOne(ctx, branch); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating branch"})
+			return
+		}
+		c.JSON(http.StatusOK, branch)
+	}
+}
+
+// UpdateBranch godoc
+// @Summary Update a branch
+// @Description Update branch details (partial updates supported)
+// @Tags branches
+// @Accept json
+// @Produce json
+// @Param branch_id path string true "Branch ID"
+// @Param branch body models.Branch true "Fields to update"
+// @Success 200 {object} object "Update result"
+// @Failure 400 {object} object "Invalid input"
+// @Failure 404 {object} object "Branch not found"
+// @Failure 500 {object} object "Error updating branch"
+// @Router /branches/{branch_id} [patch]
+func UpdateBranch() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var branch models.Branch
+		if err := c.BindJSON(&branch); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var updateObj primitive.D
+		if branch.Name != "" {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: branch.Name})
+		}
+		if branch.Address != "" {
+			updateObj = append(updateObj, bson.E{Key: "address", Value: branch.Address})
+		}
+		if branch.Phone != "" {
+			updateObj = append(updateObj, bson.E{Key: "phone", Value: branch.Phone})
+		}
+		if branch.Email != "" {
+			updateObj = append(updateObj, bson.E{Key: "email", Value: branch.Email})
+		}
+		if branch.Tax_Number != "" {
+			updateObj = append(updateObj, bson.E{Key: "tax_number", Value: branch.Tax_Number})
+		}
+		if branch.Currency != "" {
+			updateObj = append(updateObj, bson.E{Key: "currency", Value: branch.Currency})
+		}
+		if branch.Tax_Inclusive != nil {
+			updateObj = append(updateObj, bson.E{Key: "tax_inclusive", Value: *branch.Tax_Inclusive})
+		}
+		if branch.Timezone != "" {
+			if _, err := time.LoadLocation(branch.Timezone); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown timezone " + branch.Timezone})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "timezone", Value: branch.Timezone})
+		}
+		branch.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: branch.Updated_At})
+
+		updateBranchFields(ctx, c, updateObj)
+	}
+}
+
+// UpdateBranchTemplate godoc
+// @Summary Replace a branch document template
+// @Description Customise the invoice PDF and text receipt for a branch. receipt_layout is a text/template; empty fields use the defaults.
+// @Tags branches
+// @Accept json
+// @Produce json
+// @Param branch_id path string true "Branch ID"
+// @Param template body models.Document_Template true "Document template"
+// @Success 200 {object} object "Update result"
+// @Failure 400 {object} object "Invalid input or layout"
+// @Failure 404 {object} object "Branch not found"
+// @Failure 500 {object} object "Error updating branch"
+// @Router /branches/{branch_id}/template [put]
+func UpdateBranchTemplate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var tmpl models.Document_Template
+		if err := c.BindJSON(&tmpl); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(tmpl); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		// Render a sample so a broken layout is rejected now rather than at the till.
+		if _, err := helpers.RenderReceiptText(helpers.InvoiceDocument{Branch: models.Branch{Document_Template: tmpl}}); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateBranchFields(ctx, c, primitive.D{
+			{Key: "document_template", Value: tmpl},
+			{Key: "updated_at", Value: updatedAt},
+		})
+	}
+}
+
+func updateBranchFields(ctx context.Context, c *gin.Context, updateObj primitive.D) {
+	result, err := branchCollection.UpdateOne(ctx, bson.M{"branch_id": c.Param("branch_id")}, bson.D{
+		{Key: "$set", Value: updateObj},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating branch"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Branch not found"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// branchForTable returns the branch a table belongs to. Single-branch
+// installs often leave branch_id unset on tables, so the first branch is
+// used as a fallback.
+func branchForTable(ctx context.Context, table models.Table) models.Branch {
+	var branch models.Branch
+	if table.Branch_Id != "" {
+		if err := branchCollection.FindOne(ctx, bson.M{"branch_id": table.Branch_Id}).Decode(&branch); err == nil {
+			return branch
+		}
+	}
+	if err := branchCollection.FindOne(ctx, bson.M{}).Decode(&branch); err == nil {
+		return branch
+	}
+	return models.Branch{Name: "Restaurant"}
+}
+
+// branchForOrder is the branch an order was placed at, via its table.
+func branchForOrder(ctx context.Context, order models.Order) models.Branch {
+	var table models.Table
+	tableCollection.FindOne(ctx, bson.M{"table_id": order.Table_Id}).Decode(&table)
+	return branchForTable(ctx, table)
+}
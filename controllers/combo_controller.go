@@ -0,0 +1,318 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var comboCollection = database.OpenCollection(database.Client, "combos")
+
+// GetCombos godoc
+// @Summary List combos
+// @Description Retrieve the set meals, optionally only the active ones
+// @Tags combos
+// @Produce json
+// @Param active query bool false "Only active combos"
+// @Success 200 {array} models.Combo
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /combos [get]
+func GetCombos() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if c.Query("active") == "true" {
+			filter["active"] = true
+		}
+		cursor, err := comboCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		combos := []models.Combo{}
+		if err = cursor.All(ctx, &combos); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, combos)
+	}
+}
+
+// GetCombo godoc
+// @Summary Get a combo
+// @Tags combos
+// @Produce json
+// @Param combo_id path string true "Combo ID"
+// @Success 200 {object} models.Combo
+// @Failure 404 {object} object "Combo not found"
+// @Router /combos/{combo_id} [get]
+func GetCombo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var combo models.Combo
+		if err := comboCollection.FindOne(ctx, bson.M{"combo_id": c.Param("combo_id")}).Decode(&combo); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Combo not found"})
+			return
+		}
+		c.JSON(http.StatusOK, combo)
+	}
+}
+
+// CreateCombo godoc
+// @Summary Create a combo
+// @Description Add a set meal with a fixed price and slots, each offering a choice of foods with optional upcharges
+// @Tags combos
+// @Accept json
+// @Produce json
+// @Param combo body models.Combo true "Combo"
+// @Success 200 {object} models.Combo
+// @Failure 400 {object} object "Invalid input or unknown food"
+// @Failure 500 {object} object "Error creating combo"
+// @Router /combos [post]
+func CreateCombo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var combo models.Combo
+		if err := c.BindJSON(&combo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkCombo(ctx, &combo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		combo.ID = primitive.NewObjectID()
+		combo.Combo_Id = combo.ID.Hex()
+		combo.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		combo.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if _, err := comboCollection.InsertOne(ctx, combo); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating combo"})
+			return
+		}
+		c.JSON(http.StatusOK, combo)
+	}
+}
+
+// UpdateCombo godoc
+// @Summary Update a combo
+// @Description Replace a combo. Slots keep their slot_id when it is sent back. Combos already ordered keep their prices.
+// @Tags combos
+// @Accept json
+// @Produce json
+// @Param combo_id path string true "Combo ID"
+// @Param combo body models.Combo true "Combo"
+// @Success 200 {object} models.Combo
+// @Failure 400 {object} object "Invalid input or unknown food"
+// @Failure 404 {object} object "Combo not found"
+// @Failure 500 {object} object "Error updating combo"
+// @Router /combos/{combo_id} [put]
+func UpdateCombo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var combo models.Combo
+		if err := c.BindJSON(&combo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkCombo(ctx, &combo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		combo.Combo_Id = c.Param("combo_id")
+		combo.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := comboCollection.UpdateOne(ctx, bson.M{"combo_id": combo.Combo_Id}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "name", Value: combo.Name},
+				{Key: "description", Value: combo.Description},
+				{Key: "price", Value: combo.Price},
+				{Key: "slots", Value: combo.Slots},
+				{Key: "active", Value: combo.Active},
+				{Key: "display_order", Value: combo.Display_Order},
+				{Key: "updated_at", Value: combo.Updated_At},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating combo"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Combo not found"})
+			return
+		}
+		c.JSON(http.StatusOK, combo)
+	}
+}
+
+// DeleteCombo godoc
+// @Summary Deactivate a combo
+// @Description Combos are deactivated rather than deleted so orders that include them keep their name
+// @Tags combos
+// @Produce json
+// @Param combo_id path string true "Combo ID"
+// @Success 200 {object} object "message: Combo deactivated"
+// @Failure 404 {object} object "Combo not found"
+// @Failure 500 {object} object "Error deactivating combo"
+// @Router /combos/{combo_id} [delete]
+func DeleteCombo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := comboCollection.UpdateOne(ctx, bson.M{"combo_id": c.Param("combo_id")}, bson.D{
+			{Key: "$set", Value: bson.D{{Key: "active", Value: false}}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deactivating combo"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Combo not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Combo deactivated"})
+	}
+}
+
+// checkCombo validates a combo, checks its foods exist and gives new slots
+// their ids.
+func checkCombo(ctx context.Context, combo *models.Combo) error {
+	if err := validate.Struct(combo); err != nil {
+		return err
+	}
+	price := toFixed(*combo.Price, 2)
+	combo.Price = &price
+	for i := range combo.Slots {
+		slot := &combo.Slots[i]
+		if slot.Slot_Id == "" {
+			slot.Slot_Id = primitive.NewObjectID().Hex()
+		}
+		for j := range slot.Choices {
+			choice := &slot.Choices[j]
+			count, err := foodCollection.CountDocuments(ctx, bson.M{"food_id": choice.Food_Id})
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				return fmt.Errorf("food %s not found", choice.Food_Id)
+			}
+			choice.Upcharge = toFixed(choice.Upcharge, 2)
+		}
+	}
+	return nil
+}
+
+// prepareComboItems turns a requested combo into its parent item and one
+// child item per chosen food. Children carry the combo price split by list
+// price, plus their own upcharge and modifiers; the parent carries none so
+// order totals count the combo once.
+func prepareComboItems(ctx context.Context, order models.Order, requested models.Ordered_Item, pricer *foodPricer, serving map[string]bool) ([]models.Ordered_Item, error) {
+	var combo models.Combo
+	if err := comboCollection.FindOne(ctx, bson.M{"combo_id": requested.Combo_Id, "active": true}).Decode(&combo); err != nil {
+		return nil, fmt.Errorf("combo %s not found", requested.Combo_Id)
+	}
+	picks, err := helpers.ResolveComboSelections(combo, requested.Combo_Selections)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := models.Ordered_Item{
+		ID:         primitive.NewObjectID(),
+		Order_Id:   order.Order_Id,
+		Combo_Id:   combo.Combo_Id,
+		Quantity:   requested.Quantity,
+		Created_At: order.Created_At,
+		Updated_At: order.Updated_At,
+	}
+	parent.Order_Item_Id = parent.ID.Hex()
+
+	var children []models.Ordered_Item
+	var listPrices []float64
+	comboPrice := *combo.Price
+	for _, pick := range picks {
+		var food models.Food
+		if err := foodCollection.FindOne(ctx, bson.M{"food_id": pick.Selection.Food_Id}).Decode(&food); err != nil {
+			return nil, fmt.Errorf("food %s not found", pick.Selection.Food_Id)
+		}
+		if err := foodOrderable(food, pricer.at); err != nil {
+			return nil, err
+		}
+		if err := menuServingOnce(ctx, serving, *food.Menu_Id, pricer.at); err != nil {
+			return nil, err
+		}
+		child := models.Ordered_Item{
+			ID:             primitive.NewObjectID(),
+			Order_Id:       order.Order_Id,
+			Menu_Id:        *food.Menu_Id,
+			Food_Id:        pick.Selection.Food_Id,
+			Quantity:       requested.Quantity,
+			Modifiers:      pick.Selection.Modifiers,
+			Parent_Item_Id: parent.Order_Item_Id,
+			Slot_Id:        pick.Slot.Slot_Id,
+			Created_At:     order.Created_At,
+			Updated_At:     order.Updated_At,
+		}
+		child.Order_Item_Id = child.ID.Hex()
+		if err := resolveItemModifiers(ctx, &child, food); err != nil {
+			return nil, err
+		}
+		child.Nutrition = helpers.LineNutrition(food, child.Modifiers, child.Quantity)
+		extra := pick.Upcharge
+		for _, modifier := range child.Modifiers {
+			extra += modifier.Price_Delta
+		}
+		child.Price = extra
+		child.Base_Price = toFixed(*food.Food_Price+extra-pick.Upcharge, 2)
+		comboPrice += extra
+		children = append(children, child)
+		listPrices = append(listPrices, *food.Food_Price)
+	}
+
+	shares := helpers.AllocateComboPrice(*combo.Price, listPrices)
+	for i := range children {
+		children[i].Price = toFixed(children[i].Price+shares[i], 2)
+	}
+	parent.Combo_Price = toFixed(comboPrice, 2)
+	return append([]models.Ordered_Item{parent}, children...), nil
+}
+
+// menuServingOnce checks a menu is being served, remembering menus already
+// checked for this request.
+func menuServingOnce(ctx context.Context, serving map[string]bool, menuId string, t time.Time) error {
+	if serving[menuId] {
+		return nil
+	}
+	if err := menuServing(ctx, menuId, t); err != nil {
+		return err
+	}
+	serving[menuId] = true
+	return nil
+}
+
+// comboLineName names a combo for receipts and tickets, falling back to a
+// generic label if the combo has since been removed.
+func comboLineName(ctx context.Context, comboId string) string {
+	var combo models.Combo
+	if err := comboCollection.FindOne(ctx, bson.M{"combo_id": comboId}).Decode(&combo); err != nil {
+		return "Combo"
+	}
+	return combo.Name
+}
@@ -0,0 +1,510 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var foodCostCollection = database.OpenCollection(database.Client, "food_costs")
+
+// marginDrop is a dish in the margin report: where its margin stands now
+// and where it stood before falling below the target.
+type marginDrop struct {
+	Food_Id           string             `json:"food_id"`
+	Food_Name         string             `json:"food_name"`
+	Price             float64            `json:"price"`
+	Cost              float64            `json:"cost"`
+	Margin            float64            `json:"margin"`
+	Previous_Cost     float64            `json:"previous_cost"`
+	Previous_Margin   float64            `json:"previous_margin"`
+	Fell_At           time.Time          `json:"fell_at"`
+	Risen_Ingredients []models.Cost_Line `json:"risen_ingredients"`
+}
+
+// GetFoodCost godoc
+// @Summary Get what a dish costs to make
+// @Description Work out the theoretical cost of a food from its recipe and current ingredient costs, with its gross margin. Each modifier option with a recipe shows what it adds to the cost and the margin of the dish with it.
+// @Tags costing
+// @Produce json
+// @Param food_id path string true "Food ID"
+// @Success 200 {object} models.Food_Cost
+// @Failure 404 {object} object "Food or recipe not found"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /foods/{food_id}/cost [get]
+func GetFoodCost() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var food models.Food
+		if err := foodCollection.FindOne(ctx, bson.M{"food_id": c.Param("food_id")}).Decode(&food); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food not found"})
+			return
+		}
+		ingredients, err := ingredientsById(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		cost, found, err := foodCost(ctx, food, ingredients)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food has no recipe"})
+			return
+		}
+		c.JSON(http.StatusOK, cost)
+	}
+}
+
+// GetFoodCostHistory godoc
+// @Summary Get the cost history of a dish
+// @Description Retrieve the stored costs of a food, newest first. One is stored whenever an ingredient cost, the recipe or the price changes the dish's cost or margin.
+// @Tags costing
+// @Produce json
+// @Param food_id path string true "Food ID"
+// @Success 200 {array} models.Food_Cost
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /foods/{food_id}/cost_history [get]
+func GetFoodCostHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		cursor, err := foodCostCollection.Find(ctx, bson.M{"food_id": c.Param("food_id")}, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		history := []models.Food_Cost{}
+		if err = cursor.All(ctx, &history); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, history)
+	}
+}
+
+// GetFoodCosts godoc
+// @Summary List dish costs and margins
+// @Description Work out the current cost and gross margin of every food with a recipe, lowest margin first
+// @Tags costing
+// @Produce json
+// @Param below query number false "Only dishes with a margin below this percentage"
+// @Success 200 {array} models.Food_Cost
+// @Failure 400 {object} object "Invalid margin"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /costing/foods [get]
+func GetFoodCosts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		below := 0.0
+		if value := c.Query("below"); value != "" {
+			var err error
+			if below, err = strconv.ParseFloat(value, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "below must be a percentage"})
+				return
+			}
+		}
+		cursor, err := foodCollection.Find(ctx, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var foods []models.Food
+		if err = cursor.All(ctx, &foods); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		ingredients, err := ingredientsById(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		costs := []models.Food_Cost{}
+		for _, food := range foods {
+			cost, found, err := foodCost(ctx, food, ingredients)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if found && (below == 0 || cost.Margin < below) {
+				costs = append(costs, cost)
+			}
+		}
+		sort.SliceStable(costs, func(i, j int) bool { return costs[i].Margin < costs[j].Margin })
+		c.JSON(http.StatusOK, costs)
+	}
+}
+
+// RecalculateFoodCosts godoc
+// @Summary Recalculate dish costs
+// @Description Work out every dish's cost now and store it where it changed, e.g. after ingredient costs were imported directly. Requires the manager role.
+// @Tags costing
+// @Produce json
+// @Success 200 {object} object "stored: number of costs stored"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /costing/recalculate [post]
+func RecalculateFoodCosts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		stored, err := recordFoodCosts(ctx, helpers.CostRecalculated, nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"stored": stored})
+	}
+}
+
+// GetMarginReport godoc
+// @Summary Report dishes whose margin fell below target
+// @Description List dishes whose gross margin is below the target now but was at or above it before ingredient costs rose, with the ingredients that went up. The target defaults to MARGIN_TARGET_PERCENT.
+// @Tags costing
+// @Produce json
+// @Param target query number false "Target gross margin percentage"
+// @Param from query string false "Only drops on or after this day, YYYY-MM-DD"
+// @Success 200 {object} object "target and dishes"
+// @Failure 400 {object} object "Invalid target or date"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /reports/margins [get]
+func GetMarginReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		target := marginTarget()
+		if value := c.Query("target"); value != "" {
+			var err error
+			if target, err = strconv.ParseFloat(value, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "target must be a percentage"})
+				return
+			}
+		}
+		var from time.Time
+		if value := c.Query("from"); value != "" {
+			var err error
+			if from, err = time.Parse("2006-01-02", value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+				return
+			}
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "food_id", Value: 1}, {Key: "created_at", Value: 1}})
+		cursor, err := foodCostCollection.Find(ctx, bson.M{}, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var snapshots []models.Food_Cost
+		if err = cursor.All(ctx, &snapshots); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		byFood := map[string][]models.Food_Cost{}
+		var foodIds []string
+		for _, snapshot := range snapshots {
+			if _, ok := byFood[snapshot.Food_Id]; !ok {
+				foodIds = append(foodIds, snapshot.Food_Id)
+			}
+			byFood[snapshot.Food_Id] = append(byFood[snapshot.Food_Id], snapshot)
+		}
+
+		drops := []marginDrop{}
+		for _, foodId := range foodIds {
+			if drop, ok := marginFall(byFood[foodId], target); ok && !drop.Fell_At.Before(from) {
+				drops = append(drops, drop)
+			}
+		}
+		sort.SliceStable(drops, func(i, j int) bool { return drops[i].Margin < drops[j].Margin })
+		c.JSON(http.StatusOK, gin.H{"target": target, "dishes": drops})
+	}
+}
+
+// marginFall looks through a dish's cost history, oldest first, for the
+// last time its margin fell below target. It only counts if the margin is
+// still below target and an ingredient cost change was part of the fall.
+func marginFall(history []models.Food_Cost, target float64) (marginDrop, bool) {
+	latest := history[len(history)-1]
+	if latest.Margin >= target {
+		return marginDrop{}, false
+	}
+	before := -1
+	for i := len(history) - 2; i >= 0; i-- {
+		if history[i].Margin >= target {
+			before = i
+			break
+		}
+	}
+	if before < 0 {
+		return marginDrop{}, false
+	}
+	fromIngredients := false
+	for _, snapshot := range history[before+1:] {
+		if snapshot.Reason == helpers.CostIngredientPrice {
+			fromIngredients = true
+		}
+	}
+	if !fromIngredients {
+		return marginDrop{}, false
+	}
+	return marginDrop{
+		Food_Id:           latest.Food_Id,
+		Food_Name:         latest.Food_Name,
+		Price:             latest.Price,
+		Cost:              latest.Cost,
+		Margin:            latest.Margin,
+		Previous_Cost:     history[before].Cost,
+		Previous_Margin:   history[before].Margin,
+		Fell_At:           history[before+1].Created_At,
+		Risen_Ingredients: helpers.RisenCosts(history[before].Lines, latest.Lines),
+	}, true
+}
+
+// marginTarget is the gross margin dishes should make, MARGIN_TARGET_PERCENT
+// or 65%.
+func marginTarget() float64 {
+	value, err := strconv.ParseFloat(os.Getenv("MARGIN_TARGET_PERCENT"), 64)
+	if err != nil || value <= 0 || value >= 100 {
+		return 65
+	}
+	return value
+}
+
+// foodCost works out a food's cost from its recipe and the recipes of the
+// modifier options it offers. It reports false when the food has no recipe.
+func foodCost(ctx context.Context, food models.Food, ingredients map[string]models.Ingredient) (models.Food_Cost, bool, error) {
+	var cost models.Food_Cost
+	var recipe models.Recipe
+	if food.Food_Id == nil {
+		return cost, false, nil
+	}
+	if err := recipeCollection.FindOne(ctx, bson.M{"food_id": *food.Food_Id}).Decode(&recipe); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return cost, false, nil
+		}
+		return cost, false, err
+	}
+	price := 0.0
+	if food.Food_Price != nil {
+		price = *food.Food_Price
+	}
+	lines, total, missing := helpers.RecipeCost(recipe, ingredients)
+	cost = models.Food_Cost{
+		Food_Id:   *food.Food_Id,
+		Food_Name: food.Food_Name,
+		Price:     price,
+		Cost:      total,
+		Margin:    helpers.GrossMargin(price, total),
+		Lines:     lines,
+	}
+
+	groups, err := foodModifierGroups(ctx, food)
+	if err != nil {
+		return cost, true, err
+	}
+	optionRecipes := map[string]models.Recipe{}
+	if len(food.Modifier_Groups) > 0 {
+		cursor, err := recipeCollection.Find(ctx, bson.M{"modifier_group_id": bson.M{"$in": food.Modifier_Groups}})
+		if err != nil {
+			return cost, true, err
+		}
+		var recipes []models.Recipe
+		if err = cursor.All(ctx, &recipes); err != nil {
+			return cost, true, err
+		}
+		for _, recipe := range recipes {
+			optionRecipes[helpers.OptionRecipeKey(recipe.Modifier_Group_Id, recipe.Option_Id)] = recipe
+		}
+	}
+	for _, group := range groups {
+		for _, option := range group.Options {
+			recipe, ok := optionRecipes[helpers.OptionRecipeKey(group.Modifier_Group_Id, option.Option_Id)]
+			if !ok {
+				continue
+			}
+			_, optionCost, optionMissing := helpers.RecipeCost(recipe, ingredients)
+			if group.Type == "REMOVAL" {
+				optionCost = -optionCost
+			}
+			cost.Options = append(cost.Options, models.Option_Cost{
+				Modifier_Group_Id: group.Modifier_Group_Id,
+				Option_Id:         option.Option_Id,
+				Name:              option.Name,
+				Price_Delta:       option.Price_Delta,
+				Cost:              optionCost,
+				Margin:            helpers.GrossMargin(price+option.Price_Delta, total+optionCost),
+			})
+			missing = append(missing, optionMissing...)
+		}
+	}
+	seen := map[string]bool{}
+	for _, ingredientId := range missing {
+		if !seen[ingredientId] {
+			seen[ingredientId] = true
+			cost.Missing_Costs = append(cost.Missing_Costs, ingredientId)
+		}
+	}
+	return cost, true, nil
+}
+
+// ingredientsById loads every ingredient, deactivated ones included since
+// existing recipes may still use them.
+func ingredientsById(ctx context.Context) (map[string]models.Ingredient, error) {
+	cursor, err := ingredientCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var ingredients []models.Ingredient
+	if err = cursor.All(ctx, &ingredients); err != nil {
+		return nil, err
+	}
+	byId := map[string]models.Ingredient{}
+	for _, ingredient := range ingredients {
+		byId[ingredient.Ingredient_Id] = ingredient
+	}
+	return byId, nil
+}
+
+// recordFoodCosts works out the cost of the foods given, or of every food
+// when foodIds is nil, and stores it for those whose cost or price changed
+// since it was last stored. It returns how many were stored.
+func recordFoodCosts(ctx context.Context, reason string, foodIds []string) (int, error) {
+	filter := bson.M{}
+	if foodIds != nil {
+		if len(foodIds) == 0 {
+			return 0, nil
+		}
+		filter["food_id"] = bson.M{"$in": foodIds}
+	}
+	cursor, err := foodCollection.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	var foods []models.Food
+	if err = cursor.All(ctx, &foods); err != nil {
+		return 0, err
+	}
+	ingredients, err := ingredientsById(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	stored := 0
+	for _, food := range foods {
+		cost, found, err := foodCost(ctx, food, ingredients)
+		if err != nil {
+			return stored, err
+		}
+		if !found {
+			continue
+		}
+		var latest models.Food_Cost
+		opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		if err := foodCostCollection.FindOne(ctx, bson.M{"food_id": cost.Food_Id}, opts).Decode(&latest); err == nil && helpers.SameFoodCost(latest, cost) {
+			continue
+		}
+		cost.ID = primitive.NewObjectID()
+		cost.Food_Cost_Id = cost.ID.Hex()
+		cost.Reason = reason
+		cost.Created_At = now
+		if _, err := foodCostCollection.InsertOne(ctx, cost); err != nil {
+			return stored, err
+		}
+		stored++
+	}
+	return stored, nil
+}
+
+// refreshFoodCosts records the costs of foods after something they depend
+// on changed. A failure is logged rather than returned so it never undoes
+// the change itself.
+func refreshFoodCosts(ctx context.Context, reason string, foodIds []string) {
+	if _, err := recordFoodCosts(ctx, reason, foodIds); err != nil {
+		log.Println("Error recording food costs:", err)
+	}
+}
+
+// refreshIngredientFoodCosts records the costs of the foods using an
+// ingredient after its unit cost changed.
+func refreshIngredientFoodCosts(ctx context.Context, ingredientId string) {
+	foodIds, err := foodsUsingIngredient(ctx, ingredientId)
+	if err != nil {
+		log.Println("Error recording food costs:", err)
+		return
+	}
+	refreshFoodCosts(ctx, helpers.CostIngredientPrice, foodIds)
+}
+
+// foodsUsingIngredient lists the foods whose recipe, or the recipe of an
+// option they offer, uses an ingredient.
+func foodsUsingIngredient(ctx context.Context, ingredientId string) ([]string, error) {
+	cursor, err := recipeCollection.Find(ctx, bson.M{"lines.ingredient_id": ingredientId})
+	if err != nil {
+		return nil, err
+	}
+	var recipes []models.Recipe
+	if err = cursor.All(ctx, &recipes); err != nil {
+		return nil, err
+	}
+	return foodsUsingRecipes(ctx, recipes)
+}
+
+// foodsUsingRecipes lists the foods the recipes are for, directly or
+// through the modifier groups they offer.
+func foodsUsingRecipes(ctx context.Context, recipes []models.Recipe) ([]string, error) {
+	foodIds := []string{}
+	var groupIds []string
+	for _, recipe := range recipes {
+		if recipe.Food_Id != "" {
+			foodIds = append(foodIds, recipe.Food_Id)
+		} else {
+			groupIds = append(groupIds, recipe.Modifier_Group_Id)
+		}
+	}
+	if len(groupIds) == 0 {
+		return foodIds, nil
+	}
+	cursor, err := foodCollection.Find(ctx, bson.M{"modifier_groups": bson.M{"$in": groupIds}})
+	if err != nil {
+		return nil, err
+	}
+	var foods []models.Food
+	if err = cursor.All(ctx, &foods); err != nil {
+		return nil, err
+	}
+	for _, food := range foods {
+		if food.Food_Id != nil {
+			foodIds = append(foodIds, *food.Food_Id)
+		}
+	}
+	return foodIds, nil
+}
@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var customerCollection = database.OpenCollection(database.Client, "customers")
+
+// customerAccount is a customer with their current points balance.
+type customerAccount struct {
+	models.Customer
+	Points_Balance int `json:"points_balance"`
+}
+
+// GetCustomers godoc
+// @Summary List customers
+// @Description Retrieve loyalty customers, optionally looked up by phone
+// @Tags customers
+// @Produce json
+// @Param phone query string false "Phone number"
+// @Success 200 {array} models.Customer
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /customers [get]
+func GetCustomers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if phone := c.Query("phone"); phone != "" {
+			filter["phone"] = phone
+		}
+		cursor, err := customerCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var customers []models.Customer
+		if err = cursor.All(ctx, &customers); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, customers)
+	}
+}
+
+// GetCustomer godoc
+// @Summary Get a customer
+// @Description Retrieve a customer with their points balance
+// @Tags customers
+// @Produce json
+// @Param customer_id path string true "Customer ID"
+// @Success 200 {object} customerAccount
+// @Failure 404 {object} object "Customer not found"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /customers/{customer_id} [get]
+func GetCustomer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var customer models.Customer
+		if err := customerCollection.FindOne(ctx, bson.M{"customer_id": c.Param("customer_id")}).Decode(&customer); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+			return
+		}
+		if err := expireCustomerPoints(ctx, customer.Customer_Id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		balance, err := customerBalance(ctx, customer.Customer_Id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, customerAccount{Customer: customer, Points_Balance: balance})
+	}
+}
+
+// CreateCustomer godoc
+// @Summary Enrol a customer
+// @Description Create a loyalty customer account. Phone numbers are unique.
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Param customer body models.Customer true "Customer data"
+// @Success 200 {object} models.Customer
+// @Failure 400 {object} object "Invalid input"
+// @Failure 409 {object} object "Phone already registered"
+// @Failure 500 {object} object "Error creating customer"
+// @Router /customers [post]
+func CreateCustomer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var customer models.Customer
+		if err := c.BindJSON(&customer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(customer); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		count, err := customerCollection.CountDocuments(ctx, bson.M{"phone": customer.Phone})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Phone already registered"})
+			return
+		}
+
+		customer.ID = primitive.NewObjectID()
+		customer.Customer_Id = customer.ID.Hex()
+		customer.Lifetime_Points = 0
+		customer.Tier = tierForPoints(ctx, 0)
+		customer.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		customer.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if _, err := customerCollection.InsertOne(ctx, customer); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating customer"})
+			return
+		}
+		c.JSON(http.StatusOK, customer)
+	}
+}
+
+// UpdateCustomer godoc
+// @Summary Update a customer
+// @Description Update a customer's contact details (partial updates supported). Points and tier only change through the ledger.
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Param customer_id path string true "Customer ID"
+// @Param customer body models.Customer true "Fields to update"
+// @Success 200 {object} object "Update result"
+// @Failure 400 {object} object "Invalid input"
+// @Failure 404 {object} object "Customer not found"
+// @Failure 409 {object} object "Phone already registered"
+// @Failure 500 {object} object "Error updating customer"
+// @Router /customers/{customer_id} [patch]
+func UpdateCustomer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var customer models.Customer
+		customerId := c.Param("customer_id")
+		if err := c.BindJSON(&customer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var updateObj primitive.D
+		if customer.First_Name != "" {
+			updateObj = append(updateObj, bson.E{Key: "first_name", Value: customer.First_Name})
+		}
+		if customer.Last_Name != "" {
+			updateObj = append(updateObj, bson.E{Key: "last_name", Value: customer.Last_Name})
+		}
+		if customer.Phone != "" {
+			count, err := customerCollection.CountDocuments(ctx, bson.M{"phone": customer.Phone, "customer_id": bson.M{"$ne": customerId}})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if count > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "Phone already registered"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "phone", Value: customer.Phone})
+		}
+		if customer.Email != "" {
+			if err := validate.Var(customer.Email, "email"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "email", Value: customer.Email})
+		}
+		customer.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: customer.Updated_At})
+
+		result, err := customerCollection.UpdateOne(ctx, bson.M{"customer_id": customerId}, bson.D{
+			{Key: "$set", Value: updateObj},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating customer"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// customerExists reports whether a customer account with the ID exists.
+func customerExists(ctx context.Context, customerId string) (bool, error) {
+	count, err := customerCollection.CountDocuments(ctx, bson.M{"customer_id": customerId})
+	return count > 0, err
+}
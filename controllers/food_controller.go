@@ -5,9 +5,11 @@ import (
 	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
 	"github.com/abik1221/Tewanay-Engineering_Intership/models"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -21,12 +23,15 @@ var validate = validator.New()
 var foodCollection = database.OpenCollection(database.Client, "food")
 
 // @Summary      Get a single food item
-// @Description  Fetch food details by its unique ID
+// @Description  Fetch food details by its unique ID, with its current price and modifier groups
 // @Tags         foods
 // @Accept       json
 // @Produce      json
-// @Param        food_id  path  string  true  "Food ID"
-// @Success      200  {object}  models.Food
+// @Param        food_id    path   string  true   "Food ID"
+// @Param        branch_id  query  string  false  "Branch whose price rules apply"
+// @Param        at         query  string  false  "Price at this RFC3339 time instead of now"
+// @Success      200  {object}  pricedFood
+// @Failure      400  {object}  object  "Invalid at time"
 // @Failure      404  {object}  object  "Food not found"
 // @Failure      500  {object}  object  "Internal server error"
 // @Router       /foods/{food_id} [get]
@@ -41,10 +46,20 @@ func GetFoods() gin.HandlerFunc {
 
 		err := foodCollection.FindOne(ctx, bson.M{"food_id": foodID}).Decode(&food)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Food not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food not found"})
+			return
+		}
+		pricer, err := foodPricerFor(ctx, c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		priced := pricer.price(food)
+		if priced.Modifiers, err = foodModifierGroups(ctx, food); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, food)
+		c.JSON(http.StatusOK, priced)
 	}
 }
 
@@ -56,7 +71,12 @@ func GetFoods() gin.HandlerFunc {
 // @Param        page          query  int     false  "Page number (default: 1)"
 // @Param        recordPerPage query  int     false  "Items per page (default: 10)"
 // @Param        startIndex    query  int     false  "Custom start index (overrides page)"
-// @Success      200  {object}  []bson.M
+// @Param        branch_id     query  string  false  "Branch whose price rules apply"
+// @Param        at            query  string  false  "Price at this RFC3339 time instead of now"
+// @Param        include       query  string  false  "Comma-separated allergens or dietary tags foods must have"
+// @Param        exclude       query  string  false  "Comma-separated allergens or dietary tags foods must not have"
+// @Success      200  {object}  []bson.M  "totalCount and food_items with their effective_price"
+// @Failure      400  {object}  object  "Unknown allergen or dietary tag"
 // @Failure      500  {object}  object  "Internal server error"
 // @Router       /foods [get]
 func GetFood() gin.HandlerFunc {
@@ -75,8 +95,15 @@ func GetFood() gin.HandlerFunc {
 		}
 
 		startIndex := (page - 1) * recordPerPage
-		startIndex, err = strconv.Atoi(c.Query("startIndex"))
-		matchStage := bson.D{{Key: "$match", Value: bson.D{}}}
+		if index, err := strconv.Atoi(c.Query("startIndex")); err == nil && index >= 0 {
+			startIndex = index
+		}
+		tagFilter, err := helpers.FoodTagFilter(queryList(c, "include"), queryList(c, "exclude"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		matchStage := bson.D{{Key: "$match", Value: tagFilter}}
 		groupStage := bson.D{
 			{Key: "$group", Value: bson.D{
 				{Key: "_id", Value: "null"},
@@ -106,11 +133,28 @@ func GetFood() gin.HandlerFunc {
 			return
 		}
 
-		var allFoods []bson.M
-		if err = result.All(ctx, &allFoods); err != nil {
+		var pages []struct {
+			TotalCount int           `bson:"totalCount"`
+			Food_Items []models.Food `bson:"food_items"`
+		}
+		if err = result.All(ctx, &pages); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+
+		pricer, err := foodPricerFor(ctx, c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		allFoods := []gin.H{}
+		for _, page := range pages {
+			items := []pricedFood{}
+			for _, food := range page.Food_Items {
+				items = append(items, pricer.price(food))
+			}
+			allFoods = append(allFoods, gin.H{"totalCount": page.TotalCount, "food_items": items})
+		}
 		c.JSON(http.StatusOK, allFoods)
 	}
 }
@@ -120,9 +164,9 @@ func GetFood() gin.HandlerFunc {
 // @Tags         foods
 // @Accept       json
 // @Produce      json
-// @Param        request  body  models.Menu  true  "Food data (Note: Uses Menu model for binding)"
+// @Param        request  body  models.Food  true  "Food data"
 // @Success      200  {object}  object  "MongoDB insert result"
-// @Failure      400  {object}  object  "Invalid input or validation error"
+// @Failure      400  {object}  object  "Invalid input, validation error or unknown menu or modifier group"
 // @Failure      500  {object}  object  "Internal server error"
 // @Router       /foods [post]
 func CreateFood() gin.HandlerFunc {
@@ -133,49 +177,67 @@ func CreateFood() gin.HandlerFunc {
 		var menu models.Menu
 		var food models.Food
 
-		if err := c.BindJSON(&menu); err != nil {
+		if err := c.BindJSON(&food); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": "Invalid input data",
 			})
 			return
 		}
 
+		food.ID = primitive.NewObjectID()
+		foodIdHex := food.ID.Hex()
+		food.Food_Id = &foodIdHex
 		if err := validate.Struct(food); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if err := helpers.CheckFoodNutrition(food.Nutrition); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.Menu_Id}).Decode(&menu); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Menu not found"})
+			return
+		}
+		if err := modifierGroupsExist(ctx, food.Modifier_Groups); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		food.Created_AT, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		food.Updated_AT, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		food.ID = primitive.NewObjectID()
-		foodIdHex := food.ID.Hex()
-		food.Food_Id = &foodIdHex
 		var num = toFixed(*food.Food_Price, 2)
 		food.Food_Price = &num
-
-		menu.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		menu.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		food.Version = 1
+		if err := baselineMenu(ctx, menu.Menu_Id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error recording version"})
+			return
+		}
 
 		result, err := foodCollection.InsertOne(ctx, food)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating a food"})
 			return
 		}
+		invalidateSearchIndex()
+		if err := versionFoodChange(ctx, food, helpers.VersionCreated, c.GetString("user_id"), ""); err != nil {
+			logVersionError("food", *food.Food_Id, err)
+		}
 
 		c.JSON(http.StatusOK, result)
 	}
 }
 
 // @Summary      Update a food item
-// @Description  Modify food details by ID (partial updates supported)
+// @Description  Modify food details by ID (partial updates supported). Every change is recorded as a new version of the food and its menu.
 // @Tags         foods
 // @Accept       json
 // @Produce      json
 // @Param        food_id  path  string       true  "Food ID to update"
 // @Param        request  body  models.Food  true  "Fields to update (all optional)"
-// @Success      200  {object}  object  "MongoDB update result"
+// @Success      200  {object}  models.Food
 // @Failure      400  {object}  object  "Invalid input"
-// @Failure      404  {object}  object  "Menu ID not found"
+// @Failure      404  {object}  object  "Food or menu not found"
 // @Failure      500  {object}  object  "Internal server error"
 // @Router       /foods/{food_id} [patch]
 func UpdateFood() gin.HandlerFunc {
@@ -225,32 +287,107 @@ func UpdateFood() gin.HandlerFunc {
 			UpdateObj = append(UpdateObj, bson.E{Key: "food_description", Value: food.Food_Description})
 		}
 
+		if food.Station != "" {
+			UpdateObj = append(UpdateObj, bson.E{Key: "station", Value: food.Station})
+		}
+		if food.Display_Order != nil {
+			UpdateObj = append(UpdateObj, bson.E{Key: "display_order", Value: food.Display_Order})
+		}
+		if food.Modifier_Groups != nil {
+			if err := modifierGroupsExist(ctx, food.Modifier_Groups); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			UpdateObj = append(UpdateObj, bson.E{Key: "modifier_groups", Value: food.Modifier_Groups})
+		}
+		if food.Allergens != nil || food.Dietary_Tags != nil {
+			if err := validate.StructPartial(food, "Allergens", "Dietary_Tags"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+		}
+		if food.Nutrition != nil {
+			if err := helpers.CheckFoodNutrition(food.Nutrition); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			UpdateObj = append(UpdateObj, bson.E{Key: "nutrition", Value: food.Nutrition})
+		}
+		if food.Portion != nil {
+			if err := validate.Struct(food.Portion); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			UpdateObj = append(UpdateObj, bson.E{Key: "portion", Value: food.Portion})
+		}
+		if food.Allergens != nil {
+			UpdateObj = append(UpdateObj, bson.E{Key: "allergens", Value: food.Allergens})
+		}
+		if food.Dietary_Tags != nil {
+			UpdateObj = append(UpdateObj, bson.E{Key: "dietary_tags", Value: food.Dietary_Tags})
+		}
+
 		food.Updated_AT, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 
 		UpdateObj = append(UpdateObj, bson.E{Key: "updated_at", Value: food.Updated_AT})
 
-		upsert := true
+		previous, _, err := baselineFood(ctx, food_Id)
+		if err == nil && food.Menu_Id != nil {
+			err = baselineMenu(ctx, *food.Menu_Id)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error recording version"})
+			return
+		}
+		previousMenuId := ""
+		if previous.Menu_Id != nil {
+			previousMenuId = *previous.Menu_Id
+		}
+
 		filter := bson.M{"food_id": food_Id}
 
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
-		}
+		opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
 
-		result, err := foodCollection.UpdateOne(
+		var updated models.Food
+		err = foodCollection.FindOneAndUpdate(
 			ctx,
 			filter,
-			bson.D{{Key: "$set", Value: UpdateObj}},
-			&opt,
-		)
+			bson.D{
+				{Key: "$set", Value: UpdateObj},
+				{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+			},
+			opt,
+		).Decode(&updated)
 
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food not found"})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
+		invalidateSearchIndex()
+		if food.Food_Price != nil {
+			refreshFoodCosts(ctx, helpers.CostFoodPrice, []string{food_Id})
+		} else if food.Modifier_Groups != nil {
+			refreshFoodCosts(ctx, helpers.CostRecipe, []string{food_Id})
+		}
+		if err := versionFoodChange(ctx, updated, helpers.VersionUpdated, c.GetString("user_id"), previousMenuId); err != nil {
+			logVersionError("food", food_Id, err)
+		}
 
-		c.JSON(http.StatusOK, result)
+		c.JSON(http.StatusOK, updated)
 
 	}
 }
@@ -274,20 +411,34 @@ func DeleteFood() gin.HandlerFunc {
 
 		filter := bson.M{"food_id": food_Id}
 
-		result, err := foodCollection.DeleteOne(ctx, filter)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Error deleting food",
-			})
+		if _, _, err := baselineFood(ctx, food_Id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error recording version"})
 			return
 		}
 
-		if result.DeletedCount == 0 {
+		var food models.Food
+		err := foodCollection.FindOneAndDelete(ctx, filter).Decode(&food)
+		if err == mongo.ErrNoDocuments {
 			c.JSON(http.StatusNotFound, gin.H{
 				"message": "Food not found",
 			})
 			return
 		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Error deleting food",
+			})
+			return
+		}
+
+		food.Version++
+		invalidateSearchIndex()
+		if food.Images != nil {
+			removeStoredImages(ctx, food.Images.Keys)
+		}
+		if err := versionFoodChange(ctx, food, helpers.VersionDeleted, c.GetString("user_id"), ""); err != nil {
+			logVersionError("food", food_Id, err)
+		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Food deleted successfully",
@@ -295,11 +446,28 @@ func DeleteFood() gin.HandlerFunc {
 	}
 }
 
+// queryList reads a query parameter given as a comma-separated list, a
+// repeated parameter, or both.
+func queryList(c *gin.Context, name string) []string {
+	var values []string
+	for _, raw := range c.QueryArray(name) {
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
 func round(num float64) int {
 	return int(num + math.Copysign(0.5, num))
 }
 
+// toFixed rounds num to precision decimals, halves away from zero. It
+// rounds rather than truncates because amounts such as 1.15 are stored as
+// 1.1499999..., which truncation turns into 1.14.
 func toFixed(num float64, precision int) float64 {
 	output := math.Pow(10, float64(precision))
-	return float64(int(num*output)) / output
+	return float64(round(num*output)) / output
 }
@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var imageStore = helpers.StoreFromEnv()
+
+const (
+	defaultImageMaxBytes = 5 << 20
+	maxImagePixels       = 40000000
+	mediumImageSide      = 480
+	smallImageSide       = 160
+)
+
+// UploadFoodImage godoc
+// @Summary Upload a food image
+// @Description Upload a JPEG, PNG or GIF as the multipart field "image". The original and medium and small thumbnails are stored under content-addressed keys, so their URLs can be cached forever; a new upload replaces the previous files.
+// @Tags foods
+// @Accept multipart/form-data
+// @Produce json
+// @Param food_id path string true "Food ID"
+// @Param image formData file true "Image file"
+// @Success 200 {object} models.Food_Images
+// @Failure 400 {object} object "Missing or unreadable image"
+// @Failure 404 {object} object "Food not found"
+// @Failure 413 {object} object "Image too large"
+// @Failure 415 {object} object "Unsupported image type"
+// @Failure 500 {object} object "Error storing image"
+// @Router /foods/{food_id}/image [post]
+func UploadFoodImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		foodId := c.Param("food_id")
+		var food models.Food
+		if err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food not found"})
+			return
+		}
+
+		maxBytes := imageMaxBytes()
+		// Leave room for the multipart headers around the file itself.
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+64<<10)
+		file, header, err := c.Request.FormFile("image")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is larger than " + strconv.FormatInt(maxBytes, 10) + " bytes"})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "image file is required"})
+			return
+		}
+		defer file.Close()
+		if header.Size > maxBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is larger than " + strconv.FormatInt(maxBytes, 10) + " bytes"})
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Error reading image"})
+			return
+		}
+		if int64(len(data)) > maxBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is larger than " + strconv.FormatInt(maxBytes, 10) + " bytes"})
+			return
+		}
+
+		// The type is sniffed from the bytes; the client's Content-Type is
+		// not trusted.
+		contentType := http.DetectContentType(data)
+		ext, ok := helpers.ImageExtensions[contentType]
+		if !ok {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Image must be JPEG, PNG or GIF, got " + contentType})
+			return
+		}
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Image could not be read"})
+			return
+		}
+		if config.Width*config.Height > maxImagePixels {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Image dimensions are too large"})
+			return
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Image could not be read"})
+			return
+		}
+
+		sum := sha256.Sum256(data)
+		prefix := "foods/" + foodId + "/" + hex.EncodeToString(sum[:8]) + "/"
+		images := models.Food_Images{
+			Content_Type: contentType,
+			Width:        config.Width,
+			Height:       config.Height,
+		}
+		original := prefix + "original." + ext
+		if err := imageStore.Put(ctx, original, data, contentType); err != nil {
+			log.Printf("storing image %s: %v", original, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error storing image"})
+			return
+		}
+		images.Original = imageURL(original)
+		images.Keys = append(images.Keys, original)
+
+		for _, size := range []struct {
+			name string
+			side int
+			url  *string
+		}{
+			{"medium", mediumImageSide, &images.Medium},
+			{"small", smallImageSide, &images.Small},
+		} {
+			body, thumbType, err := helpers.EncodeImage(helpers.Thumbnail(img, size.side), contentType)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating thumbnail"})
+				return
+			}
+			key := prefix + size.name + "." + helpers.ImageExtensions[thumbType]
+			if err := imageStore.Put(ctx, key, body, thumbType); err != nil {
+				log.Printf("storing image %s: %v", key, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error storing image"})
+				return
+			}
+			*size.url = imageURL(key)
+			images.Keys = append(images.Keys, key)
+		}
+		images.Uploaded_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		_, err = foodCollection.UpdateOne(ctx, bson.M{"food_id": foodId}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "images", Value: images},
+				{Key: "food_image", Value: images.Original},
+				{Key: "updated_at", Value: images.Uploaded_At},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating food"})
+			return
+		}
+		invalidateSearchIndex()
+
+		if food.Images != nil {
+			removeStoredImages(ctx, food.Images.Keys, images.Keys...)
+		}
+		c.JSON(http.StatusOK, images)
+	}
+}
+
+// GetImage godoc
+// @Summary Get a stored image
+// @Description Serve an uploaded image or thumbnail. Keys change whenever the image does, so responses are marked immutable.
+// @Tags foods
+// @Produce image/jpeg,image/png,image/gif
+// @Param key path string true "Image key"
+// @Success 200 {file} binary
+// @Success 304 {object} object "Not modified"
+// @Failure 404 {object} object "Image not found"
+// @Router /images/{key} [get]
+func GetImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		key := strings.TrimPrefix(c.Param("key"), "/")
+		body, contentType, err := imageStore.Get(ctx, key)
+		if errors.Is(err, helpers.ErrObjectNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading image"})
+			return
+		}
+
+		etag := helpers.ETag(body)
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		c.Header("ETag", etag)
+		if helpers.ETagMatches(c.GetHeader("If-None-Match"), etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		c.Data(http.StatusOK, contentType, body)
+	}
+}
+
+// imageMaxBytes reads the upload limit from IMAGE_MAX_BYTES, defaulting to
+// 5 MB.
+func imageMaxBytes() int64 {
+	if limit, err := strconv.ParseInt(os.Getenv("IMAGE_MAX_BYTES"), 10, 64); err == nil && limit > 0 {
+		return limit
+	}
+	return defaultImageMaxBytes
+}
+
+// imageURL is where a stored image is served: under IMAGE_BASE_URL when
+// images sit behind a CDN or public bucket, otherwise from /images.
+func imageURL(key string) string {
+	if base := os.Getenv("IMAGE_BASE_URL"); base != "" {
+		return strings.TrimSuffix(base, "/") + "/" + key
+	}
+	return "/images/" + key
+}
+
+// removeStoredImages deletes stored images other than keep. Failures only
+// leave orphaned files, so they are logged rather than returned.
+func removeStoredImages(ctx context.Context, keys []string, keep ...string) {
+	for _, key := range keys {
+		kept := false
+		for _, k := range keep {
+			if k == key {
+				kept = true
+			}
+		}
+		if kept {
+			continue
+		}
+		if err := imageStore.Delete(ctx, key); err != nil {
+			log.Printf("removing image %s: %v", key, err)
+		}
+	}
+}
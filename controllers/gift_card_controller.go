@@ -0,0 +1,603 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var giftCardCollection = database.OpenCollection(database.Client, "gift_cards")
+var giftCardTransactionCollection = database.OpenCollection(database.Client, "gift_card_transactions")
+
+const (
+	giftCardPending     = "PENDING"
+	giftCardActive      = "ACTIVE"
+	giftCardDeactivated = "DEACTIVATED"
+	giftCardCancelled   = "CANCELLED"
+	giftCardExpired     = "EXPIRED"
+)
+
+// giftCardSaleRequest is the body accepted by IssueGiftCard and ReloadGiftCard.
+type giftCardSaleRequest struct {
+	Order_Id    string  `json:"order_id" validate:"required"`
+	Amount      float64 `json:"amount" validate:"required,gt=0"`
+	Customer_Id string  `json:"customer_id,omitempty"`
+}
+
+// giftCardExpiryDays is how long a card stays valid after it is sold, from
+// GIFT_CARD_EXPIRY_DAYS. Zero means cards never expire.
+func giftCardExpiryDays() int {
+	days, err := strconv.Atoi(os.Getenv("GIFT_CARD_EXPIRY_DAYS"))
+	if err != nil || days < 0 {
+		return 365
+	}
+	return days
+}
+
+// GetGiftCards godoc
+// @Summary List gift cards
+// @Description Retrieve gift cards, optionally filtered by status or customer
+// @Tags gift-cards
+// @Produce json
+// @Param status query string false "PENDING, ACTIVE, DEACTIVATED or CANCELLED"
+// @Param customer_id query string false "Customer ID"
+// @Success 200 {array} models.Gift_Card
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /gift_cards [get]
+func GetGiftCards() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		if customerId := c.Query("customer_id"); customerId != "" {
+			filter["customer_id"] = customerId
+		}
+		cursor, err := giftCardCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var cards []models.Gift_Card
+		if err = cursor.All(ctx, &cards); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for i := range cards {
+			cards[i].Status = giftCardStatus(cards[i])
+		}
+		c.JSON(http.StatusOK, cards)
+	}
+}
+
+// GetGiftCard godoc
+// @Summary Gift card balance inquiry
+// @Description Look up a gift card by its code and return its balance, status and expiry
+// @Tags gift-cards
+// @Produce json
+// @Param code path string true "Gift card code"
+// @Success 200 {object} models.Gift_Card
+// @Failure 404 {object} object "Gift card not found"
+// @Router /gift_cards/{code} [get]
+func GetGiftCard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		card, err := giftCardByCode(ctx, c.Param("code"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Gift card not found"})
+			return
+		}
+		card.Status = giftCardStatus(card)
+		c.JSON(http.StatusOK, card)
+	}
+}
+
+// GetGiftCardTransactions godoc
+// @Summary Gift card ledger
+// @Description Retrieve every balance movement on a gift card, oldest first
+// @Tags gift-cards
+// @Produce json
+// @Param code path string true "Gift card code"
+// @Success 200 {array} models.Gift_Card_Transaction
+// @Failure 404 {object} object "Gift card not found"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /gift_cards/{code}/transactions [get]
+func GetGiftCardTransactions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		card, err := giftCardByCode(ctx, c.Param("code"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Gift card not found"})
+			return
+		}
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+		cursor, err := giftCardTransactionCollection.Find(ctx, bson.M{"gift_card_id": card.Gift_Card_Id}, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var transactions []models.Gift_Card_Transaction
+		if err = cursor.All(ctx, &transactions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, transactions)
+	}
+}
+
+// IssueGiftCard godoc
+// @Summary Sell a gift card
+// @Description Create a gift card and add its value to an order as a line item. The card stays PENDING until the order's invoice is paid.
+// @Tags gift-cards
+// @Accept json
+// @Produce json
+// @Param request body giftCardSaleRequest true "Order and card value"
+// @Success 200 {object} object "Gift card and order item"
+// @Failure 400 {object} object "Invalid input or order already paid"
+// @Failure 404 {object} object "Order not found"
+// @Failure 500 {object} object "Error issuing gift card"
+// @Router /gift_cards [post]
+func IssueGiftCard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		request, ok := bindGiftCardSale(ctx, c)
+		if !ok {
+			return
+		}
+		if request.Customer_Id != "" {
+			if exists, err := customerExists(ctx, request.Customer_Id); err != nil || !exists {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Customer not found"})
+				return
+			}
+		}
+
+		var card models.Gift_Card
+		code, err := uniqueGiftCardCode(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error issuing gift card"})
+			return
+		}
+		card.ID = primitive.NewObjectID()
+		card.Gift_Card_Id = card.ID.Hex()
+		card.Code = code
+		card.Status = giftCardPending
+		card.Customer_Id = request.Customer_Id
+		card.Issued_Order_Id = request.Order_Id
+		card.Created_By = c.GetString("user_id")
+		card.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		card.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		if _, err := giftCardCollection.InsertOne(ctx, card); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error issuing gift card"})
+			return
+		}
+
+		item, err := addGiftCardItem(ctx, card, "ISSUE", request)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAudit(ctx, "GIFT_CARD_ISSUED", "gift_card", card.Gift_Card_Id, card.Created_By, request.Amount, "order "+request.Order_Id)
+		c.JSON(http.StatusOK, gin.H{"gift_card": card, "order_item": item})
+	}
+}
+
+// ReloadGiftCard godoc
+// @Summary Reload a gift card
+// @Description Add value to an active gift card by selling it on an order. The value is loaded once the order's invoice is paid.
+// @Tags gift-cards
+// @Accept json
+// @Produce json
+// @Param code path string true "Gift card code"
+// @Param request body giftCardSaleRequest true "Order and reload value"
+// @Success 200 {object} models.Ordered_Item
+// @Failure 400 {object} object "Invalid input, inactive card or order already paid"
+// @Failure 404 {object} object "Gift card or order not found"
+// @Failure 500 {object} object "Error reloading gift card"
+// @Router /gift_cards/{code}/reload [post]
+func ReloadGiftCard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		card, err := giftCardByCode(ctx, c.Param("code"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Gift card not found"})
+			return
+		}
+		if status := giftCardStatus(card); status != giftCardActive {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Gift card is " + status})
+			return
+		}
+		request, ok := bindGiftCardSale(ctx, c)
+		if !ok {
+			return
+		}
+
+		item, err := addGiftCardItem(ctx, card, "RELOAD", request)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAudit(ctx, "GIFT_CARD_RELOAD_SOLD", "gift_card", card.Gift_Card_Id, c.GetString("user_id"), request.Amount, "order "+request.Order_Id)
+		c.JSON(http.StatusOK, item)
+	}
+}
+
+// DeactivateGiftCard godoc
+// @Summary Deactivate a gift card
+// @Description Block a lost or stolen gift card. Requires the manager or admin role; the remaining balance is kept on record.
+// @Tags gift-cards
+// @Produce json
+// @Param code path string true "Gift card code"
+// @Success 200 {object} object "message: Gift card deactivated"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 404 {object} object "Gift card not found"
+// @Failure 500 {object} object "Error deactivating gift card"
+// @Router /gift_cards/{code}/deactivate [post]
+func DeactivateGiftCard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		card, err := giftCardByCode(ctx, c.Param("code"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Gift card not found"})
+			return
+		}
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		_, err = giftCardCollection.UpdateOne(ctx, bson.M{"gift_card_id": card.Gift_Card_Id}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: giftCardDeactivated},
+				{Key: "deactivated_by", Value: c.GetString("user_id")},
+				{Key: "updated_at", Value: updatedAt},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deactivating gift card"})
+			return
+		}
+		recordAudit(ctx, "GIFT_CARD_DEACTIVATED", "gift_card", card.Gift_Card_Id, c.GetString("user_id"), card.Balance, "")
+		c.JSON(http.StatusOK, gin.H{"message": "Gift card deactivated"})
+	}
+}
+
+// bindGiftCardSale reads a sale request and checks the order can still take
+// new lines. It writes the error response itself.
+func bindGiftCardSale(ctx context.Context, c *gin.Context) (giftCardSaleRequest, bool) {
+	var request giftCardSaleRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return request, false
+	}
+	if validationErr := validate.Struct(request); validationErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		return request, false
+	}
+	request.Amount = toFixed(request.Amount, 2)
+
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": request.Order_Id}).Decode(&order); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return request, false
+	}
+	if order.Order_Status == "VOIDED" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order is voided"})
+		return request, false
+	}
+	if err := ensureOrderUnpaid(ctx, request.Order_Id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gift cards must be added before the order is paid"})
+		return request, false
+	}
+	return request, true
+}
+
+func addGiftCardItem(ctx context.Context, card models.Gift_Card, op string, request giftCardSaleRequest) (models.Ordered_Item, error) {
+	var item models.Ordered_Item
+	item.ID = primitive.NewObjectID()
+	item.Order_Item_Id = item.ID.Hex()
+	item.Order_Id = request.Order_Id
+	item.Quantity = 1
+	item.Price = request.Amount
+	item.Gift_Card_Id = card.Gift_Card_Id
+	item.Gift_Card_Op = op
+	item.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	item.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := orderItemCollection.InsertOne(ctx, item)
+	return item, err
+}
+
+func uniqueGiftCardCode(ctx context.Context) (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		code, err := helpers.NewGiftCardCode()
+		if err != nil {
+			return "", err
+		}
+		count, err := giftCardCollection.CountDocuments(ctx, bson.M{"code": code})
+		if err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return code, nil
+		}
+	}
+	return "", errors.New("could not generate a unique gift card code")
+}
+
+func giftCardByCode(ctx context.Context, code string) (models.Gift_Card, error) {
+	var card models.Gift_Card
+	err := giftCardCollection.FindOne(ctx, bson.M{"code": helpers.NormalizeGiftCardCode(code)}).Decode(&card)
+	return card, err
+}
+
+// giftCardStatus reports EXPIRED for active cards past their expiry date.
+func giftCardStatus(card models.Gift_Card) string {
+	if card.Status == giftCardActive && !card.Expires_At.IsZero() && time.Now().After(card.Expires_At) {
+		return giftCardExpired
+	}
+	return card.Status
+}
+
+// moveGiftCardBalance changes a card's balance and records the transaction.
+// Debits only succeed while the card is active and has enough balance, so
+// concurrent redemptions cannot overdraw it.
+func moveGiftCardBalance(ctx context.Context, transaction models.Gift_Card_Transaction) (models.Gift_Card_Transaction, error) {
+	filter := bson.M{"gift_card_id": transaction.Gift_Card_Id}
+	if transaction.Amount < 0 {
+		filter["status"] = giftCardActive
+		filter["balance"] = bson.M{"$gte": -transaction.Amount}
+	}
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	var card models.Gift_Card
+	err := giftCardCollection.FindOneAndUpdate(ctx, filter, bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "balance", Value: transaction.Amount}}},
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: updatedAt}}},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&card)
+	if err != nil {
+		return transaction, errors.New("gift card balance is too low or the card is not active")
+	}
+
+	transaction.ID = primitive.NewObjectID()
+	transaction.Transaction_Id = transaction.ID.Hex()
+	transaction.Balance_After = toFixed(card.Balance, 2)
+	transaction.Created_At = updatedAt
+	_, err = giftCardTransactionCollection.InsertOne(ctx, transaction)
+	return transaction, err
+}
+
+// checkGiftCardPayment validates a GIFT_CARD payment before it is stored.
+func checkGiftCardPayment(ctx context.Context, payment *models.Payment) error {
+	if payment.Gift_Card_Code == "" {
+		return errors.New("gift_card_code is required for GIFT_CARD payments")
+	}
+	if payment.Tip_Amount > 0 {
+		return errors.New("tips cannot be paid with a gift card")
+	}
+	card, err := giftCardByCode(ctx, payment.Gift_Card_Code)
+	if err != nil {
+		return errors.New("gift card not found")
+	}
+	if status := giftCardStatus(card); status != giftCardActive {
+		return fmt.Errorf("gift card is %s", status)
+	}
+	if card.Balance < *payment.Amount {
+		return fmt.Errorf("gift card balance is %.2f", card.Balance)
+	}
+	payment.Gift_Card_Code = card.Code
+	return nil
+}
+
+// redeemGiftCardPayment takes a stored GIFT_CARD payment off the card.
+func redeemGiftCardPayment(ctx context.Context, payment models.Payment) error {
+	card, err := giftCardByCode(ctx, payment.Gift_Card_Code)
+	if err != nil {
+		return errors.New("gift card not found")
+	}
+	_, err = moveGiftCardBalance(ctx, models.Gift_Card_Transaction{
+		Gift_Card_Id: card.Gift_Card_Id,
+		Type:         "REDEEM",
+		Amount:       -*payment.Amount,
+		Invoice_Id:   payment.Invoice_Id,
+		Payment_Id:   payment.Payment_Id,
+		Created_By:   payment.Recorded_By,
+	})
+	return err
+}
+
+// refundGiftCardPayment puts a refunded GIFT_CARD payment back on the card.
+func refundGiftCardPayment(ctx context.Context, reversal models.Reversal, payment models.Payment) error {
+	card, err := giftCardByCode(ctx, payment.Gift_Card_Code)
+	if err != nil {
+		return errors.New("gift card not found")
+	}
+	_, err = moveGiftCardBalance(ctx, models.Gift_Card_Transaction{
+		Gift_Card_Id: card.Gift_Card_Id,
+		Type:         "REFUND",
+		Amount:       reversal.Amount,
+		Invoice_Id:   reversal.Invoice_Id,
+		Payment_Id:   payment.Payment_Id,
+		Reversal_Id:  reversal.Reversal_Id,
+		Created_By:   reversal.Approved_By,
+	})
+	return err
+}
+
+// fulfilGiftCards loads the value of gift cards sold on a paid invoice's
+// order and activates new cards. Each order line is loaded only once.
+func fulfilGiftCards(ctx context.Context, invoice models.Invoice) error {
+	cursor, err := orderItemCollection.Find(ctx, bson.M{
+		"order_id":     invoice.Order_Id,
+		"gift_card_id": bson.M{"$exists": true},
+		"fulfilled":    bson.M{"$ne": true},
+	})
+	if err != nil {
+		return err
+	}
+	var items []models.Ordered_Item
+	if err = cursor.All(ctx, &items); err != nil {
+		return err
+	}
+	for _, item := range items {
+		result, err := orderItemCollection.UpdateOne(ctx, bson.M{"order_item_id": item.Order_Item_Id, "fulfilled": bson.M{"$ne": true}}, bson.D{
+			{Key: "$set", Value: bson.D{{Key: "fulfilled", Value: true}}},
+		})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+		if item.Gift_Card_Op == "ISSUE" {
+			activation := bson.D{{Key: "status", Value: giftCardActive}}
+			if days := giftCardExpiryDays(); days > 0 {
+				activation = append(activation, bson.E{Key: "expires_at", Value: time.Now().AddDate(0, 0, days)})
+			}
+			_, err := giftCardCollection.UpdateOne(ctx, bson.M{"gift_card_id": item.Gift_Card_Id, "status": giftCardPending}, bson.D{
+				{Key: "$set", Value: activation},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		_, err = moveGiftCardBalance(ctx, models.Gift_Card_Transaction{
+			Gift_Card_Id: item.Gift_Card_Id,
+			Type:         item.Gift_Card_Op,
+			Amount:       item.Price * float64(item.Quantity),
+			Order_Id:     invoice.Order_Id,
+			Invoice_Id:   invoice.Invoice_Id,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clawBackGiftCards takes gift card value back when a refund on an order
+// that sold or reloaded cards goes beyond the rest of the order. It fails,
+// leaving the cards as they were, when the cards no longer hold that value.
+func clawBackGiftCards(ctx context.Context, reversal models.Reversal, invoice models.Invoice, refundedBefore float64) error {
+	cursor, err := orderItemCollection.Find(ctx, bson.M{
+		"order_id":     invoice.Order_Id,
+		"gift_card_id": bson.M{"$exists": true},
+		"fulfilled":    true,
+	})
+	if err != nil {
+		return err
+	}
+	var items []models.Ordered_Item
+	if err = cursor.All(ctx, &items); err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	var loads []helpers.GiftCardLoad
+	index := map[string]int{}
+	for _, item := range items {
+		i, ok := index[item.Gift_Card_Id]
+		if !ok {
+			i = len(loads)
+			index[item.Gift_Card_Id] = i
+			loads = append(loads, helpers.GiftCardLoad{Gift_Card_Id: item.Gift_Card_Id})
+		}
+		loads[i].Loaded += item.Price * float64(item.Quantity)
+	}
+	cursor, err = giftCardTransactionCollection.Find(ctx, bson.M{"order_id": invoice.Order_Id, "type": "CLAWBACK"})
+	if err != nil {
+		return err
+	}
+	var clawbacks []models.Gift_Card_Transaction
+	if err = cursor.All(ctx, &clawbacks); err != nil {
+		return err
+	}
+	for _, clawback := range clawbacks {
+		if i, ok := index[clawback.Gift_Card_Id]; ok {
+			loads[i].Taken_Back -= clawback.Amount
+		}
+	}
+
+	total, err := orderAmountDue(ctx, invoice.Order_Id)
+	if err != nil {
+		return err
+	}
+	amounts := helpers.GiftCardClawback(total, refundedBefore, reversal.Amount, loads)
+	for i, amount := range amounts {
+		if amount <= 0 {
+			continue
+		}
+		_, err := moveGiftCardBalance(ctx, models.Gift_Card_Transaction{
+			Gift_Card_Id: loads[i].Gift_Card_Id,
+			Type:         "CLAWBACK",
+			Amount:       -amount,
+			Order_Id:     invoice.Order_Id,
+			Invoice_Id:   invoice.Invoice_Id,
+			Payment_Id:   reversal.Payment_Id,
+			Reversal_Id:  reversal.Reversal_Id,
+			Created_By:   reversal.Approved_By,
+		})
+		if err != nil {
+			// Put back what was already taken from the other cards.
+			for j := 0; j < i; j++ {
+				if amounts[j] > 0 {
+					moveGiftCardBalance(ctx, models.Gift_Card_Transaction{
+						Gift_Card_Id: loads[j].Gift_Card_Id,
+						Type:         "CLAWBACK_UNDONE",
+						Amount:       amounts[j],
+						Invoice_Id:   invoice.Invoice_Id,
+						Reversal_Id:  reversal.Reversal_Id,
+						Created_By:   reversal.Approved_By,
+					})
+				}
+			}
+			return errors.New("the gift cards sold on this order no longer hold the value being refunded")
+		}
+	}
+	return nil
+}
+
+// cancelOrderGiftCards cancels the unsold cards of a voided order.
+func cancelOrderGiftCards(ctx context.Context, orderId string) error {
+	_, err := giftCardCollection.UpdateMany(ctx, bson.M{"issued_order_id": orderId, "status": giftCardPending}, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "status", Value: giftCardCancelled}}},
+	})
+	return err
+}
+
+// giftCardLineName is how a gift card sale appears on invoices and receipts.
+func giftCardLineName(ctx context.Context, item models.Ordered_Item) string {
+	name := "Gift card"
+	if item.Gift_Card_Op == "RELOAD" {
+		name = "Gift card reload"
+	}
+	var card models.Gift_Card
+	if err := giftCardCollection.FindOne(ctx, bson.M{"gift_card_id": item.Gift_Card_Id}).Decode(&card); err == nil {
+		name += " " + helpers.MaskGiftCardCode(card.Code)
+	}
+	return name
+}
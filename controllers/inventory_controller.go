@@ -0,0 +1,979 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ingredientCollection = database.OpenCollection(database.Client, "ingredients")
+var stockLevelCollection = database.OpenCollection(database.Client, "stock_levels")
+var recipeCollection = database.OpenCollection(database.Client, "recipes")
+var stockMovementCollection = database.OpenCollection(database.Client, "stock_movements")
+
+// stockAdjustment is the body of POST /inventory/adjustments. The quantity
+// is signed.
+type stockAdjustment struct {
+	Branch_Id     string  `json:"branch_id"`
+	Ingredient_Id string  `json:"ingredient_id" validate:"required"`
+	Quantity      float64 `json:"quantity" validate:"required"`
+	Reason        string  `json:"reason" validate:"required,max=200"`
+}
+
+// stockCount is the body of POST /inventory/counts: what was found on the
+// shelves of a branch.
+type stockCount struct {
+	Branch_Id string `json:"branch_id"`
+	Counts    []struct {
+		Ingredient_Id string  `json:"ingredient_id" validate:"required"`
+		Quantity      float64 `json:"quantity" validate:"gte=0"`
+	} `json:"counts" validate:"required,min=1,dive"`
+}
+
+// parLevel is the body of PUT /inventory/par_levels.
+type parLevel struct {
+	Branch_Id     string  `json:"branch_id"`
+	Ingredient_Id string  `json:"ingredient_id" validate:"required"`
+	Reorder_Point float64 `json:"reorder_point" validate:"gte=0"`
+	Par_Level     float64 `json:"par_level" validate:"gtfield=Reorder_Point"`
+}
+
+type stockLevelView struct {
+	Ingredient_Id string    `json:"ingredient_id"`
+	Name          string    `json:"name"`
+	Unit          string    `json:"unit"`
+	Branch_Id     string    `json:"branch_id"`
+	Quantity      float64   `json:"quantity"`
+	Reorder_Point float64   `json:"reorder_point,omitempty"`
+	Par_Level     float64   `json:"par_level,omitempty"`
+	Low           bool      `json:"low,omitempty"`
+	Updated_At    time.Time `json:"updated_at,omitempty"`
+}
+
+// GetIngredients godoc
+// @Summary List ingredients
+// @Description Retrieve the ingredients kept in stock. Deactivated ones are included only with active=false.
+// @Tags inventory
+// @Produce json
+// @Param active query bool false "Only active (default) or only deactivated ingredients"
+// @Success 200 {array} models.Ingredient
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /ingredients [get]
+func GetIngredients() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{"active": c.Query("active") != "false"}
+		opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+		cursor, err := ingredientCollection.Find(ctx, filter, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		ingredients := []models.Ingredient{}
+		if err = cursor.All(ctx, &ingredients); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, ingredients)
+	}
+}
+
+// GetIngredient godoc
+// @Summary Get an ingredient
+// @Tags inventory
+// @Produce json
+// @Param ingredient_id path string true "Ingredient ID"
+// @Success 200 {object} models.Ingredient
+// @Failure 404 {object} object "Ingredient not found"
+// @Router /ingredients/{ingredient_id} [get]
+func GetIngredient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var ingredient models.Ingredient
+		if err := ingredientCollection.FindOne(ctx, bson.M{"ingredient_id": c.Param("ingredient_id")}).Decode(&ingredient); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ingredient not found"})
+			return
+		}
+		c.JSON(http.StatusOK, ingredient)
+	}
+}
+
+// CreateIngredient godoc
+// @Summary Create an ingredient
+// @Description Add an ingredient with the unit it is stocked and used in (g, kg, ml, l or piece), its cost per unit and the supplier it is reordered from. Requires the manager role.
+// @Tags inventory
+// @Accept json
+// @Produce json
+// @Param ingredient body models.Ingredient true "Ingredient"
+// @Success 200 {object} models.Ingredient
+// @Failure 400 {object} object "Invalid input"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 500 {object} object "Error creating ingredient"
+// @Router /ingredients [post]
+func CreateIngredient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		var ingredient models.Ingredient
+		if err := c.BindJSON(&ingredient); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(ingredient); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		if err := supplierExists(ctx, ingredient.Supplier_Id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ingredient.ID = primitive.NewObjectID()
+		ingredient.Ingredient_Id = ingredient.ID.Hex()
+		ingredient.Unit_Cost = toFixed(ingredient.Unit_Cost, 4)
+		ingredient.Active = true
+		ingredient.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		ingredient.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if _, err := ingredientCollection.InsertOne(ctx, ingredient); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating ingredient"})
+			return
+		}
+		c.JSON(http.StatusOK, ingredient)
+	}
+}
+
+// UpdateIngredient godoc
+// @Summary Update an ingredient
+// @Description Change the name, unit or unit cost of an ingredient. The unit cannot change once stock has moved, since the ledger is kept in it. A new unit cost re-costs the dishes using it. Requires the manager role.
+// @Tags inventory
+// @Accept json
+// @Produce json
+// @Param ingredient_id path string true "Ingredient ID"
+// @Param ingredient body models.Ingredient true "Ingredient"
+// @Success 200 {object} models.Ingredient
+// @Failure 400 {object} object "Invalid input"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 404 {object} object "Ingredient not found"
+// @Failure 409 {object} object "Unit cannot change once stock has moved"
+// @Failure 500 {object} object "Error updating ingredient"
+// @Router /ingredients/{ingredient_id} [put]
+func UpdateIngredient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		var ingredient models.Ingredient
+		if err := c.BindJSON(&ingredient); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(ingredient); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		var existing models.Ingredient
+		if err := ingredientCollection.FindOne(ctx, bson.M{"ingredient_id": c.Param("ingredient_id")}).Decode(&existing); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ingredient not found"})
+			return
+		}
+		if err := supplierExists(ctx, ingredient.Supplier_Id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if ingredient.Unit != existing.Unit {
+			count, err := stockMovementCollection.CountDocuments(ctx, bson.M{"ingredient_id": existing.Ingredient_Id})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating ingredient"})
+				return
+			}
+			if count > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "Unit cannot change once stock has moved"})
+				return
+			}
+		}
+
+		ingredient.ID = existing.ID
+		ingredient.Ingredient_Id = existing.Ingredient_Id
+		ingredient.Unit_Cost = toFixed(ingredient.Unit_Cost, 4)
+		ingredient.Active = existing.Active
+		ingredient.Created_At = existing.Created_At
+		ingredient.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		_, err := ingredientCollection.UpdateOne(ctx, bson.M{"ingredient_id": existing.Ingredient_Id}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "name", Value: ingredient.Name},
+				{Key: "unit", Value: ingredient.Unit},
+				{Key: "unit_cost", Value: ingredient.Unit_Cost},
+				{Key: "supplier_id", Value: ingredient.Supplier_Id},
+				{Key: "updated_at", Value: ingredient.Updated_At},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating ingredient"})
+			return
+		}
+		if ingredient.Unit_Cost != existing.Unit_Cost {
+			refreshIngredientFoodCosts(ctx, ingredient.Ingredient_Id)
+		}
+		c.JSON(http.StatusOK, ingredient)
+	}
+}
+
+// DeleteIngredient godoc
+// @Summary Deactivate an ingredient
+// @Description Hide an ingredient from lists and new recipes. It is kept so the ledger and existing recipes still resolve. Requires the manager role.
+// @Tags inventory
+// @Produce json
+// @Param ingredient_id path string true "Ingredient ID"
+// @Success 200 {object} object "message: Ingredient deactivated"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 404 {object} object "Ingredient not found"
+// @Failure 500 {object} object "Error deactivating ingredient"
+// @Router /ingredients/{ingredient_id} [delete]
+func DeleteIngredient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := ingredientCollection.UpdateOne(ctx, bson.M{"ingredient_id": c.Param("ingredient_id")}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "active", Value: false},
+				{Key: "updated_at", Value: updatedAt},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deactivating ingredient"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ingredient not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Ingredient deactivated"})
+	}
+}
+
+// GetRecipes godoc
+// @Summary List recipes
+// @Description Retrieve the recipes of foods and modifier options, optionally for one food or one modifier group
+// @Tags inventory
+// @Produce json
+// @Param food_id query string false "Food ID"
+// @Param modifier_group_id query string false "Modifier group ID"
+// @Success 200 {array} models.Recipe
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /recipes [get]
+func GetRecipes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if foodId := c.Query("food_id"); foodId != "" {
+			filter["food_id"] = foodId
+		}
+		if groupId := c.Query("modifier_group_id"); groupId != "" {
+			filter["modifier_group_id"] = groupId
+		}
+		cursor, err := recipeCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recipes := []models.Recipe{}
+		if err = cursor.All(ctx, &recipes); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, recipes)
+	}
+}
+
+// SaveRecipe godoc
+// @Summary Set the recipe of a food or modifier option
+// @Description Set the ingredients one portion of a food uses, or that a modifier option adds. For a REMOVAL option list what it leaves out. A food or option has one recipe; saving again replaces it. Requires the manager role.
+// @Tags inventory
+// @Accept json
+// @Produce json
+// @Param recipe body models.Recipe true "Recipe"
+// @Success 200 {object} models.Recipe
+// @Failure 400 {object} object "Invalid recipe, unknown food, option or ingredient"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 500 {object} object "Error saving recipe"
+// @Router /recipes [post]
+func SaveRecipe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		var recipe models.Recipe
+		if err := c.BindJSON(&recipe); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(recipe); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		if err := helpers.CheckRecipe(recipe); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkRecipeReferences(ctx, recipe); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for i := range recipe.Lines {
+			recipe.Lines[i].Quantity = helpers.RoundQuantity(recipe.Lines[i].Quantity)
+		}
+
+		target := recipeTarget(recipe)
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		var existing models.Recipe
+		if err := recipeCollection.FindOne(ctx, target).Decode(&existing); err == nil {
+			recipe.ID = existing.ID
+			recipe.Recipe_Id = existing.Recipe_Id
+			recipe.Created_At = existing.Created_At
+		} else {
+			recipe.ID = primitive.NewObjectID()
+			recipe.Recipe_Id = recipe.ID.Hex()
+			recipe.Created_At = now
+		}
+		recipe.Updated_At = now
+
+		opts := options.Replace().SetUpsert(true)
+		if _, err := recipeCollection.ReplaceOne(ctx, bson.M{"recipe_id": recipe.Recipe_Id}, recipe, opts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving recipe"})
+			return
+		}
+		if foodIds, err := foodsUsingRecipes(ctx, []models.Recipe{recipe}); err == nil {
+			refreshFoodCosts(ctx, helpers.CostRecipe, foodIds)
+		}
+		c.JSON(http.StatusOK, recipe)
+	}
+}
+
+// DeleteRecipe godoc
+// @Summary Delete a recipe
+// @Description Remove a recipe. The food or option stops taking stock when ordered; the ledger is unchanged.
+// @Tags inventory
+// @Produce json
+// @Param recipe_id path string true "Recipe ID"
+// @Success 200 {object} object "message: Recipe deleted"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 404 {object} object "Recipe not found"
+// @Failure 500 {object} object "Error deleting recipe"
+// @Router /recipes/{recipe_id} [delete]
+func DeleteRecipe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		var recipe models.Recipe
+		if err := recipeCollection.FindOneAndDelete(ctx, bson.M{"recipe_id": c.Param("recipe_id")}).Decode(&recipe); err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting recipe"})
+			return
+		}
+		if recipe.Option_Id != "" {
+			// The food's own cost is unknown without its recipe; only an
+			// option's recipe leaves a cost to record.
+			if foodIds, err := foodsUsingRecipes(ctx, []models.Recipe{recipe}); err == nil {
+				refreshFoodCosts(ctx, helpers.CostRecipe, foodIds)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Recipe deleted"})
+	}
+}
+
+// recipeTarget is the filter matching the recipe of the same food or option.
+func recipeTarget(recipe models.Recipe) bson.M {
+	if recipe.Food_Id != "" {
+		return bson.M{"food_id": recipe.Food_Id}
+	}
+	return bson.M{"modifier_group_id": recipe.Modifier_Group_Id, "option_id": recipe.Option_Id}
+}
+
+// checkRecipeReferences makes sure the recipe's food or option exists and
+// its ingredients are active.
+func checkRecipeReferences(ctx context.Context, recipe models.Recipe) error {
+	if recipe.Food_Id != "" {
+		count, err := foodCollection.CountDocuments(ctx, bson.M{"food_id": recipe.Food_Id})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("food %s not found", recipe.Food_Id)
+		}
+	} else {
+		count, err := modifierGroupCollection.CountDocuments(ctx, bson.M{"modifier_group_id": recipe.Modifier_Group_Id, "options.option_id": recipe.Option_Id})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("modifier option %s not found in group %s", recipe.Option_Id, recipe.Modifier_Group_Id)
+		}
+	}
+	for _, line := range recipe.Lines {
+		if _, err := activeIngredient(ctx, line.Ingredient_Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func activeIngredient(ctx context.Context, ingredientId string) (models.Ingredient, error) {
+	var ingredient models.Ingredient
+	err := ingredientCollection.FindOne(ctx, bson.M{"ingredient_id": ingredientId, "active": true}).Decode(&ingredient)
+	if err != nil {
+		return ingredient, fmt.Errorf("ingredient %s not found", ingredientId)
+	}
+	return ingredient, nil
+}
+
+// GetStockLevels godoc
+// @Summary Get stock on hand
+// @Description Retrieve how much of each ingredient a branch has, with names, units and par levels. Ingredients at or below their reorder point are marked low. Without branch_id the default branch is used.
+// @Tags inventory
+// @Produce json
+// @Param branch_id query string false "Branch ID"
+// @Success 200 {array} stockLevelView
+// @Failure 400 {object} object "Branch not found"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /inventory/stock [get]
+func GetStockLevels() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		branchId, err := inventoryBranch(ctx, c.Query("branch_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		cursor, err := stockLevelCollection.Find(ctx, bson.M{"branch_id": branchId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var levels []models.Stock_Level
+		if err = cursor.All(ctx, &levels); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		cursor, err = ingredientCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var ingredients []models.Ingredient
+		if err = cursor.All(ctx, &ingredients); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		onHand := map[string]models.Stock_Level{}
+		for _, level := range levels {
+			onHand[level.Ingredient_Id] = level
+		}
+		stock := []stockLevelView{}
+		for _, ingredient := range ingredients {
+			level, ok := onHand[ingredient.Ingredient_Id]
+			if !ingredient.Active && !ok {
+				continue
+			}
+			stock = append(stock, stockLevelView{
+				Ingredient_Id: ingredient.Ingredient_Id,
+				Name:          ingredient.Name,
+				Unit:          ingredient.Unit,
+				Branch_Id:     branchId,
+				Quantity:      helpers.RoundQuantity(level.Quantity),
+				Reorder_Point: level.Reorder_Point,
+				Par_Level:     level.Par_Level,
+				Low:           level.Par_Level > 0 && level.Quantity <= level.Reorder_Point,
+				Updated_At:    level.Updated_At,
+			})
+		}
+		c.JSON(http.StatusOK, stock)
+	}
+}
+
+// SetParLevel godoc
+// @Summary Set an ingredient's par level
+// @Description Set the reorder point and par level of an ingredient at a branch. When stock plus what is on order falls to the reorder point, a purchase order bringing it back to par is suggested. Requires the manager role.
+// @Tags inventory
+// @Accept json
+// @Produce json
+// @Param par_level body parLevel true "Reorder point and par level"
+// @Success 200 {object} models.Stock_Level
+// @Failure 400 {object} object "Invalid input, unknown branch or ingredient"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 500 {object} object "Error setting par level"
+// @Router /inventory/par_levels [put]
+func SetParLevel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		var request parLevel
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		branchId, err := inventoryBranch(ctx, request.Branch_Id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := activeIngredient(ctx, request.Ingredient_Id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var level models.Stock_Level
+		err = stockLevelCollection.FindOneAndUpdate(ctx,
+			bson.M{"ingredient_id": request.Ingredient_Id, "branch_id": branchId},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "reorder_point", Value: helpers.RoundQuantity(request.Reorder_Point)},
+				{Key: "par_level", Value: helpers.RoundQuantity(request.Par_Level)},
+			}}},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&level)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error setting par level"})
+			return
+		}
+		c.JSON(http.StatusOK, level)
+	}
+}
+
+// AdjustStock godoc
+// @Summary Adjust stock
+// @Description Add or take out stock with a reason, e.g. a delivery or a transfer. The quantity is signed and in the ingredient's unit. Requires the manager role.
+// @Tags inventory
+// @Accept json
+// @Produce json
+// @Param adjustment body stockAdjustment true "Adjustment"
+// @Success 200 {object} models.Stock_Movement
+// @Failure 400 {object} object "Invalid input, unknown branch or ingredient"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 500 {object} object "Error adjusting stock"
+// @Router /inventory/adjustments [post]
+func AdjustStock() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		var request stockAdjustment
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		branchId, err := inventoryBranch(ctx, request.Branch_Id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := activeIngredient(ctx, request.Ingredient_Id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		movement, err := postStockMovement(ctx, models.Stock_Movement{
+			Ingredient_Id: request.Ingredient_Id,
+			Branch_Id:     branchId,
+			Type:          helpers.MovementAdjustment,
+			Quantity:      request.Quantity,
+			Reason:        request.Reason,
+			Created_By:    c.GetString("user_id"),
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adjusting stock"})
+			return
+		}
+		c.JSON(http.StatusOK, movement)
+	}
+}
+
+// CountStock godoc
+// @Summary Record a stock count
+// @Description Set stock to what was counted on the shelves. Each ingredient counted gets a COUNT movement for the difference from the stock on record. Requires the manager role.
+// @Tags inventory
+// @Accept json
+// @Produce json
+// @Param count body stockCount true "Counted quantities"
+// @Success 200 {array} models.Stock_Movement
+// @Failure 400 {object} object "Invalid input, unknown branch or ingredient"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 500 {object} object "Error recording count"
+// @Router /inventory/counts [post]
+func CountStock() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		var request stockCount
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		branchId, err := inventoryBranch(ctx, request.Branch_Id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for _, count := range request.Counts {
+			if _, err := activeIngredient(ctx, count.Ingredient_Id); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		movements := []models.Stock_Movement{}
+		for _, count := range request.Counts {
+			var level models.Stock_Level
+			_ = stockLevelCollection.FindOne(ctx, bson.M{"ingredient_id": count.Ingredient_Id, "branch_id": branchId}).Decode(&level)
+			difference := helpers.RoundQuantity(count.Quantity - level.Quantity)
+			if difference == 0 {
+				continue
+			}
+			movement, err := postStockMovement(ctx, models.Stock_Movement{
+				Ingredient_Id: count.Ingredient_Id,
+				Branch_Id:     branchId,
+				Type:          helpers.MovementCount,
+				Quantity:      difference,
+				Reason:        fmt.Sprintf("counted %g", count.Quantity),
+				Created_By:    c.GetString("user_id"),
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error recording count"})
+				return
+			}
+			movements = append(movements, movement)
+		}
+		c.JSON(http.StatusOK, movements)
+	}
+}
+
+// GetStockMovements godoc
+// @Summary List the stock ledger
+// @Description Retrieve stock movements, newest first: SALE and VOID from orders, RECEIPT from purchase orders, WASTE from the waste log, ADJUSTMENT and COUNT
+// @Tags inventory
+// @Produce json
+// @Param branch_id query string false "Branch ID"
+// @Param ingredient_id query string false "Ingredient ID"
+// @Param type query string false "Movement type"
+// @Param order_id query string false "Order ID"
+// @Param purchase_order_id query string false "Purchase order ID"
+// @Param waste_id query string false "Waste event ID"
+// @Param from query string false "First day, YYYY-MM-DD"
+// @Param to query string false "Last day, YYYY-MM-DD"
+// @Success 200 {array} models.Stock_Movement
+// @Failure 400 {object} object "Invalid dates"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /inventory/movements [get]
+func GetStockMovements() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		for _, field := range []string{"branch_id", "ingredient_id", "type", "order_id", "purchase_order_id", "waste_id"} {
+			if value := c.Query(field); value != "" {
+				filter[field] = value
+			}
+		}
+		created := bson.M{}
+		if value := c.Query("from"); value != "" {
+			from, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+				return
+			}
+			created["$gte"] = from
+		}
+		if value := c.Query("to"); value != "" {
+			to, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+				return
+			}
+			created["$lt"] = to.AddDate(0, 0, 1)
+		}
+		if len(created) > 0 {
+			filter["created_at"] = created
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		cursor, err := stockMovementCollection.Find(ctx, filter, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		movements := []models.Stock_Movement{}
+		if err = cursor.All(ctx, &movements); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, movements)
+	}
+}
+
+// FireOrder godoc
+// @Summary Fire an order to the kitchen
+// @Description Mark the order's items not yet fired as sent to the kitchen and take their ingredients out of stock, for kitchens that work without printed tickets. Printing kitchen tickets fires the items too.
+// @Tags inventory
+// @Produce json
+// @Param order_id path string true "Order ID"
+// @Success 200 {array} models.Ordered_Item
+// @Failure 404 {object} object "Order not found"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /orders/{order_id}/fire [post]
+func FireOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var order models.Order
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": c.Param("order_id")}).Decode(&order); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		cursor, err := orderItemCollection.Find(ctx, bson.M{"order_id": order.Order_Id})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var items []models.Ordered_Item
+		if err = cursor.All(ctx, &items); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		fired, err := fireOrderItems(ctx, order, items)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, fired)
+	}
+}
+
+// inventoryBranch checks the branch of an inventory request. Without one it
+// is the branch orders fall back to, so single-branch installs need not
+// send it.
+func inventoryBranch(ctx context.Context, branchId string) (string, error) {
+	if branchId == "" {
+		return branchForTable(ctx, models.Table{}).Branch_Id, nil
+	}
+	count, err := branchCollection.CountDocuments(ctx, bson.M{"branch_id": branchId})
+	if err != nil {
+		return "", err
+	}
+	if count == 0 {
+		return "", errors.New("branch " + branchId + " not found")
+	}
+	return branchId, nil
+}
+
+// postStockMovement applies a movement to the branch's stock level and
+// appends it to the ledger with the resulting balance.
+func postStockMovement(ctx context.Context, movement models.Stock_Movement) (models.Stock_Movement, error) {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	movement.Quantity = helpers.RoundQuantity(movement.Quantity)
+	var level models.Stock_Level
+	err := stockLevelCollection.FindOneAndUpdate(ctx,
+		bson.M{"ingredient_id": movement.Ingredient_Id, "branch_id": movement.Branch_Id},
+		bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "quantity", Value: movement.Quantity}}},
+			{Key: "$set", Value: bson.D{{Key: "updated_at", Value: now}}},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&level)
+	if err != nil {
+		return movement, err
+	}
+
+	movement.ID = primitive.NewObjectID()
+	movement.Movement_Id = movement.ID.Hex()
+	movement.Balance = helpers.RoundQuantity(level.Quantity)
+	movement.Created_At = now
+	_, err = stockMovementCollection.InsertOne(ctx, movement)
+	return movement, err
+}
+
+// fireOrderItems marks the items not fired yet as sent to the kitchen and
+// takes their ingredients out of the branch's stock. Each item is claimed
+// with a conditional update, so firing twice at once cannot deduct twice.
+// Combo parents and gift cards are skipped: a combo is cooked as its
+// children.
+func fireOrderItems(ctx context.Context, order models.Order, items []models.Ordered_Item) ([]models.Ordered_Item, error) {
+	branchId := branchForOrder(ctx, order).Branch_Id
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	fired := []models.Ordered_Item{}
+	for _, item := range items {
+		if item.Combo_Id != "" || item.Gift_Card_Id != "" || !item.Fired_At.IsZero() {
+			continue
+		}
+		result, err := orderItemCollection.UpdateOne(ctx,
+			bson.M{"order_item_id": item.Order_Item_Id, "fired_at": bson.M{"$exists": false}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "fired_at", Value: now}}}},
+		)
+		if err != nil {
+			return fired, err
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+		item.Fired_At = now
+		fired = append(fired, item)
+
+		usage := itemStockUsage(ctx, item)
+		for _, ingredientId := range helpers.UsageIngredients(usage) {
+			_, err := postStockMovement(ctx, models.Stock_Movement{
+				Ingredient_Id: ingredientId,
+				Branch_Id:     branchId,
+				Type:          helpers.MovementSale,
+				Quantity:      -usage[ingredientId],
+				Order_Id:      order.Order_Id,
+				Order_Item_Id: item.Order_Item_Id,
+			})
+			if err != nil {
+				return fired, err
+			}
+		}
+	}
+	return fired, nil
+}
+
+// itemStockUsage loads the recipes of an item's food and options and works
+// out what the item takes out of stock.
+func itemStockUsage(ctx context.Context, item models.Ordered_Item) map[string]float64 {
+	var foodRecipe models.Recipe
+	_ = recipeCollection.FindOne(ctx, bson.M{"food_id": item.Food_Id}).Decode(&foodRecipe)
+	optionRecipes := map[string]models.Recipe{}
+	for _, modifier := range item.Modifiers {
+		var recipe models.Recipe
+		err := recipeCollection.FindOne(ctx, bson.M{"modifier_group_id": modifier.Modifier_Group_Id, "option_id": modifier.Option_Id}).Decode(&recipe)
+		if err == nil {
+			optionRecipes[helpers.OptionRecipeKey(modifier.Modifier_Group_Id, modifier.Option_Id)] = recipe
+		}
+	}
+	return helpers.ItemUsage(foodRecipe, item.Modifiers, optionRecipes, item.Quantity)
+}
+
+// restoreOrderStock puts back the stock a voided order took, with a VOID
+// movement for each of its SALE movements.
+func restoreOrderStock(ctx context.Context, orderId string) error {
+	return restoreStock(ctx, bson.M{"order_id": orderId}, "order voided")
+}
+
+// restoreOrderItemStock puts back the stock fired items took when they are
+// deleted from an order.
+func restoreOrderItemStock(ctx context.Context, orderId string, orderItemIds []string) error {
+	return restoreStock(ctx, bson.M{"order_id": orderId, "order_item_id": bson.M{"$in": orderItemIds}}, "item deleted")
+}
+
+// restoreStock posts a VOID movement for each SALE movement matching filter.
+// Sales already restored, by an earlier item deletion or a retried void,
+// are skipped.
+func restoreStock(ctx context.Context, filter bson.M, reason string) error {
+	var movements []models.Stock_Movement
+	cursor, err := stockMovementCollection.Find(ctx, bson.M{"$and": bson.A{
+		filter,
+		bson.M{"type": bson.M{"$in": bson.A{helpers.MovementSale, helpers.MovementVoid}}},
+	}})
+	if err != nil {
+		return err
+	}
+	if err = cursor.All(ctx, &movements); err != nil {
+		return err
+	}
+	restored := map[string]bool{}
+	for _, movement := range movements {
+		if movement.Type == helpers.MovementVoid {
+			restored[movement.Order_Item_Id+"/"+movement.Ingredient_Id] = true
+		}
+	}
+	for _, sale := range movements {
+		if sale.Type != helpers.MovementSale || restored[sale.Order_Item_Id+"/"+sale.Ingredient_Id] {
+			continue
+		}
+		_, err := postStockMovement(ctx, models.Stock_Movement{
+			Ingredient_Id: sale.Ingredient_Id,
+			Branch_Id:     sale.Branch_Id,
+			Type:          helpers.MovementVoid,
+			Quantity:      -sale.Quantity,
+			Order_Id:      sale.Order_Id,
+			Order_Item_Id: sale.Order_Item_Id,
+			Reason:        reason,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"context"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/abik1221/Tewanay-Engineering_Intership/database"
@@ -15,10 +16,11 @@ import (
 var invoiceCollection = database.OpenCollection(database.Client, "invoices")
 
 // @Summary      List all invoices
-// @Description  Retrieve a list of all invoices in the system
+// @Description  Retrieve a list of all invoices in the system. status=overdue lists unsettled invoices past their due date; any other status matches payment_status.
 // @Tags         invoices
 // @Accept       json
 // @Produce      json
+// @Param        status  query  string  false  "overdue or a payment status"
 // @Success      200  {array}   models.Invoice
 // @Failure      500  {object}  object  "Invoices not found or server error"
 // @Router       /invoices [get]
@@ -28,7 +30,14 @@ func GetInvoices() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 		var invoices []models.Invoice
-		cursor, err := invoiceCollection.Find(ctx, bson.M{})
+		filter := bson.M{}
+		switch status := c.Query("status"); {
+		case strings.EqualFold(status, "overdue"):
+			filter = overdueFilter(time.Now())
+		case status != "":
+			filter["payment_status"] = status
+		}
+		cursor, err := invoiceCollection.Find(ctx, filter)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invoices not found"})
 			return
@@ -98,6 +107,30 @@ func CreateInvoice() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
 			return
 		}
+		// Discounts are always taken from the order, never from the request.
+		invoice.Discounts = nil
+		invoice.Discount_Total = 0
+		var order models.Order
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": invoice.Order_Id}).Decode(&order); err == nil {
+			invoice.Discounts = order.Discounts
+			invoice.Discount_Total = order.Discount_Total
+			charges, branch, err := orderCharges(ctx, order)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if err := ensurePeriodOpen(ctx, branch.Branch_Id, time.Now()); err != nil {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			invoice.Branch_Id = branch.Branch_Id
+			invoice.Subtotal = charges.Subtotal
+			invoice.Service_Charges = charges.Service_Charges
+			invoice.Taxes = charges.Taxes
+			invoice.Tax_Total = charges.Tax_Total
+			invoice.Tax_Inclusive = charges.Tax_Inclusive
+			invoice.Total_Amount = charges.Total
+		}
 		invoice.Invoice_Id = primitive.NewObjectID().Hex()
 		invoice.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		invoice.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
@@ -111,7 +144,7 @@ func CreateInvoice() gin.HandlerFunc {
 }
 
 // @Summary      Update an invoice
-// @Description  Modify an existing invoice by ID (full update)
+// @Description  Change the due date and account contact details of an invoice. Amounts, taxes, branch and payment status are computed by the server and cannot be set here.
 // @Tags         invoices
 // @Accept       json
 // @Produce      json
@@ -119,6 +152,8 @@ func CreateInvoice() gin.HandlerFunc {
 // @Param        request     body  models.Invoice  true  "Updated invoice data"
 // @Success      200  {object}  object  "MongoDB update result"
 // @Failure      400  {object}  object  "Invalid input"
+// @Failure      404  {object}  object  "Invoice not found"
+// @Failure      409  {object}  object  "Accounting period is locked"
 // @Failure      500  {object}  object  "Error updating invoice"
 // @Router       /invoices/{invoice_id} [put]
 func UpdateInvoice() gin.HandlerFunc {
@@ -131,12 +166,40 @@ func UpdateInvoice() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		filter := bson.M{"invoice_id": invoiceId}
-		invoice.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		update := bson.M{
-			"$set": invoice,
+		var existing models.Invoice
+		if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&existing); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return
+		}
+		if err := ensureInvoicePeriodOpen(ctx, existing); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		var UpdateObj primitive.D
+		if !invoice.Payment_Due_Date.IsZero() {
+			UpdateObj = append(UpdateObj, bson.E{Key: "payment_due_date", Value: invoice.Payment_Due_Date})
+		}
+		if invoice.Account_Name != "" {
+			UpdateObj = append(UpdateObj, bson.E{Key: "account_name", Value: invoice.Account_Name})
+		}
+		if invoice.Contact_Email != "" {
+			if err := validate.StructPartial(invoice, "Contact_Email"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			UpdateObj = append(UpdateObj, bson.E{Key: "contact_email", Value: invoice.Contact_Email})
 		}
-		result, err := invoiceCollection.UpdateOne(ctx, filter, update)
+		if invoice.Contact_Phone != "" {
+			UpdateObj = append(UpdateObj, bson.E{Key: "contact_phone", Value: invoice.Contact_Phone})
+		}
+		invoice.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		UpdateObj = append(UpdateObj, bson.E{Key: "updated_at", Value: invoice.Updated_At})
+
+		filter := bson.M{"invoice_id": invoiceId}
+		result, err := invoiceCollection.UpdateOne(ctx, filter, bson.D{
+			{Key: "$set", Value: UpdateObj},
+		})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating invoice"})
 			return
@@ -146,12 +209,13 @@ func UpdateInvoice() gin.HandlerFunc {
 }
 
 // @Summary      Delete an invoice
-// @Description  Remove an invoice by ID
+// @Description  Remove an invoice by ID. Invoices with recorded payments cannot be deleted; refund them instead.
 // @Tags         invoices
 // @Accept       json
 // @Produce      json
 // @Param        invoice_id  path  string  true  "Invoice ID to delete"
 // @Success      200  {object}  object  "MongoDB delete result"
+// @Failure      409  {object}  object  "Invoice has recorded payments or its period is locked"
 // @Failure      500  {object}  object  "Error deleting invoice"
 // @Router       /invoices/{invoice_id} [delete]
 func DeleteInvoice() gin.HandlerFunc {
@@ -159,12 +223,29 @@ func DeleteInvoice() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 		invoiceId := c.Param("invoice_id")
+		var invoice models.Invoice
+		if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err == nil {
+			if err := ensureInvoicePeriodOpen(ctx, invoice); err != nil {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		count, err := paymentCollection.CountDocuments(ctx, bson.M{"invoice_id": invoiceId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting invoice"})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Invoice has recorded payments; issue a refund instead of deleting it"})
+			return
+		}
 		filter := bson.M{"invoice_id": invoiceId}
 		result, err := invoiceCollection.DeleteOne(ctx, filter)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting invoice"})
 			return
 		}
+		recordAudit(ctx, "INVOICE_DELETED", "invoice", invoiceId, c.GetString("user_id"), 0, "")
 		c.JSON(http.StatusOK, result)
 	}
 }
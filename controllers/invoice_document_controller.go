@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// GetInvoicePDF godoc
+// @Summary Download an invoice as PDF
+// @Description Render a branded PDF with restaurant details, line items, taxes, payments and a verification QR code
+// @Tags invoices
+// @Produce application/pdf
+// @Param invoice_id path string true "Invoice ID"
+// @Success 200 {file} file "PDF document"
+// @Failure 404 {object} object "Invoice not found"
+// @Failure 500 {object} object "Error rendering invoice"
+// @Router /invoices/{invoice_id}/pdf [get]
+func GetInvoicePDF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		doc, ok := loadInvoiceDocument(ctx, c)
+		if !ok {
+			return
+		}
+		pdf, err := helpers.RenderInvoicePDF(doc)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rendering invoice"})
+			return
+		}
+		c.Header("Content-Disposition", `inline; filename="invoice-`+doc.Invoice_Id+`.pdf"`)
+		c.Data(http.StatusOK, "application/pdf", pdf)
+	}
+}
+
+// GetInvoiceReceipt godoc
+// @Summary Get a plain-text receipt
+// @Description Render the invoice as a plain-text receipt using the branch receipt layout
+// @Tags invoices
+// @Produce plain
+// @Param invoice_id path string true "Invoice ID"
+// @Success 200 {string} string "Receipt text"
+// @Failure 404 {object} object "Invoice not found"
+// @Failure 500 {object} object "Error rendering receipt"
+// @Router /invoices/{invoice_id}/receipt [get]
+func GetInvoiceReceipt() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		doc, ok := loadInvoiceDocument(ctx, c)
+		if !ok {
+			return
+		}
+		receipt, err := helpers.RenderReceiptText(doc)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.String(http.StatusOK, receipt)
+	}
+}
+
+// VerifyInvoice godoc
+// @Summary Verify a printed invoice
+// @Description Public endpoint behind the invoice QR code. Confirms the invoice exists and returns its totals.
+// @Tags invoices
+// @Produce json
+// @Param invoice_id path string true "Invoice ID"
+// @Param sig query string true "Signature from the QR code"
+// @Success 200 {object} object "Invoice summary"
+// @Failure 404 {object} object "Invoice not found or signature invalid"
+// @Router /invoices/{invoice_id}/verify [get]
+func VerifyInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if !helpers.ValidInvoiceSignature(c.Param("invoice_id"), c.Query("sig")) {
+			c.JSON(http.StatusNotFound, gin.H{"valid": false, "error": "Invoice not found"})
+			return
+		}
+		doc, ok := loadInvoiceDocument(ctx, c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"valid":          true,
+			"invoice_id":     doc.Invoice_Id,
+			"branch":         doc.Branch.Name,
+			"issued_at":      doc.Issued_At,
+			"total":          doc.Total,
+			"payment_status": doc.Payment_Status,
+		})
+	}
+}
+
+// loadInvoiceDocument builds the document for the invoice named in the path,
+// writing the error response itself when that fails.
+func loadInvoiceDocument(ctx context.Context, c *gin.Context) (helpers.InvoiceDocument, bool) {
+	var invoice models.Invoice
+	if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": c.Param("invoice_id")}).Decode(&invoice); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+		return helpers.InvoiceDocument{}, false
+	}
+	doc, err := buildInvoiceDocument(ctx, invoice)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return helpers.InvoiceDocument{}, false
+	}
+	return doc, true
+}
+
+// buildInvoiceDocument gathers the order, table, branch, items and payments
+// behind an invoice into a renderable document.
+func buildInvoiceDocument(ctx context.Context, invoice models.Invoice) (helpers.InvoiceDocument, error) {
+	var order models.Order
+	var table models.Table
+
+	doc := helpers.InvoiceDocument{
+		Invoice_Id:       invoice.Invoice_Id,
+		Order_Id:         invoice.Order_Id,
+		Issued_At:        invoice.Created_At,
+		Due_Date:         invoice.Payment_Due_Date,
+		Verification_URL: helpers.InvoiceVerificationURL(invoice.Invoice_Id),
+	}
+	if invoice.Payment_Status != nil {
+		doc.Payment_Status = *invoice.Payment_Status
+	}
+
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": invoice.Order_Id}).Decode(&order); err == nil {
+		if err := tableCollection.FindOne(ctx, bson.M{"table_id": order.Table_Id}).Decode(&table); err == nil {
+			doc.Table_Name = table.Table_Name
+		}
+	}
+	doc.Branch = branchForTable(ctx, table)
+
+	cursor, err := orderItemCollection.Find(ctx, bson.M{"order_id": invoice.Order_Id})
+	if err != nil {
+		return doc, err
+	}
+	var items []models.Ordered_Item
+	if err = cursor.All(ctx, &items); err != nil {
+		return doc, err
+	}
+	// A combo prints as one line at its combo price, naming its dishes; the
+	// component items carry that price between them.
+	components := map[string][]models.Ordered_Item{}
+	for _, item := range items {
+		if item.Parent_Item_Id != "" {
+			components[item.Parent_Item_Id] = append(components[item.Parent_Item_Id], item)
+		}
+	}
+	for _, item := range items {
+		if item.Parent_Item_Id != "" {
+			continue
+		}
+		var food models.Food
+		name := item.Food_Id
+		amount := toFixed(item.Price*float64(item.Quantity), 2)
+		unitPrice := item.Price
+		if item.Combo_Id != "" {
+			var dishes []string
+			amount = 0
+			for _, component := range components[item.Order_Item_Id] {
+				dish := component.Food_Id
+				if err := foodCollection.FindOne(ctx, bson.M{"food_id": component.Food_Id}).Decode(&food); err == nil {
+					dish = helpers.ModifiedItemName(food.Food_Name, component.Modifiers)
+				}
+				dishes = append(dishes, dish)
+				amount += component.Price * float64(component.Quantity)
+			}
+			name = comboLineName(ctx, item.Combo_Id)
+			if len(dishes) > 0 {
+				name += ": " + strings.Join(dishes, ", ")
+			}
+			amount = toFixed(amount, 2)
+			unitPrice = item.Combo_Price
+		} else if item.Gift_Card_Id != "" {
+			name = giftCardLineName(ctx, item)
+		} else if err := foodCollection.FindOne(ctx, bson.M{"food_id": item.Food_Id}).Decode(&food); err == nil {
+			name = helpers.ModifiedItemName(food.Food_Name, item.Modifiers)
+		}
+		doc.Lines = append(doc.Lines, helpers.DocumentLine{
+			Name:       name,
+			Quantity:   item.Quantity,
+			Unit_Price: unitPrice,
+			Amount:     amount,
+		})
+		doc.Subtotal += amount
+	}
+	doc.Subtotal = toFixed(doc.Subtotal, 2)
+	doc.Total = doc.Subtotal
+	for _, discount := range invoice.Discounts {
+		doc.Discounts = append(doc.Discounts, helpers.DocumentDiscount{
+			Name:   discount.Name,
+			Amount: discount.Amount,
+		})
+		doc.Total -= discount.Amount
+	}
+	doc.Total = toFixed(doc.Total, 2)
+	if order.Order_Id != "" {
+		charges, _, err := orderCharges(ctx, order)
+		if err != nil {
+			return doc, err
+		}
+		doc.Tax_Inclusive = charges.Tax_Inclusive
+		for _, service := range charges.Service_Charges {
+			doc.Service_Charges = append(doc.Service_Charges, helpers.DocumentTax{
+				Name:   service.Name,
+				Rate:   service.Rate,
+				Amount: service.Amount,
+			})
+		}
+		for _, tax := range charges.Taxes {
+			name := tax.Name
+			if charges.Tax_Inclusive {
+				name += " (incl.)"
+			}
+			doc.Taxes = append(doc.Taxes, helpers.DocumentTax{
+				Name:   name,
+				Rate:   tax.Rate,
+				Amount: tax.Amount,
+			})
+		}
+		doc.Total = charges.Total
+	}
+
+	payments, err := paymentsByInvoice(ctx, invoice.Invoice_Id)
+	if err != nil {
+		return doc, err
+	}
+	for _, payment := range payments {
+		net := toFixed(*payment.Amount-payment.Refunded_Amount, 2)
+		doc.Payments = append(doc.Payments, helpers.DocumentPayment{
+			Method:  *payment.Payment_Method,
+			Amount:  net,
+			Paid_At: payment.Created_At,
+		})
+		doc.Amount_Paid += net
+	}
+	doc.Amount_Paid = toFixed(doc.Amount_Paid, 2)
+	doc.Balance_Due = toFixed(doc.Total-doc.Amount_Paid, 2)
+	return doc, nil
+}
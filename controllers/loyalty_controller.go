@@ -0,0 +1,574 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var loyaltyLedgerCollection = database.OpenCollection(database.Client, "loyalty_ledger")
+var loyaltyProgramCollection = database.OpenCollection(database.Client, "loyalty_program")
+
+// Ledger entry types. EARN, REFUND and positive ADJUST entries are credits
+// that can be spent; the rest are debits.
+const (
+	loyaltyEarn     = "EARN"
+	loyaltyRedeem   = "REDEEM"
+	loyaltyExpire   = "EXPIRE"
+	loyaltyReversal = "REVERSAL"
+	loyaltyRefund   = "REFUND"
+	loyaltyAdjust   = "ADJUST"
+)
+
+// GetLoyaltyProgram godoc
+// @Summary Get the loyalty program
+// @Description Retrieve the earn rate, point value, expiry and tiers currently in force
+// @Tags loyalty
+// @Produce json
+// @Success 200 {object} models.Loyalty_Program
+// @Router /loyalty/program [get]
+func GetLoyaltyProgram() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		c.JSON(http.StatusOK, loyaltyProgram(ctx))
+	}
+}
+
+// UpdateLoyaltyProgram godoc
+// @Summary Update the loyalty program
+// @Description Replace the earn, redeem, expiry and tier rules. Requires the manager or admin role. Existing points keep their expiry dates.
+// @Tags loyalty
+// @Accept json
+// @Produce json
+// @Param program body models.Loyalty_Program true "Loyalty program"
+// @Success 200 {object} models.Loyalty_Program
+// @Failure 400 {object} object "Invalid input"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 500 {object} object "Error saving loyalty program"
+// @Router /loyalty/program [put]
+func UpdateLoyaltyProgram() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		var program models.Loyalty_Program
+		if err := c.BindJSON(&program); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(program); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		program.ID = primitive.NilObjectID
+		program.Updated_By = c.GetString("user_id")
+		program.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		upsert := true
+		_, err := loyaltyProgramCollection.ReplaceOne(ctx, bson.M{}, program, &options.ReplaceOptions{Upsert: &upsert})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving loyalty program"})
+			return
+		}
+		recordAudit(ctx, "LOYALTY_PROGRAM_UPDATED", "loyalty_program", "", program.Updated_By, 0, "")
+		c.JSON(http.StatusOK, program)
+	}
+}
+
+// GetCustomerLoyalty godoc
+// @Summary Get a customer's loyalty account
+// @Description Retrieve the points balance, tier, redeemable value and full ledger for a customer, newest entries first
+// @Tags loyalty
+// @Produce json
+// @Param customer_id path string true "Customer ID"
+// @Success 200 {object} object "Balance, tier and ledger"
+// @Failure 404 {object} object "Customer not found"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /customers/{customer_id}/loyalty [get]
+func GetCustomerLoyalty() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var customer models.Customer
+		if err := customerCollection.FindOne(ctx, bson.M{"customer_id": c.Param("customer_id")}).Decode(&customer); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+			return
+		}
+		if err := expireCustomerPoints(ctx, customer.Customer_Id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		balance, err := customerBalance(ctx, customer.Customer_Id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		cursor, err := loyaltyLedgerCollection.Find(ctx, bson.M{"customer_id": customer.Customer_Id}, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var ledger []models.Loyalty_Entry
+		if err = cursor.All(ctx, &ledger); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		program := loyaltyProgram(ctx)
+		tier := helpers.LoyaltyTierFor(program, customer.Lifetime_Points)
+		c.JSON(http.StatusOK, gin.H{
+			"customer_id":     customer.Customer_Id,
+			"points_balance":  balance,
+			"points_value":    toFixed(float64(balance)*program.Point_Value, 2),
+			"lifetime_points": customer.Lifetime_Points,
+			"tier":            tier,
+			"ledger":          ledger,
+		})
+	}
+}
+
+// adjustPointsRequest is the body accepted by AdjustCustomerPoints.
+type adjustPointsRequest struct {
+	Points      int    `json:"points" validate:"required"`
+	Description string `json:"description" validate:"required"`
+}
+
+// AdjustCustomerPoints godoc
+// @Summary Adjust a customer's points
+// @Description Add or remove points by hand, e.g. for a goodwill gesture. Requires the manager or admin role and is audited.
+// @Tags loyalty
+// @Accept json
+// @Produce json
+// @Param customer_id path string true "Customer ID"
+// @Param request body adjustPointsRequest true "Points (negative to remove) and reason"
+// @Success 200 {object} models.Loyalty_Entry
+// @Failure 400 {object} object "Invalid input or insufficient points"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 404 {object} object "Customer not found"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /customers/{customer_id}/loyalty/adjust [post]
+func AdjustCustomerPoints() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		var request adjustPointsRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		customerId := c.Param("customer_id")
+		if exists, err := customerExists(ctx, customerId); err != nil || !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+			return
+		}
+
+		entry := models.Loyalty_Entry{
+			Customer_Id: customerId,
+			Type:        loyaltyAdjust,
+			Description: request.Description,
+			Created_By:  c.GetString("user_id"),
+		}
+		var err error
+		if request.Points > 0 {
+			entry.Points = request.Points
+			err = creditPoints(ctx, &entry)
+		} else {
+			if err = expireCustomerPoints(ctx, customerId); err == nil {
+				err = spendPoints(ctx, &entry, -request.Points)
+			}
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		recordAudit(ctx, "LOYALTY_POINTS_ADJUSTED", "customer", customerId, entry.Created_By, float64(entry.Points), request.Description)
+		c.JSON(http.StatusOK, entry)
+	}
+}
+
+// ExpireLoyaltyPoints godoc
+// @Summary Expire loyalty points
+// @Description Expire every customer's points that are past their expiry date. Balances are also expired lazily whenever they are read or spent.
+// @Tags loyalty
+// @Produce json
+// @Success 200 {object} object "Number of customers checked"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /loyalty/expire [post]
+func ExpireLoyaltyPoints() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		customerIds, err := loyaltyLedgerCollection.Distinct(ctx, "customer_id", bson.M{
+			"remaining":  bson.M{"$gt": 0},
+			"expires_at": bson.M{"$lte": time.Now()},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, id := range customerIds {
+			if customerId, ok := id.(string); ok {
+				if err := expireCustomerPoints(ctx, customerId); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"customers": len(customerIds)})
+	}
+}
+
+// loyaltyProgram is the saved program, or the defaults when none is saved.
+func loyaltyProgram(ctx context.Context) models.Loyalty_Program {
+	var program models.Loyalty_Program
+	if err := loyaltyProgramCollection.FindOne(ctx, bson.M{}).Decode(&program); err != nil {
+		return helpers.DefaultLoyaltyProgram()
+	}
+	return program
+}
+
+func tierForPoints(ctx context.Context, lifetimePoints int) string {
+	return helpers.LoyaltyTierFor(loyaltyProgram(ctx), lifetimePoints).Name
+}
+
+// customerBalance sums the customer's ledger.
+func customerBalance(ctx context.Context, customerId string) (int, error) {
+	cursor, err := loyaltyLedgerCollection.Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "customer_id", Value: customerId}}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$customer_id"},
+			{Key: "balance", Value: bson.D{{Key: "$sum", Value: "$points"}}},
+		}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	var totals []struct {
+		Balance int `bson:"balance"`
+	}
+	if err = cursor.All(ctx, &totals); err != nil {
+		return 0, err
+	}
+	if len(totals) == 0 {
+		return 0, nil
+	}
+	return totals[0].Balance, nil
+}
+
+func insertLoyaltyEntry(ctx context.Context, entry *models.Loyalty_Entry) error {
+	entry.ID = primitive.NewObjectID()
+	entry.Entry_Id = entry.ID.Hex()
+	entry.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := loyaltyLedgerCollection.InsertOne(ctx, entry)
+	return err
+}
+
+// creditPoints adds spendable points that expire after the program's expiry
+// period.
+func creditPoints(ctx context.Context, entry *models.Loyalty_Entry) error {
+	program := loyaltyProgram(ctx)
+	entry.Remaining = entry.Points
+	if program.Expiry_Days > 0 {
+		entry.Expires_At = time.Now().AddDate(0, 0, program.Expiry_Days)
+	}
+	return insertLoyaltyEntry(ctx, entry)
+}
+
+// spendPoints writes a debit of points, consuming the oldest unspent credits
+// first. It fails without writing anything when the balance is too low.
+func spendPoints(ctx context.Context, entry *models.Loyalty_Entry, points int) error {
+	balance, err := customerBalance(ctx, entry.Customer_Id)
+	if err != nil {
+		return err
+	}
+	if points > balance {
+		return fmt.Errorf("insufficient points: balance is %d", balance)
+	}
+	if err := consumeCredits(ctx, entry.Customer_Id, "", points); err != nil {
+		return err
+	}
+	entry.Points = -points
+	return insertLoyaltyEntry(ctx, entry)
+}
+
+// consumeCredits lowers Remaining on the customer's credits, starting with
+// preferEntryId when given and then the oldest.
+func consumeCredits(ctx context.Context, customerId, preferEntryId string, points int) error {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := loyaltyLedgerCollection.Find(ctx, bson.M{
+		"customer_id": customerId,
+		"remaining":   bson.M{"$gt": 0},
+	}, opts)
+	if err != nil {
+		return err
+	}
+	var credits []models.Loyalty_Entry
+	if err = cursor.All(ctx, &credits); err != nil {
+		return err
+	}
+	for entryId, used := range helpers.ConsumeCredits(credits, preferEntryId, points) {
+		_, err := loyaltyLedgerCollection.UpdateOne(ctx, bson.M{"entry_id": entryId}, bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "remaining", Value: -used}}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expireCustomerPoints writes an EXPIRE entry for every credit past its
+// expiry date that still has unspent points.
+func expireCustomerPoints(ctx context.Context, customerId string) error {
+	cursor, err := loyaltyLedgerCollection.Find(ctx, bson.M{
+		"customer_id": customerId,
+		"remaining":   bson.M{"$gt": 0},
+		"expires_at":  bson.M{"$lte": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	var credits []models.Loyalty_Entry
+	if err = cursor.All(ctx, &credits); err != nil {
+		return err
+	}
+	for _, credit := range credits {
+		_, err := loyaltyLedgerCollection.UpdateOne(ctx, bson.M{"entry_id": credit.Entry_Id}, bson.D{
+			{Key: "$set", Value: bson.D{{Key: "remaining", Value: 0}}},
+		})
+		if err != nil {
+			return err
+		}
+		entry := models.Loyalty_Entry{
+			Customer_Id:       customerId,
+			Type:              loyaltyExpire,
+			Points:            -credit.Remaining,
+			Reverses_Entry_Id: credit.Entry_Id,
+			Description:       "Points expired",
+		}
+		if err := insertLoyaltyEntry(ctx, &entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addLifetimePoints moves the customer's lifetime points and re-derives their
+// tier from them.
+func addLifetimePoints(ctx context.Context, customerId string, points int) error {
+	var customer models.Customer
+	if err := customerCollection.FindOne(ctx, bson.M{"customer_id": customerId}).Decode(&customer); err != nil {
+		return err
+	}
+	lifetime := customer.Lifetime_Points + points
+	if lifetime < 0 {
+		lifetime = 0
+	}
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := customerCollection.UpdateOne(ctx, bson.M{"customer_id": customerId}, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "lifetime_points", Value: lifetime},
+			{Key: "tier", Value: tierForPoints(ctx, lifetime)},
+			{Key: "updated_at", Value: updatedAt},
+		}},
+	})
+	return err
+}
+
+// orderCustomer is the loyalty customer on the invoice's order, or "".
+func orderCustomer(ctx context.Context, invoice models.Invoice) string {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": invoice.Order_Id}).Decode(&order); err != nil {
+		return ""
+	}
+	return order.Customer_Id
+}
+
+// earnInvoicePoints credits the order's customer once an invoice is paid.
+// Points are earned on what was paid with real tenders, excluding tips,
+// points and gift cards (which earned points when they were bought). Calling it again for the same invoice does nothing.
+func earnInvoicePoints(ctx context.Context, invoice models.Invoice, payments []models.Payment) error {
+	customerId := orderCustomer(ctx, invoice)
+	if customerId == "" {
+		return nil
+	}
+	count, err := loyaltyLedgerCollection.CountDocuments(ctx, bson.M{"invoice_id": invoice.Invoice_Id, "type": loyaltyEarn})
+	if err != nil || count > 0 {
+		return err
+	}
+	var customer models.Customer
+	if err := customerCollection.FindOne(ctx, bson.M{"customer_id": customerId}).Decode(&customer); err != nil {
+		return nil
+	}
+
+	var basis float64
+	for _, payment := range payments {
+		if method := *payment.Payment_Method; method != "LOYALTY_POINTS" && method != "GIFT_CARD" {
+			basis += *payment.Amount - payment.Refunded_Amount
+		}
+	}
+	basis = toFixed(basis, 2)
+	program := loyaltyProgram(ctx)
+	points := helpers.PointsEarned(program, helpers.LoyaltyTierFor(program, customer.Lifetime_Points), basis)
+	if points <= 0 {
+		return nil
+	}
+
+	entry := models.Loyalty_Entry{
+		Customer_Id: customerId,
+		Type:        loyaltyEarn,
+		Points:      points,
+		Amount:      basis,
+		Invoice_Id:  invoice.Invoice_Id,
+		Description: "Earned on invoice " + invoice.Invoice_Id,
+	}
+	if err := creditPoints(ctx, &entry); err != nil {
+		return err
+	}
+	return addLifetimePoints(ctx, customerId, points)
+}
+
+// redeemPointsForPayment checks and prices a LOYALTY_POINTS payment. The
+// points are spent by spendPaymentPoints once the payment is stored.
+func redeemPointsForPayment(ctx context.Context, invoice models.Invoice, payment *models.Payment) error {
+	customerId := orderCustomer(ctx, invoice)
+	if customerId == "" {
+		return errors.New("paying with points requires a customer on the order")
+	}
+	if payment.Tip_Amount > 0 {
+		return errors.New("tips cannot be paid with points")
+	}
+	if err := expireCustomerPoints(ctx, customerId); err != nil {
+		return err
+	}
+	program := loyaltyProgram(ctx)
+	points := helpers.PointsForAmount(program, *payment.Amount)
+	if points < program.Min_Redeem_Points {
+		return fmt.Errorf("at least %d points must be redeemed at a time", program.Min_Redeem_Points)
+	}
+	balance, err := customerBalance(ctx, customerId)
+	if err != nil {
+		return err
+	}
+	if points > balance {
+		return fmt.Errorf("insufficient points: %d needed, balance is %d", points, balance)
+	}
+	payment.Loyalty_Points = points
+	return nil
+}
+
+func spendPaymentPoints(ctx context.Context, invoice models.Invoice, payment models.Payment) error {
+	entry := models.Loyalty_Entry{
+		Customer_Id: orderCustomer(ctx, invoice),
+		Type:        loyaltyRedeem,
+		Amount:      *payment.Amount,
+		Invoice_Id:  invoice.Invoice_Id,
+		Payment_Id:  payment.Payment_Id,
+		Description: "Redeemed on invoice " + invoice.Invoice_Id,
+		Created_By:  payment.Recorded_By,
+	}
+	return spendPoints(ctx, &entry, payment.Loyalty_Points)
+}
+
+// reverseRefundPoints keeps the ledger in step with a refund. Refunding a
+// points payment gives the points back; refunding any other tender takes back
+// the share of points earned on the refunded amount.
+func reverseRefundPoints(ctx context.Context, reversal models.Reversal, payment models.Payment) error {
+	if *payment.Payment_Method == "LOYALTY_POINTS" {
+		var redeemed models.Loyalty_Entry
+		if err := loyaltyLedgerCollection.FindOne(ctx, bson.M{"payment_id": payment.Payment_Id, "type": loyaltyRedeem}).Decode(&redeemed); err != nil {
+			return nil
+		}
+		points := int(math.Round(float64(payment.Loyalty_Points) * reversal.Amount / *payment.Amount))
+		if points <= 0 {
+			return nil
+		}
+		entry := models.Loyalty_Entry{
+			Customer_Id:       redeemed.Customer_Id,
+			Type:              loyaltyRefund,
+			Points:            points,
+			Amount:            reversal.Amount,
+			Invoice_Id:        reversal.Invoice_Id,
+			Payment_Id:        payment.Payment_Id,
+			Reversal_Id:       reversal.Reversal_Id,
+			Reverses_Entry_Id: redeemed.Entry_Id,
+			Description:       "Points returned by refund",
+			Created_By:        reversal.Approved_By,
+		}
+		return creditPoints(ctx, &entry)
+	}
+
+	var earned models.Loyalty_Entry
+	if err := loyaltyLedgerCollection.FindOne(ctx, bson.M{"invoice_id": reversal.Invoice_Id, "type": loyaltyEarn}).Decode(&earned); err != nil {
+		return nil
+	}
+	cursor, err := loyaltyLedgerCollection.Find(ctx, bson.M{"reverses_entry_id": earned.Entry_Id, "type": loyaltyReversal})
+	if err != nil {
+		return err
+	}
+	var previous []models.Loyalty_Entry
+	if err = cursor.All(ctx, &previous); err != nil {
+		return err
+	}
+	left := earned.Points
+	for _, entry := range previous {
+		left += entry.Points
+	}
+	points := helpers.RefundedPoints(earned, reversal.Amount, left)
+	if points <= 0 {
+		return nil
+	}
+
+	// The clawback may take the balance below zero if the points were
+	// already spent; the customer then earns their way back.
+	if err := consumeCredits(ctx, earned.Customer_Id, earned.Entry_Id, points); err != nil {
+		return err
+	}
+	entry := models.Loyalty_Entry{
+		Customer_Id:       earned.Customer_Id,
+		Type:              loyaltyReversal,
+		Points:            -points,
+		Amount:            reversal.Amount,
+		Invoice_Id:        reversal.Invoice_Id,
+		Payment_Id:        payment.Payment_Id,
+		Reversal_Id:       reversal.Reversal_Id,
+		Reverses_Entry_Id: earned.Entry_Id,
+		Description:       "Points reversed by refund",
+		Created_By:        reversal.Approved_By,
+	}
+	if err := insertLoyaltyEntry(ctx, &entry); err != nil {
+		return err
+	}
+	return addLifetimePoints(ctx, earned.Customer_Id, -points)
+}
@@ -2,20 +2,28 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
 	"github.com/abik1221/Tewanay-Engineering_Intership/models"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var menuCollection = database.OpenCollection(database.Client, "menu")
 
+type activeMenu struct {
+	models.Menu
+	Foods []pricedFood `json:"foods"`
+}
+
 // @Summary      Get a menu by ID
 // @Description  Fetch a single menu by its unique ID
 // @Tags         menus
@@ -68,6 +76,65 @@ func GetMenus() gin.HandlerFunc {
 	}
 }
 
+// @Summary      List menus being served now
+// @Description  Retrieve the menus that can be ordered from at this moment in the branch timezone, each with its foods at their current prices
+// @Tags         menus
+// @Produce      json
+// @Param        branch_id  query  string  false  "Branch ID"
+// @Param        at         query  string  false  "Moment to check instead of now (RFC3339)"
+// @Success      200  {array}   object  "Menus with their foods"
+// @Failure      400  {object}  object  "Invalid time"
+// @Failure      500  {object}  object  "Internal server error"
+// @Router       /menus/active [get]
+func GetActiveMenus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		pricer, err := foodPricerFor(ctx, c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at must be an RFC3339 time"})
+			return
+		}
+		cursor, err := menuCollection.Find(ctx, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var menus []models.Menu
+		if err = cursor.All(ctx, &menus); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		active := []activeMenu{}
+		for _, menu := range menus {
+			if !helpers.MenuAvailable(menu, pricer.at) {
+				continue
+			}
+			cursor, err := foodCollection.Find(ctx, bson.M{"menu_id": menu.Menu_Id})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			var foods []models.Food
+			if err = cursor.All(ctx, &foods); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			entry := activeMenu{Menu: menu, Foods: []pricedFood{}}
+			for _, food := range foods {
+				if food.Availability == helpers.Hidden {
+					continue
+				}
+				entry.Foods = append(entry.Foods, pricer.price(food))
+			}
+			active = append(active, entry)
+		}
+		c.JSON(http.StatusOK, active)
+	}
+}
+
 // @Summary      Create a new menu
 // @Description  Add a new menu to the database
 // @Tags         menus
@@ -96,10 +163,17 @@ func CreateMenu() gin.HandlerFunc {
 			})
 			return
 		}
+		if !inTimeSpan(menu.Start_Date, menu.End_Date, time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Start date must be before end date and the end date must be in the future",
+			})
+			return
+		}
 		menu.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		menu.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		menu.ID = primitive.NewObjectID()
 		menu.Menu_Id = menu.ID.Hex()
+		menu.Version = 1
 
 		sucess, err := menuCollection.InsertOne(ctx, menu)
 
@@ -109,20 +183,25 @@ func CreateMenu() gin.HandlerFunc {
 			})
 			return
 		}
+		invalidateSearchIndex()
+		if _, err := insertMenuVersion(ctx, menu, nil, helpers.VersionCreated, "", c.GetString("user_id")); err != nil {
+			logVersionError("menu", menu.Menu_Id, err)
+		}
 		c.JSON(http.StatusOK, sucess)
 
 	}
 }
 
 // @Summary      Update a menu
-// @Description  Modify an existing menu by ID (partial updates supported)
+// @Description  Modify an existing menu by ID (partial updates supported). Every change is recorded as a new version of the menu.
 // @Tags         menus
 // @Accept       json
 // @Produce      json
 // @Param        menu_id  path  string       true  "Menu ID to update"
 // @Param        request  body  models.Menu  true  "Fields to update (all optional)"
-// @Success      200  {object}  object  "MongoDB update result"
+// @Success      200  {object}  models.Menu
 // @Failure      400  {object}  object  "Invalid date range or input"
+// @Failure      404  {object}  object  "Menu not found"
 // @Failure      500  {object}  object  "Error updating menu"
 // @Router       /menus/{menu_id} [patch]
 func UpdateMenu() gin.HandlerFunc {
@@ -148,7 +227,7 @@ func UpdateMenu() gin.HandlerFunc {
 
 			if !inTimeSpan(menu.Start_Date, menu.End_Date, time.Now()) {
 				c.JSON(http.StatusBadRequest, gin.H{
-					"error": "Start date must be before end date and both must be in the future",
+					"error": "Start date must be before end date and the end date must be in the future",
 				})
 				return
 			}
@@ -166,36 +245,72 @@ func UpdateMenu() gin.HandlerFunc {
 			UpdateObj = append(UpdateObj, bson.E{Key: "catagory", Value: menu.Catagory})
 		}
 
+		if menu.Display_Order != nil {
+			UpdateObj = append(UpdateObj, bson.E{Key: "display_order", Value: menu.Display_Order})
+		}
+
+		if menu.Service_Windows != nil || menu.Exceptions != nil {
+			if err := validateMenuSchedule(menu); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+		}
+		if menu.Service_Windows != nil {
+			UpdateObj = append(UpdateObj, bson.E{Key: "service_windows", Value: menu.Service_Windows})
+		}
+		if menu.Exceptions != nil {
+			UpdateObj = append(UpdateObj, bson.E{Key: "exceptions", Value: menu.Exceptions})
+		}
+
 		menu.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		UpdateObj = append(UpdateObj, bson.E{Key: "updated_at", Value: menu.Updated_At})
 
-		upsert := true
-
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+		if err := baselineMenu(ctx, menu_id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error recording version"})
+			return
 		}
 
-		result, err := menuCollection.UpdateOne(
+		opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+		var updated models.Menu
+		err := menuCollection.FindOneAndUpdate(
 			ctx,
 			filter,
 			bson.D{
 				{"$set", UpdateObj},
+				{"$inc", bson.D{{"version", 1}}},
 			},
-			&opt,
-		)
+			opt,
+		).Decode(&updated)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found"})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		c.JSON(http.StatusOK, result)
+		invalidateSearchIndex()
+		foods, err := menuFoods(ctx, menu_id)
+		if err == nil {
+			_, err = insertMenuVersion(ctx, updated, foods, helpers.VersionUpdated, "", c.GetString("user_id"))
+		}
+		if err != nil {
+			logVersionError("menu", menu_id, err)
+		}
+		c.JSON(http.StatusOK, updated)
 
 	}
 }
 
+// inTimeSpan reports whether start to end is a valid range that has not
+// already finished at now.
 func inTimeSpan(start, end, now time.Time) bool {
-	return start.After(time.Now()) && end.After(start)
+	return end.After(start) && end.After(now)
 }
 
 // @Summary      Delete a menu
@@ -214,16 +329,53 @@ func DeleteMenu() gin.HandlerFunc {
 		defer cancel()
 		menu_id := c.Param("menu_id")
 		filter := bson.M{"menu_id": menu_id}
-		result, err := menuCollection.DeleteOne(ctx, filter)
-		if err != nil {
-			log.Println("Error deleting menu:", err)
+		if err := baselineMenu(ctx, menu_id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error recording version"})
 			return
 		}
-		if result.DeletedCount == 0 {
+		var menu models.Menu
+		err := menuCollection.FindOneAndDelete(ctx, filter).Decode(&menu)
+		if err == mongo.ErrNoDocuments {
 			log.Println("No menu found with the given ID")
 			return
 		}
+		if err != nil {
+			log.Println("Error deleting menu:", err)
+			return
+		}
+		menu.Version++
+		invalidateSearchIndex()
+		if _, err := insertMenuVersion(ctx, menu, nil, helpers.VersionDeleted, "", c.GetString("user_id")); err != nil {
+			logVersionError("menu", menu_id, err)
+		}
 		log.Println("Menu deleted successfully")
 		c.JSON(http.StatusOK, gin.H{"message": "Menu deleted successfully"})
 	}
 }
+
+func validateMenuSchedule(menu models.Menu) error {
+	for _, window := range menu.Service_Windows {
+		if err := validate.Struct(window); err != nil {
+			return err
+		}
+	}
+	for _, exception := range menu.Exceptions {
+		if err := validate.Struct(exception); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// menuServing returns an error unless the menu can be ordered from at t,
+// which must already be in the branch's timezone.
+func menuServing(ctx context.Context, menuId string, t time.Time) error {
+	var menu models.Menu
+	if err := menuCollection.FindOne(ctx, bson.M{"menu_id": menuId}).Decode(&menu); err != nil {
+		return fmt.Errorf("menu %s not found", menuId)
+	}
+	if !helpers.MenuAvailable(menu, t) {
+		return fmt.Errorf("menu %q is not being served right now", menu.Name)
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// unorderedPosition sorts entries without a display order after the rest.
+const unorderedPosition = 1 << 30
+
+// menuWithFoods is a menu as returned by the $lookup pipeline.
+type menuWithFoods struct {
+	models.Menu `bson:",inline"`
+	Foods       []foodWithModifiers `bson:"foods"`
+}
+
+type foodWithModifiers struct {
+	models.Food `bson:",inline"`
+	Modifiers   []models.Modifier_Group `bson:"modifiers"`
+}
+
+type menuTree struct {
+	models.Menu
+	Serving_Now bool         `json:"serving_now"`
+	Foods       []pricedFood `json:"foods"`
+}
+
+type menuCategory struct {
+	Name  string     `json:"name"`
+	Menus []menuTree `json:"menus"`
+}
+
+// GetFullMenus godoc
+// @Summary Get the whole menu tree
+// @Description Retrieve every menu grouped by category, each with its foods at their current prices and their modifier groups, all in display order. Send the returned ETag in If-None-Match to get 304 Not Modified while nothing has changed.
+// @Tags menus
+// @Produce json
+// @Param branch_id query string false "Branch ID"
+// @Param at query string false "Moment to price at instead of now (RFC3339)"
+// @Param If-None-Match header string false "ETag from an earlier response"
+// @Success 200 {object} object "Categories with their menus and foods"
+// @Success 304 "Not modified"
+// @Failure 400 {object} object "Invalid time"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /menus/full [get]
+func GetFullMenus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		pricer, err := foodPricerFor(ctx, c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at must be an RFC3339 time"})
+			return
+		}
+		menus, err := menusWithFoods(ctx, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		categories := []menuCategory{}
+		index := map[string]int{}
+		for _, menu := range menus {
+			i, ok := index[menu.Catagory]
+			if !ok {
+				i = len(categories)
+				index[menu.Catagory] = i
+				categories = append(categories, menuCategory{Name: menu.Catagory, Menus: []menuTree{}})
+			}
+			categories[i].Menus = append(categories[i].Menus, buildMenuTree(menu, pricer))
+		}
+		respondWithETag(c, gin.H{"categories": categories})
+	}
+}
+
+// GetFullMenu godoc
+// @Summary Get one menu with its foods
+// @Description Retrieve a menu with its foods at their current prices and their modifier groups, in display order. Send the returned ETag in If-None-Match to get 304 Not Modified while nothing has changed.
+// @Tags menus
+// @Produce json
+// @Param menu_id path string true "Menu ID"
+// @Param branch_id query string false "Branch ID"
+// @Param at query string false "Moment to price at instead of now (RFC3339)"
+// @Param If-None-Match header string false "ETag from an earlier response"
+// @Success 200 {object} object "Menu with its foods"
+// @Success 304 "Not modified"
+// @Failure 400 {object} object "Invalid time"
+// @Failure 404 {object} object "Menu not found"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /menus/{menu_id}/full [get]
+func GetFullMenu() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		pricer, err := foodPricerFor(ctx, c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at must be an RFC3339 time"})
+			return
+		}
+		menus, err := menusWithFoods(ctx, bson.M{"menu_id": c.Param("menu_id")})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(menus) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found"})
+			return
+		}
+		respondWithETag(c, buildMenuTree(menus[0], pricer))
+	}
+}
+
+// ExportMenus godoc
+// @Summary Export the menu for menu boards
+// @Description Download every dish with its category, menu, current price, portion, nutrition, allergens and dietary tags, in display order. Unknown nutrition values are left blank.
+// @Tags menus
+// @Produce text/csv
+// @Produce json
+// @Param format query string false "csv or json (default csv)"
+// @Param branch_id query string false "Branch ID"
+// @Param at query string false "Moment to price at instead of now (RFC3339)"
+// @Success 200 {file} file "Menu export"
+// @Failure 400 {object} object "Invalid format or time"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /menus/export [get]
+func ExportMenus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		format := c.DefaultQuery("format", "csv")
+		if format != "csv" && format != "json" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+			return
+		}
+		pricer, err := foodPricerFor(ctx, c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at must be an RFC3339 time"})
+			return
+		}
+		menus, err := menusWithFoods(ctx, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows := []helpers.MenuBoardRow{}
+		for _, menu := range menus {
+			for _, food := range menu.Foods {
+				rows = append(rows, helpers.MenuBoardRow{
+					Category:     menu.Catagory,
+					Menu:         menu.Name,
+					Food_Id:      *food.Food_Id,
+					Food:         food.Food_Name,
+					Price:        pricer.price(food.Food).Effective_Price,
+					Portion:      food.Portion,
+					Nutrition:    food.Nutrition,
+					Allergens:    food.Allergens,
+					Dietary_Tags: food.Dietary_Tags,
+				})
+			}
+		}
+		if format == "json" {
+			c.JSON(http.StatusOK, rows)
+			return
+		}
+		file, err := helpers.MenuBoardCSV(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="menu.csv"`)
+		c.Data(http.StatusOK, "text/csv", file)
+	}
+}
+
+// menusWithFoods joins each matching menu with its foods, and each food with
+// its modifier groups, in one aggregation. All three levels are sorted by
+// display order and then by name.
+func menusWithFoods(ctx context.Context, match bson.M) ([]menuWithFoods, error) {
+	orderFirst := bson.D{{Key: "$addFields", Value: bson.D{
+		{Key: "position", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$display_order", unorderedPosition}}}},
+	}}}
+	cursor, err := menuCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "food"},
+			{Key: "let", Value: bson.D{{Key: "menu_id", Value: "$menu_id"}}},
+			{Key: "pipeline", Value: bson.A{
+				bson.D{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{
+					{Key: "$eq", Value: bson.A{"$menu_id", "$$menu_id"}},
+				}}}}},
+				orderFirst,
+				bson.D{{Key: "$sort", Value: bson.D{{Key: "position", Value: 1}, {Key: "food_name", Value: 1}}}},
+				bson.D{{Key: "$lookup", Value: bson.D{
+					{Key: "from", Value: "modifier_groups"},
+					{Key: "let", Value: bson.D{{Key: "groups", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$modifier_groups", bson.A{}}}}}}},
+					{Key: "pipeline", Value: bson.A{
+						bson.D{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{
+							{Key: "$in", Value: bson.A{"$modifier_group_id", "$$groups"}},
+						}}}}},
+						orderFirst,
+						bson.D{{Key: "$sort", Value: bson.D{{Key: "position", Value: 1}, {Key: "name", Value: 1}}}},
+					}},
+					{Key: "as", Value: "modifiers"},
+				}}},
+			}},
+			{Key: "as", Value: "foods"},
+		}}},
+		orderFirst,
+		{{Key: "$sort", Value: bson.D{{Key: "position", Value: 1}, {Key: "name", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var menus []menuWithFoods
+	if err = cursor.All(ctx, &menus); err != nil {
+		return nil, err
+	}
+	return menus, nil
+}
+
+func buildMenuTree(menu menuWithFoods, pricer *foodPricer) menuTree {
+	tree := menuTree{
+		Menu:        menu.Menu,
+		Serving_Now: helpers.MenuAvailable(menu.Menu, pricer.at),
+		Foods:       []pricedFood{},
+	}
+	for _, food := range menu.Foods {
+		if food.Availability == helpers.Hidden {
+			continue
+		}
+		priced := pricer.price(food.Food)
+		priced.Modifiers = food.Modifiers
+		tree.Foods = append(tree.Foods, priced)
+	}
+	return tree
+}
+
+// respondWithETag writes body as JSON with an ETag, or 304 Not Modified when
+// the client already holds the same version.
+func respondWithETag(c *gin.Context, body interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	etag := helpers.ETag(data)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	if helpers.ETagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
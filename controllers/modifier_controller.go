@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var modifierGroupCollection = database.OpenCollection(database.Client, "modifier_groups")
+
+// GetModifierGroups godoc
+// @Summary List modifier groups
+// @Description Retrieve the sizes, add-ons and removals foods can offer
+// @Tags modifiers
+// @Produce json
+// @Success 200 {array} models.Modifier_Group
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /modifier_groups [get]
+func GetModifierGroups() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		cursor, err := modifierGroupCollection.Find(ctx, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		groups := []models.Modifier_Group{}
+		if err = cursor.All(ctx, &groups); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, groups)
+	}
+}
+
+// GetModifierGroup godoc
+// @Summary Get a modifier group
+// @Tags modifiers
+// @Produce json
+// @Param modifier_group_id path string true "Modifier group ID"
+// @Success 200 {object} models.Modifier_Group
+// @Failure 404 {object} object "Modifier group not found"
+// @Router /modifier_groups/{modifier_group_id} [get]
+func GetModifierGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var group models.Modifier_Group
+		if err := modifierGroupCollection.FindOne(ctx, bson.M{"modifier_group_id": c.Param("modifier_group_id")}).Decode(&group); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Modifier group not found"})
+			return
+		}
+		c.JSON(http.StatusOK, group)
+	}
+}
+
+// CreateModifierGroup godoc
+// @Summary Create a modifier group
+// @Description Add a SIZE, ADD_ON or REMOVAL group with its options and price deltas. Attach it to foods through their modifier_groups.
+// @Tags modifiers
+// @Accept json
+// @Produce json
+// @Param group body models.Modifier_Group true "Modifier group"
+// @Success 200 {object} models.Modifier_Group
+// @Failure 400 {object} object "Invalid input"
+// @Failure 500 {object} object "Error creating modifier group"
+// @Router /modifier_groups [post]
+func CreateModifierGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var group models.Modifier_Group
+		if err := c.BindJSON(&group); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkModifierGroup(&group); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		group.ID = primitive.NewObjectID()
+		group.Modifier_Group_Id = group.ID.Hex()
+		group.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		group.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if _, err := modifierGroupCollection.InsertOne(ctx, group); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating modifier group"})
+			return
+		}
+		c.JSON(http.StatusOK, group)
+	}
+}
+
+// UpdateModifierGroup godoc
+// @Summary Update a modifier group
+// @Description Replace a modifier group. Options keep their option_id when it is sent back; new options get one. Items already ordered keep the names and prices they were ordered with.
+// @Tags modifiers
+// @Accept json
+// @Produce json
+// @Param modifier_group_id path string true "Modifier group ID"
+// @Param group body models.Modifier_Group true "Modifier group"
+// @Success 200 {object} models.Modifier_Group
+// @Failure 400 {object} object "Invalid input"
+// @Failure 404 {object} object "Modifier group not found"
+// @Failure 500 {object} object "Error updating modifier group"
+// @Router /modifier_groups/{modifier_group_id} [put]
+func UpdateModifierGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var group models.Modifier_Group
+		if err := c.BindJSON(&group); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkModifierGroup(&group); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		group.Modifier_Group_Id = c.Param("modifier_group_id")
+		group.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := modifierGroupCollection.UpdateOne(ctx, bson.M{"modifier_group_id": group.Modifier_Group_Id}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "name", Value: group.Name},
+				{Key: "type", Value: group.Type},
+				{Key: "required", Value: group.Required},
+				{Key: "min_select", Value: group.Min_Select},
+				{Key: "max_select", Value: group.Max_Select},
+				{Key: "options", Value: group.Options},
+				{Key: "display_order", Value: group.Display_Order},
+				{Key: "updated_at", Value: group.Updated_At},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating modifier group"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Modifier group not found"})
+			return
+		}
+		if foodIds, err := foodsUsingRecipes(ctx, []models.Recipe{{Modifier_Group_Id: group.Modifier_Group_Id}}); err == nil {
+			refreshFoodCosts(ctx, helpers.CostFoodPrice, foodIds)
+		}
+		c.JSON(http.StatusOK, group)
+	}
+}
+
+// DeleteModifierGroup godoc
+// @Summary Delete a modifier group
+// @Description Remove a modifier group that no food offers any more
+// @Tags modifiers
+// @Produce json
+// @Param modifier_group_id path string true "Modifier group ID"
+// @Success 200 {object} object "message: Modifier group deleted"
+// @Failure 404 {object} object "Modifier group not found"
+// @Failure 409 {object} object "Modifier group is still offered by foods"
+// @Failure 500 {object} object "Error deleting modifier group"
+// @Router /modifier_groups/{modifier_group_id} [delete]
+func DeleteModifierGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		groupId := c.Param("modifier_group_id")
+		count, err := foodCollection.CountDocuments(ctx, bson.M{"modifier_groups": groupId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting modifier group"})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Modifier group is still offered by %d food(s)", count)})
+			return
+		}
+		result, err := modifierGroupCollection.DeleteOne(ctx, bson.M{"modifier_group_id": groupId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting modifier group"})
+			return
+		}
+		if result.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Modifier group not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Modifier group deleted"})
+	}
+}
+
+// checkModifierGroup validates a group and gives new options their ids.
+func checkModifierGroup(group *models.Modifier_Group) error {
+	if err := validate.Struct(group); err != nil {
+		return err
+	}
+	if err := helpers.CheckModifierGroup(*group); err != nil {
+		return err
+	}
+	for i := range group.Options {
+		if group.Options[i].Option_Id == "" {
+			group.Options[i].Option_Id = primitive.NewObjectID().Hex()
+		}
+		group.Options[i].Price_Delta = toFixed(group.Options[i].Price_Delta, 2)
+	}
+	return nil
+}
+
+// modifierGroupsExist returns an error naming the first id with no group.
+func modifierGroupsExist(ctx context.Context, groupIds []string) error {
+	for _, groupId := range groupIds {
+		count, err := modifierGroupCollection.CountDocuments(ctx, bson.M{"modifier_group_id": groupId})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("modifier group %s not found", groupId)
+		}
+	}
+	return nil
+}
+
+// foodModifierGroups loads the groups a food offers in the food's order.
+func foodModifierGroups(ctx context.Context, food models.Food) ([]models.Modifier_Group, error) {
+	if len(food.Modifier_Groups) == 0 {
+		return nil, nil
+	}
+	cursor, err := modifierGroupCollection.Find(ctx, bson.M{"modifier_group_id": bson.M{"$in": food.Modifier_Groups}})
+	if err != nil {
+		return nil, err
+	}
+	var found []models.Modifier_Group
+	if err = cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	var groups []models.Modifier_Group
+	for _, groupId := range food.Modifier_Groups {
+		for _, group := range found {
+			if group.Modifier_Group_Id == groupId {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups, nil
+}
+
+// resolveItemModifiers validates the item's chosen options against the food
+// and replaces them with full copies carrying names and prices. Options that
+// are sold out or hidden are rejected.
+func resolveItemModifiers(ctx context.Context, item *models.Ordered_Item, food models.Food) error {
+	groups, err := foodModifierGroups(ctx, food)
+	if err != nil {
+		return err
+	}
+	resolved, _, err := helpers.ResolveModifiers(groups, item.Modifiers)
+	if err != nil {
+		return fmt.Errorf("%s: %w", food.Food_Name, err)
+	}
+	now := time.Now()
+	for _, choice := range resolved {
+		for _, group := range groups {
+			if group.Modifier_Group_Id != choice.Modifier_Group_Id {
+				continue
+			}
+			option, _ := helpers.ModifierOption(group, choice.Option_Id)
+			if status, _ := helpers.CurrentAvailability(option.Availability, option.Sold_Out_Until, now); status != helpers.Available {
+				return fmt.Errorf("%s: %s is not available", food.Food_Name, option.Name)
+			}
+		}
+	}
+	item.Modifiers = resolved
+	return nil
+}
@@ -2,11 +2,14 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
 	"github.com/abik1221/Tewanay-Engineering_Intership/models"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -63,11 +66,12 @@ func GetOrder() gin.HandlerFunc {
 		defer cancel()
 		order_id := c.Param("order_id")
 		var order models.Order
-		err := menuCollection.FindOne(ctx, bson.M{"menu_id": order_id}).Decode(&order)
+		err := orderCollection.FindOne(ctx, bson.M{"order_id": order_id}).Decode(&order)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
+			c.JSON(http.StatusNotFound, gin.H{
 				"error": "Order Not found",
 			})
+			return
 		}
 		c.JSON(http.StatusOK, order)
 	}
@@ -78,26 +82,27 @@ func GetOrder() gin.HandlerFunc {
 // @Tags         orders
 // @Accept       json
 // @Produce      json
-// @Param        request  body  models.Order  true  "Order data (must include table_id)"
-// @Success      200  {object}  object  "MongoDB insert result"
-// @Failure      400  {object}  object  "Invalid input or missing table_id"
+// @Param        request  body  orderRequest  true  "Order data (must include table_id), optional order_items and print_tickets"
+// @Success      200  {object}  object  "Inserted order ID, created order items and queued print jobs"
+// @Failure      400  {object}  object  "Invalid input, missing table_id or unknown food"
 // @Failure      404  {object}  object  "Table not found"
 // @Failure      500  {object}  object  "Error creating order"
 // @Router       /orders [post]
 func CreateOrder() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var order models.Order
+		var request orderRequest
 		var table models.Table
 
 		// to create an order related to the table we need to check if the table exists
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		if err := c.BindJSON(&order); err != nil {
+		if err := c.BindJSON(&request); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
+		order := request.Order
 
 		if validationErr := validate.Struct(order); validationErr != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -120,21 +125,191 @@ func CreateOrder() gin.HandlerFunc {
 			})
 			return
 		}
+		if order.Customer_Id != "" {
+			if exists, err := customerExists(ctx, order.Customer_Id); err != nil || !exists {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "Customer not found",
+				})
+				return
+			}
+		}
 		order.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		order.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		order.ID = primitive.NewObjectID()
 		order.Order_Id = order.ID.Hex()
 
+		items, err := prepareOrderItems(ctx, order, branchForTable(ctx, table), request.Order_Items)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if err := applyOrderPromotions(ctx, &order, items); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		// Claim the promotions first so the last use of a limited code is
+		// not promised to two orders.
+		if err := recordPromotionRedemptions(ctx, order); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, errPromotionUsedUp) {
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
 		sucess, err := orderCollection.InsertOne(ctx, order)
 
 		if err != nil {
+			releasePromotionRedemptions(ctx, order.Order_Id)
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		c.JSON(http.StatusOK, sucess)
+
+		if len(items) > 0 {
+			docs := make([]interface{}, len(items))
+			for i, item := range items {
+				docs[i] = item
+			}
+			if _, err := orderItemCollection.InsertMany(ctx, docs); err != nil {
+				releasePromotionRedemptions(ctx, order.Order_Id)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+		}
+		response := gin.H{
+			"InsertedID":     sucess.InsertedID,
+			"order_id":       order.Order_Id,
+			"order_items":    items,
+			"discounts":      order.Discounts,
+			"discount_total": order.Discount_Total,
+		}
+		if warnings := allergyWarnings(ctx, order.Allergy_Note, items); len(warnings) > 0 {
+			response["allergy_warnings"] = warnings
+		}
+		if len(items) > 0 && (request.Print_Tickets || autoPrintKitchenTickets()) {
+			// A printer problem must not lose the order; report it instead.
+			jobs, err := printKitchenTickets(ctx, order, items)
+			response["print_jobs"] = jobs
+			if err != nil {
+				response["print_error"] = err.Error()
+			}
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// orderRequest is the body accepted by CreateOrder: the order plus the line
+// items to create with it.
+type orderRequest struct {
+	models.Order
+	Order_Items   []models.Ordered_Item `json:"order_items"`
+	Print_Tickets bool                  `json:"print_tickets"`
+}
+
+// allergyWarning flags an order item that conflicts with the order's
+// allergy note.
+type allergyWarning struct {
+	Order_Item_Id string   `json:"order_item_id"`
+	Food_Id       string   `json:"food_id"`
+	Food_Name     string   `json:"food_name"`
+	Conflicts     []string `json:"conflicts"`
+}
+
+// allergyWarnings checks every dish in items against the allergens and
+// diets named in note. Combos are checked through their dishes.
+func allergyWarnings(ctx context.Context, note string, items []models.Ordered_Item) []allergyWarning {
+	avoid, required := helpers.NoteRestrictions(note)
+	if len(avoid) == 0 && len(required) == 0 {
+		return nil
+	}
+	var warnings []allergyWarning
+	for _, item := range items {
+		if item.Combo_Id != "" || item.Gift_Card_Id != "" {
+			continue
+		}
+		var food models.Food
+		if err := foodCollection.FindOne(ctx, bson.M{"food_id": item.Food_Id}).Decode(&food); err != nil {
+			continue
+		}
+		if conflicts := helpers.AllergyConflicts(food, item.Modifiers, avoid, required); len(conflicts) > 0 {
+			warnings = append(warnings, allergyWarning{
+				Order_Item_Id: item.Order_Item_Id,
+				Food_Id:       item.Food_Id,
+				Food_Name:     food.Food_Name,
+				Conflicts:     conflicts,
+			})
+		}
 	}
+	return warnings
+}
+
+// prepareOrderItems checks each requested item against its food and fills in
+// the menu, price and IDs. Prices always come from the food and the branch's
+// price rules at the time of ordering, never the client.
+func prepareOrderItems(ctx context.Context, order models.Order, branch models.Branch, requested []models.Ordered_Item) ([]models.Ordered_Item, error) {
+	var items []models.Ordered_Item
+	if len(requested) == 0 {
+		return items, nil
+	}
+	pricer, err := newFoodPricer(ctx, branch, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	serving := map[string]bool{}
+	for _, item := range requested {
+		var food models.Food
+		if item.Combo_Id != "" {
+			if item.Quantity <= 0 {
+				return nil, fmt.Errorf("quantity for combo %s must be at least 1", item.Combo_Id)
+			}
+			combo, err := prepareComboItems(ctx, order, item, pricer, serving)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, combo...)
+			continue
+		}
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("quantity for food %s must be at least 1", item.Food_Id)
+		}
+		if err := validate.StructPartial(item, "Note"); err != nil {
+			return nil, err
+		}
+		if err := foodCollection.FindOne(ctx, bson.M{"food_id": item.Food_Id}).Decode(&food); err != nil {
+			return nil, fmt.Errorf("food %s not found", item.Food_Id)
+		}
+		if err := foodOrderable(food, pricer.at); err != nil {
+			return nil, err
+		}
+		if err := menuServingOnce(ctx, serving, *food.Menu_Id, pricer.at); err != nil {
+			return nil, err
+		}
+		item.ID = primitive.NewObjectID()
+		item.Order_Item_Id = item.ID.Hex()
+		item.Order_Id = order.Order_Id
+		item.Menu_Id = *food.Menu_Id
+		if err := resolveItemModifiers(ctx, &item, food); err != nil {
+			return nil, err
+		}
+		pricer.priceItem(&item, food)
+		item.Nutrition = helpers.LineNutrition(food, item.Modifiers, item.Quantity)
+		item.Created_At = order.Created_At
+		item.Updated_At = order.Updated_At
+		items = append(items, item)
+	}
+	return items, nil
 }
 
 // @Summary      Create a new order
@@ -163,8 +338,42 @@ func UpdateOrder() gin.HandlerFunc {
 		}
 		var UpdateObj primitive.D
 		if order.Order_Status != "" {
+			// Voiding reverses stock, invoices and gift cards, so it only
+			// happens through the void flow, and a voided order stays voided.
+			if order.Order_Status == "VOIDED" {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "Orders are voided through POST /orders/{order_id}/void",
+				})
+				return
+			}
+			var current models.Order
+			if err := orderCollection.FindOne(ctx, bson.M{"order_id": order_Id}).Decode(&current); err == nil && current.Order_Status == "VOIDED" {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "Order is voided and its status can no longer change",
+				})
+				return
+			}
 			UpdateObj = append(UpdateObj, bson.E{Key: "order_status", Value: order.Order_Status})
 		}
+		if order.Allergy_Note != "" {
+			if err := validate.StructPartial(order, "Allergy_Note"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			UpdateObj = append(UpdateObj, bson.E{Key: "allergy_note", Value: order.Allergy_Note})
+		}
+		if order.Promo_Codes != nil || order.Customer_Id != "" {
+			discountObj, err := reapplyOrderPromotions(ctx, order_Id, order)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			UpdateObj = append(UpdateObj, discountObj...)
+		}
 		UpdateObj = append(UpdateObj, bson.E{Key: "updated_at", Value: time.Now()})
 
 		upsert := true
@@ -197,14 +406,89 @@ func UpdateOrder() gin.HandlerFunc {
 	}
 }
 
+// refreshOrderPromotions re-applies an order's promotions after its items
+// changed and stores the new discounts on the order.
+func refreshOrderPromotions(ctx context.Context, orderId string) error {
+	discountObj, err := reapplyOrderPromotions(ctx, orderId, models.Order{})
+	if err != nil {
+		return err
+	}
+	_, err = orderCollection.UpdateOne(ctx, bson.M{"order_id": orderId}, bson.D{
+		{Key: "$set", Value: append(discountObj, bson.E{Key: "updated_at", Value: time.Now()})},
+	})
+	return err
+}
+
+// reapplyOrderPromotions recalculates the discounts of a stored order after
+// its promo codes, customer or items changed, moves the redemptions over and
+// copies the new discounts and resulting taxes onto the order's invoices.
+// It returns the order fields to set.
+func reapplyOrderPromotions(ctx context.Context, orderId string, changes models.Order) (primitive.D, error) {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return nil, fmt.Errorf("order not found")
+	}
+	if order.Order_Status == "VOIDED" {
+		return nil, fmt.Errorf("order is voided")
+	}
+	if err := ensureOrderPeriodOpen(ctx, orderId); err != nil {
+		return nil, err
+	}
+	if changes.Promo_Codes != nil {
+		order.Promo_Codes = changes.Promo_Codes
+	}
+	if changes.Customer_Id != "" {
+		if exists, err := customerExists(ctx, changes.Customer_Id); err != nil || !exists {
+			return nil, fmt.Errorf("customer not found")
+		}
+		order.Customer_Id = changes.Customer_Id
+	}
+
+	cursor, err := orderItemCollection.Find(ctx, bson.M{"order_id": orderId})
+	if err != nil {
+		return nil, err
+	}
+	var items []models.Ordered_Item
+	if err = cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	if err := applyOrderPromotions(ctx, &order, items); err != nil {
+		return nil, err
+	}
+	if err := recordPromotionRedemptions(ctx, order); err != nil {
+		return nil, err
+	}
+
+	charges, branch, err := orderCharges(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	_, err = invoiceCollection.UpdateMany(ctx, bson.M{"order_id": orderId}, bson.D{
+		{Key: "$set", Value: append(bson.D{
+			{Key: "discounts", Value: order.Discounts},
+			{Key: "discount_total", Value: order.Discount_Total},
+		}, invoiceChargeFields(charges, branch)...)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return primitive.D{
+		{Key: "customer_id", Value: order.Customer_Id},
+		{Key: "promo_codes", Value: order.Promo_Codes},
+		{Key: "discounts", Value: order.Discounts},
+		{Key: "discount_total", Value: order.Discount_Total},
+	}, nil
+}
+
 // @Summary      Delete an order
-// @Description  Remove an order by ID
+// @Description  Remove an order by ID. Orders already sent to the kitchen or invoiced cannot be deleted; void them instead.
 // @Tags         orders
 // @Accept       json
 // @Produce      json
 // @Param        order_id  path  string  true  "Order ID to delete"
 // @Success      200  {object}  object  "MongoDB delete result"
 // @Failure      404  {object}  object  "Order not found"
+// @Failure      409  {object}  object  "Order is fired or invoiced, or its period is locked"
 // @Failure      500  {object}  object  "Error deleting order"
 // @Router       /orders/{order_id} [delete]
 func DeleteOrder() gin.HandlerFunc {
@@ -212,6 +496,33 @@ func DeleteOrder() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 		order_Id := c.Param("order_id")
+		if err := ensureOrderPeriodOpen(ctx, order_Id); err != nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		fired, err := orderItemCollection.CountDocuments(ctx, bson.M{"order_id": order_Id, "fired_at": bson.M{"$exists": true}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Error deleting order",
+			})
+			return
+		}
+		invoiced, err := invoiceCollection.CountDocuments(ctx, bson.M{"order_id": order_Id})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Error deleting order",
+			})
+			return
+		}
+		if fired > 0 || invoiced > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Order has been sent to the kitchen or invoiced; void it instead",
+			})
+			return
+		}
+
 		filter := bson.M{"order_id": order_Id}
 		result, err := orderCollection.DeleteOne(ctx, filter)
 		if err != nil {
@@ -226,6 +537,9 @@ func DeleteOrder() gin.HandlerFunc {
 			})
 			return
 		}
+		if err := releasePromotionRedemptions(ctx, order_Id); err != nil {
+			log.Println("Error releasing promotions of deleted order", order_Id, ":", err)
+		}
 		c.JSON(http.StatusOK, result)
 	}
 }
@@ -2,10 +2,12 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
 	"github.com/abik1221/Tewanay-Engineering_Intership/models"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -95,13 +97,15 @@ func GetOrderItemsByOrderId() gin.HandlerFunc {
 }
 
 // @Summary      Create a new order item
-// @Description  Add a new order item to the database
+// @Description  Add a new order item to the database. The price is the food's price in effect now, after the branch's price rules. The order's promotions are re-applied.
 // @Tags         order-items
 // @Accept       json
 // @Produce      json
-// @Param        request  body  models.Ordered_Item  true  "Order item data"
+// @Param        request  body  models.Ordered_Item  true  "Order item data; a combo_id with combo_selections adds a combo"
 // @Success      200  {object}  object  "MongoDB insert result"
-// @Failure      400  {object}  object  "Invalid input"
+// @Failure      400  {object}  object  "Invalid input, unknown food, invalid combo selection or a promotion that no longer applies"
+// @Failure      404  {object}  object  "Order not found"
+// @Failure      409  {object}  object  "Order's accounting period is closed"
 // @Failure      500  {object}  object  "Error creating order item"
 // @Router       /order-items [post]
 func CreateOrderItem() gin.HandlerFunc {
@@ -113,8 +117,77 @@ func CreateOrderItem() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 			return
 		}
+		var order models.Order
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderItem.Order_Id}).Decode(&order); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		if err := ensureOrderPeriodOpen(ctx, order.Order_Id); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		pricer, err := newFoodPricer(ctx, branchForOrder(ctx, order), time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if orderItem.Combo_Id != "" {
+			if orderItem.Quantity <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be at least 1"})
+				return
+			}
+			// The new items are stamped with the time they were added.
+			addedTo := order
+			addedTo.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+			addedTo.Updated_At = addedTo.Created_At
+			items, err := prepareComboItems(ctx, addedTo, orderItem, pricer, map[string]bool{})
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			docs := make([]interface{}, len(items))
+			for i, item := range items {
+				docs[i] = item
+			}
+			result, err := orderItemCollection.InsertMany(ctx, docs)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating order item"})
+				return
+			}
+			if err := refreshOrderPromotions(ctx, order.Order_Id); err != nil {
+				orderItemCollection.DeleteMany(ctx, bson.M{"order_item_id": bson.M{"$in": orderItemIds(items)}})
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"InsertedIDs": result.InsertedIDs, "order_items": items})
+			return
+		}
+		if err := validate.StructPartial(orderItem, "Note"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var food models.Food
+		if err := foodCollection.FindOne(ctx, bson.M{"food_id": orderItem.Food_Id}).Decode(&food); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Food not found"})
+			return
+		}
+		if err := foodOrderable(food, pricer.at); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := menuServing(ctx, *food.Menu_Id, pricer.at); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		orderItem.Menu_Id = *food.Menu_Id
+		if err := resolveItemModifiers(ctx, &orderItem, food); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		pricer.priceItem(&orderItem, food)
+		orderItem.Nutrition = helpers.LineNutrition(food, orderItem.Modifiers, orderItem.Quantity)
 		orderItem.ID = primitive.NewObjectID()
-		orderItem.Order_Id = orderItem.ID.Hex()
+		orderItem.Order_Item_Id = orderItem.ID.Hex()
 		orderItem.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		orderItem.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		result, err := orderItemCollection.InsertOne(ctx, orderItem)
@@ -122,57 +195,156 @@ func CreateOrderItem() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating order item"})
 			return
 		}
+		if err := refreshOrderPromotions(ctx, order.Order_Id); err != nil {
+			orderItemCollection.DeleteOne(ctx, bson.M{"order_item_id": orderItem.Order_Item_Id})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusOK, result)
 	}
 }
 
+// orderItemUpdate is what can be changed on an order item once it is on an
+// order. Everything else is set by the server.
+type orderItemUpdate struct {
+	Quantity *int    `json:"quantity" validate:"omitempty,min=1"`
+	Note     *string `json:"note" validate:"omitempty,max=200"`
+}
+
 // @Summary      Update an order item
-// @Description  Modify an existing order item
+// @Description  Change the quantity or kitchen note of an order item. A new quantity re-prices the item at the food's price in effect now and re-applies the order's promotions. Quantities of combos, gift cards and items already sent to the kitchen cannot be changed.
 // @Tags         order-items
 // @Accept       json
 // @Produce      json
-// @Param        order_item_id  path  string                true  "Order Item ID"
-// @Param        request        body  models.Ordered_Item  true  "Order item data"
-// @Success      200  {object}  object  "MongoDB update result"
-// @Failure      400  {object}  object  "Invalid input"
+// @Param        order_item_id  path  string           true  "Order Item ID"
+// @Param        request        body  orderItemUpdate  true  "Quantity and/or note"
+// @Success      200  {object}  models.Ordered_Item
+// @Failure      400  {object}  object  "Invalid input, item cannot be changed, or food no longer orderable"
 // @Failure      404  {object}  object  "Order item not found"
+// @Failure      409  {object}  object  "Order's accounting period is closed"
 // @Failure      500  {object}  object  "Error updating order item"
-// @Router       /order-items/{order_item_id} [put]
+// @Router       /order_items/{order_item_id} [patch]
 func UpdateOrderItem() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		var orderItem models.Ordered_Item
+		var request orderItemUpdate
 		orderItemId := c.Param("order_item_id")
-		if err := c.ShouldBindJSON(&orderItem); err != nil {
+		if err := c.ShouldBindJSON(&request); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 			return
 		}
-		orderItem.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		filter := bson.M{"order_item_id": orderItemId}
-		update := bson.M{"$set": orderItem}
-		result, err := orderItemCollection.UpdateOne(ctx, filter, update)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating order item"})
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		if result.MatchedCount == 0 {
+		var existing models.Ordered_Item
+		if err := orderItemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&existing); err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"message": "Order item not found"})
 			return
 		}
-		c.JSON(http.StatusOK, result)
+		if err := ensureOrderPeriodOpen(ctx, existing.Order_Id); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		orderItem := existing
+		if request.Note != nil {
+			orderItem.Note = *request.Note
+		}
+		requantified := request.Quantity != nil && *request.Quantity != existing.Quantity
+		if requantified {
+			switch {
+			case existing.Combo_Id != "" || existing.Parent_Item_Id != "":
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Combo quantities cannot be changed; delete the combo and add it again"})
+				return
+			case existing.Gift_Card_Id != "":
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Gift card quantities cannot be changed"})
+				return
+			case !existing.Fired_At.IsZero():
+				c.JSON(http.StatusBadRequest, gin.H{"error": "This item has already been sent to the kitchen"})
+				return
+			}
+			orderItem.Quantity = *request.Quantity
+			if err := repriceOrderItem(ctx, &orderItem); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		orderItem.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if err := setOrderItemFields(ctx, orderItem); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating order item"})
+			return
+		}
+		if requantified {
+			if err := refreshOrderPromotions(ctx, orderItem.Order_Id); err != nil {
+				setOrderItemFields(ctx, existing)
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, orderItem)
 	}
 }
 
+// repriceOrderItem checks the item's food can still be ordered and prices it
+// and its nutrition for its quantity, as prepareOrderItems does for new items.
+func repriceOrderItem(ctx context.Context, item *models.Ordered_Item) error {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": item.Order_Id}).Decode(&order); err != nil {
+		return fmt.Errorf("order not found")
+	}
+	var food models.Food
+	if err := foodCollection.FindOne(ctx, bson.M{"food_id": item.Food_Id}).Decode(&food); err != nil {
+		return fmt.Errorf("food %s not found", item.Food_Id)
+	}
+	pricer, err := newFoodPricer(ctx, branchForOrder(ctx, order), time.Now())
+	if err != nil {
+		return err
+	}
+	if err := foodOrderable(food, pricer.at); err != nil {
+		return err
+	}
+	if err := menuServing(ctx, *food.Menu_Id, pricer.at); err != nil {
+		return err
+	}
+	if err := resolveItemModifiers(ctx, item, food); err != nil {
+		return err
+	}
+	pricer.priceItem(item, food)
+	item.Nutrition = helpers.LineNutrition(food, item.Modifiers, item.Quantity)
+	return nil
+}
+
+// setOrderItemFields stores the fields an update can change, leaving links
+// to combos, gift cards and the kitchen alone.
+func setOrderItemFields(ctx context.Context, item models.Ordered_Item) error {
+	_, err := orderItemCollection.UpdateOne(ctx, bson.M{"order_item_id": item.Order_Item_Id}, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "quantity", Value: item.Quantity},
+			{Key: "note", Value: item.Note},
+			{Key: "price", Value: item.Price},
+			{Key: "base_price", Value: item.Base_Price},
+			{Key: "price_rule_id", Value: item.Price_Rule_Id},
+			{Key: "modifiers", Value: item.Modifiers},
+			{Key: "nutrition", Value: item.Nutrition},
+			{Key: "updated_at", Value: item.Updated_At},
+		}},
+	})
+	return err
+}
 
 // @Summary      Delete an order item
-// @Description  Remove an order item by ID
+// @Description  Remove an order item by ID. Deleting a combo removes its component items; components cannot be deleted on their own. The order's promotions are re-applied, and stock taken for items already sent to the kitchen is put back with VOID movements.
 // @Tags         order-items
 // @Accept       json
 // @Produce      json
 // @Param        order_item_id  path  string  true  "Order Item ID"
 // @Success      200  {object}  object  "message: Order item deleted successfully"
+// @Failure      400  {object}  object  "Part of a combo, or a promotion that no longer applies"
 // @Failure      404  {object}  object  "Order item not found"
+// @Failure      409  {object}  object  "Order's accounting period is closed"
 // @Failure      500  {object}  object  "Error deleting order item"
 // @Router       /order-items/{order_item_id} [delete]
 func DeleteOrderItem() gin.HandlerFunc {
@@ -180,6 +352,28 @@ func DeleteOrderItem() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 		orderItemId := c.Param("order_item_id")
+		var existing models.Ordered_Item
+		if err := orderItemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&existing); err == nil && existing.Parent_Item_Id != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This item is part of a combo; delete the combo instead"})
+			return
+		}
+		if existing.Order_Id != "" {
+			if err := ensureOrderPeriodOpen(ctx, existing.Order_Id); err != nil {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		var components []models.Ordered_Item
+		if existing.Combo_Id != "" {
+			cursor, err := orderItemCollection.Find(ctx, bson.M{"parent_item_id": orderItemId})
+			if err == nil {
+				err = cursor.All(ctx, &components)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting order item"})
+				return
+			}
+		}
 		filter := bson.M{"order_item_id": orderItemId}
 		result, err := orderItemCollection.DeleteOne(ctx, filter)
 		if err != nil {
@@ -190,10 +384,40 @@ func DeleteOrderItem() gin.HandlerFunc {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Order item not found"})
 			return
 		}
+		if existing.Combo_Id != "" {
+			if _, err := orderItemCollection.DeleteMany(ctx, bson.M{"parent_item_id": orderItemId}); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting order item"})
+				return
+			}
+		}
+		if err := refreshOrderPromotions(ctx, existing.Order_Id); err != nil {
+			// Put the items back rather than leave the discounts stale.
+			docs := []interface{}{existing}
+			for _, component := range components {
+				docs = append(docs, component)
+			}
+			orderItemCollection.InsertMany(ctx, docs)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// Whatever the kitchen already took for the items goes back into stock.
+		deleted := append([]models.Ordered_Item{existing}, components...)
+		if err := restoreOrderItemStock(ctx, existing.Order_Id, orderItemIds(deleted)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error restoring stock"})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"message": "Order item deleted successfully"})
 	}
 }
 
+func orderItemIds(items []models.Ordered_Item) []string {
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.Order_Item_Id
+	}
+	return ids
+}
+
 func itemsByOrder(id string) (OrederItems []primitive.M, err error) {
 	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
@@ -210,3 +434,40 @@ func itemsByOrder(id string) (OrederItems []primitive.M, err error) {
 	}
 	return orederItems, nil
 }
+
+// orderTotal sums price * quantity over every item recorded for the order.
+func orderTotal(ctx context.Context, orderId string) (float64, error) {
+	matchStage := bson.D{{Key: "$match", Value: bson.D{{Key: "order_id", Value: orderId}}}}
+	groupStage := bson.D{{Key: "$group", Value: bson.D{
+		{Key: "_id", Value: "$order_id"},
+		{Key: "total", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$multiply", Value: bson.A{"$price", "$quantity"}}}}}},
+	}}}
+	result, err := orderItemCollection.Aggregate(ctx, mongo.Pipeline{matchStage, groupStage})
+	if err != nil {
+		return 0, err
+	}
+	var totals []struct {
+		Total float64 `bson:"total"`
+	}
+	if err = result.All(ctx, &totals); err != nil {
+		return 0, err
+	}
+	if len(totals) == 0 {
+		return 0, nil
+	}
+	return toFixed(totals[0].Total, 2), nil
+}
+
+// orderAmountDue is what the customer owes for the order: the items less
+// discounts, plus service charges and taxes.
+func orderAmountDue(ctx context.Context, orderId string) (float64, error) {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return orderTotal(ctx, orderId)
+	}
+	charges, _, err := orderCharges(ctx, order)
+	if err != nil {
+		return 0, err
+	}
+	return charges.Total, nil
+}
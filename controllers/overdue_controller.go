@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// settledPaymentStatuses are the invoice statuses that can never be overdue.
+// Refunded invoices are not among them: a refund can leave a balance due, so
+// whether they are overdue is decided on the balance.
+var settledPaymentStatuses = []string{"PAID", "VOID"}
+
+type escalationStage struct {
+	Days int
+	Name string
+}
+
+var defaultEscalationNames = []string{"REMINDER", "SECOND_REMINDER", "FINAL_NOTICE", "COLLECTIONS"}
+
+// escalationStages returns the reminder stages. OVERDUE_ESCALATION_DAYS holds
+// a comma-separated list of days past due, one per stage, e.g. "1,15,30,60".
+func escalationStages() []escalationStage {
+	days := []int{1, 15, 30, 60}
+	if value := os.Getenv("OVERDUE_ESCALATION_DAYS"); value != "" {
+		var parsed []int
+		for _, part := range strings.Split(value, ",") {
+			day, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || day < 0 {
+				parsed = nil
+				break
+			}
+			parsed = append(parsed, day)
+		}
+		if len(parsed) > 0 {
+			days = parsed
+		}
+	}
+
+	stages := make([]escalationStage, len(days))
+	for i, day := range days {
+		name := "STAGE_" + strconv.Itoa(i+1)
+		if i < len(defaultEscalationNames) {
+			name = defaultEscalationNames[i]
+		}
+		stages[i] = escalationStage{Days: day, Name: name}
+	}
+	return stages
+}
+
+// overdueFilter matches unsettled invoices whose due date has passed.
+func overdueFilter(now time.Time) bson.M {
+	return bson.M{
+		"payment_due_date": bson.M{"$lt": now, "$gt": time.Time{}},
+		"payment_status":   bson.M{"$nin": settledPaymentStatuses},
+	}
+}
+
+func daysOverdue(invoice models.Invoice, now time.Time) int {
+	return int(now.Sub(invoice.Payment_Due_Date).Hours() / 24)
+}
+
+// invoiceBalance is the amount due on the order less the net payments on the invoice.
+func invoiceBalance(ctx context.Context, invoice models.Invoice) (float64, error) {
+	total, err := orderAmountDue(ctx, invoice.Order_Id)
+	if err != nil {
+		return 0, err
+	}
+	payments, err := paymentsByInvoice(ctx, invoice.Invoice_Id)
+	if err != nil {
+		return 0, err
+	}
+	for _, payment := range payments {
+		total -= *payment.Amount - payment.Refunded_Amount
+	}
+	return toFixed(total, 2), nil
+}
+
+// RunOverdueInvoiceCheck flags overdue invoices and sends a reminder each
+// time an invoice reaches a new escalation stage. It returns the number of
+// reminders sent.
+func RunOverdueInvoiceCheck(ctx context.Context, notifier helpers.Notifier, now time.Time) (int, error) {
+	// Invoices paid since the last run are no longer overdue.
+	_, err := invoiceCollection.UpdateMany(ctx,
+		bson.M{"overdue": true, "payment_status": bson.M{"$in": settledPaymentStatuses}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "overdue", Value: false}}}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	cursor, err := invoiceCollection.Find(ctx, overdueFilter(now))
+	if err != nil {
+		return 0, err
+	}
+	var invoices []models.Invoice
+	if err = cursor.All(ctx, &invoices); err != nil {
+		return 0, err
+	}
+
+	stages := escalationStages()
+	sent := 0
+	for _, invoice := range invoices {
+		balance, err := invoiceBalance(ctx, invoice)
+		if err != nil {
+			return sent, err
+		}
+		if balance <= 0 {
+			if invoice.Overdue {
+				if _, err := invoiceCollection.UpdateOne(ctx, bson.M{"invoice_id": invoice.Invoice_Id}, bson.D{
+					{Key: "$set", Value: bson.D{{Key: "overdue", Value: false}}},
+				}); err != nil {
+					return sent, err
+				}
+			}
+			continue
+		}
+
+		days := daysOverdue(invoice, now)
+		stage := 0
+		for i, s := range stages {
+			if days >= s.Days {
+				stage = i + 1
+			}
+		}
+
+		update := bson.D{{Key: "overdue", Value: true}}
+		if stage > invoice.Overdue_Stage {
+			reminder := helpers.Reminder{
+				Invoice_Id:    invoice.Invoice_Id,
+				Account_Name:  invoice.Account_Name,
+				Contact_Email: invoice.Contact_Email,
+				Contact_Phone: invoice.Contact_Phone,
+				Stage:         stage,
+				Stage_Name:    stages[stage-1].Name,
+				Days_Overdue:  days,
+				Amount_Due:    balance,
+				Due_Date:      invoice.Payment_Due_Date,
+			}
+			if err := notifier.Notify(ctx, reminder); err != nil {
+				// Leave the stage unchanged so the next run tries again.
+				log.Println("Error sending reminder for invoice", invoice.Invoice_Id, ":", err)
+			} else {
+				update = append(update, bson.E{Key: "overdue_stage", Value: stage}, bson.E{Key: "last_reminder_at", Value: now})
+				recordAudit(ctx, "INVOICE_REMINDER_SENT", "invoice", invoice.Invoice_Id, "", balance, reminder.Stage_Name)
+				sent++
+			}
+		}
+
+		if _, err := invoiceCollection.UpdateOne(ctx, bson.M{"invoice_id": invoice.Invoice_Id}, bson.D{{Key: "$set", Value: update}}); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// StartOverdueInvoiceJob runs RunOverdueInvoiceCheck now and then every
+// interval in the background.
+func StartOverdueInvoiceJob(interval time.Duration, notifier helpers.Notifier) {
+	run := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		if _, err := RunOverdueInvoiceCheck(ctx, notifier, time.Now()); err != nil {
+			log.Println("Error checking overdue invoices:", err)
+		}
+	}
+	go func() {
+		run()
+		for range time.Tick(interval) {
+			run()
+		}
+	}()
+}
+
+// CheckOverdueInvoices godoc
+// @Summary Run the overdue invoice check now
+// @Description Flag overdue invoices and send any due reminders without waiting for the background job
+// @Tags invoices
+// @Produce json
+// @Success 200 {object} object "reminders_sent"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /invoices/overdue/check [post]
+func CheckOverdueInvoices() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		sent, err := RunOverdueInvoiceCheck(ctx, helpers.NotifierFromEnv(), time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"reminders_sent": sent})
+	}
+}
+
+type agingInvoice struct {
+	Invoice_Id   string    `json:"invoice_id"`
+	Account_Name string    `json:"account_name,omitempty"`
+	Due_Date     time.Time `json:"due_date"`
+	Days_Overdue int       `json:"days_overdue"`
+	Amount_Due   float64   `json:"amount_due"`
+}
+
+type agingBucket struct {
+	Bucket     string         `json:"bucket"`
+	Count      int            `json:"count"`
+	Amount_Due float64        `json:"amount_due"`
+	Invoices   []agingInvoice `json:"invoices"`
+}
+
+// GetInvoiceAging godoc
+// @Summary Accounts receivable aging report
+// @Description Outstanding overdue invoices grouped into 0-30, 31-60, 61-90 and 90+ days past due
+// @Tags invoices
+// @Produce json
+// @Success 200 {object} object "Aging buckets with totals"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /invoices/aging [get]
+func GetInvoiceAging() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		now := time.Now()
+		cursor, err := invoiceCollection.Find(ctx, overdueFilter(now))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var invoices []models.Invoice
+		if err = cursor.All(ctx, &invoices); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		buckets := []agingBucket{{Bucket: "0-30"}, {Bucket: "31-60"}, {Bucket: "61-90"}, {Bucket: "90+"}}
+		var totalDue float64
+		for _, invoice := range invoices {
+			balance, err := invoiceBalance(ctx, invoice)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if balance <= 0 {
+				continue
+			}
+			days := daysOverdue(invoice, now)
+			index := 3
+			switch {
+			case days <= 30:
+				index = 0
+			case days <= 60:
+				index = 1
+			case days <= 90:
+				index = 2
+			}
+			bucket := &buckets[index]
+			bucket.Count++
+			bucket.Amount_Due = toFixed(bucket.Amount_Due+balance, 2)
+			bucket.Invoices = append(bucket.Invoices, agingInvoice{
+				Invoice_Id:   invoice.Invoice_Id,
+				Account_Name: invoice.Account_Name,
+				Due_Date:     invoice.Payment_Due_Date,
+				Days_Overdue: days,
+				Amount_Due:   balance,
+			})
+			totalDue += balance
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"as_of":     now,
+			"buckets":   buckets,
+			"total_due": toFixed(totalDue, 2),
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var paymentCollection = database.OpenCollection(database.Client, "payments")
+
+// GetInvoicePayments godoc
+// @Summary List payments for an invoice
+// @Description Retrieve every payment recorded against an invoice
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param invoice_id path string true "Invoice ID"
+// @Success 200 {array} models.Payment
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /invoices/{invoice_id}/payments [get]
+func GetInvoicePayments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		payments, err := paymentsByInvoice(ctx, c.Param("invoice_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, payments)
+	}
+}
+
+// CreatePayment godoc
+// @Summary Record a payment
+// @Description Record a payment against an invoice and refresh the invoice payment status. The payment is attached to the cashier's open shift, if any. LOYALTY_POINTS payments spend the order customer's points and GIFT_CARD payments draw on the card in gift_card_code.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param invoice_id path string true "Invoice ID"
+// @Param payment body models.Payment true "Payment data"
+// @Success 200 {object} models.Payment
+// @Failure 400 {object} object "Invalid input"
+// @Failure 404 {object} object "Invoice not found"
+// @Failure 409 {object} object "Accounting period is locked"
+// @Failure 500 {object} object "Error recording payment"
+// @Router /invoices/{invoice_id}/payments [post]
+func CreatePayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var payment models.Payment
+		var invoice models.Invoice
+		invoiceId := c.Param("invoice_id")
+
+		if err := c.BindJSON(&payment); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(payment); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return
+		}
+		if invoice.Payment_Status != nil && *invoice.Payment_Status == "VOID" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot record a payment against a voided invoice"})
+			return
+		}
+		if err := ensurePeriodOpen(ctx, invoice.Branch_Id, time.Now()); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		amount := toFixed(*payment.Amount, 2)
+		payment.Amount = &amount
+		payment.Loyalty_Points = 0
+		if *payment.Payment_Method == "LOYALTY_POINTS" {
+			if err := redeemPointsForPayment(ctx, invoice, &payment); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if *payment.Payment_Method == "GIFT_CARD" {
+			if err := checkGiftCardPayment(ctx, &payment); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		} else {
+			payment.Gift_Card_Code = ""
+		}
+		payment.ID = primitive.NewObjectID()
+		payment.Payment_Id = payment.ID.Hex()
+		payment.Invoice_Id = invoiceId
+		payment.Refunded_Amount = 0
+		payment.Recorded_By = c.GetString("user_id")
+		if shift, err := openShiftFor(ctx, payment.Recorded_By); err == nil {
+			payment.Shift_Id = shift.Shift_Id
+		}
+		payment.Tip_Amount = toFixed(payment.Tip_Amount, 2)
+		payment.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		payment.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if _, err := paymentCollection.InsertOne(ctx, payment); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error recording payment"})
+			return
+		}
+		if payment.Loyalty_Points > 0 {
+			if err := spendPaymentPoints(ctx, invoice, payment); err != nil {
+				paymentCollection.DeleteOne(ctx, bson.M{"payment_id": payment.Payment_Id})
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if payment.Gift_Card_Code != "" {
+			if err := redeemGiftCardPayment(ctx, payment); err != nil {
+				paymentCollection.DeleteOne(ctx, bson.M{"payment_id": payment.Payment_Id})
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if err := refreshInvoicePaymentStatus(ctx, invoice); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAudit(ctx, "PAYMENT_RECORDED", "payment", payment.Payment_Id, payment.Recorded_By, amount, "invoice "+invoiceId)
+		c.JSON(http.StatusOK, payment)
+	}
+}
+
+func paymentsByInvoice(ctx context.Context, invoiceId string) ([]models.Payment, error) {
+	cursor, err := paymentCollection.Find(ctx, bson.M{"invoice_id": invoiceId})
+	if err != nil {
+		return nil, err
+	}
+	var payments []models.Payment
+	if err = cursor.All(ctx, &payments); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// refreshInvoicePaymentStatus derives the invoice payment status from the
+// payments and refunds recorded against it and the amount due on the order,
+// refreshing the invoice's tax snapshot on the way.
+func refreshInvoicePaymentStatus(ctx context.Context, invoice models.Invoice) error {
+	payments, err := paymentsByInvoice(ctx, invoice.Invoice_Id)
+	if err != nil {
+		return err
+	}
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": invoice.Order_Id}).Decode(&order); err != nil {
+		return errors.New("order not found for invoice")
+	}
+	charges, branch, err := orderCharges(ctx, order)
+	if err != nil {
+		return err
+	}
+	total := charges.Total
+
+	var paid, refunded float64
+	for _, payment := range payments {
+		paid += *payment.Amount
+		refunded += payment.Refunded_Amount
+	}
+	paid = toFixed(paid, 2)
+	refunded = toFixed(refunded, 2)
+
+	// A refund of part of a fully paid invoice is a partial refund; below the
+	// total the invoice is simply partly paid.
+	var status string
+	switch {
+	case refunded > 0 && paid-refunded <= 0:
+		status = "REFUNDED"
+	case refunded > 0 && paid >= total:
+		status = "PARTIALLY_REFUNDED"
+	case paid > 0 && paid >= total:
+		status = "PAID"
+	case paid > 0:
+		status = "PARTIALLY_PAID"
+	default:
+		return nil
+	}
+
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	update := append(bson.D{
+		{Key: "payment_status", Value: status},
+		{Key: "updated_at", Value: updatedAt},
+	}, invoiceChargeFields(charges, branch)...)
+	if toFixed(paid-refunded, 2) >= total {
+		update = append(update, bson.E{Key: "overdue", Value: false})
+	}
+	_, err = invoiceCollection.UpdateOne(ctx, bson.M{"invoice_id": invoice.Invoice_Id}, bson.D{
+		{Key: "$set", Value: update},
+	})
+	if err != nil {
+		return err
+	}
+	if status == "PAID" {
+		if err := fulfilGiftCards(ctx, invoice); err != nil {
+			return err
+		}
+		return earnInvoicePoints(ctx, invoice, payments)
+	}
+	return nil
+}
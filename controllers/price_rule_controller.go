@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var priceRuleCollection = database.OpenCollection(database.Client, "price_rules")
+
+// pricedFood is a food with the price in effect at the requested time.
+type pricedFood struct {
+	models.Food
+	Effective_Price float64                 `json:"effective_price"`
+	Price_Rule_Id   string                  `json:"price_rule_id,omitempty"`
+	Price_Rule_Name string                  `json:"price_rule_name,omitempty"`
+	Modifiers       []models.Modifier_Group `json:"modifiers,omitempty"`
+}
+
+// GetPriceRules godoc
+// @Summary List price rules
+// @Description Retrieve the scheduled price rules, optionally for one branch or food
+// @Tags price-rules
+// @Produce json
+// @Param branch_id query string false "Branch ID"
+// @Param food_id query string false "Food ID"
+// @Success 200 {array} models.Price_Rule
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /price_rules [get]
+func GetPriceRules() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if branchId := c.Query("branch_id"); branchId != "" {
+			filter["branch_id"] = bson.M{"$in": bson.A{branchId, "", nil}}
+		}
+		if foodId := c.Query("food_id"); foodId != "" {
+			filter["$or"] = bson.A{
+				bson.M{"food_ids": foodId},
+				bson.M{"food_ids": bson.M{"$size": 0}},
+				bson.M{"food_ids": nil},
+			}
+		}
+		cursor, err := priceRuleCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var rules []models.Price_Rule
+		if err = cursor.All(ctx, &rules); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, rules)
+	}
+}
+
+// CreatePriceRule godoc
+// @Summary Create a price rule
+// @Description Add a scheduled price change (percentage off, amount off or fixed price) for some or all foods, evaluated in the branch timezone
+// @Tags price-rules
+// @Accept json
+// @Produce json
+// @Param rule body models.Price_Rule true "Price rule"
+// @Success 200 {object} models.Price_Rule
+// @Failure 400 {object} object "Invalid input"
+// @Failure 500 {object} object "Error creating price rule"
+// @Router /price_rules [post]
+func CreatePriceRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var rule models.Price_Rule
+		if err := c.BindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(rule); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		if rule.Type == "PERCENTAGE" && rule.Value > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Percentage cannot exceed 100"})
+			return
+		}
+
+		rule.ID = primitive.NewObjectID()
+		rule.Price_Rule_Id = rule.ID.Hex()
+		rule.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		rule.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if _, err := priceRuleCollection.InsertOne(ctx, rule); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating price rule"})
+			return
+		}
+		c.JSON(http.StatusOK, rule)
+	}
+}
+
+// UpdatePriceRule godoc
+// @Summary Update a price rule
+// @Description Replace a price rule's schedule and adjustment
+// @Tags price-rules
+// @Accept json
+// @Produce json
+// @Param price_rule_id path string true "Price rule ID"
+// @Param rule body models.Price_Rule true "Price rule"
+// @Success 200 {object} object "Update result"
+// @Failure 400 {object} object "Invalid input"
+// @Failure 404 {object} object "Price rule not found"
+// @Failure 500 {object} object "Error updating price rule"
+// @Router /price_rules/{price_rule_id} [put]
+func UpdatePriceRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var rule models.Price_Rule
+		if err := c.BindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(rule); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		if rule.Type == "PERCENTAGE" && rule.Value > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Percentage cannot exceed 100"})
+			return
+		}
+
+		rule.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := priceRuleCollection.UpdateOne(ctx, bson.M{"price_rule_id": c.Param("price_rule_id")}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "name", Value: rule.Name},
+				{Key: "branch_id", Value: rule.Branch_Id},
+				{Key: "food_ids", Value: rule.Food_Ids},
+				{Key: "menu_id", Value: rule.Menu_Id},
+				{Key: "type", Value: rule.Type},
+				{Key: "value", Value: rule.Value},
+				{Key: "days_of_week", Value: rule.Days_Of_Week},
+				{Key: "start_time", Value: rule.Start_Time},
+				{Key: "end_time", Value: rule.End_Time},
+				{Key: "valid_from", Value: rule.Valid_From},
+				{Key: "valid_to", Value: rule.Valid_To},
+				{Key: "priority", Value: rule.Priority},
+				{Key: "active", Value: rule.Active},
+				{Key: "updated_at", Value: rule.Updated_At},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating price rule"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Price rule not found"})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// DeletePriceRule godoc
+// @Summary Delete a price rule
+// @Description Remove a price rule. Orders keep the prices they were placed at.
+// @Tags price-rules
+// @Produce json
+// @Param price_rule_id path string true "Price rule ID"
+// @Success 200 {object} object "message: Price rule deleted"
+// @Failure 404 {object} object "Price rule not found"
+// @Failure 500 {object} object "Error deleting price rule"
+// @Router /price_rules/{price_rule_id} [delete]
+func DeletePriceRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := priceRuleCollection.DeleteOne(ctx, bson.M{"price_rule_id": c.Param("price_rule_id")})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting price rule"})
+			return
+		}
+		if result.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Price rule not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Price rule deleted"})
+	}
+}
+
+// foodPricer prices foods for one branch at one moment, loading the active
+// rules once.
+type foodPricer struct {
+	branch models.Branch
+	at     time.Time
+	rules  []models.Price_Rule
+}
+
+func newFoodPricer(ctx context.Context, branch models.Branch, at time.Time) (*foodPricer, error) {
+	cursor, err := priceRuleCollection.Find(ctx, bson.M{"active": true})
+	if err != nil {
+		return nil, err
+	}
+	var rules []models.Price_Rule
+	if err = cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return &foodPricer{branch: branch, at: at.In(helpers.BranchLocation(branch)), rules: rules}, nil
+}
+
+func (p *foodPricer) price(food models.Food) pricedFood {
+	price, rule := helpers.EffectivePrice(food, p.rules, p.branch.Branch_Id, p.at)
+	priced := pricedFood{Food: food, Effective_Price: price}
+	priced.Availability, priced.Sold_Out_Until = helpers.CurrentAvailability(food.Availability, food.Sold_Out_Until, p.at)
+	if rule != nil {
+		priced.Price_Rule_Id = rule.Price_Rule_Id
+		priced.Price_Rule_Name = rule.Name
+	}
+	return priced
+}
+
+// priceItem captures the food's price in effect now on an order item, along
+// with the list price and the rule that changed it. The item's resolved
+// modifiers are added on top; price rules never discount them.
+func (p *foodPricer) priceItem(item *models.Ordered_Item, food models.Food) {
+	priced := p.price(food)
+	var modifiers float64
+	for _, modifier := range item.Modifiers {
+		modifiers += modifier.Price_Delta
+	}
+	item.Price = toFixed(math.Max(priced.Effective_Price+modifiers, 0), 2)
+	item.Base_Price = toFixed(math.Max(*food.Food_Price+modifiers, 0), 2)
+	item.Price_Rule_Id = priced.Price_Rule_Id
+}
+
+// foodPricerFor builds a pricer from the branch_id and at (RFC3339) query
+// parameters, defaulting to the first branch and the current time.
+func foodPricerFor(ctx context.Context, c *gin.Context) (*foodPricer, error) {
+	at := time.Now()
+	if value := c.Query("at"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, err
+		}
+		at = parsed
+	}
+	return newFoodPricer(ctx, branchForTable(ctx, models.Table{Branch_Id: c.Query("branch_id")}), at)
+}
@@ -0,0 +1,408 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var printerCollection = database.OpenCollection(database.Client, "printers")
+
+const defaultKitchenStation = "kitchen"
+const receiptStation = "receipt"
+
+// GetPrinters godoc
+// @Summary List printers
+// @Description Retrieve the printer registry, optionally filtered by branch and station
+// @Tags printing
+// @Accept json
+// @Produce json
+// @Param branch_id query string false "Branch ID"
+// @Param station query string false "Station"
+// @Success 200 {array} models.Printer
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /printers [get]
+func GetPrinters() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if branchId := c.Query("branch_id"); branchId != "" {
+			filter["branch_id"] = branchId
+		}
+		if station := c.Query("station"); station != "" {
+			filter["station"] = station
+		}
+		cursor, err := printerCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var printers []models.Printer
+		if err = cursor.All(ctx, &printers); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, printers)
+	}
+}
+
+// CreatePrinter godoc
+// @Summary Register a printer
+// @Description Add an ESC/POS network printer for a branch station. Addresses without a port use 9100.
+// @Tags printing
+// @Accept json
+// @Produce json
+// @Param printer body models.Printer true "Printer data"
+// @Success 200 {object} models.Printer
+// @Failure 400 {object} object "Invalid input"
+// @Failure 500 {object} object "Error creating printer"
+// @Router /printers [post]
+func CreatePrinter() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var printer models.Printer
+		if err := c.BindJSON(&printer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(printer); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		printer.ID = primitive.NewObjectID()
+		printer.Printer_Id = printer.ID.Hex()
+		printer.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		printer.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if _, err := printerCollection.InsertOne(ctx, printer); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating printer"})
+			return
+		}
+		c.JSON(http.StatusOK, printer)
+	}
+}
+
+// printerUpdate is what UpdatePrinter accepts. Disabled is a pointer so a
+// request that leaves it out keeps the printer as it is.
+type printerUpdate struct {
+	Name        string `json:"name"`
+	Station     string `json:"station"`
+	Address     string `json:"address"`
+	Paper_Width int    `json:"paper_width" validate:"omitempty,min=24,max=64"`
+	Disabled    *bool  `json:"disabled"`
+}
+
+// UpdatePrinter godoc
+// @Summary Update a printer
+// @Description Change a printer's name, station, address, paper width or disabled flag
+// @Tags printing
+// @Accept json
+// @Produce json
+// @Param printer_id path string true "Printer ID"
+// @Param printer body printerUpdate true "Fields to update"
+// @Success 200 {object} object "Update result"
+// @Failure 400 {object} object "Invalid input"
+// @Failure 404 {object} object "Printer not found"
+// @Failure 500 {object} object "Error updating printer"
+// @Router /printers/{printer_id} [patch]
+func UpdatePrinter() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var printer printerUpdate
+		if err := c.BindJSON(&printer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(printer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var updateObj primitive.D
+		if printer.Name != "" {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: printer.Name})
+		}
+		if printer.Station != "" {
+			updateObj = append(updateObj, bson.E{Key: "station", Value: printer.Station})
+		}
+		if printer.Address != "" {
+			updateObj = append(updateObj, bson.E{Key: "address", Value: printer.Address})
+		}
+		if printer.Paper_Width != 0 {
+			updateObj = append(updateObj, bson.E{Key: "paper_width", Value: printer.Paper_Width})
+		}
+		if printer.Disabled != nil {
+			updateObj = append(updateObj, bson.E{Key: "disabled", Value: *printer.Disabled})
+		}
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := printerCollection.UpdateOne(ctx, bson.M{"printer_id": c.Param("printer_id")}, bson.D{
+			{Key: "$set", Value: updateObj},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating printer"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Printer not found"})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// DeletePrinter godoc
+// @Summary Remove a printer
+// @Description Delete a printer from the registry
+// @Tags printing
+// @Accept json
+// @Produce json
+// @Param printer_id path string true "Printer ID"
+// @Success 200 {object} object "message: Printer deleted successfully"
+// @Failure 404 {object} object "Printer not found"
+// @Failure 500 {object} object "Error deleting printer"
+// @Router /printers/{printer_id} [delete]
+func DeletePrinter() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := printerCollection.DeleteOne(ctx, bson.M{"printer_id": c.Param("printer_id")})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting printer"})
+			return
+		}
+		if result.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Printer not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Printer deleted successfully"})
+	}
+}
+
+// GetPrintJobs godoc
+// @Summary List recent print jobs
+// @Description Show the most recent print jobs with their status and retry attempts
+// @Tags printing
+// @Produce json
+// @Success 200 {array} helpers.PrintJob
+// @Router /print_jobs [get]
+func GetPrintJobs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, helpers.Printing.Jobs())
+	}
+}
+
+// PrintInvoice godoc
+// @Summary Print an invoice receipt
+// @Description Queue the invoice on the branch receipt printer, or on printer_id when given
+// @Tags printing
+// @Produce json
+// @Param invoice_id path string true "Invoice ID"
+// @Param printer_id query string false "Printer ID"
+// @Success 202 {object} helpers.PrintJob
+// @Failure 404 {object} object "Invoice or printer not found"
+// @Failure 500 {object} object "Error rendering receipt"
+// @Router /invoices/{invoice_id}/print [post]
+func PrintInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		doc, ok := loadInvoiceDocument(ctx, c)
+		if !ok {
+			return
+		}
+
+		var printer models.Printer
+		var err error
+		if printerId := c.Query("printer_id"); printerId != "" {
+			err = printerCollection.FindOne(ctx, bson.M{"printer_id": printerId}).Decode(&printer)
+		} else {
+			printer, err = printerFor(ctx, doc.Branch.Branch_Id, receiptStation)
+		}
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No receipt printer configured"})
+			return
+		}
+
+		data, err := helpers.RenderInvoiceEscPos(doc)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		job := helpers.Printing.Enqueue(printer.Printer_Id, printer.Address, "invoice "+doc.Invoice_Id, data)
+		c.JSON(http.StatusAccepted, job)
+	}
+}
+
+// PrintOrderTickets godoc
+// @Summary Print kitchen tickets for an order
+// @Description Queue one kitchen ticket per station for the items of an order and fire the items. If a station has no printer the items are still fired and the error lists the stations, with the jobs that were queued.
+// @Tags printing
+// @Produce json
+// @Param order_id path string true "Order ID"
+// @Success 202 {array} helpers.PrintJob
+// @Failure 404 {object} object "Order not found"
+// @Failure 500 {object} object "Station without a printer, with the print_jobs queued"
+// @Router /orders/{order_id}/print [post]
+func PrintOrderTickets() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var order models.Order
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": c.Param("order_id")}).Decode(&order); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		cursor, err := orderItemCollection.Find(ctx, bson.M{"order_id": order.Order_Id})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var items []models.Ordered_Item
+		if err = cursor.All(ctx, &items); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		jobs, err := printKitchenTickets(ctx, order, items)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "print_jobs": jobs})
+			return
+		}
+		c.JSON(http.StatusAccepted, jobs)
+	}
+}
+
+// autoPrintKitchenTickets reports whether new orders print without being
+// asked to, controlled by AUTO_PRINT_KITCHEN_TICKETS.
+func autoPrintKitchenTickets() bool {
+	return os.Getenv("AUTO_PRINT_KITCHEN_TICKETS") == "true"
+}
+
+// printKitchenTickets groups the items by the station of their food and
+// queues one ticket per station on that station's printer. Stations without
+// a printer of their own fall back to the "kitchen" printer. The items are
+// then fired, taking their ingredients out of stock, even if a station had
+// no printer; that is returned as the error.
+func printKitchenTickets(ctx context.Context, order models.Order, items []models.Ordered_Item) ([]helpers.PrintJob, error) {
+	var table models.Table
+	_ = tableCollection.FindOne(ctx, bson.M{"table_id": order.Table_Id}).Decode(&table)
+	branch := branchForTable(ctx, table)
+
+	tickets := map[string]*helpers.KitchenTicket{}
+	var stations []string
+	avoid, required := helpers.NoteRestrictions(order.Allergy_Note)
+	combos := map[string]string{}
+	for _, item := range items {
+		if item.Combo_Id != "" {
+			combos[item.Order_Item_Id] = comboLineName(ctx, item.Combo_Id)
+		}
+	}
+	for _, item := range items {
+		// Gift cards are not cooked, and a combo reaches the kitchen as the
+		// dishes it is made of.
+		if item.Gift_Card_Id != "" || item.Combo_Id != "" {
+			continue
+		}
+		var food models.Food
+		name := item.Food_Id
+		station := defaultKitchenStation
+		notes := comboItemNotes(combos[item.Parent_Item_Id], item.Modifiers)
+		if item.Note != "" {
+			notes = append(notes, item.Note)
+		}
+		if err := foodCollection.FindOne(ctx, bson.M{"food_id": item.Food_Id}).Decode(&food); err == nil {
+			name = food.Food_Name
+			if food.Station != "" {
+				station = food.Station
+			}
+			if conflicts := helpers.AllergyConflicts(food, item.Modifiers, avoid, required); len(conflicts) > 0 {
+				notes = append(notes, "!! ALLERGY: "+strings.Join(conflicts, ", "))
+			}
+		}
+		ticket, ok := tickets[station]
+		if !ok {
+			ticket = &helpers.KitchenTicket{
+				Station:      station,
+				Order_Id:     order.Order_Id,
+				Table_Name:   table.Table_Name,
+				Allergy_Note: order.Allergy_Note,
+				Created_At:   time.Now(),
+			}
+			tickets[station] = ticket
+			stations = append(stations, station)
+		}
+		ticket.Items = append(ticket.Items, helpers.KitchenTicketItem{
+			Quantity: item.Quantity,
+			Name:     name,
+			Notes:    notes,
+		})
+	}
+
+	var jobs []helpers.PrintJob
+	var unprinted []string
+	for _, station := range stations {
+		printer, err := printerFor(ctx, branch.Branch_Id, station)
+		if err != nil && station != defaultKitchenStation {
+			printer, err = printerFor(ctx, branch.Branch_Id, defaultKitchenStation)
+		}
+		if err != nil {
+			unprinted = append(unprinted, station)
+			continue
+		}
+		data := helpers.RenderKitchenTicket(*tickets[station], printer.Paper_Width)
+		jobs = append(jobs, helpers.Printing.Enqueue(printer.Printer_Id, printer.Address, station+" ticket for order "+order.Order_Id, data))
+	}
+	// The items are fired even when a station could not print: the order
+	// has gone to the kitchen, and the missing ticket is reported instead.
+	var printErr error
+	if len(unprinted) > 0 {
+		printErr = errors.New("no kitchen printer configured for station " + strings.Join(unprinted, ", "))
+	}
+	if _, err := fireOrderItems(ctx, order, items); err != nil {
+		return jobs, errors.Join(printErr, err)
+	}
+	return jobs, printErr
+}
+
+// printerFor finds an enabled printer for the station of a branch.
+func printerFor(ctx context.Context, branchId, station string) (models.Printer, error) {
+	var printer models.Printer
+	filter := bson.M{"station": station, "disabled": false}
+	if branchId != "" {
+		filter["branch_id"] = branchId
+	}
+	err := printerCollection.FindOne(ctx, filter).Decode(&printer)
+	return printer, err
+}
+
+// comboItemNotes are the kitchen notes for an item: its modifiers, and the
+// combo it belongs to so the dishes can be plated together.
+func comboItemNotes(combo string, modifiers []models.Selected_Modifier) []string {
+	notes := helpers.ModifierNotes(modifiers)
+	if combo != "" {
+		notes = append(notes, "Part of "+combo)
+	}
+	return notes
+}
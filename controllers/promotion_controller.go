@@ -0,0 +1,460 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var promotionCollection = database.OpenCollection(database.Client, "promotions")
+var redemptionCollection = database.OpenCollection(database.Client, "promotion_redemptions")
+
+var errPromotionUsedUp = errors.New("has reached its usage limit")
+
+// GetPromotions godoc
+// @Summary List promotions
+// @Description Retrieve promotions, optionally only the active ones
+// @Tags promotions
+// @Produce json
+// @Param active query bool false "Only active promotions"
+// @Success 200 {array} models.Promotion
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /promotions [get]
+func GetPromotions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if c.Query("active") == "true" {
+			filter["active"] = true
+		}
+		cursor, err := promotionCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var promotions []models.Promotion
+		if err = cursor.All(ctx, &promotions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, promotions)
+	}
+}
+
+// GetPromotion godoc
+// @Summary Get a promotion
+// @Description Retrieve a promotion by ID
+// @Tags promotions
+// @Produce json
+// @Param promotion_id path string true "Promotion ID"
+// @Success 200 {object} models.Promotion
+// @Failure 404 {object} object "Promotion not found"
+// @Router /promotions/{promotion_id} [get]
+func GetPromotion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var promotion models.Promotion
+		if err := promotionCollection.FindOne(ctx, bson.M{"promotion_id": c.Param("promotion_id")}).Decode(&promotion); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+			return
+		}
+		c.JSON(http.StatusOK, promotion)
+	}
+}
+
+// CreatePromotion godoc
+// @Summary Create a promotion
+// @Description Add a percentage, fixed, buy-X-get-Y or category discount with its validity window, usage limits and stacking rule
+// @Tags promotions
+// @Accept json
+// @Produce json
+// @Param promotion body models.Promotion true "Promotion data"
+// @Success 200 {object} models.Promotion
+// @Failure 400 {object} object "Invalid input"
+// @Failure 409 {object} object "Promo code already exists"
+// @Failure 500 {object} object "Error creating promotion"
+// @Router /promotions [post]
+func CreatePromotion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var promotion models.Promotion
+		if err := c.BindJSON(&promotion); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(promotion); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		if (promotion.Type == "PERCENTAGE" || promotion.Type == "CATEGORY") && promotion.Value > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Percentage discounts cannot exceed 100"})
+			return
+		}
+
+		promotion.Code = strings.ToUpper(promotion.Code)
+		if promotion.Code != "" {
+			count, err := promotionCollection.CountDocuments(ctx, bson.M{"code": promotion.Code})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if count > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "Promo code already exists"})
+				return
+			}
+		}
+
+		promotion.ID = primitive.NewObjectID()
+		promotion.Promotion_Id = promotion.ID.Hex()
+		promotion.Times_Used = 0
+		promotion.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		promotion.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if _, err := promotionCollection.InsertOne(ctx, promotion); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating promotion"})
+			return
+		}
+		c.JSON(http.StatusOK, promotion)
+	}
+}
+
+// UpdatePromotion godoc
+// @Summary Update a promotion
+// @Description Replace a promotion's rules. The code and usage count are kept.
+// @Tags promotions
+// @Accept json
+// @Produce json
+// @Param promotion_id path string true "Promotion ID"
+// @Param promotion body models.Promotion true "Promotion data"
+// @Success 200 {object} object "Update result"
+// @Failure 400 {object} object "Invalid input"
+// @Failure 404 {object} object "Promotion not found"
+// @Failure 500 {object} object "Error updating promotion"
+// @Router /promotions/{promotion_id} [put]
+func UpdatePromotion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var promotion models.Promotion
+		if err := c.BindJSON(&promotion); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(promotion); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		promotion.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := promotionCollection.UpdateOne(ctx, bson.M{"promotion_id": c.Param("promotion_id")}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "name", Value: promotion.Name},
+				{Key: "type", Value: promotion.Type},
+				{Key: "value", Value: promotion.Value},
+				{Key: "buy_quantity", Value: promotion.Buy_Quantity},
+				{Key: "get_quantity", Value: promotion.Get_Quantity},
+				{Key: "food_ids", Value: promotion.Food_Ids},
+				{Key: "category", Value: promotion.Category},
+				{Key: "min_order_amount", Value: promotion.Min_Order_Amount},
+				{Key: "valid_from", Value: promotion.Valid_From},
+				{Key: "valid_to", Value: promotion.Valid_To},
+				{Key: "days_of_week", Value: promotion.Days_Of_Week},
+				{Key: "start_time", Value: promotion.Start_Time},
+				{Key: "end_time", Value: promotion.End_Time},
+				{Key: "max_uses", Value: promotion.Max_Uses},
+				{Key: "max_uses_per_customer", Value: promotion.Max_Uses_Per_Customer},
+				{Key: "stackable", Value: promotion.Stackable},
+				{Key: "active", Value: promotion.Active},
+				{Key: "updated_at", Value: promotion.Updated_At},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating promotion"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// DeletePromotion godoc
+// @Summary Deactivate a promotion
+// @Description Promotions are deactivated rather than deleted so past discounts keep their reference
+// @Tags promotions
+// @Produce json
+// @Param promotion_id path string true "Promotion ID"
+// @Success 200 {object} object "message: Promotion deactivated"
+// @Failure 404 {object} object "Promotion not found"
+// @Failure 500 {object} object "Error deactivating promotion"
+// @Router /promotions/{promotion_id} [delete]
+func DeletePromotion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := promotionCollection.UpdateOne(ctx, bson.M{"promotion_id": c.Param("promotion_id")}, bson.D{
+			{Key: "$set", Value: bson.D{{Key: "active", Value: false}}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deactivating promotion"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Promotion deactivated"})
+	}
+}
+
+// applyOrderPromotions works out the discounts for an order from its items
+// and promo codes and sets them on the order. Entered codes that are unknown,
+// used up or not valid right now are reported as an error.
+func applyOrderPromotions(ctx context.Context, order *models.Order, items []models.Ordered_Item) error {
+	order.Discounts = nil
+	order.Discount_Total = 0
+	for i, code := range order.Promo_Codes {
+		order.Promo_Codes[i] = strings.ToUpper(strings.TrimSpace(code))
+	}
+
+	cursor, err := promotionCollection.Find(ctx, bson.M{"active": true})
+	if err != nil {
+		return err
+	}
+	var promotions []models.Promotion
+	if err = cursor.All(ctx, &promotions); err != nil {
+		return err
+	}
+
+	now := time.Now().In(helpers.BranchLocation(branchForOrder(ctx, *order)))
+	var usable []models.Promotion
+	for _, promotion := range promotions {
+		if reason, err := promotionUnavailable(ctx, promotion, order, now); err != nil {
+			return err
+		} else if reason != "" {
+			if promotion.Code != "" && containsString(order.Promo_Codes, promotion.Code) {
+				return fmt.Errorf("promo code %s %s", promotion.Code, reason)
+			}
+			continue
+		}
+		usable = append(usable, promotion)
+	}
+	for _, code := range order.Promo_Codes {
+		found := false
+		for _, promotion := range promotions {
+			if promotion.Code == code {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("promo code %s is not valid", code)
+		}
+	}
+
+	lines, err := promotionLines(ctx, items)
+	if err != nil {
+		return err
+	}
+	order.Discounts = helpers.ApplyPromotions(usable, lines, order.Promo_Codes, now)
+	for _, discount := range order.Discounts {
+		order.Discount_Total += discount.Amount
+	}
+	order.Discount_Total = toFixed(order.Discount_Total, 2)
+	return nil
+}
+
+// promotionUnavailable returns why a promotion cannot be used on the order,
+// or "" when it can.
+func promotionUnavailable(ctx context.Context, promotion models.Promotion, order *models.Order, now time.Time) (string, error) {
+	if !helpers.PromotionInWindow(promotion, now) {
+		return "is not valid at this time", nil
+	}
+	if promotion.Max_Uses > 0 && promotion.Times_Used >= promotion.Max_Uses {
+		// A use the order already holds does not count against it.
+		own, err := redemptionCollection.CountDocuments(ctx, bson.M{"promotion_id": promotion.Promotion_Id, "order_id": order.Order_Id})
+		if err != nil {
+			return "", err
+		}
+		if promotion.Times_Used-int(own) >= promotion.Max_Uses {
+			return errPromotionUsedUp.Error(), nil
+		}
+	}
+	if promotion.Max_Uses_Per_Customer > 0 {
+		if order.Customer_Id == "" {
+			return "requires a customer on the order", nil
+		}
+		used, err := redemptionCollection.CountDocuments(ctx, bson.M{
+			"promotion_id": promotion.Promotion_Id,
+			"customer_id":  order.Customer_Id,
+			"order_id":     bson.M{"$ne": order.Order_Id},
+		})
+		if err != nil {
+			return "", err
+		}
+		if int(used) >= promotion.Max_Uses_Per_Customer {
+			return "has already been used the maximum number of times by this customer", nil
+		}
+	}
+	return "", nil
+}
+
+// promotionLines turns order items into engine lines, looking up the menu
+// category of each item.
+func promotionLines(ctx context.Context, items []models.Ordered_Item) ([]helpers.PromotionLine, error) {
+	categories := menuCategories(ctx, items)
+	var lines []helpers.PromotionLine
+	for _, item := range items {
+		// Gift card sales are stored value, not menu items, and never discounted.
+		// Combos are already sold at a set price.
+		if item.Gift_Card_Id != "" || item.Combo_Id != "" || item.Parent_Item_Id != "" {
+			continue
+		}
+		lines = append(lines, helpers.PromotionLine{
+			Food_Id:    item.Food_Id,
+			Category:   categories[item.Menu_Id],
+			Quantity:   item.Quantity,
+			Unit_Price: item.Price,
+		})
+	}
+	return lines, nil
+}
+
+// recordPromotionRedemptions stores the discounts applied to an order and
+// counts them against each promotion's usage limit. Promotions the order
+// already held keep their use; new ones are claimed with a conditional
+// increment, so two orders cannot take the last use between them, and ones
+// no longer applied are given back. When a promotion is used up nothing is
+// changed and the error wraps errPromotionUsedUp.
+func recordPromotionRedemptions(ctx context.Context, order models.Order) error {
+	cursor, err := redemptionCollection.Find(ctx, bson.M{"order_id": order.Order_Id})
+	if err != nil {
+		return err
+	}
+	var held []models.Promotion_Redemption
+	if err = cursor.All(ctx, &held); err != nil {
+		return err
+	}
+	holds := map[string]bool{}
+	for _, redemption := range held {
+		holds[redemption.Promotion_Id] = true
+	}
+	applied := map[string]bool{}
+	var claimed []string
+	for _, discount := range order.Discounts {
+		applied[discount.Promotion_Id] = true
+		if holds[discount.Promotion_Id] {
+			continue
+		}
+		if err := claimPromotionUse(ctx, discount); err != nil {
+			for _, promotionId := range claimed {
+				changePromotionUses(ctx, promotionId, -1)
+			}
+			return err
+		}
+		claimed = append(claimed, discount.Promotion_Id)
+	}
+	for promotionId := range holds {
+		if !applied[promotionId] {
+			if err := changePromotionUses(ctx, promotionId, -1); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := redemptionCollection.DeleteMany(ctx, bson.M{"order_id": order.Order_Id}); err != nil {
+		return err
+	}
+	for _, discount := range order.Discounts {
+		var redemption models.Promotion_Redemption
+		redemption.ID = primitive.NewObjectID()
+		redemption.Promotion_Id = discount.Promotion_Id
+		redemption.Order_Id = order.Order_Id
+		redemption.Customer_Id = order.Customer_Id
+		redemption.Amount = discount.Amount
+		redemption.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		if _, err := redemptionCollection.InsertOne(ctx, redemption); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// claimPromotionUse counts one use of the discount's promotion, unless that
+// would take it past max_uses.
+func claimPromotionUse(ctx context.Context, discount models.Applied_Discount) error {
+	result, err := promotionCollection.UpdateOne(ctx, bson.M{
+		"promotion_id": discount.Promotion_Id,
+		"$or": bson.A{
+			bson.M{"max_uses": bson.M{"$not": bson.M{"$gt": 0}}},
+			bson.M{"$expr": bson.M{"$lt": bson.A{bson.M{"$ifNull": bson.A{"$times_used", 0}}, "$max_uses"}}},
+		},
+	}, bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "times_used", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if discount.Code != "" {
+			return fmt.Errorf("promo code %s %w", discount.Code, errPromotionUsedUp)
+		}
+		return fmt.Errorf("promotion %s %w", discount.Name, errPromotionUsedUp)
+	}
+	return nil
+}
+
+func changePromotionUses(ctx context.Context, promotionId string, by int) error {
+	_, err := promotionCollection.UpdateOne(ctx, bson.M{"promotion_id": promotionId}, bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "times_used", Value: by}}},
+	})
+	return err
+}
+
+// releasePromotionRedemptions undoes recordPromotionRedemptions for an order
+// that could not be stored.
+func releasePromotionRedemptions(ctx context.Context, orderId string) error {
+	cursor, err := redemptionCollection.Find(ctx, bson.M{"order_id": orderId})
+	if err != nil {
+		return err
+	}
+	var redemptions []models.Promotion_Redemption
+	if err = cursor.All(ctx, &redemptions); err != nil {
+		return err
+	}
+	for _, redemption := range redemptions {
+		if err := changePromotionUses(ctx, redemption.Promotion_Id, -1); err != nil {
+			return err
+		}
+	}
+	_, err = redemptionCollection.DeleteMany(ctx, bson.M{"order_id": orderId})
+	return err
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,712 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var purchaseOrderCollection = database.OpenCollection(database.Client, "purchase_orders")
+
+// receiveRequest is the body of POST /purchase_orders/:id/receive. A line
+// without unit_cost was invoiced at the price on the purchase order.
+type receiveRequest struct {
+	Lines []struct {
+		Ingredient_Id string   `json:"ingredient_id" validate:"required"`
+		Quantity      float64  `json:"quantity" validate:"gt=0"`
+		Unit_Cost     *float64 `json:"unit_cost,omitempty" validate:"omitempty,gte=0"`
+	} `json:"lines" validate:"required,min=1,dive"`
+	Note string `json:"note,omitempty" validate:"max=300"`
+}
+
+// reorderSuggestion is an ingredient a branch is low on and how much to
+// order to bring it back to par.
+type reorderSuggestion struct {
+	Branch_Id     string  `json:"branch_id"`
+	Ingredient_Id string  `json:"ingredient_id"`
+	Name          string  `json:"name"`
+	Unit          string  `json:"unit"`
+	Supplier_Id   string  `json:"supplier_id,omitempty"`
+	On_Hand       float64 `json:"on_hand"`
+	On_Order      float64 `json:"on_order"`
+	Reorder_Point float64 `json:"reorder_point"`
+	Par_Level     float64 `json:"par_level"`
+	Quantity      float64 `json:"quantity"`
+	Unit_Cost     float64 `json:"unit_cost"`
+}
+
+// GetPurchaseOrders godoc
+// @Summary List purchase orders
+// @Description Retrieve purchase orders, newest first, optionally filtered by status, supplier or branch
+// @Tags purchasing
+// @Produce json
+// @Param status query string false "DRAFT, APPROVED, PARTIALLY_RECEIVED, RECEIVED or CANCELLED"
+// @Param supplier_id query string false "Supplier ID"
+// @Param branch_id query string false "Branch ID"
+// @Success 200 {array} models.Purchase_Order
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /purchase_orders [get]
+func GetPurchaseOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		for _, field := range []string{"status", "supplier_id", "branch_id"} {
+			if value := c.Query(field); value != "" {
+				filter[field] = value
+			}
+		}
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		cursor, err := purchaseOrderCollection.Find(ctx, filter, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		orders := []models.Purchase_Order{}
+		if err = cursor.All(ctx, &orders); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, orders)
+	}
+}
+
+// GetPurchaseOrder godoc
+// @Summary Get a purchase order
+// @Tags purchasing
+// @Produce json
+// @Param purchase_order_id path string true "Purchase order ID"
+// @Success 200 {object} models.Purchase_Order
+// @Failure 404 {object} object "Purchase order not found"
+// @Router /purchase_orders/{purchase_order_id} [get]
+func GetPurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var order models.Purchase_Order
+		if err := purchaseOrderCollection.FindOne(ctx, bson.M{"purchase_order_id": c.Param("purchase_order_id")}).Decode(&order); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Purchase order not found"})
+			return
+		}
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+// CreatePurchaseOrder godoc
+// @Summary Create a purchase order
+// @Description Draft an order of ingredients from a supplier with an expected delivery date. Lines without unit_cost use the ingredient's current cost. A manager must approve it before goods are received.
+// @Tags purchasing
+// @Accept json
+// @Produce json
+// @Param purchase_order body models.Purchase_Order true "Purchase order"
+// @Success 200 {object} models.Purchase_Order
+// @Failure 400 {object} object "Invalid input, unknown supplier, branch or ingredient"
+// @Failure 500 {object} object "Error creating purchase order"
+// @Router /purchase_orders [post]
+func CreatePurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var order models.Purchase_Order
+		if err := c.BindJSON(&order); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkPurchaseOrder(ctx, &order); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		order.ID = primitive.NewObjectID()
+		order.Purchase_Order_Id = order.ID.Hex()
+		order.Status = helpers.PurchaseDraft
+		order.Suggested = false
+		order.Receipts = nil
+		order.Approved_By = ""
+		order.Approved_At = nil
+		order.Created_By = c.GetString("user_id")
+		order.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		order.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if _, err := purchaseOrderCollection.InsertOne(ctx, order); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating purchase order"})
+			return
+		}
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+// UpdatePurchaseOrder godoc
+// @Summary Update a draft purchase order
+// @Description Replace the lines, expected date and notes of a purchase order that has not been approved yet
+// @Tags purchasing
+// @Accept json
+// @Produce json
+// @Param purchase_order_id path string true "Purchase order ID"
+// @Param purchase_order body models.Purchase_Order true "Purchase order"
+// @Success 200 {object} models.Purchase_Order
+// @Failure 400 {object} object "Invalid input, unknown supplier, branch or ingredient"
+// @Failure 404 {object} object "Purchase order not found"
+// @Failure 409 {object} object "Only draft purchase orders can be changed"
+// @Failure 500 {object} object "Error updating purchase order"
+// @Router /purchase_orders/{purchase_order_id} [put]
+func UpdatePurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var order models.Purchase_Order
+		if err := c.BindJSON(&order); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var existing models.Purchase_Order
+		if err := purchaseOrderCollection.FindOne(ctx, bson.M{"purchase_order_id": c.Param("purchase_order_id")}).Decode(&existing); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Purchase order not found"})
+			return
+		}
+		if existing.Status != helpers.PurchaseDraft {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only draft purchase orders can be changed"})
+			return
+		}
+		if err := checkPurchaseOrder(ctx, &order); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		existing.Supplier_Id = order.Supplier_Id
+		existing.Branch_Id = order.Branch_Id
+		existing.Lines = order.Lines
+		existing.Expected_At = order.Expected_At
+		existing.Total = order.Total
+		existing.Notes = order.Notes
+		existing.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := purchaseOrderCollection.UpdateOne(ctx,
+			bson.M{"purchase_order_id": existing.Purchase_Order_Id, "status": helpers.PurchaseDraft},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "supplier_id", Value: existing.Supplier_Id},
+				{Key: "branch_id", Value: existing.Branch_Id},
+				{Key: "lines", Value: existing.Lines},
+				{Key: "expected_at", Value: existing.Expected_At},
+				{Key: "total", Value: existing.Total},
+				{Key: "notes", Value: existing.Notes},
+				{Key: "updated_at", Value: existing.Updated_At},
+			}}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating purchase order"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only draft purchase orders can be changed"})
+			return
+		}
+		c.JSON(http.StatusOK, existing)
+	}
+}
+
+// ApprovePurchaseOrder godoc
+// @Summary Approve a purchase order
+// @Description Approve a draft purchase order so goods can be received against it. Requires the manager role.
+// @Tags purchasing
+// @Produce json
+// @Param purchase_order_id path string true "Purchase order ID"
+// @Success 200 {object} models.Purchase_Order
+// @Failure 403 {object} object "Manager role required"
+// @Failure 404 {object} object "Purchase order not found"
+// @Failure 409 {object} object "Only draft purchase orders can be approved"
+// @Failure 500 {object} object "Error approving purchase order"
+// @Router /purchase_orders/{purchase_order_id}/approve [post]
+func ApprovePurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		order, status, err := setPurchaseOrderStatus(ctx, c.Param("purchase_order_id"), []string{helpers.PurchaseDraft}, bson.D{
+			{Key: "status", Value: helpers.PurchaseApproved},
+			{Key: "approved_by", Value: c.GetString("user_id")},
+			{Key: "approved_at", Value: now},
+			{Key: "updated_at", Value: now},
+		})
+		if err != nil {
+			if status == http.StatusConflict {
+				err = errors.New("Only draft purchase orders can be approved")
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		recordAudit(ctx, "PURCHASE_ORDER_APPROVED", "purchase_order", order.Purchase_Order_Id, c.GetString("user_id"), order.Total, order.Supplier_Id)
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+// CancelPurchaseOrder godoc
+// @Summary Cancel a purchase order
+// @Description Cancel a purchase order whose goods have not all arrived. Goods already received stay in stock. Requires the manager role.
+// @Tags purchasing
+// @Produce json
+// @Param purchase_order_id path string true "Purchase order ID"
+// @Success 200 {object} models.Purchase_Order
+// @Failure 403 {object} object "Manager role required"
+// @Failure 404 {object} object "Purchase order not found"
+// @Failure 409 {object} object "Purchase order is already closed"
+// @Failure 500 {object} object "Error cancelling purchase order"
+// @Router /purchase_orders/{purchase_order_id}/cancel [post]
+func CancelPurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		order, status, err := setPurchaseOrderStatus(ctx, c.Param("purchase_order_id"), helpers.OpenPurchaseStatuses, bson.D{
+			{Key: "status", Value: helpers.PurchaseCancelled},
+			{Key: "updated_at", Value: now},
+		})
+		if err != nil {
+			if status == http.StatusConflict {
+				err = errors.New("Purchase order is already closed")
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		recordAudit(ctx, "PURCHASE_ORDER_CANCELLED", "purchase_order", order.Purchase_Order_Id, c.GetString("user_id"), order.Total, "")
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+// ReceivePurchaseOrder godoc
+// @Summary Receive goods against a purchase order
+// @Description Record a delivery, in full or in part. Each line received is added to the branch's stock as a RECEIPT movement and moves the ingredient's unit cost to the weighted average of stock on hand and the price received. The order is RECEIVED once every line has arrived.
+// @Tags purchasing
+// @Accept json
+// @Produce json
+// @Param purchase_order_id path string true "Purchase order ID"
+// @Param receipt body receiveRequest true "Quantities received"
+// @Success 200 {object} models.Purchase_Order
+// @Failure 400 {object} object "Invalid input, or more than is outstanding"
+// @Failure 404 {object} object "Purchase order not found"
+// @Failure 409 {object} object "Purchase order is not approved or changed meanwhile"
+// @Failure 500 {object} object "Error receiving goods"
+// @Router /purchase_orders/{purchase_order_id}/receive [post]
+func ReceivePurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request receiveRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		var order models.Purchase_Order
+		if err := purchaseOrderCollection.FindOne(ctx, bson.M{"purchase_order_id": c.Param("purchase_order_id")}).Decode(&order); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Purchase order not found"})
+			return
+		}
+		if order.Status != helpers.PurchaseApproved && order.Status != helpers.PurchasePartiallyReceived {
+			c.JSON(http.StatusConflict, gin.H{"error": "Goods can only be received against an approved purchase order"})
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		receipt := models.Goods_Receipt{
+			Receipt_Id:  primitive.NewObjectID().Hex(),
+			Note:        request.Note,
+			Received_By: c.GetString("user_id"),
+			Received_At: now,
+		}
+		value := 0.0
+		for _, received := range request.Lines {
+			index := -1
+			for i, line := range order.Lines {
+				if line.Ingredient_Id == received.Ingredient_Id {
+					index = i
+				}
+			}
+			if index < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "ingredient " + received.Ingredient_Id + " is not on this purchase order"})
+				return
+			}
+			line := &order.Lines[index]
+			quantity := helpers.RoundQuantity(received.Quantity)
+			if outstanding := helpers.RoundQuantity(line.Quantity - line.Received_Quantity); quantity > outstanding {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("only %g of ingredient %s is outstanding", outstanding, line.Ingredient_Id)})
+				return
+			}
+			unitCost := line.Unit_Cost
+			if received.Unit_Cost != nil {
+				unitCost = toFixed(*received.Unit_Cost, 4)
+			}
+			line.Received_Quantity = helpers.RoundQuantity(line.Received_Quantity + quantity)
+			receipt.Lines = append(receipt.Lines, models.Goods_Received_Line{Ingredient_Id: line.Ingredient_Id, Quantity: quantity, Unit_Cost: unitCost})
+			value += quantity * unitCost
+		}
+
+		// Claim the order as last read so two deliveries entered at once
+		// cannot both be booked against the same outstanding quantity.
+		status := helpers.ReceivedStatus(order.Lines)
+		result, err := purchaseOrderCollection.UpdateOne(ctx,
+			bson.M{"purchase_order_id": order.Purchase_Order_Id, "updated_at": order.Updated_At},
+			bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: "lines", Value: order.Lines},
+					{Key: "status", Value: status},
+					{Key: "updated_at", Value: now},
+				}},
+				{Key: "$push", Value: bson.D{{Key: "receipts", Value: receipt}}},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error receiving goods"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Purchase order changed meanwhile; reload and try again"})
+			return
+		}
+		order.Status = status
+		order.Receipts = append(order.Receipts, receipt)
+		order.Updated_At = now
+
+		for _, line := range receipt.Lines {
+			if err := updateIngredientCost(ctx, line); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating ingredient cost"})
+				return
+			}
+			_, err := postStockMovement(ctx, models.Stock_Movement{
+				Ingredient_Id:     line.Ingredient_Id,
+				Branch_Id:         order.Branch_Id,
+				Type:              helpers.MovementReceipt,
+				Quantity:          line.Quantity,
+				Purchase_Order_Id: order.Purchase_Order_Id,
+				Reason:            "received from supplier " + order.Supplier_Id,
+				Created_By:        c.GetString("user_id"),
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error receiving goods"})
+				return
+			}
+		}
+		recordAudit(ctx, "PURCHASE_ORDER_RECEIVED", "purchase_order", order.Purchase_Order_Id, c.GetString("user_id"), toFixed(value, 2), receipt.Receipt_Id)
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+// GetReorderSuggestions godoc
+// @Summary Suggest reorders
+// @Description List ingredients whose stock plus what is on order has fallen to their reorder point, with the quantity that brings them back to par
+// @Tags purchasing
+// @Produce json
+// @Param branch_id query string false "Branch ID (all branches when omitted)"
+// @Success 200 {array} reorderSuggestion
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /purchase_orders/suggestions [get]
+func GetReorderSuggestions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		suggestions, err := reorderSuggestions(ctx, c.Query("branch_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, suggestions)
+	}
+}
+
+// CreateSuggestedPurchaseOrders godoc
+// @Summary Draft purchase orders from par levels
+// @Description Run the reorder check now instead of waiting for the background job: draft one purchase order per branch and supplier for the suggested reorders. Ingredients without a supplier are left out.
+// @Tags purchasing
+// @Produce json
+// @Success 200 {array} models.Purchase_Order
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /purchase_orders/suggestions [post]
+func CreateSuggestedPurchaseOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orders, err := RunReorderCheck(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, orders)
+	}
+}
+
+// StartReorderJob runs RunReorderCheck now and then every interval in the
+// background.
+func StartReorderJob(interval time.Duration) {
+	run := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		if _, err := RunReorderCheck(ctx); err != nil {
+			log.Println("Error checking par levels:", err)
+		}
+	}
+	go func() {
+		run()
+		for range time.Tick(interval) {
+			run()
+		}
+	}()
+}
+
+// RunReorderCheck drafts purchase orders for every ingredient below its
+// reorder point. The drafts count as on order, so running it again does not
+// order the same stock twice.
+func RunReorderCheck(ctx context.Context) ([]models.Purchase_Order, error) {
+	suggestions, err := reorderSuggestions(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	type key struct{ branchId, supplierId string }
+	grouped := map[key][]reorderSuggestion{}
+	var keys []key
+	for _, suggestion := range suggestions {
+		if suggestion.Supplier_Id == "" {
+			continue
+		}
+		k := key{suggestion.Branch_Id, suggestion.Supplier_Id}
+		if _, ok := grouped[k]; !ok {
+			keys = append(keys, k)
+		}
+		grouped[k] = append(grouped[k], suggestion)
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	orders := []models.Purchase_Order{}
+	for _, k := range keys {
+		supplier, err := activeSupplier(ctx, k.supplierId)
+		if err != nil {
+			continue
+		}
+		var order models.Purchase_Order
+		order.ID = primitive.NewObjectID()
+		order.Purchase_Order_Id = order.ID.Hex()
+		order.Supplier_Id = k.supplierId
+		order.Branch_Id = k.branchId
+		order.Status = helpers.PurchaseDraft
+		order.Suggested = true
+		order.Notes = "Suggested from par levels"
+		order.Expected_At = now.AddDate(0, 0, supplier.Lead_Time_Days)
+		for _, suggestion := range grouped[k] {
+			order.Lines = append(order.Lines, models.Purchase_Order_Line{
+				Ingredient_Id: suggestion.Ingredient_Id,
+				Quantity:      suggestion.Quantity,
+				Unit_Cost:     suggestion.Unit_Cost,
+			})
+		}
+		order.Total = helpers.PurchaseOrderTotal(order.Lines)
+		order.Created_At = now
+		order.Updated_At = now
+		if _, err := purchaseOrderCollection.InsertOne(ctx, order); err != nil {
+			return orders, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+// reorderSuggestions works out what to reorder for a branch, or for every
+// branch when branchId is empty.
+func reorderSuggestions(ctx context.Context, branchId string) ([]reorderSuggestion, error) {
+	levelFilter := bson.M{"par_level": bson.M{"$gt": 0}}
+	openFilter := bson.M{"status": bson.M{"$in": helpers.OpenPurchaseStatuses}}
+	if branchId != "" {
+		levelFilter["branch_id"] = branchId
+		openFilter["branch_id"] = branchId
+	}
+	cursor, err := stockLevelCollection.Find(ctx, levelFilter)
+	if err != nil {
+		return nil, err
+	}
+	var levels []models.Stock_Level
+	if err = cursor.All(ctx, &levels); err != nil {
+		return nil, err
+	}
+	cursor, err = purchaseOrderCollection.Find(ctx, openFilter)
+	if err != nil {
+		return nil, err
+	}
+	var open []models.Purchase_Order
+	if err = cursor.All(ctx, &open); err != nil {
+		return nil, err
+	}
+	cursor, err = ingredientCollection.Find(ctx, bson.M{"active": true})
+	if err != nil {
+		return nil, err
+	}
+	var ingredients []models.Ingredient
+	if err = cursor.All(ctx, &ingredients); err != nil {
+		return nil, err
+	}
+
+	onOrder := map[string]float64{}
+	for _, order := range open {
+		for _, line := range order.Lines {
+			onOrder[order.Branch_Id+"/"+line.Ingredient_Id] += line.Quantity - line.Received_Quantity
+		}
+	}
+	byId := map[string]models.Ingredient{}
+	for _, ingredient := range ingredients {
+		byId[ingredient.Ingredient_Id] = ingredient
+	}
+
+	suggestions := []reorderSuggestion{}
+	for _, level := range levels {
+		ingredient, ok := byId[level.Ingredient_Id]
+		if !ok {
+			continue
+		}
+		ordered := helpers.RoundQuantity(onOrder[level.Branch_Id+"/"+level.Ingredient_Id])
+		quantity := helpers.ReorderQuantity(level.Quantity, ordered, level.Reorder_Point, level.Par_Level)
+		if quantity <= 0 {
+			continue
+		}
+		suggestions = append(suggestions, reorderSuggestion{
+			Branch_Id:     level.Branch_Id,
+			Ingredient_Id: level.Ingredient_Id,
+			Name:          ingredient.Name,
+			Unit:          ingredient.Unit,
+			Supplier_Id:   ingredient.Supplier_Id,
+			On_Hand:       helpers.RoundQuantity(level.Quantity),
+			On_Order:      ordered,
+			Reorder_Point: level.Reorder_Point,
+			Par_Level:     level.Par_Level,
+			Quantity:      quantity,
+			Unit_Cost:     ingredient.Unit_Cost,
+		})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Branch_Id != suggestions[j].Branch_Id {
+			return suggestions[i].Branch_Id < suggestions[j].Branch_Id
+		}
+		return suggestions[i].Name < suggestions[j].Name
+	})
+	return suggestions, nil
+}
+
+// checkPurchaseOrder validates a purchase order from a request, fills in
+// line costs from the ingredients and works out the total.
+func checkPurchaseOrder(ctx context.Context, order *models.Purchase_Order) error {
+	if err := validate.Struct(order); err != nil {
+		return err
+	}
+	if _, err := activeSupplier(ctx, order.Supplier_Id); err != nil {
+		return err
+	}
+	branchId, err := inventoryBranch(ctx, order.Branch_Id)
+	if err != nil {
+		return err
+	}
+	order.Branch_Id = branchId
+	seen := map[string]bool{}
+	for i := range order.Lines {
+		line := &order.Lines[i]
+		if seen[line.Ingredient_Id] {
+			return fmt.Errorf("ingredient %s is listed twice", line.Ingredient_Id)
+		}
+		seen[line.Ingredient_Id] = true
+		ingredient, err := activeIngredient(ctx, line.Ingredient_Id)
+		if err != nil {
+			return err
+		}
+		if line.Unit_Cost == 0 {
+			line.Unit_Cost = ingredient.Unit_Cost
+		}
+		line.Unit_Cost = toFixed(line.Unit_Cost, 4)
+		line.Quantity = helpers.RoundQuantity(line.Quantity)
+		line.Received_Quantity = 0
+	}
+	order.Total = helpers.PurchaseOrderTotal(order.Lines)
+	return nil
+}
+
+// setPurchaseOrderStatus applies update to a purchase order in one of the
+// statuses from. The status code tells a missing order from one in the
+// wrong status.
+func setPurchaseOrderStatus(ctx context.Context, purchaseOrderId string, from []string, update bson.D) (models.Purchase_Order, int, error) {
+	var order models.Purchase_Order
+	err := purchaseOrderCollection.FindOneAndUpdate(ctx,
+		bson.M{"purchase_order_id": purchaseOrderId, "status": bson.M{"$in": from}},
+		bson.D{{Key: "$set", Value: update}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&order)
+	if err == nil {
+		return order, http.StatusOK, nil
+	}
+	count, countErr := purchaseOrderCollection.CountDocuments(ctx, bson.M{"purchase_order_id": purchaseOrderId})
+	if countErr != nil {
+		return order, http.StatusInternalServerError, countErr
+	}
+	if count == 0 {
+		return order, http.StatusNotFound, errors.New("Purchase order not found")
+	}
+	return order, http.StatusConflict, err
+}
+
+// updateIngredientCost moves an ingredient's unit cost to the weighted
+// average of the stock on hand across branches and the goods received, and
+// re-costs the dishes using it.
+func updateIngredientCost(ctx context.Context, line models.Goods_Received_Line) error {
+	var ingredient models.Ingredient
+	if err := ingredientCollection.FindOne(ctx, bson.M{"ingredient_id": line.Ingredient_Id}).Decode(&ingredient); err != nil {
+		return err
+	}
+	cursor, err := stockLevelCollection.Find(ctx, bson.M{"ingredient_id": line.Ingredient_Id})
+	if err != nil {
+		return err
+	}
+	var levels []models.Stock_Level
+	if err = cursor.All(ctx, &levels); err != nil {
+		return err
+	}
+	onHand := 0.0
+	for _, level := range levels {
+		onHand += level.Quantity
+	}
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err = ingredientCollection.UpdateOne(ctx, bson.M{"ingredient_id": line.Ingredient_Id}, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "unit_cost", Value: helpers.AverageCost(onHand, ingredient.Unit_Cost, line.Quantity, line.Unit_Cost)},
+			{Key: "updated_at", Value: updatedAt},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	refreshIngredientFoodCosts(ctx, line.Ingredient_Id)
+	return nil
+}
@@ -0,0 +1,615 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var reversalCollection = database.OpenCollection(database.Client, "reversals")
+var creditNoteCollection = database.OpenCollection(database.Client, "credit_notes")
+
+const (
+	reversalPending   = "PENDING_APPROVAL"
+	reversalApplying  = "APPLYING"
+	reversalCompleted = "COMPLETED"
+	reversalRejected  = "REJECTED"
+)
+
+type refundRequest struct {
+	Payment_Id  string   `json:"payment_id" validate:"required"`
+	Amount      *float64 `json:"amount" validate:"omitempty,gt=0"`
+	Reason_Code string   `json:"reason_code" validate:"required,oneof=CUSTOMER_COMPLAINT WRONG_ORDER QUALITY_ISSUE OVERCHARGE DUPLICATE_PAYMENT OTHER"`
+	Reason      string   `json:"reason"`
+}
+
+type voidRequest struct {
+	Reason_Code string `json:"reason_code" validate:"required,oneof=CUSTOMER_CANCELLED KITCHEN_ERROR WRONG_ORDER DUPLICATE_ORDER TEST_ORDER OTHER"`
+	Reason      string `json:"reason"`
+}
+
+// approvalThreshold reads a manager-approval threshold from the environment.
+// Reversals at or above the threshold wait for a manager.
+func approvalThreshold(name string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(name), 64)
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+// CreateRefund godoc
+// @Summary Refund a payment
+// @Description Fully or partially refund a payment recorded against an invoice. A credit note is issued once the refund takes effect; refunds at or above REFUND_APPROVAL_THRESHOLD wait for manager approval.
+// @Tags reversals
+// @Accept json
+// @Produce json
+// @Param invoice_id path string true "Invoice ID"
+// @Param refund body refundRequest true "Refund data (omit amount for a full refund)"
+// @Success 200 {object} models.Reversal "Refund completed"
+// @Success 202 {object} models.Reversal "Refund awaiting manager approval"
+// @Failure 400 {object} object "Invalid input or amount exceeds refundable balance"
+// @Failure 404 {object} object "Invoice or payment not found"
+// @Failure 409 {object} object "Another refund took the balance first"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /invoices/{invoice_id}/refunds [post]
+func CreateRefund() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request refundRequest
+		var invoice models.Invoice
+		var payment models.Payment
+		invoiceId := c.Param("invoice_id")
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return
+		}
+		err := paymentCollection.FindOne(ctx, bson.M{"payment_id": request.Payment_Id, "invoice_id": invoiceId}).Decode(&payment)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found on this invoice"})
+			return
+		}
+
+		refundable := toFixed(*payment.Amount-payment.Refunded_Amount, 2)
+		amount := refundable
+		if request.Amount != nil {
+			amount = toFixed(*request.Amount, 2)
+		}
+		if amount <= 0 || amount > refundable {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":      "Refund amount exceeds the refundable balance of the payment",
+				"refundable": refundable,
+			})
+			return
+		}
+
+		var reversal models.Reversal
+		reversal.ID = primitive.NewObjectID()
+		reversal.Reversal_Id = reversal.ID.Hex()
+		reversal.Type = "REFUND"
+		reversal.Invoice_Id = invoiceId
+		reversal.Order_Id = invoice.Order_Id
+		reversal.Payment_Id = payment.Payment_Id
+		reversal.Amount = amount
+		reversal.Reason_Code = request.Reason_Code
+		reversal.Reason = request.Reason
+		reversal.Requested_By = c.GetString("user_id")
+
+		submitReversal(ctx, c, reversal, approvalThreshold("REFUND_APPROVAL_THRESHOLD", 1000))
+	}
+}
+
+// VoidOrder godoc
+// @Summary Void an unpaid order
+// @Description Void an order that has no payments against it. Voids at or above VOID_APPROVAL_THRESHOLD wait for manager approval.
+// @Tags reversals
+// @Accept json
+// @Produce json
+// @Param order_id path string true "Order ID"
+// @Param void body voidRequest true "Void reason"
+// @Success 200 {object} models.Reversal "Order voided"
+// @Success 202 {object} models.Reversal "Void awaiting manager approval"
+// @Failure 400 {object} object "Invalid input or order already paid"
+// @Failure 404 {object} object "Order not found"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /orders/{order_id}/void [post]
+func VoidOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request voidRequest
+		var order models.Order
+		orderId := c.Param("order_id")
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		if order.Order_Status == "VOIDED" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Order is already voided"})
+			return
+		}
+		if err := ensureOrderUnpaid(ctx, orderId); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		total, err := orderAmountDue(ctx, orderId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var reversal models.Reversal
+		reversal.ID = primitive.NewObjectID()
+		reversal.Reversal_Id = reversal.ID.Hex()
+		reversal.Type = "VOID"
+		reversal.Order_Id = orderId
+		reversal.Amount = total
+		reversal.Reason_Code = request.Reason_Code
+		reversal.Reason = request.Reason
+		reversal.Requested_By = c.GetString("user_id")
+
+		submitReversal(ctx, c, reversal, approvalThreshold("VOID_APPROVAL_THRESHOLD", 500))
+	}
+}
+
+// submitReversal stores the reversal and either applies it straight away or
+// parks it for approval. Managers never need a second approval for their own
+// requests.
+func submitReversal(ctx context.Context, c *gin.Context, reversal models.Reversal, threshold float64) {
+	reversal.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	reversal.Updated_At = reversal.Created_At
+
+	needsApproval := reversal.Amount >= threshold && helpers.CheckUserRole(c, "manager", "admin") != nil
+	if needsApproval {
+		reversal.Status = reversalPending
+		if _, err := reversalCollection.InsertOne(ctx, reversal); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAudit(ctx, reversal.Type+"_REQUESTED", "reversal", reversal.Reversal_Id, reversal.Requested_By, reversal.Amount, reversal.Reason_Code)
+		c.JSON(http.StatusAccepted, reversal)
+		return
+	}
+
+	// The reversal is stored before it takes effect, so a refund or void is
+	// never applied without a record of it.
+	reversal.Approved_By = reversal.Requested_By
+	reversal.Status = reversalApplying
+	if _, err := reversalCollection.InsertOne(ctx, reversal); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := applyReversal(ctx, &reversal); err != nil {
+		reversalCollection.DeleteOne(ctx, bson.M{"reversal_id": reversal.Reversal_Id, "status": reversalApplying})
+		c.JSON(reversalErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if err := completeReversal(ctx, reversal); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(ctx, reversal.Type+"_COMPLETED", "reversal", reversal.Reversal_Id, reversal.Requested_By, reversal.Amount, reversal.Reason_Code)
+	c.JSON(http.StatusOK, reversal)
+}
+
+// errRefundExceedsBalance is returned when a refund is more than is left to
+// refund on the payment, including when another refund took it first.
+var errRefundExceedsBalance = errors.New("refund amount exceeds the refundable balance of the payment")
+
+// errOrderAlreadyVoided is returned when another void of the order took
+// effect first.
+var errOrderAlreadyVoided = errors.New("order is already voided")
+
+// reversalErrorStatus is the HTTP status for an error from applyReversal.
+func reversalErrorStatus(err error) int {
+	if errors.Is(err, errRefundExceedsBalance) || errors.Is(err, errOrderAlreadyVoided) {
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// applyReversal makes the reversal take effect and marks it COMPLETED.
+func applyReversal(ctx context.Context, reversal *models.Reversal) error {
+	// The reversal counts against the drawer of whoever carries it out.
+	if shift, err := openShiftFor(ctx, reversal.Approved_By); err == nil {
+		reversal.Shift_Id = shift.Shift_Id
+	}
+	switch reversal.Type {
+	case "REFUND":
+		return applyRefund(ctx, reversal)
+	case "VOID":
+		return applyVoid(ctx, reversal)
+	}
+	return fmt.Errorf("unknown reversal type %q", reversal.Type)
+}
+
+func applyRefund(ctx context.Context, reversal *models.Reversal) error {
+	var payment models.Payment
+	var invoice models.Invoice
+
+	if err := paymentCollection.FindOne(ctx, bson.M{"payment_id": reversal.Payment_Id}).Decode(&payment); err != nil {
+		return errors.New("payment not found")
+	}
+	if reversal.Amount > toFixed(*payment.Amount-payment.Refunded_Amount, 2) {
+		return errRefundExceedsBalance
+	}
+	if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": reversal.Invoice_Id}).Decode(&invoice); err != nil {
+		return errors.New("invoice not found")
+	}
+	if err := ensurePeriodOpen(ctx, invoice.Branch_Id, time.Now()); err != nil {
+		return err
+	}
+	payments, err := paymentsByInvoice(ctx, invoice.Invoice_Id)
+	if err != nil {
+		return err
+	}
+	var refundedBefore float64
+	for _, p := range payments {
+		refundedBefore += p.Refunded_Amount
+	}
+
+	// The balance is checked again in the update itself, so two refunds
+	// approved at once cannot both take the last of it. Half a cent of slack
+	// absorbs float error in the stored amounts.
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	result, err := paymentCollection.UpdateOne(ctx, bson.M{
+		"payment_id": payment.Payment_Id,
+		"$expr": bson.M{"$lte": bson.A{
+			bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$refunded_amount", 0}}, reversal.Amount}},
+			bson.M{"$add": bson.A{"$amount", 0.005}},
+		}},
+	}, bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "refunded_amount", Value: reversal.Amount}}},
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: now}}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errRefundExceedsBalance
+	}
+	// Gift cards the order sold or reloaded give back what the refund takes
+	// beyond the rest of the order.
+	if err := clawBackGiftCards(ctx, *reversal, invoice, refundedBefore); err != nil {
+		paymentCollection.UpdateOne(ctx, bson.M{"payment_id": payment.Payment_Id}, bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "refunded_amount", Value: -reversal.Amount}}},
+		})
+		return err
+	}
+	if err := reverseRefundPoints(ctx, *reversal, payment); err != nil {
+		return err
+	}
+	if *payment.Payment_Method == "GIFT_CARD" {
+		if err := refundGiftCardPayment(ctx, *reversal, payment); err != nil {
+			return err
+		}
+	}
+
+	var creditNote models.Credit_Note
+	creditNote.ID = primitive.NewObjectID()
+	creditNote.Credit_Note_Id = creditNote.ID.Hex()
+	creditNote.Invoice_Id = reversal.Invoice_Id
+	creditNote.Reversal_Id = reversal.Reversal_Id
+	creditNote.Amount = reversal.Amount
+	creditNote.Reason_Code = reversal.Reason_Code
+	creditNote.Created_At = now
+	if _, err := creditNoteCollection.InsertOne(ctx, creditNote); err != nil {
+		return err
+	}
+
+	reversal.Credit_Note_Id = creditNote.Credit_Note_Id
+	reversal.Status = reversalCompleted
+	reversal.Updated_At = now
+	recordAudit(ctx, "CREDIT_NOTE_ISSUED", "invoice", reversal.Invoice_Id, reversal.Approved_By, reversal.Amount, creditNote.Credit_Note_Id)
+	return refreshInvoicePaymentStatus(ctx, invoice)
+}
+
+func applyVoid(ctx context.Context, reversal *models.Reversal) error {
+	if err := ensureOrderUnpaid(ctx, reversal.Order_Id); err != nil {
+		return err
+	}
+	if err := ensureOrderPeriodOpen(ctx, reversal.Order_Id); err != nil {
+		return err
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	result, err := orderCollection.UpdateOne(ctx, bson.M{"order_id": reversal.Order_Id, "order_status": bson.M{"$ne": "VOIDED"}}, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "order_status", Value: "VOIDED"},
+			{Key: "updated_at", Value: now},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errOrderAlreadyVoided
+	}
+	_, err = invoiceCollection.UpdateMany(ctx, bson.M{"order_id": reversal.Order_Id}, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "payment_status", Value: "VOID"},
+			{Key: "updated_at", Value: now},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if err := cancelOrderGiftCards(ctx, reversal.Order_Id); err != nil {
+		return err
+	}
+	if err := restoreOrderStock(ctx, reversal.Order_Id); err != nil {
+		return err
+	}
+	// A voided order no longer counts against max_uses or per-customer limits.
+	if err := releasePromotionRedemptions(ctx, reversal.Order_Id); err != nil {
+		return err
+	}
+
+	reversal.Status = reversalCompleted
+	reversal.Updated_At = now
+	return nil
+}
+
+// ensureOrderUnpaid returns an error when any invoice of the order has a
+// payment recorded against it. Paid orders must be refunded instead.
+func ensureOrderUnpaid(ctx context.Context, orderId string) error {
+	cursor, err := invoiceCollection.Find(ctx, bson.M{"order_id": orderId})
+	if err != nil {
+		return err
+	}
+	var invoices []models.Invoice
+	if err = cursor.All(ctx, &invoices); err != nil {
+		return err
+	}
+	for _, invoice := range invoices {
+		count, err := paymentCollection.CountDocuments(ctx, bson.M{"invoice_id": invoice.Invoice_Id})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("order has payments recorded against it; refund the payments instead of voiding")
+		}
+	}
+	return nil
+}
+
+// GetReversals godoc
+// @Summary List refunds and voids
+// @Description Retrieve reversals, optionally filtered by status (PENDING_APPROVAL, APPLYING, COMPLETED, REJECTED)
+// @Tags reversals
+// @Accept json
+// @Produce json
+// @Param status query string false "Reversal status"
+// @Success 200 {array} models.Reversal
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /reversals [get]
+func GetReversals() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		cursor, err := reversalCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var reversals []models.Reversal
+		if err = cursor.All(ctx, &reversals); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, reversals)
+	}
+}
+
+// ApproveReversal godoc
+// @Summary Approve a pending refund or void
+// @Description Manager approval for a reversal above the approval threshold. The requester cannot approve their own reversal.
+// @Tags reversals
+// @Accept json
+// @Produce json
+// @Param reversal_id path string true "Reversal ID"
+// @Success 200 {object} models.Reversal
+// @Failure 400 {object} object "Reversal is not pending or can no longer be applied"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 404 {object} object "Reversal not found"
+// @Failure 409 {object} object "Refund exceeds what is left on the payment, or the reversal was already approved"
+// @Router /reversals/{reversal_id}/approve [post]
+func ApproveReversal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		reversal, ok := pendingReversalForManager(ctx, c)
+		if !ok {
+			return
+		}
+
+		// Claim the reversal before applying it, so two managers approving
+		// at once cannot both apply it.
+		result, err := reversalCollection.UpdateOne(ctx, bson.M{"reversal_id": reversal.Reversal_Id, "status": reversalPending}, bson.D{
+			{Key: "$set", Value: bson.D{{Key: "status", Value: reversalApplying}}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.MatchedCount != 1 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Reversal is no longer awaiting approval"})
+			return
+		}
+
+		reversal.Approved_By = c.GetString("user_id")
+		if err := applyReversal(ctx, &reversal); err != nil {
+			reversalCollection.UpdateOne(ctx, bson.M{"reversal_id": reversal.Reversal_Id, "status": reversalApplying}, bson.D{
+				{Key: "$set", Value: bson.D{{Key: "status", Value: reversalPending}}},
+			})
+			c.JSON(reversalErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if err := completeReversal(ctx, reversal); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAudit(ctx, reversal.Type+"_APPROVED", "reversal", reversal.Reversal_Id, reversal.Approved_By, reversal.Amount, reversal.Reason_Code)
+		c.JSON(http.StatusOK, reversal)
+	}
+}
+
+// RejectReversal godoc
+// @Summary Reject a pending refund or void
+// @Description Manager rejection of a reversal awaiting approval
+// @Tags reversals
+// @Accept json
+// @Produce json
+// @Param reversal_id path string true "Reversal ID"
+// @Success 200 {object} models.Reversal
+// @Failure 400 {object} object "Reversal is not pending"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 404 {object} object "Reversal not found"
+// @Failure 409 {object} object "Reversal was approved or rejected meanwhile"
+// @Router /reversals/{reversal_id}/reject [post]
+func RejectReversal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		reversal, ok := pendingReversalForManager(ctx, c)
+		if !ok {
+			return
+		}
+
+		reversal.Status = reversalRejected
+		reversal.Approved_By = c.GetString("user_id")
+		reversal.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := reversalCollection.UpdateOne(ctx, bson.M{"reversal_id": reversal.Reversal_Id, "status": reversalPending}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: reversal.Status},
+				{Key: "approved_by", Value: reversal.Approved_By},
+				{Key: "updated_at", Value: reversal.Updated_At},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.MatchedCount != 1 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Reversal is no longer awaiting approval"})
+			return
+		}
+		recordAudit(ctx, reversal.Type+"_REJECTED", "reversal", reversal.Reversal_Id, reversal.Approved_By, reversal.Amount, reversal.Reason_Code)
+		c.JSON(http.StatusOK, reversal)
+	}
+}
+
+// completeReversal records the outcome of a reversal that was claimed with
+// the APPLYING status and has now taken effect.
+func completeReversal(ctx context.Context, reversal models.Reversal) error {
+	_, err := reversalCollection.UpdateOne(ctx, bson.M{"reversal_id": reversal.Reversal_Id, "status": reversalApplying}, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: reversal.Status},
+			{Key: "approved_by", Value: reversal.Approved_By},
+			{Key: "credit_note_id", Value: reversal.Credit_Note_Id},
+			{Key: "shift_id", Value: reversal.Shift_Id},
+			{Key: "updated_at", Value: reversal.Updated_At},
+		}},
+	})
+	return err
+}
+
+// pendingReversalForManager loads the reversal named in the path and checks
+// that the caller is a manager other than the requester. It writes the error
+// response itself and reports whether the handler should continue.
+func pendingReversalForManager(ctx context.Context, c *gin.Context) (models.Reversal, bool) {
+	var reversal models.Reversal
+
+	if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+		return reversal, false
+	}
+	if err := reversalCollection.FindOne(ctx, bson.M{"reversal_id": c.Param("reversal_id")}).Decode(&reversal); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reversal not found"})
+		return reversal, false
+	}
+	if reversal.Status != reversalPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reversal is not awaiting approval"})
+		return reversal, false
+	}
+	if reversal.Requested_By == c.GetString("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "A reversal must be approved by someone other than the requester"})
+		return reversal, false
+	}
+	return reversal, true
+}
+
+// GetCreditNotes godoc
+// @Summary List credit notes
+// @Description Retrieve credit notes, optionally limited to one invoice
+// @Tags reversals
+// @Accept json
+// @Produce json
+// @Param invoice_id query string false "Original invoice ID"
+// @Success 200 {array} models.Credit_Note
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /credit_notes [get]
+func GetCreditNotes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if invoiceId := c.Query("invoice_id"); invoiceId != "" {
+			filter["invoice_id"] = invoiceId
+		}
+		cursor, err := creditNoteCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var creditNotes []models.Credit_Note
+		if err = cursor.All(ctx, &creditNotes); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, creditNotes)
+	}
+}
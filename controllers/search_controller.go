@@ -0,0 +1,370 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// searchIndexMaxAge bounds how stale the trigram index can get from writes
+// made by other server instances. Writes through this one rebuild it.
+const searchIndexMaxAge = 5 * time.Minute
+
+// Field weights for fuzzy matches. Menu text scores count for less than
+// food text scores since they match every food on the menu.
+const (
+	searchNameWeight        = 3
+	searchMenuWeight        = 1.5
+	searchDescriptionWeight = 1
+	menuTextScoreShare      = 0.5
+)
+
+type foodSearchIndex struct {
+	index  *helpers.SearchIndex
+	foods  map[string]models.Food
+	menus  map[string]models.Menu
+	byMenu map[string][]string
+	built  time.Time
+}
+
+var (
+	searchMu         sync.Mutex
+	searchCache      *foodSearchIndex
+	searchIndexesSet sync.Once
+)
+
+type searchResult struct {
+	Food_Id      string            `json:"food_id"`
+	Food_Name    string            `json:"food_name"`
+	Food_Price   float64           `json:"food_price"`
+	Food_Image   string            `json:"food_image,omitempty"`
+	Menu_Id      string            `json:"menu_id"`
+	Menu_Name    string            `json:"menu_name,omitempty"`
+	Category     string            `json:"category,omitempty"`
+	Dietary_Tags []string          `json:"dietary_tags,omitempty"`
+	Score        float64           `json:"score"`
+	Highlights   map[string]string `json:"highlights,omitempty"`
+}
+
+type searchFilter struct {
+	menuId      string
+	minPrice    *float64
+	maxPrice    *float64
+	dietaryTags []string
+}
+
+// SearchFoods godoc
+// @Summary Search foods
+// @Description Find dishes by name, description, menu name or category. Mongo text search ranks whole words and stems; an in-memory trigram index adds typo-tolerant matches. Matched words are wrapped in <mark> in the highlights.
+// @Tags foods
+// @Produce json
+// @Param q query string true "Search text"
+// @Param menu_id query string false "Only foods on this menu"
+// @Param min_price query number false "Lowest list price"
+// @Param max_price query number false "Highest list price"
+// @Param dietary_tag query []string false "Dietary tags the food must have" collectionFormat(multi)
+// @Param limit query int false "Maximum results (default 20, at most 100)"
+// @Success 200 {object} object "query, total, results"
+// @Failure 400 {object} object "Missing query or invalid filter"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /search [get]
+func SearchFoods() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		query := strings.TrimSpace(c.Query("q"))
+		if len(helpers.SearchTerms(query)) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+			return
+		}
+		filter, err := parseSearchFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		limit, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || limit <= 0 {
+			limit = 20
+		}
+		if limit > 100 {
+			limit = 100
+		}
+
+		index, err := currentSearchIndex(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		scores := map[string]float64{}
+		matched := map[string]map[string]bool{}
+		for _, hit := range index.index.Search(query) {
+			scores[hit.Id] += hit.Score
+			matched[hit.Id] = hit.Matched
+		}
+		// Without the text indexes, e.g. while Mongo builds them, search
+		// still works from the trigram index alone.
+		if foodScores, menuScores, err := textSearchScores(ctx, query); err != nil {
+			log.Println("Text search unavailable:", err)
+		} else {
+			for foodId, score := range foodScores {
+				scores[foodId] += score
+			}
+			for menuId, score := range menuScores {
+				for _, foodId := range index.byMenu[menuId] {
+					scores[foodId] += score * menuTextScoreShare
+				}
+			}
+		}
+
+		queryTerms := map[string]bool{}
+		for _, term := range helpers.SearchTerms(query) {
+			queryTerms[term] = true
+		}
+		results := []searchResult{}
+		for foodId, score := range scores {
+			food, ok := index.foods[foodId]
+			if !ok || !filter.keep(food) {
+				continue
+			}
+			words := map[string]bool{}
+			for word := range queryTerms {
+				words[word] = true
+			}
+			for word := range matched[foodId] {
+				words[word] = true
+			}
+			results = append(results, foodSearchResult(food, index.menus[*food.Menu_Id], score, words))
+		}
+		sort.Slice(results, func(i, j int) bool {
+			if results[i].Score != results[j].Score {
+				return results[i].Score > results[j].Score
+			}
+			return results[i].Food_Name < results[j].Food_Name
+		})
+		total := len(results)
+		if len(results) > limit {
+			results = results[:limit]
+		}
+		c.JSON(http.StatusOK, gin.H{"query": query, "total": total, "results": results})
+	}
+}
+
+func parseSearchFilter(c *gin.Context) (searchFilter, error) {
+	filter := searchFilter{menuId: c.Query("menu_id")}
+	for _, bound := range []struct {
+		name  string
+		value **float64
+	}{
+		{"min_price", &filter.minPrice},
+		{"max_price", &filter.maxPrice},
+	} {
+		raw := c.Query(bound.name)
+		if raw == "" {
+			continue
+		}
+		price, err := strconv.ParseFloat(raw, 64)
+		if err != nil || price < 0 {
+			return filter, errors.New(bound.name + " must be a non-negative number")
+		}
+		*bound.value = &price
+	}
+	if filter.minPrice != nil && filter.maxPrice != nil && *filter.minPrice > *filter.maxPrice {
+		return filter, errors.New("min_price must not be above max_price")
+	}
+	for _, tag := range c.QueryArray("dietary_tag") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			filter.dietaryTags = append(filter.dietaryTags, tag)
+		}
+	}
+	return filter, nil
+}
+
+func (f searchFilter) keep(food models.Food) bool {
+	if f.menuId != "" && (food.Menu_Id == nil || *food.Menu_Id != f.menuId) {
+		return false
+	}
+	if f.minPrice != nil && (food.Food_Price == nil || *food.Food_Price < *f.minPrice) {
+		return false
+	}
+	if f.maxPrice != nil && (food.Food_Price == nil || *food.Food_Price > *f.maxPrice) {
+		return false
+	}
+	for _, tag := range f.dietaryTags {
+		found := false
+		for _, has := range food.Dietary_Tags {
+			if strings.EqualFold(has, tag) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func foodSearchResult(food models.Food, menu models.Menu, score float64, words map[string]bool) searchResult {
+	result := searchResult{
+		Food_Id:      *food.Food_Id,
+		Food_Name:    food.Food_Name,
+		Food_Image:   food.Food_Image,
+		Menu_Id:      *food.Menu_Id,
+		Menu_Name:    menu.Name,
+		Category:     menu.Catagory,
+		Dietary_Tags: food.Dietary_Tags,
+		Score:        math.Round(score*1000) / 1000,
+		Highlights:   map[string]string{},
+	}
+	if food.Food_Price != nil {
+		result.Food_Price = *food.Food_Price
+	}
+	for field, text := range map[string]string{
+		"food_name":        food.Food_Name,
+		"food_description": food.Food_Description,
+		"menu_name":        menu.Name,
+		"category":         menu.Catagory,
+	} {
+		if highlighted := helpers.Highlight(text, words); highlighted != "" {
+			result.Highlights[field] = highlighted
+		}
+	}
+	return result
+}
+
+// currentSearchIndex returns the trigram index over every food, rebuilding
+// it when a food or menu has changed or it has grown old.
+func currentSearchIndex(ctx context.Context) (*foodSearchIndex, error) {
+	searchIndexesSet.Do(func() { ensureTextIndexes(ctx) })
+
+	searchMu.Lock()
+	defer searchMu.Unlock()
+	if searchCache != nil && time.Since(searchCache.built) < searchIndexMaxAge {
+		return searchCache, nil
+	}
+
+	var menus []models.Menu
+	cursor, err := menuCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(ctx, &menus); err != nil {
+		return nil, err
+	}
+	var foods []models.Food
+	cursor, err = foodCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(ctx, &foods); err != nil {
+		return nil, err
+	}
+
+	built := &foodSearchIndex{
+		index:  helpers.NewSearchIndex(),
+		foods:  map[string]models.Food{},
+		menus:  map[string]models.Menu{},
+		byMenu: map[string][]string{},
+		built:  time.Now(),
+	}
+	for _, menu := range menus {
+		built.menus[menu.Menu_Id] = menu
+	}
+	for _, food := range foods {
+		if food.Food_Id == nil || food.Menu_Id == nil {
+			continue
+		}
+		menu := built.menus[*food.Menu_Id]
+		built.foods[*food.Food_Id] = food
+		built.byMenu[*food.Menu_Id] = append(built.byMenu[*food.Menu_Id], *food.Food_Id)
+		built.index.Add(*food.Food_Id,
+			helpers.SearchField{Name: "food_name", Text: food.Food_Name, Weight: searchNameWeight},
+			helpers.SearchField{Name: "menu_name", Text: menu.Name, Weight: searchMenuWeight},
+			helpers.SearchField{Name: "category", Text: menu.Catagory, Weight: searchMenuWeight},
+			helpers.SearchField{Name: "food_description", Text: food.Food_Description, Weight: searchDescriptionWeight},
+		)
+	}
+	searchCache = built
+	return built, nil
+}
+
+// invalidateSearchIndex makes the next search rebuild the trigram index.
+func invalidateSearchIndex() {
+	searchMu.Lock()
+	searchCache = nil
+	searchMu.Unlock()
+}
+
+// ensureTextIndexes creates the text indexes search relies on. Failures are
+// logged; search then falls back to the trigram index.
+func ensureTextIndexes(ctx context.Context) {
+	_, err := foodCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "food_name", Value: "text"}, {Key: "food_description", Value: "text"}},
+		Options: options.Index().SetName("food_text").
+			SetWeights(bson.D{{Key: "food_name", Value: 10}, {Key: "food_description", Value: 2}}),
+	})
+	if err != nil {
+		log.Println("Error creating food text index:", err)
+	}
+	_, err = menuCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: "text"}, {Key: "catagory", Value: "text"}},
+		Options: options.Index().SetName("menu_text"),
+	})
+	if err != nil {
+		log.Println("Error creating menu text index:", err)
+	}
+}
+
+// textSearchScores runs query against the food and menu text indexes and
+// returns the text score of each matching food and menu.
+func textSearchScores(ctx context.Context, query string) (map[string]float64, map[string]float64, error) {
+	score := bson.M{"score": bson.M{"$meta": "textScore"}}
+	var hits []struct {
+		Food_Id string  `bson:"food_id"`
+		Menu_Id string  `bson:"menu_id"`
+		Score   float64 `bson:"score"`
+	}
+
+	cursor, err := foodCollection.Find(ctx, bson.M{"$text": bson.M{"$search": query}},
+		options.Find().SetProjection(bson.M{"food_id": 1, "score": score["score"]}).SetSort(score).SetLimit(200))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = cursor.All(ctx, &hits); err != nil {
+		return nil, nil, err
+	}
+	foodScores := map[string]float64{}
+	for _, hit := range hits {
+		foodScores[hit.Food_Id] = hit.Score
+	}
+
+	hits = nil
+	cursor, err = menuCollection.Find(ctx, bson.M{"$text": bson.M{"$search": query}},
+		options.Find().SetProjection(bson.M{"menu_id": 1, "score": score["score"]}).SetSort(score).SetLimit(50))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = cursor.All(ctx, &hits); err != nil {
+		return nil, nil, err
+	}
+	menuScores := map[string]float64{}
+	for _, hit := range hits {
+		menuScores[hit.Menu_Id] = hit.Score
+	}
+	return foodScores, menuScores, nil
+}
@@ -0,0 +1,611 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var shiftCollection = database.OpenCollection(database.Client, "shifts")
+var zReportCollection = database.OpenCollection(database.Client, "z_reports")
+var zReportIndexesSet sync.Once
+
+const (
+	shiftOpen   = "OPEN"
+	shiftClosed = "CLOSED"
+)
+
+type closeShiftRequest struct {
+	Counted_Cash *float64 `json:"counted_cash" validate:"required,gte=0"`
+}
+
+type zReportRequest struct {
+	Branch_Id     string `json:"branch_id"`
+	Business_Date string `json:"business_date" validate:"required,datetime=2006-01-02"`
+}
+
+// OpenShift godoc
+// @Summary Open a cashier shift
+// @Description Start a shift for the authenticated cashier with a starting float. A cashier can only have one open shift.
+// @Tags shifts
+// @Accept json
+// @Produce json
+// @Param shift body models.Shift true "Opening float and optional branch_id"
+// @Success 200 {object} models.Shift
+// @Failure 400 {object} object "Invalid input"
+// @Failure 409 {object} object "Cashier already has an open shift"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /shifts/open [post]
+func OpenShift() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var shift models.Shift
+		if err := c.BindJSON(&shift); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(shift); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		cashierId := c.GetString("user_id")
+		if _, err := openShiftFor(ctx, cashierId); err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "You already have an open shift"})
+			return
+		}
+
+		openingFloat := toFixed(*shift.Opening_Float, 2)
+		shift.ID = primitive.NewObjectID()
+		shift.Shift_Id = shift.ID.Hex()
+		shift.Cashier_Id = cashierId
+		shift.Status = shiftOpen
+		shift.Opening_Float = &openingFloat
+		shift.Cash_Movements = []models.Cash_Movement{}
+		shift.Opened_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		shift.Updated_At = shift.Opened_At
+
+		if _, err := shiftCollection.InsertOne(ctx, shift); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error opening shift"})
+			return
+		}
+		recordAudit(ctx, "SHIFT_OPENED", "shift", shift.Shift_Id, cashierId, openingFloat, "")
+		c.JSON(http.StatusOK, shift)
+	}
+}
+
+// GetShifts godoc
+// @Summary List shifts
+// @Description Retrieve shifts, newest first, optionally filtered by status, cashier or branch
+// @Tags shifts
+// @Produce json
+// @Param status query string false "OPEN or CLOSED"
+// @Param cashier_id query string false "Cashier user ID"
+// @Param branch_id query string false "Branch ID"
+// @Success 200 {array} models.Shift
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /shifts [get]
+func GetShifts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		for _, key := range []string{"status", "cashier_id", "branch_id"} {
+			if value := c.Query(key); value != "" {
+				filter[key] = value
+			}
+		}
+		opts := options.Find().SetSort(bson.D{{Key: "opened_at", Value: -1}})
+		cursor, err := shiftCollection.Find(ctx, filter, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var shifts []models.Shift
+		if err = cursor.All(ctx, &shifts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, shifts)
+	}
+}
+
+// GetCurrentShift godoc
+// @Summary Get my open shift
+// @Description Retrieve the authenticated cashier's open shift
+// @Tags shifts
+// @Produce json
+// @Success 200 {object} models.Shift
+// @Failure 404 {object} object "No open shift"
+// @Router /shifts/current [get]
+func GetCurrentShift() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		shift, err := openShiftFor(ctx, c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No open shift"})
+			return
+		}
+		c.JSON(http.StatusOK, shift)
+	}
+}
+
+// GetShift godoc
+// @Summary Get a shift
+// @Description Retrieve a shift by ID
+// @Tags shifts
+// @Produce json
+// @Param shift_id path string true "Shift ID"
+// @Success 200 {object} models.Shift
+// @Failure 404 {object} object "Shift not found"
+// @Router /shifts/{shift_id} [get]
+func GetShift() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var shift models.Shift
+		if err := shiftCollection.FindOne(ctx, bson.M{"shift_id": c.Param("shift_id")}).Decode(&shift); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Shift not found"})
+			return
+		}
+		c.JSON(http.StatusOK, shift)
+	}
+}
+
+// RecordCashMovement godoc
+// @Summary Record cash in or out
+// @Description Record cash added to (CASH_IN) or taken from (CASH_OUT) the drawer of an open shift
+// @Tags shifts
+// @Accept json
+// @Produce json
+// @Param shift_id path string true "Shift ID"
+// @Param movement body models.Cash_Movement true "Cash movement"
+// @Success 200 {object} models.Cash_Movement
+// @Failure 400 {object} object "Invalid input or shift closed"
+// @Failure 404 {object} object "Shift not found"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /shifts/{shift_id}/cash [post]
+func RecordCashMovement() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var movement models.Cash_Movement
+		if err := c.BindJSON(&movement); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(movement); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		amount := toFixed(*movement.Amount, 2)
+		movement.Movement_Id = primitive.NewObjectID().Hex()
+		movement.Amount = &amount
+		movement.Recorded_By = c.GetString("user_id")
+		movement.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		result, err := shiftCollection.UpdateOne(ctx,
+			bson.M{"shift_id": c.Param("shift_id"), "status": shiftOpen},
+			bson.D{
+				{Key: "$push", Value: bson.D{{Key: "cash_movements", Value: movement}}},
+				{Key: "$set", Value: bson.D{{Key: "updated_at", Value: movement.Created_At}}},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Open shift not found"})
+			return
+		}
+		recordAudit(ctx, movement.Type, "shift", c.Param("shift_id"), movement.Recorded_By, amount, movement.Reason)
+		c.JSON(http.StatusOK, movement)
+	}
+}
+
+// CloseShift godoc
+// @Summary Close a shift
+// @Description Close an open shift with the counted drawer cash. Expected cash is the opening float plus cash payments and tips, less cash refunds, plus cash in, less cash out.
+// @Tags shifts
+// @Accept json
+// @Produce json
+// @Param shift_id path string true "Shift ID"
+// @Param count body closeShiftRequest true "Counted cash"
+// @Success 200 {object} models.Shift_Report
+// @Failure 400 {object} object "Invalid input or shift already closed"
+// @Failure 404 {object} object "Shift not found"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /shifts/{shift_id}/close [post]
+func CloseShift() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request closeShiftRequest
+		var shift models.Shift
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		if err := shiftCollection.FindOne(ctx, bson.M{"shift_id": c.Param("shift_id")}).Decode(&shift); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Shift not found"})
+			return
+		}
+		if shift.Status != shiftOpen {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Shift is already closed"})
+			return
+		}
+
+		closedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		shift.Closed_At = closedAt
+		report, err := buildShiftReport(ctx, "X", shift.Branch_Id, []models.Shift{shift})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		counted := toFixed(*request.Counted_Cash, 2)
+		variance := toFixed(counted-report.Expected_Cash, 2)
+		report.Counted_Cash = counted
+		report.Variance = variance
+
+		_, err = shiftCollection.UpdateOne(ctx, bson.M{"shift_id": shift.Shift_Id, "status": shiftOpen}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: shiftClosed},
+				{Key: "expected_cash", Value: report.Expected_Cash},
+				{Key: "counted_cash", Value: counted},
+				{Key: "variance", Value: variance},
+				{Key: "closed_by", Value: c.GetString("user_id")},
+				{Key: "closed_at", Value: closedAt},
+				{Key: "updated_at", Value: closedAt},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAudit(ctx, "SHIFT_CLOSED", "shift", shift.Shift_Id, c.GetString("user_id"), variance, "counted cash variance")
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// GetShiftXReport godoc
+// @Summary X-report for a shift
+// @Description Mid-shift totals by payment method with voids, refunds, tips and expected drawer cash. Does not close the shift.
+// @Tags shifts
+// @Produce json
+// @Param shift_id path string true "Shift ID"
+// @Success 200 {object} models.Shift_Report
+// @Failure 404 {object} object "Shift not found"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /shifts/{shift_id}/x-report [get]
+func GetShiftXReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var shift models.Shift
+		if err := shiftCollection.FindOne(ctx, bson.M{"shift_id": c.Param("shift_id")}).Decode(&shift); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Shift not found"})
+			return
+		}
+		report, err := buildShiftReport(ctx, "X", shift.Branch_Id, []models.Shift{shift})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// CreateZReport godoc
+// @Summary Run the end-of-day Z-report
+// @Description Totals every shift of a branch opened on the business date, in the branch's timezone. All of those shifts must be closed, and each date can only be reported once. Z numbers run consecutively per branch.
+// @Tags shifts
+// @Accept json
+// @Produce json
+// @Param request body zReportRequest true "Branch and business date (YYYY-MM-DD)"
+// @Success 200 {object} models.Z_Report
+// @Failure 400 {object} object "Invalid input, shifts still open or no shifts"
+// @Failure 409 {object} object "Z-report already run for this date"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /z_reports [post]
+func CreateZReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request zReportRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		count, err := zReportCollection.CountDocuments(ctx, bson.M{"branch_id": request.Branch_Id, "business_date": request.Business_Date})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Z-report already run for this business date"})
+			return
+		}
+
+		location := helpers.BranchLocation(branchForTable(ctx, models.Table{Branch_Id: request.Branch_Id}))
+		day, _ := time.ParseInLocation("2006-01-02", request.Business_Date, location)
+		filter := bson.M{"opened_at": bson.M{"$gte": day, "$lt": day.AddDate(0, 0, 1)}}
+		if request.Branch_Id != "" {
+			filter["branch_id"] = request.Branch_Id
+		}
+		cursor, err := shiftCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var shifts []models.Shift
+		if err = cursor.All(ctx, &shifts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(shifts) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No shifts were opened on this business date"})
+			return
+		}
+		for _, shift := range shifts {
+			if shift.Status == shiftOpen {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Close all shifts before running the Z-report", "shift_id": shift.Shift_Id})
+				return
+			}
+		}
+
+		report, err := buildShiftReport(ctx, "Z", request.Branch_Id, shifts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var zReport models.Z_Report
+		zReport.ID = primitive.NewObjectID()
+		zReport.Z_Report_Id = zReport.ID.Hex()
+		zReport.Branch_Id = request.Branch_Id
+		zReport.Business_Date = request.Business_Date
+		zReport.Report = report
+		zReport.Created_By = c.GetString("user_id")
+		zReport.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if err := insertZReport(ctx, &zReport); err != nil {
+			if err == errZReportExists {
+				c.JSON(http.StatusConflict, gin.H{"error": "Z-report already run for this business date"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAudit(ctx, "Z_REPORT_RUN", "z_report", zReport.Z_Report_Id, zReport.Created_By, report.Net_Sales, request.Business_Date)
+		c.JSON(http.StatusOK, zReport)
+	}
+}
+
+// GetZReports godoc
+// @Summary List Z-reports
+// @Description Retrieve stored end-of-day reports, newest first
+// @Tags shifts
+// @Produce json
+// @Param branch_id query string false "Branch ID"
+// @Success 200 {array} models.Z_Report
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /z_reports [get]
+func GetZReports() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if branchId := c.Query("branch_id"); branchId != "" {
+			filter["branch_id"] = branchId
+		}
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		cursor, err := zReportCollection.Find(ctx, filter, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var reports []models.Z_Report
+		if err = cursor.All(ctx, &reports); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, reports)
+	}
+}
+
+// openShiftFor returns the open shift of a cashier.
+func openShiftFor(ctx context.Context, cashierId string) (models.Shift, error) {
+	var shift models.Shift
+	err := shiftCollection.FindOne(ctx, bson.M{"cashier_id": cashierId, "status": shiftOpen}).Decode(&shift)
+	return shift, err
+}
+
+// buildShiftReport totals the payments, refunds, voids and cash movements
+// recorded against the given shifts.
+func buildShiftReport(ctx context.Context, reportType, branchId string, shifts []models.Shift) (models.Shift_Report, error) {
+	report := models.Shift_Report{
+		Report_Type:       reportType,
+		Branch_Id:         branchId,
+		Sales_By_Method:   map[string]float64{},
+		Tips_By_Method:    map[string]float64{},
+		Refunds_By_Method: map[string]float64{},
+	}
+	report.Generated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	report.To = report.Generated_At
+
+	for i, shift := range shifts {
+		report.Shift_Ids = append(report.Shift_Ids, shift.Shift_Id)
+		if i == 0 || shift.Opened_At.Before(report.From) {
+			report.From = shift.Opened_At
+		}
+		report.Opening_Float += *shift.Opening_Float
+		for _, movement := range shift.Cash_Movements {
+			if movement.Type == "CASH_IN" {
+				report.Cash_In += *movement.Amount
+			} else {
+				report.Cash_Out += *movement.Amount
+			}
+		}
+	}
+	if reportType == "Z" {
+		report.To = time.Time{}
+		for _, shift := range shifts {
+			if shift.Closed_At.After(report.To) {
+				report.To = shift.Closed_At
+			}
+			report.Counted_Cash += shift.Counted_Cash
+		}
+	}
+
+	cursor, err := paymentCollection.Find(ctx, bson.M{"shift_id": bson.M{"$in": report.Shift_Ids}})
+	if err != nil {
+		return report, err
+	}
+	var payments []models.Payment
+	if err = cursor.All(ctx, &payments); err != nil {
+		return report, err
+	}
+	for _, payment := range payments {
+		report.Payment_Count++
+		report.Sales_By_Method[*payment.Payment_Method] += *payment.Amount
+		report.Tips_By_Method[*payment.Payment_Method] += payment.Tip_Amount
+		report.Gross_Sales += *payment.Amount
+		report.Total_Tips += payment.Tip_Amount
+	}
+
+	cursor, err = reversalCollection.Find(ctx, bson.M{"shift_id": bson.M{"$in": report.Shift_Ids}, "status": reversalCompleted})
+	if err != nil {
+		return report, err
+	}
+	var reversals []models.Reversal
+	if err = cursor.All(ctx, &reversals); err != nil {
+		return report, err
+	}
+	for _, reversal := range reversals {
+		if reversal.Type == "VOID" {
+			report.Void_Count++
+			report.Total_Voids += reversal.Amount
+			continue
+		}
+		var payment models.Payment
+		method := "UNKNOWN"
+		if err := paymentCollection.FindOne(ctx, bson.M{"payment_id": reversal.Payment_Id}).Decode(&payment); err == nil {
+			method = *payment.Payment_Method
+		}
+		report.Refund_Count++
+		report.Refunds_By_Method[method] += reversal.Amount
+		report.Total_Refunds += reversal.Amount
+	}
+
+	for method, amount := range report.Sales_By_Method {
+		report.Sales_By_Method[method] = toFixed(amount, 2)
+	}
+	for method, amount := range report.Tips_By_Method {
+		report.Tips_By_Method[method] = toFixed(amount, 2)
+	}
+	for method, amount := range report.Refunds_By_Method {
+		report.Refunds_By_Method[method] = toFixed(amount, 2)
+	}
+	report.Gross_Sales = toFixed(report.Gross_Sales, 2)
+	report.Total_Tips = toFixed(report.Total_Tips, 2)
+	report.Total_Refunds = toFixed(report.Total_Refunds, 2)
+	report.Total_Voids = toFixed(report.Total_Voids, 2)
+	report.Net_Sales = toFixed(report.Gross_Sales-report.Total_Refunds, 2)
+	report.Opening_Float = toFixed(report.Opening_Float, 2)
+	report.Cash_In = toFixed(report.Cash_In, 2)
+	report.Cash_Out = toFixed(report.Cash_Out, 2)
+	report.Expected_Cash = toFixed(report.Opening_Float+report.Sales_By_Method["CASH"]+report.Tips_By_Method["CASH"]-
+		report.Refunds_By_Method["CASH"]+report.Cash_In-report.Cash_Out, 2)
+	if reportType == "Z" {
+		report.Counted_Cash = toFixed(report.Counted_Cash, 2)
+		report.Variance = toFixed(report.Counted_Cash-report.Expected_Cash, 2)
+	}
+	return report, nil
+}
+
+var errZReportExists = errors.New("z-report already run for this business date")
+
+// insertZReport stores a Z-report under the branch's next Z number. Unique
+// indexes on the number and on the business date let two concurrent runs
+// race safely: the loser retries with the next number, or gets
+// errZReportExists when the other run was for the same date.
+func insertZReport(ctx context.Context, zReport *models.Z_Report) error {
+	zReportIndexesSet.Do(func() { ensureZReportIndexes(ctx) })
+
+	for attempt := 0; attempt < 5; attempt++ {
+		var last models.Z_Report
+		err := zReportCollection.FindOne(ctx, bson.M{"branch_id": zReport.Branch_Id},
+			options.FindOne().SetSort(bson.M{"z_number": -1})).Decode(&last)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+		zReport.Z_Number = last.Z_Number + 1
+
+		_, err = zReportCollection.InsertOne(ctx, zReport)
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		count, countErr := zReportCollection.CountDocuments(ctx, bson.M{"branch_id": zReport.Branch_Id, "business_date": zReport.Business_Date})
+		if countErr != nil {
+			return countErr
+		}
+		if count > 0 {
+			return errZReportExists
+		}
+	}
+	return errors.New("could not allocate a Z number, try again")
+}
+
+// ensureZReportIndexes makes Z numbers and business dates unique per
+// branch. Failures are logged; reports are still numbered, just without the
+// guarantee under concurrent runs.
+func ensureZReportIndexes(ctx context.Context) {
+	_, err := zReportCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "branch_id", Value: 1}, {Key: "z_number", Value: 1}},
+			Options: options.Index().SetName("z_number_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "branch_id", Value: 1}, {Key: "business_date", Value: 1}},
+			Options: options.Index().SetName("business_date_unique").SetUnique(true),
+		},
+	})
+	if err != nil {
+		log.Println("Error creating Z-report indexes:", err)
+	}
+}
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var supplierCollection = database.OpenCollection(database.Client, "suppliers")
+
+// GetSuppliers godoc
+// @Summary List suppliers
+// @Description Retrieve the suppliers ingredients are bought from. Deactivated ones are included only with active=false.
+// @Tags purchasing
+// @Produce json
+// @Param active query bool false "Only active (default) or only deactivated suppliers"
+// @Success 200 {array} models.Supplier
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /suppliers [get]
+func GetSuppliers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{"active": c.Query("active") != "false"}
+		opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+		cursor, err := supplierCollection.Find(ctx, filter, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		suppliers := []models.Supplier{}
+		if err = cursor.All(ctx, &suppliers); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, suppliers)
+	}
+}
+
+// GetSupplier godoc
+// @Summary Get a supplier
+// @Tags purchasing
+// @Produce json
+// @Param supplier_id path string true "Supplier ID"
+// @Success 200 {object} models.Supplier
+// @Failure 404 {object} object "Supplier not found"
+// @Router /suppliers/{supplier_id} [get]
+func GetSupplier() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var supplier models.Supplier
+		if err := supplierCollection.FindOne(ctx, bson.M{"supplier_id": c.Param("supplier_id")}).Decode(&supplier); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+			return
+		}
+		c.JSON(http.StatusOK, supplier)
+	}
+}
+
+// CreateSupplier godoc
+// @Summary Create a supplier
+// @Description Add a supplier with its contact details and usual delivery lead time. Requires the manager role.
+// @Tags purchasing
+// @Accept json
+// @Produce json
+// @Param supplier body models.Supplier true "Supplier"
+// @Success 200 {object} models.Supplier
+// @Failure 400 {object} object "Invalid input"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 500 {object} object "Error creating supplier"
+// @Router /suppliers [post]
+func CreateSupplier() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		var supplier models.Supplier
+		if err := c.BindJSON(&supplier); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(supplier); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		supplier.ID = primitive.NewObjectID()
+		supplier.Supplier_Id = supplier.ID.Hex()
+		supplier.Active = true
+		supplier.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		supplier.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if _, err := supplierCollection.InsertOne(ctx, supplier); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating supplier"})
+			return
+		}
+		c.JSON(http.StatusOK, supplier)
+	}
+}
+
+// UpdateSupplier godoc
+// @Summary Update a supplier
+// @Description Replace a supplier's contact details and lead time. Requires the manager role.
+// @Tags purchasing
+// @Accept json
+// @Produce json
+// @Param supplier_id path string true "Supplier ID"
+// @Param supplier body models.Supplier true "Supplier"
+// @Success 200 {object} models.Supplier
+// @Failure 400 {object} object "Invalid input"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 404 {object} object "Supplier not found"
+// @Failure 500 {object} object "Error updating supplier"
+// @Router /suppliers/{supplier_id} [put]
+func UpdateSupplier() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		var supplier models.Supplier
+		if err := c.BindJSON(&supplier); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(supplier); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		supplier.Supplier_Id = c.Param("supplier_id")
+		supplier.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		var updated models.Supplier
+		err := supplierCollection.FindOneAndUpdate(ctx, bson.M{"supplier_id": supplier.Supplier_Id}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "name", Value: supplier.Name},
+				{Key: "contact_name", Value: supplier.Contact_Name},
+				{Key: "phone", Value: supplier.Phone},
+				{Key: "email", Value: supplier.Email},
+				{Key: "address", Value: supplier.Address},
+				{Key: "lead_time_days", Value: supplier.Lead_Time_Days},
+				{Key: "updated_at", Value: supplier.Updated_At},
+			}},
+		}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// DeleteSupplier godoc
+// @Summary Deactivate a supplier
+// @Description Stop ordering from a supplier. It is kept so its purchase orders still resolve. Requires the manager role.
+// @Tags purchasing
+// @Produce json
+// @Param supplier_id path string true "Supplier ID"
+// @Success 200 {object} object "message: Supplier deactivated"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 404 {object} object "Supplier not found"
+// @Failure 500 {object} object "Error deactivating supplier"
+// @Router /suppliers/{supplier_id} [delete]
+func DeleteSupplier() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := supplierCollection.UpdateOne(ctx, bson.M{"supplier_id": c.Param("supplier_id")}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "active", Value: false},
+				{Key: "updated_at", Value: updatedAt},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deactivating supplier"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Supplier deactivated"})
+	}
+}
+
+// supplierExists returns an error unless supplierId is empty or an active
+// supplier.
+func supplierExists(ctx context.Context, supplierId string) error {
+	if supplierId == "" {
+		return nil
+	}
+	_, err := activeSupplier(ctx, supplierId)
+	return err
+}
+
+func activeSupplier(ctx context.Context, supplierId string) (models.Supplier, error) {
+	var supplier models.Supplier
+	if err := supplierCollection.FindOne(ctx, bson.M{"supplier_id": supplierId, "active": true}).Decode(&supplier); err != nil {
+		return supplier, errors.New("supplier " + supplierId + " not found")
+	}
+	return supplier, nil
+}
@@ -0,0 +1,396 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var taxRateCollection = database.OpenCollection(database.Client, "tax_rates")
+
+// GetTaxRates godoc
+// @Summary List tax rates
+// @Description Retrieve taxes and service charges, optionally those that apply to one branch
+// @Tags taxes
+// @Produce json
+// @Param branch_id query string false "Branch ID"
+// @Success 200 {array} models.Tax_Rate
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /tax_rates [get]
+func GetTaxRates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if branchId := c.Query("branch_id"); branchId != "" {
+			filter["branch_id"] = bson.M{"$in": bson.A{branchId, "", nil}}
+		}
+		cursor, err := taxRateCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var rates []models.Tax_Rate
+		if err = cursor.All(ctx, &rates); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, rates)
+	}
+}
+
+// CreateTaxRate godoc
+// @Summary Create a tax rate
+// @Description Add a tax (VAT, excise, ...) or service charge for a branch or all branches, optionally limited to menu categories
+// @Tags taxes
+// @Accept json
+// @Produce json
+// @Param rate body models.Tax_Rate true "Tax rate"
+// @Success 200 {object} models.Tax_Rate
+// @Failure 400 {object} object "Invalid input"
+// @Failure 500 {object} object "Error creating tax rate"
+// @Router /tax_rates [post]
+func CreateTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var rate models.Tax_Rate
+		if err := c.BindJSON(&rate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(rate); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		rate.ID = primitive.NewObjectID()
+		rate.Tax_Rate_Id = rate.ID.Hex()
+		rate.Code = strings.ToUpper(rate.Code)
+		rate.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		rate.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if _, err := taxRateCollection.InsertOne(ctx, rate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating tax rate"})
+			return
+		}
+		c.JSON(http.StatusOK, rate)
+	}
+}
+
+// UpdateTaxRate godoc
+// @Summary Update a tax rate
+// @Description Replace a tax rate. Invoices keep the taxes they were issued with until their next payment.
+// @Tags taxes
+// @Accept json
+// @Produce json
+// @Param tax_rate_id path string true "Tax rate ID"
+// @Param rate body models.Tax_Rate true "Tax rate"
+// @Success 200 {object} object "Update result"
+// @Failure 400 {object} object "Invalid input"
+// @Failure 404 {object} object "Tax rate not found"
+// @Failure 500 {object} object "Error updating tax rate"
+// @Router /tax_rates/{tax_rate_id} [put]
+func UpdateTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var rate models.Tax_Rate
+		if err := c.BindJSON(&rate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(rate); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		rate.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := taxRateCollection.UpdateOne(ctx, bson.M{"tax_rate_id": c.Param("tax_rate_id")}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "name", Value: rate.Name},
+				{Key: "code", Value: strings.ToUpper(rate.Code)},
+				{Key: "type", Value: rate.Type},
+				{Key: "rate", Value: rate.Rate},
+				{Key: "branch_id", Value: rate.Branch_Id},
+				{Key: "categories", Value: rate.Categories},
+				{Key: "compound", Value: rate.Compound},
+				{Key: "taxable_service_charge", Value: rate.Taxable_Service_Charge},
+				{Key: "active", Value: rate.Active},
+				{Key: "updated_at", Value: rate.Updated_At},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating tax rate"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tax rate not found"})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// DeleteTaxRate godoc
+// @Summary Deactivate a tax rate
+// @Description Tax rates are deactivated rather than deleted so issued invoices keep their reference
+// @Tags taxes
+// @Produce json
+// @Param tax_rate_id path string true "Tax rate ID"
+// @Success 200 {object} object "message: Tax rate deactivated"
+// @Failure 404 {object} object "Tax rate not found"
+// @Failure 500 {object} object "Error deactivating tax rate"
+// @Router /tax_rates/{tax_rate_id} [delete]
+func DeleteTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := taxRateCollection.UpdateOne(ctx, bson.M{"tax_rate_id": c.Param("tax_rate_id")}, bson.D{
+			{Key: "$set", Value: bson.D{{Key: "active", Value: false}}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deactivating tax rate"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tax rate not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Tax rate deactivated"})
+	}
+}
+
+// taxPeriod is one row of the tax report.
+type taxPeriod struct {
+	Period          string               `json:"period"`
+	Invoices        int                  `json:"invoices"`
+	Credit_Notes    int                  `json:"credit_notes"`
+	Taxes           []models.Tax_Summary `json:"taxes"`
+	Service_Charges []models.Tax_Summary `json:"service_charges"`
+	Tax_Total       float64              `json:"tax_total"`
+}
+
+// GetTaxReport godoc
+// @Summary Tax report
+// @Description Tax and service charge per rate for each day or month, for filing. Invoices count in the period they were issued; credit notes take back their share of tax in the period they were issued. Voided invoices are left out.
+// @Tags taxes
+// @Produce json
+// @Param from query string false "First day (YYYY-MM-DD), default start of this month"
+// @Param to query string false "Last day (YYYY-MM-DD), default today"
+// @Param period query string false "day or month (default month)"
+// @Param branch_id query string false "Branch ID"
+// @Success 200 {object} object "Periods with totals per rate"
+// @Failure 400 {object} object "Invalid dates"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /reports/tax [get]
+func GetTaxReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		branchId := c.Query("branch_id")
+		location := helpers.BranchLocation(branchForTable(ctx, models.Table{Branch_Id: branchId}))
+		now := time.Now().In(location)
+		from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, location)
+		to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+		var err error
+		if value := c.Query("from"); value != "" {
+			if from, err = time.ParseInLocation("2006-01-02", value, location); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+				return
+			}
+		}
+		if value := c.Query("to"); value != "" {
+			if to, err = time.ParseInLocation("2006-01-02", value, location); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+				return
+			}
+		}
+		end := to.AddDate(0, 0, 1)
+		layout := "2006-01"
+		if c.Query("period") == "day" {
+			layout = "2006-01-02"
+		}
+
+		filter := bson.M{
+			"created_at":     bson.M{"$gte": from, "$lt": end},
+			"payment_status": bson.M{"$ne": "VOID"},
+		}
+		if branchId != "" {
+			filter["branch_id"] = branchId
+		}
+		cursor, err := invoiceCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var invoices []models.Invoice
+		if err = cursor.All(ctx, &invoices); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		periods := map[string]*taxPeriod{}
+		periodFor := func(t time.Time) *taxPeriod {
+			key := t.In(location).Format(layout)
+			if periods[key] == nil {
+				periods[key] = &taxPeriod{Period: key}
+			}
+			return periods[key]
+		}
+		for _, invoice := range invoices {
+			period := periodFor(invoice.Created_At)
+			period.Invoices++
+			period.Taxes = addTaxSummaries(period.Taxes, invoice.Taxes, 1)
+			period.Service_Charges = addTaxSummaries(period.Service_Charges, invoice.Service_Charges, 1)
+		}
+
+		cursor, err = creditNoteCollection.Find(ctx, bson.M{"created_at": bson.M{"$gte": from, "$lt": end}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var notes []models.Credit_Note
+		if err = cursor.All(ctx, &notes); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, note := range notes {
+			var invoice models.Invoice
+			if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": note.Invoice_Id}).Decode(&invoice); err != nil {
+				continue
+			}
+			if (branchId != "" && invoice.Branch_Id != branchId) || invoice.Total_Amount <= 0 {
+				continue
+			}
+			share := -note.Amount / invoice.Total_Amount
+			period := periodFor(note.Created_At)
+			period.Credit_Notes++
+			period.Taxes = addTaxSummaries(period.Taxes, invoice.Taxes, share)
+			period.Service_Charges = addTaxSummaries(period.Service_Charges, invoice.Service_Charges, share)
+		}
+
+		var report []taxPeriod
+		var grandTotal float64
+		for _, period := range periods {
+			for _, tax := range period.Taxes {
+				period.Tax_Total += tax.Amount
+			}
+			period.Tax_Total = toFixed(period.Tax_Total, 2)
+			grandTotal += period.Tax_Total
+			report = append(report, *period)
+		}
+		sort.Slice(report, func(i, j int) bool { return report[i].Period < report[j].Period })
+
+		c.JSON(http.StatusOK, gin.H{
+			"from":      from.Format("2006-01-02"),
+			"to":        to.Format("2006-01-02"),
+			"branch_id": branchId,
+			"periods":   report,
+			"tax_total": toFixed(grandTotal, 2),
+		})
+	}
+}
+
+// addTaxSummaries adds share times each summary into totals, merging by rate.
+func addTaxSummaries(totals, summaries []models.Tax_Summary, share float64) []models.Tax_Summary {
+	for _, summary := range summaries {
+		found := false
+		for i := range totals {
+			if totals[i].Tax_Rate_Id == summary.Tax_Rate_Id {
+				totals[i].Taxable_Amount = toFixed(totals[i].Taxable_Amount+summary.Taxable_Amount*share, 2)
+				totals[i].Amount = toFixed(totals[i].Amount+summary.Amount*share, 2)
+				found = true
+			}
+		}
+		if !found {
+			summary.Taxable_Amount = toFixed(summary.Taxable_Amount*share, 2)
+			summary.Amount = toFixed(summary.Amount*share, 2)
+			totals = append(totals, summary)
+		}
+	}
+	return totals
+}
+
+// orderCharges prices an order with the taxes and service charges of the
+// branch it was placed at.
+func orderCharges(ctx context.Context, order models.Order) (helpers.OrderCharges, models.Branch, error) {
+	branch := branchForOrder(ctx, order)
+
+	cursor, err := orderItemCollection.Find(ctx, bson.M{"order_id": order.Order_Id})
+	if err != nil {
+		return helpers.OrderCharges{}, branch, err
+	}
+	var items []models.Ordered_Item
+	if err = cursor.All(ctx, &items); err != nil {
+		return helpers.OrderCharges{}, branch, err
+	}
+
+	cursor, err = taxRateCollection.Find(ctx, bson.M{
+		"active":    true,
+		"branch_id": bson.M{"$in": bson.A{branch.Branch_Id, "", nil}},
+	})
+	if err != nil {
+		return helpers.OrderCharges{}, branch, err
+	}
+	var rates []models.Tax_Rate
+	if err = cursor.All(ctx, &rates); err != nil {
+		return helpers.OrderCharges{}, branch, err
+	}
+
+	categories := menuCategories(ctx, items)
+	var lines []helpers.TaxLine
+	for _, item := range items {
+		lines = append(lines, helpers.TaxLine{
+			Category: categories[item.Menu_Id],
+			Amount:   item.Price * float64(item.Quantity),
+			Exempt:   item.Gift_Card_Id != "",
+		})
+	}
+	inclusive := branch.Tax_Inclusive != nil && *branch.Tax_Inclusive
+	return helpers.CalculateCharges(rates, lines, order.Discount_Total, inclusive), branch, nil
+}
+
+// invoiceChargeFields are the invoice fields that snapshot an order's charges.
+func invoiceChargeFields(charges helpers.OrderCharges, branch models.Branch) bson.D {
+	return bson.D{
+		{Key: "branch_id", Value: branch.Branch_Id},
+		{Key: "subtotal", Value: charges.Subtotal},
+		{Key: "service_charges", Value: charges.Service_Charges},
+		{Key: "taxes", Value: charges.Taxes},
+		{Key: "tax_total", Value: charges.Tax_Total},
+		{Key: "tax_inclusive", Value: charges.Tax_Inclusive},
+		{Key: "total_amount", Value: charges.Total},
+	}
+}
+
+// menuCategories maps each item's menu to its category.
+func menuCategories(ctx context.Context, items []models.Ordered_Item) map[string]string {
+	categories := map[string]string{}
+	for _, item := range items {
+		if _, ok := categories[item.Menu_Id]; ok || item.Menu_Id == "" {
+			continue
+		}
+		var menu models.Menu
+		if err := menuCollection.FindOne(ctx, bson.M{"menu_id": item.Menu_Id}).Decode(&menu); err == nil {
+			categories[item.Menu_Id] = menu.Catagory
+		} else {
+			categories[item.Menu_Id] = ""
+		}
+	}
+	return categories
+}
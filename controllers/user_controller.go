@@ -86,7 +86,7 @@ func GetUser() gin.HandlerFunc {
 
 // Signup godoc
 // @Summary Register a new user
-// @Description Create a new user account
+// @Description Create a new user account. Accounts are always created with the user role; an admin grants manager or admin afterwards.
 // @Tags authentication
 // @Accept json
 // @Produce json
@@ -108,6 +108,9 @@ func Signup() gin.HandlerFunc {
 			})
 			return
 		}
+		// Anyone can sign up, so new accounts are always plain users; an
+		// admin grants other roles through SetUserRole.
+		user.Role = "user"
 
 		validation_err := validate.Struct(user)
 		if validation_err != nil {
@@ -162,6 +165,65 @@ func Signup() gin.HandlerFunc {
 	}
 }
 
+// userRoleRequest is the body of SetUserRole.
+type userRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin manager user"`
+}
+
+// SetUserRole godoc
+// @Summary Change a user's role
+// @Description Make a user an admin, a manager or a plain user. Requires the admin role.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Param role body userRoleRequest true "New role"
+// @Success 200 {object} object "message: Role updated"
+// @Failure 400 {object} object "Invalid role"
+// @Failure 403 {object} object "Admin role required"
+// @Failure 404 {object} object "User not found"
+// @Failure 500 {object} object "Error updating role"
+// @Router /users/{user_id}/role [patch]
+func SetUserRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin role required"})
+			return
+		}
+		var request userRoleRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		userId := c.Param("user_id")
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := userCollection.UpdateOne(ctx, bson.M{"user_id": userId}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "role", Value: request.Role},
+				{Key: "updated_at", Value: updatedAt},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating role"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		recordAudit(ctx, "USER_ROLE_CHANGED", "user", userId, c.GetString("user_id"), 0, request.Role)
+		c.JSON(http.StatusOK, gin.H{"message": "Role updated"})
+	}
+}
+
 // Login godoc
 // @Summary User login
 // @Description Authenticate user and return access tokens
@@ -206,13 +268,13 @@ func Login() gin.HandlerFunc {
 			return
 		}
 
-		token, refresh_token, _ := helpers.GenerateAllTokens(Found_user.Email, Found_user.First_Name, Found_user.Last_Name, Found_user.User_id)
-		helpers.UpdateAllTokens(token, refresh_token, Found_user.User_id)
+		token, refresh_token, _ := helpers.GenerateAllTokens(user.Email, user.First_Name, user.Last_Name, user.User_id)
+		helpers.UpdateAllTokens(token, refresh_token, user.User_id)
 
-		Found_user.Token = &token
-		Found_user.Refresh_Token = &refresh_token
+		user.Token = &token
+		user.Refresh_Token = &refresh_token
 
-		c.JSON(http.StatusOK, Found_user)
+		c.JSON(http.StatusOK, user)
 	}
 }
 
@@ -0,0 +1,726 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var foodVersionCollection = database.OpenCollection(database.Client, "food_versions")
+var menuVersionCollection = database.OpenCollection(database.Client, "menu_versions")
+var versionIndexesSet sync.Once
+
+// GetFoodVersions godoc
+// @Summary List a food's versions
+// @Description Retrieve every recorded version of a food, newest first, with when it took effect and who made the change
+// @Tags versions
+// @Produce json
+// @Param food_id path string true "Food ID"
+// @Success 200 {array} models.Food_Version
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /foods/{food_id}/versions [get]
+func GetFoodVersions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		versions, err := foodVersions(ctx, c.Param("food_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, versions)
+	}
+}
+
+// GetFoodPriceHistory godoc
+// @Summary Get a food's price history
+// @Description List the prices a food has had, newest first, each with the period it applied to and who set it. With at, return only the price in effect at that time, e.g. when an order was placed.
+// @Tags versions
+// @Produce json
+// @Param food_id path string true "Food ID"
+// @Param at query string false "RFC3339 time to look up the price at"
+// @Success 200 {array} helpers.PricePeriod
+// @Failure 400 {object} object "Invalid time"
+// @Failure 404 {object} object "Food not found or no price at that time"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /foods/{food_id}/price_history [get]
+func GetFoodPriceHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		foodId := c.Param("food_id")
+		versions, err := foodVersions(ctx, foodId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(versions) == 0 {
+			// A food not changed since versioning began has only its
+			// current price.
+			var food models.Food
+			if err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Food not found"})
+				return
+			}
+			versions = append(versions, models.Food_Version{Food: food, Change: helpers.VersionBaseline, Effective_From: versionedSince(food.Created_AT, food.Updated_AT)})
+		}
+		history := helpers.FoodPriceHistory(versions)
+
+		if value := c.Query("at"); value != "" {
+			at, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "at must be an RFC3339 time"})
+				return
+			}
+			period, ok := helpers.PriceAt(history, at)
+			if !ok {
+				c.JSON(http.StatusNotFound, gin.H{"error": "No price recorded at that time"})
+				return
+			}
+			c.JSON(http.StatusOK, period)
+			return
+		}
+		c.JSON(http.StatusOK, history)
+	}
+}
+
+// RollbackFood godoc
+// @Summary Roll a food back to an earlier version
+// @Description Restore a food's name, price, description, menu, station, modifiers, allergens and nutrition as they were in a version, recreating it if it has been deleted. The rollback is itself recorded as a new version. The image and 86 status are left as they are. Requires the manager role.
+// @Tags versions
+// @Produce json
+// @Param food_id path string true "Food ID"
+// @Param version path int true "Version to restore"
+// @Success 200 {object} models.Food
+// @Failure 400 {object} object "Version is a deletion, or its menu or modifier groups no longer exist"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 404 {object} object "Version not found"
+// @Failure 500 {object} object "Error rolling back food"
+// @Router /foods/{food_id}/versions/{version}/rollback [post]
+func RollbackFood() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		foodId := c.Param("food_id")
+		number, err := strconv.Atoi(c.Param("version"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a number"})
+			return
+		}
+		var target models.Food_Version
+		if err := foodVersionCollection.FindOne(ctx, bson.M{"food_id": foodId, "version": number}).Decode(&target); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+			return
+		}
+		if err := checkFoodRestorable(ctx, target); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		current, found, err := baselineFood(ctx, foodId)
+		if err == nil && target.Food.Menu_Id != nil {
+			err = baselineMenu(ctx, *target.Food.Menu_Id)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error recording version"})
+			return
+		}
+		previousMenuId := ""
+		var restored models.Food
+		if found {
+			if current.Menu_Id != nil {
+				previousMenuId = *current.Menu_Id
+			}
+			restored, err = restoreFood(ctx, target.Food, &current, 0)
+		} else {
+			restored, err = restoreFood(ctx, target.Food, nil, latestFoodVersion(ctx, foodId))
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rolling back food"})
+			return
+		}
+		userId := c.GetString("user_id")
+		if err := versionFoodChange(ctx, restored, helpers.VersionRolledBack, userId, previousMenuId); err != nil {
+			logVersionError("food", foodId, err)
+		}
+		recordAudit(ctx, "FOOD_ROLLED_BACK", "food", foodId, userId, 0, "version "+strconv.Itoa(number))
+		invalidateSearchIndex()
+		refreshFoodCosts(ctx, helpers.CostFoodPrice, []string{foodId})
+
+		c.JSON(http.StatusOK, restored)
+	}
+}
+
+// GetMenuVersions godoc
+// @Summary List a menu's versions
+// @Description Retrieve every recorded version of a menu, newest first, without the foods. A version with a food_id was made by a change to that food.
+// @Tags versions
+// @Produce json
+// @Param menu_id path string true "Menu ID"
+// @Success 200 {array} models.Menu_Version
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /menus/{menu_id}/versions [get]
+func GetMenuVersions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}}).SetProjection(bson.M{"foods": 0})
+		cursor, err := menuVersionCollection.Find(ctx, bson.M{"menu_id": c.Param("menu_id")}, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		versions := []models.Menu_Version{}
+		if err = cursor.All(ctx, &versions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, versions)
+	}
+}
+
+// GetMenuVersion godoc
+// @Summary Get a menu version
+// @Description Retrieve a menu as it was in one version, with its foods
+// @Tags versions
+// @Produce json
+// @Param menu_id path string true "Menu ID"
+// @Param version path int true "Version"
+// @Success 200 {object} models.Menu_Version
+// @Failure 404 {object} object "Version not found"
+// @Router /menus/{menu_id}/versions/{version} [get]
+func GetMenuVersion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		version, err := menuVersion(ctx, c.Param("menu_id"), c.Param("version"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+			return
+		}
+		c.JSON(http.StatusOK, version)
+	}
+}
+
+// DiffMenuVersions godoc
+// @Summary Compare two menu versions
+// @Description List what changed on a menu between two versions: its own fields, and the foods added, removed or changed, field by field. Compares with the latest version when to is omitted.
+// @Tags versions
+// @Produce json
+// @Param menu_id path string true "Menu ID"
+// @Param from query int true "Earlier version"
+// @Param to query int false "Later version (latest by default)"
+// @Success 200 {object} helpers.MenuDiff
+// @Failure 400 {object} object "Missing from version"
+// @Failure 404 {object} object "Version not found"
+// @Router /menus/{menu_id}/diff [get]
+func DiffMenuVersions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		menuId := c.Param("menu_id")
+		if c.Query("from") == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from version is required"})
+			return
+		}
+		from, err := menuVersion(ctx, menuId, c.Query("from"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version " + c.Query("from") + " not found"})
+			return
+		}
+		var to models.Menu_Version
+		if value := c.Query("to"); value != "" {
+			if to, err = menuVersion(ctx, menuId, value); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Version " + value + " not found"})
+				return
+			}
+		} else {
+			opts := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})
+			if err := menuVersionCollection.FindOne(ctx, bson.M{"menu_id": menuId}, opts).Decode(&to); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, helpers.DiffMenuVersions(from, to))
+	}
+}
+
+// RollbackMenu godoc
+// @Summary Roll a menu back to an earlier version
+// @Description Restore a menu and its foods as they were in a version. Foods that have changed since are rolled back, deleted ones are recreated and hidden ones shown again. Foods added to the menu since are hidden rather than deleted, so past orders still resolve. Every food restored and the menu get a new version. Requires the manager role.
+// @Tags versions
+// @Produce json
+// @Param menu_id path string true "Menu ID"
+// @Param version path int true "Version to restore"
+// @Success 200 {object} models.Menu_Version
+// @Failure 400 {object} object "Version is a deletion, or modifier groups it uses no longer exist"
+// @Failure 403 {object} object "Manager role required"
+// @Failure 404 {object} object "Version not found"
+// @Failure 500 {object} object "Error rolling back menu"
+// @Router /menus/{menu_id}/versions/{version}/rollback [post]
+func RollbackMenu() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckUserRole(c, "manager", "admin"); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager role required"})
+			return
+		}
+		menuId := c.Param("menu_id")
+		target, err := menuVersion(ctx, menuId, c.Param("version"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+			return
+		}
+		if target.Change == helpers.VersionDeleted {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "version " + c.Param("version") + " is the menu's deletion; roll back to an earlier one"})
+			return
+		}
+		for _, food := range target.Foods {
+			if err := modifierGroupsExist(ctx, food.Modifier_Groups); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": food.Food_Name + ": " + err.Error()})
+				return
+			}
+		}
+
+		// Baseline the menu and its foods before anything changes, so a
+		// failure here leaves the menu as it was.
+		if err := baselineMenu(ctx, menuId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error recording version"})
+			return
+		}
+		currentFoods := map[string]models.Food{}
+		foundFoods := map[string]bool{}
+		for _, snapshot := range target.Foods {
+			current, found, err := baselineFood(ctx, *snapshot.Food_Id)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error recording version"})
+				return
+			}
+			currentFoods[*snapshot.Food_Id] = current
+			foundFoods[*snapshot.Food_Id] = found
+		}
+		var existing models.Menu
+		var restored models.Menu
+		if menuCollection.FindOne(ctx, bson.M{"menu_id": menuId}).Decode(&existing) == nil {
+			restored, err = restoreMenu(ctx, target.Menu, &existing, 0)
+		} else {
+			restored, err = restoreMenu(ctx, target.Menu, nil, latestMenuVersion(ctx, menuId))
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rolling back menu"})
+			return
+		}
+
+		userId := c.GetString("user_id")
+		kept := map[string]bool{}
+		var restoredFoods []string
+		for _, snapshot := range target.Foods {
+			foodId := *snapshot.Food_Id
+			kept[foodId] = true
+			current, found := currentFoods[foodId], foundFoods[foodId]
+			if found && current.Menu_Id != nil && *current.Menu_Id == menuId && current.Availability != helpers.Hidden && len(helpers.FoodChanges(current, snapshot)) == 0 {
+				continue
+			}
+			var food models.Food
+			if found {
+				food, err = restoreFood(ctx, snapshot, &current, 0)
+			} else {
+				food, err = restoreFood(ctx, snapshot, nil, latestFoodVersion(ctx, foodId))
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rolling back menu"})
+				return
+			}
+			err = recordFoodVersion(ctx, food, helpers.VersionRolledBack, userId)
+			if err == nil && found && current.Menu_Id != nil && *current.Menu_Id != menuId {
+				err = recordMenuVersion(ctx, *current.Menu_Id, helpers.VersionRolledBack, foodId, userId)
+			}
+			if err != nil {
+				logVersionError("food", foodId, err)
+			}
+			if found && current.Availability == helpers.Hidden {
+				setRolledBackAvailability(ctx, food, helpers.Available, userId)
+			}
+			restoredFoods = append(restoredFoods, foodId)
+		}
+
+		foods, err := menuFoods(ctx, menuId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rolling back menu"})
+			return
+		}
+		for _, food := range foods {
+			if !kept[*food.Food_Id] {
+				setRolledBackAvailability(ctx, food, helpers.Hidden, userId)
+			}
+		}
+		if foods, err = menuFoods(ctx, menuId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rolling back menu"})
+			return
+		}
+		version, err := insertMenuVersion(ctx, restored, foods, helpers.VersionRolledBack, "", userId)
+		if err != nil {
+			logVersionError("menu", menuId, err)
+		}
+		recordAudit(ctx, "MENU_ROLLED_BACK", "menu", menuId, userId, 0, "version "+c.Param("version"))
+		invalidateSearchIndex()
+		if len(restoredFoods) > 0 {
+			refreshFoodCosts(ctx, helpers.CostFoodPrice, restoredFoods)
+		}
+
+		c.JSON(http.StatusOK, version)
+	}
+}
+
+func foodVersions(ctx context.Context, foodId string) ([]models.Food_Version, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}})
+	cursor, err := foodVersionCollection.Find(ctx, bson.M{"food_id": foodId}, opts)
+	if err != nil {
+		return nil, err
+	}
+	versions := []models.Food_Version{}
+	err = cursor.All(ctx, &versions)
+	return versions, err
+}
+
+func menuVersion(ctx context.Context, menuId, number string) (models.Menu_Version, error) {
+	var version models.Menu_Version
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return version, err
+	}
+	err = menuVersionCollection.FindOne(ctx, bson.M{"menu_id": menuId, "version": n}).Decode(&version)
+	return version, err
+}
+
+func latestFoodVersion(ctx context.Context, foodId string) int {
+	var version models.Food_Version
+	opts := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})
+	foodVersionCollection.FindOne(ctx, bson.M{"food_id": foodId}, opts).Decode(&version)
+	return version.Version
+}
+
+func latestMenuVersion(ctx context.Context, menuId string) int {
+	var version models.Menu_Version
+	opts := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})
+	menuVersionCollection.FindOne(ctx, bson.M{"menu_id": menuId}, opts).Decode(&version)
+	return version.Version
+}
+
+// menuFoods are the foods shown on a menu, in menu order.
+func menuFoods(ctx context.Context, menuId string) ([]models.Food, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "display_order", Value: 1}, {Key: "food_name", Value: 1}})
+	cursor, err := foodCollection.Find(ctx, bson.M{"menu_id": menuId, "availability": bson.M{"$ne": helpers.Hidden}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	foods := []models.Food{}
+	err = cursor.All(ctx, &foods)
+	return foods, err
+}
+
+// versionedSince is when a document's current state took effect, as far as
+// its timestamps tell.
+func versionedSince(created, updated time.Time) time.Time {
+	if updated.After(created) {
+		return updated
+	}
+	return created
+}
+
+// baselineFood records a food as it stands before its first versioned
+// change, along with its menu, so the change has something to be compared
+// with. It returns the food and whether it exists.
+func baselineFood(ctx context.Context, foodId string) (models.Food, bool, error) {
+	var food models.Food
+	err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food)
+	if err == mongo.ErrNoDocuments {
+		return food, false, nil
+	}
+	if err != nil {
+		return food, false, err
+	}
+	if food.Menu_Id != nil {
+		if err := baselineMenu(ctx, *food.Menu_Id); err != nil {
+			return food, true, err
+		}
+	}
+	if food.Version != 0 {
+		return food, true, nil
+	}
+	result, err := foodCollection.UpdateOne(ctx, bson.M{"food_id": foodId, "version": bson.M{"$exists": false}}, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "version", Value: 1}}},
+	})
+	if err != nil {
+		return food, true, err
+	}
+	if result.ModifiedCount == 1 {
+		food.Version = 1
+		err = insertFoodVersion(ctx, food, helpers.VersionBaseline, "", versionedSince(food.Created_AT, food.Updated_AT))
+	}
+	return food, true, err
+}
+
+// baselineMenu records a menu and its foods as they stand before the menu's
+// first versioned change.
+func baselineMenu(ctx context.Context, menuId string) error {
+	var menu models.Menu
+	if err := menuCollection.FindOne(ctx, bson.M{"menu_id": menuId}).Decode(&menu); err != nil || menu.Version != 0 {
+		return nil
+	}
+	foods, err := menuFoods(ctx, menuId)
+	if err != nil {
+		return err
+	}
+	result, err := menuCollection.UpdateOne(ctx, bson.M{"menu_id": menuId, "version": bson.M{"$exists": false}}, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "version", Value: 1}}},
+	})
+	if err != nil || result.ModifiedCount == 0 {
+		return err
+	}
+	menu.Version = 1
+	since := versionedSince(menu.Created_At, menu.Updated_At)
+	for _, food := range foods {
+		since = versionedSince(since, versionedSince(food.Created_AT, food.Updated_AT))
+	}
+	return storeVersion(ctx, menuVersionCollection, models.Menu_Version{
+		Menu_Id:        menuId,
+		Version:        1,
+		Change:         helpers.VersionBaseline,
+		Menu:           menu,
+		Foods:          foods,
+		Effective_From: since,
+	})
+}
+
+func insertFoodVersion(ctx context.Context, food models.Food, change, userId string, effectiveFrom time.Time) error {
+	return storeVersion(ctx, foodVersionCollection, models.Food_Version{
+		Food_Id:        *food.Food_Id,
+		Version:        food.Version,
+		Change:         change,
+		Food:           food,
+		Effective_From: effectiveFrom,
+		Changed_By:     userId,
+	})
+}
+
+// recordFoodVersion stores food, already saved with its new version
+// number, as taking effect now.
+func recordFoodVersion(ctx context.Context, food models.Food, change, userId string) error {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	return insertFoodVersion(ctx, food, change, userId, now)
+}
+
+// insertMenuVersion stores menu, already saved with its new version number,
+// and foods as taking effect now.
+func insertMenuVersion(ctx context.Context, menu models.Menu, foods []models.Food, change, foodId, userId string) (models.Menu_Version, error) {
+	version := models.Menu_Version{
+		Menu_Id:    menu.Menu_Id,
+		Version:    menu.Version,
+		Change:     change,
+		Food_Id:    foodId,
+		Menu:       menu,
+		Foods:      foods,
+		Changed_By: userId,
+	}
+	version.Effective_From, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	return version, storeVersion(ctx, menuVersionCollection, version)
+}
+
+// recordMenuVersion adds a version to a menu after one of its foods
+// changed. A menu that no longer exists is left alone.
+func recordMenuVersion(ctx context.Context, menuId, change, foodId, userId string) error {
+	var menu models.Menu
+	err := menuCollection.FindOneAndUpdate(ctx, bson.M{"menu_id": menuId}, bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&menu)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	foods, err := menuFoods(ctx, menuId)
+	if err != nil {
+		return err
+	}
+	_, err = insertMenuVersion(ctx, menu, foods, change, foodId, userId)
+	return err
+}
+
+// versionFoodChange records a new version of food and of the menu it is on,
+// and of the menu it left when it moved.
+func versionFoodChange(ctx context.Context, food models.Food, change, userId, previousMenuId string) error {
+	if err := recordFoodVersion(ctx, food, change, userId); err != nil {
+		return err
+	}
+	if food.Menu_Id != nil {
+		if err := recordMenuVersion(ctx, *food.Menu_Id, change, *food.Food_Id, userId); err != nil {
+			return err
+		}
+		if previousMenuId == *food.Menu_Id {
+			return nil
+		}
+	}
+	if previousMenuId != "" {
+		return recordMenuVersion(ctx, previousMenuId, change, *food.Food_Id, userId)
+	}
+	return nil
+}
+
+// logVersionError reports a version that could not be recorded for a change
+// that is already saved. The change stands, so the request still succeeds.
+func logVersionError(entity, id string, err error) {
+	log.Println("Error recording version of "+entity, id+":", err)
+}
+
+// storeVersion inserts a food or menu version. The unique indexes turn a
+// version number recorded twice into an error instead of a second history.
+func storeVersion(ctx context.Context, collection *mongo.Collection, version interface{}) error {
+	versionIndexesSet.Do(func() { ensureVersionIndexes(ctx) })
+	if _, err := collection.InsertOne(ctx, version); err != nil {
+		log.Println("Error recording version:", err)
+		return err
+	}
+	return nil
+}
+
+// ensureVersionIndexes makes version numbers unique per food and per menu.
+// Failures are logged; versions are still recorded without the guarantee.
+func ensureVersionIndexes(ctx context.Context) {
+	_, err := foodVersionCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "food_id", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetName("food_version_unique").SetUnique(true),
+	})
+	if err != nil {
+		log.Println("Error creating food version index:", err)
+	}
+	_, err = menuVersionCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "menu_id", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetName("menu_version_unique").SetUnique(true),
+	})
+	if err != nil {
+		log.Println("Error creating menu version index:", err)
+	}
+}
+
+func checkFoodRestorable(ctx context.Context, version models.Food_Version) error {
+	if version.Change == helpers.VersionDeleted {
+		return errors.New("version " + strconv.Itoa(version.Version) + " is the food's deletion; roll back to an earlier one")
+	}
+	if version.Food.Menu_Id != nil {
+		if count, err := menuCollection.CountDocuments(ctx, bson.M{"menu_id": *version.Food.Menu_Id}); err != nil || count == 0 {
+			return errors.New("menu " + *version.Food.Menu_Id + " no longer exists")
+		}
+	}
+	return modifierGroupsExist(ctx, version.Food.Modifier_Groups)
+}
+
+// restoreFood puts a food back as it was in snapshot and numbers it as a new
+// version. A deleted food (current nil) is recreated after lastVersion,
+// available and without its old image files.
+func restoreFood(ctx context.Context, snapshot models.Food, current *models.Food, lastVersion int) (models.Food, error) {
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	if current == nil {
+		food := snapshot
+		food.Images = nil
+		food.Availability = ""
+		food.Sold_Out_Until = nil
+		food.Updated_AT = updatedAt
+		food.Version = lastVersion + 1
+		_, err := foodCollection.InsertOne(ctx, food)
+		return food, err
+	}
+
+	var food models.Food
+	err := foodCollection.FindOneAndUpdate(ctx, bson.M{"food_id": *snapshot.Food_Id}, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "food_name", Value: snapshot.Food_Name},
+			{Key: "food_price", Value: snapshot.Food_Price},
+			{Key: "food_description", Value: snapshot.Food_Description},
+			{Key: "menu_id", Value: snapshot.Menu_Id},
+			{Key: "station", Value: snapshot.Station},
+			{Key: "display_order", Value: snapshot.Display_Order},
+			{Key: "modifier_groups", Value: snapshot.Modifier_Groups},
+			{Key: "allergens", Value: snapshot.Allergens},
+			{Key: "dietary_tags", Value: snapshot.Dietary_Tags},
+			{Key: "nutrition", Value: snapshot.Nutrition},
+			{Key: "portion", Value: snapshot.Portion},
+			{Key: "updated_at", Value: updatedAt},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&food)
+	return food, err
+}
+
+// restoreMenu puts a menu back as it was in snapshot and numbers it as a new
+// version. A deleted menu (current nil) is recreated after lastVersion.
+func restoreMenu(ctx context.Context, snapshot models.Menu, current *models.Menu, lastVersion int) (models.Menu, error) {
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	if current == nil {
+		menu := snapshot
+		menu.Updated_At = updatedAt
+		menu.Version = lastVersion + 1
+		_, err := menuCollection.InsertOne(ctx, menu)
+		return menu, err
+	}
+
+	var menu models.Menu
+	err := menuCollection.FindOneAndUpdate(ctx, bson.M{"menu_id": snapshot.Menu_Id}, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "name", Value: snapshot.Name},
+			{Key: "catagory", Value: snapshot.Catagory},
+			{Key: "start_date", Value: snapshot.Start_Date},
+			{Key: "end_date", Value: snapshot.End_Date},
+			{Key: "display_order", Value: snapshot.Display_Order},
+			{Key: "service_windows", Value: snapshot.Service_Windows},
+			{Key: "exceptions", Value: snapshot.Exceptions},
+			{Key: "updated_at", Value: updatedAt},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&menu)
+	return menu, err
+}
+
+// setRolledBackAvailability shows or hides a food as a menu rollback
+// requires, and pushes the change to the 86 board like a manual toggle.
+func setRolledBackAvailability(ctx context.Context, food models.Food, status, userId string) {
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := foodCollection.UpdateOne(ctx, bson.M{"food_id": *food.Food_Id}, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "availability", Value: status},
+			{Key: "sold_out_until", Value: nil},
+			{Key: "updated_at", Value: updatedAt},
+		}},
+	})
+	if err != nil {
+		log.Println("Error updating availability:", err)
+		return
+	}
+	recordAudit(ctx, "FOOD_AVAILABILITY", "food", *food.Food_Id, userId, 0, status)
+	helpers.Events.Publish(helpers.Event{Type: foodAvailabilityEvent, Data: foodAvailability{Food_Id: *food.Food_Id, Food_Name: food.Food_Name, Status: status}})
+}
@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var wasteCollection = database.OpenCollection(database.Client, "waste_events")
+
+// LogWaste godoc
+// @Summary Log food waste
+// @Description Record an ingredient (in its unit) or portions of a dish thrown away, with the reason (SPOILED, DROPPED, RETURNED or OVERPRODUCTION) and the staff member, who defaults to the caller. The waste is costed at current ingredient costs and taken out of stock as WASTE movements. A dish wasted from an order, such as one returned by a guest, was already taken out of stock when it was fired, so it is costed but not deducted again; portions beyond those fired on the order are deducted.
+// @Tags waste
+// @Accept json
+// @Produce json
+// @Param waste body models.Waste_Event true "Waste event"
+// @Success 200 {object} models.Waste_Event
+// @Failure 400 {object} object "Invalid input, unknown branch, ingredient, dish, staff member or order"
+// @Failure 500 {object} object "Error logging waste"
+// @Router /waste [post]
+func LogWaste() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var event models.Waste_Event
+		if err := c.BindJSON(&event); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationErr := validate.Struct(event); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		if (event.Ingredient_Id == "") == (event.Food_Id == "") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "waste needs either ingredient_id or food_id"})
+			return
+		}
+		branchId, err := inventoryBranch(ctx, event.Branch_Id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		event.Branch_Id = branchId
+		if event.Staff_Id == "" {
+			event.Staff_Id = c.GetString("user_id")
+		} else if count, err := userCollection.CountDocuments(ctx, bson.M{"user_id": event.Staff_Id}); err != nil || count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "staff member " + event.Staff_Id + " not found"})
+			return
+		}
+		if event.Order_Id != "" {
+			if count, err := orderCollection.CountDocuments(ctx, bson.M{"order_id": event.Order_Id}); err != nil || count == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "order " + event.Order_Id + " not found"})
+				return
+			}
+		}
+
+		event.Quantity = helpers.RoundQuantity(event.Quantity)
+		usage, err := wasteUsage(ctx, &event)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		event.ID = primitive.NewObjectID()
+		event.Waste_Id = event.ID.Hex()
+		event.Created_By = c.GetString("user_id")
+		event.Created_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		if _, err := wasteCollection.InsertOne(ctx, event); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error logging waste"})
+			return
+		}
+		for _, ingredientId := range helpers.UsageIngredients(usage) {
+			_, err := postStockMovement(ctx, models.Stock_Movement{
+				Ingredient_Id: ingredientId,
+				Branch_Id:     event.Branch_Id,
+				Type:          helpers.MovementWaste,
+				Quantity:      -usage[ingredientId],
+				Order_Id:      event.Order_Id,
+				Waste_Id:      event.Waste_Id,
+				Reason:        event.Reason,
+				Created_By:    event.Created_By,
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error logging waste"})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, event)
+	}
+}
+
+// GetWasteEvents godoc
+// @Summary List waste events
+// @Description Retrieve logged waste, newest first
+// @Tags waste
+// @Produce json
+// @Param branch_id query string false "Branch ID"
+// @Param reason query string false "SPOILED, DROPPED, RETURNED or OVERPRODUCTION"
+// @Param staff_id query string false "Staff member"
+// @Param ingredient_id query string false "Ingredient ID"
+// @Param food_id query string false "Food ID"
+// @Param from query string false "First day, YYYY-MM-DD"
+// @Param to query string false "Last day, YYYY-MM-DD"
+// @Success 200 {array} models.Waste_Event
+// @Failure 400 {object} object "Invalid dates"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /waste [get]
+func GetWasteEvents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		for _, field := range []string{"branch_id", "reason", "staff_id", "ingredient_id", "food_id"} {
+			if value := c.Query(field); value != "" {
+				filter[field] = value
+			}
+		}
+		created := bson.M{}
+		if value := c.Query("from"); value != "" {
+			from, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+				return
+			}
+			created["$gte"] = from
+		}
+		if value := c.Query("to"); value != "" {
+			to, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+				return
+			}
+			created["$lt"] = to.AddDate(0, 0, 1)
+		}
+		if len(created) > 0 {
+			filter["created_at"] = created
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		cursor, err := wasteCollection.Find(ctx, filter, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		events := []models.Waste_Event{}
+		if err = cursor.All(ctx, &events); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, events)
+	}
+}
+
+// GetWasteReport godoc
+// @Summary Summarize waste cost
+// @Description Total the cost of waste by reason, by day and by the ten items that cost the most. Days are counted in the branch's timezone, from the first of this month to today by default.
+// @Tags waste
+// @Produce json
+// @Param branch_id query string false "Branch ID (all branches when omitted)"
+// @Param from query string false "First day, YYYY-MM-DD"
+// @Param to query string false "Last day, YYYY-MM-DD"
+// @Success 200 {object} helpers.WasteSummary
+// @Failure 400 {object} object "Invalid dates"
+// @Failure 500 {object} object "Internal Server Error"
+// @Router /reports/waste [get]
+func GetWasteReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		branchId := c.Query("branch_id")
+		location := helpers.BranchLocation(branchForTable(ctx, models.Table{Branch_Id: branchId}))
+		now := time.Now().In(location)
+		from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, location)
+		to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+		var err error
+		if value := c.Query("from"); value != "" {
+			if from, err = time.ParseInLocation("2006-01-02", value, location); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+				return
+			}
+		}
+		if value := c.Query("to"); value != "" {
+			if to, err = time.ParseInLocation("2006-01-02", value, location); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+				return
+			}
+		}
+
+		filter := bson.M{"created_at": bson.M{"$gte": from, "$lt": to.AddDate(0, 0, 1)}}
+		if branchId != "" {
+			filter["branch_id"] = branchId
+		}
+		cursor, err := wasteCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var events []models.Waste_Event
+		if err = cursor.All(ctx, &events); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"from":    from.Format("2006-01-02"),
+			"to":      to.Format("2006-01-02"),
+			"summary": helpers.SummarizeWaste(events, location),
+		})
+	}
+}
+
+// wasteUsage names and costs a waste event and works out what it takes out
+// of stock. Portions of a dish wasted from an order that were fired on that
+// order take nothing, since the order's sale already did; any more than were
+// fired, or than earlier waste already accounted for, are deducted.
+func wasteUsage(ctx context.Context, event *models.Waste_Event) (map[string]float64, error) {
+	if event.Ingredient_Id != "" {
+		ingredient, err := activeIngredient(ctx, event.Ingredient_Id)
+		if err != nil {
+			return nil, err
+		}
+		event.Name = ingredient.Name
+		event.Unit = ingredient.Unit
+		event.Cost = toFixed(event.Quantity*ingredient.Unit_Cost, 2)
+		return map[string]float64{ingredient.Ingredient_Id: event.Quantity}, nil
+	}
+
+	var food models.Food
+	if err := foodCollection.FindOne(ctx, bson.M{"food_id": event.Food_Id}).Decode(&food); err != nil {
+		return nil, fmt.Errorf("food %s not found", event.Food_Id)
+	}
+	event.Name = food.Food_Name
+	event.Unit = "portion"
+	var recipe models.Recipe
+	if err := recipeCollection.FindOne(ctx, bson.M{"food_id": event.Food_Id}).Decode(&recipe); err != nil {
+		// Without a recipe the dish can be logged but neither costed nor
+		// taken out of stock.
+		return nil, nil
+	}
+	ingredients, err := ingredientsById(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, cost, _ := helpers.RecipeCost(recipe, ingredients)
+	event.Cost = toFixed(cost*event.Quantity, 2)
+	deducted := event.Quantity
+	if event.Order_Id != "" {
+		sold, err := unwastedFiredPortions(ctx, event.Order_Id, event.Food_Id)
+		if err != nil {
+			return nil, err
+		}
+		deducted = helpers.RoundQuantity(math.Max(event.Quantity-sold, 0))
+	}
+	if deducted == 0 {
+		return nil, nil
+	}
+	return helpers.RecipeUsage(recipe, deducted), nil
+}
+
+// unwastedFiredPortions is how many portions of a food were fired on an
+// order, and so are still out of stock, less those already logged as waste
+// from the order. Items whose stock was put back by a void or delete do not
+// count.
+func unwastedFiredPortions(ctx context.Context, orderId, foodId string) (float64, error) {
+	var fired, wasted float64
+	cursor, err := orderItemCollection.Find(ctx, bson.M{"order_id": orderId, "food_id": foodId, "fired_at": bson.M{"$exists": true}})
+	if err != nil {
+		return 0, err
+	}
+	var items []models.Ordered_Item
+	if err = cursor.All(ctx, &items); err != nil {
+		return 0, err
+	}
+	cursor, err = stockMovementCollection.Find(ctx, bson.M{
+		"order_item_id": bson.M{"$in": orderItemIds(items)},
+		"type":          helpers.MovementVoid,
+	})
+	if err != nil {
+		return 0, err
+	}
+	var voids []models.Stock_Movement
+	if err = cursor.All(ctx, &voids); err != nil {
+		return 0, err
+	}
+	restored := map[string]bool{}
+	for _, movement := range voids {
+		restored[movement.Order_Item_Id] = true
+	}
+	for _, item := range items {
+		if !restored[item.Order_Item_Id] {
+			fired += float64(item.Quantity)
+		}
+	}
+	cursor, err = wasteCollection.Find(ctx, bson.M{"order_id": orderId, "food_id": foodId})
+	if err != nil {
+		return 0, err
+	}
+	var events []models.Waste_Event
+	if err = cursor.All(ctx, &events); err != nil {
+		return 0, err
+	}
+	for _, event := range events {
+		wasted += event.Quantity
+	}
+	return math.Max(fired-wasted, 0), nil
+}
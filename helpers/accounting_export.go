@@ -0,0 +1,180 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// DefaultAccountMapping is a plain restaurant chart of accounts.
+func DefaultAccountMapping() models.Account_Mapping {
+	return models.Account_Mapping{
+		Cash:                "Cash on Hand",
+		Card:                "Card Clearing",
+		Mobile_Money:        "Mobile Money Clearing",
+		Accounts_Receivable: "Accounts Receivable",
+		Sales:               "Food & Beverage Sales",
+		Sales_Discounts:     "Sales Discounts",
+		Sales_Returns:       "Sales Returns",
+		Service_Charges:     "Service Charge Income",
+		Tax_Payable:         "Sales Tax Payable",
+		Tips_Payable:        "Tips Payable",
+		Gift_Card_Liability: "Gift Card Liability",
+		Loyalty_Redemptions: "Loyalty Redemptions",
+	}
+}
+
+// TaxAccount is the account for a tax code, falling back to Tax_Payable.
+func TaxAccount(mapping models.Account_Mapping, code string) string {
+	if account, ok := mapping.Tax_Accounts[code]; ok && account != "" {
+		return account
+	}
+	return mapping.Tax_Payable
+}
+
+// TenderAccount is the account a payment method settles into.
+func TenderAccount(mapping models.Account_Mapping, method string) string {
+	switch method {
+	case "CASH":
+		return mapping.Cash
+	case "CARD":
+		return mapping.Card
+	case "MOBILE_MONEY":
+		return mapping.Mobile_Money
+	case "GIFT_CARD":
+		return mapping.Gift_Card_Liability
+	case "LOYALTY_POINTS":
+		return mapping.Loyalty_Redemptions
+	}
+	return mapping.Cash
+}
+
+// JournalBuilder collects the lines of one entry, merging lines on the same
+// account and dropping zero amounts.
+type JournalBuilder struct {
+	entry models.Journal_Entry
+}
+
+func NewJournalEntry(id, date, source, sourceId, memo string) *JournalBuilder {
+	return &JournalBuilder{entry: models.Journal_Entry{
+		Entry_Id:  id,
+		Date:      date,
+		Source:    source,
+		Source_Id: sourceId,
+		Memo:      memo,
+	}}
+}
+
+func (b *JournalBuilder) Debit(account string, amount float64) *JournalBuilder {
+	return b.post(account, amount)
+}
+
+func (b *JournalBuilder) Credit(account string, amount float64) *JournalBuilder {
+	return b.post(account, -amount)
+}
+
+func (b *JournalBuilder) post(account string, amount float64) *JournalBuilder {
+	amount = roundMoney(amount)
+	if amount == 0 {
+		return b
+	}
+	for i, line := range b.entry.Lines {
+		if line.Account == account {
+			net := roundMoney(line.Debit - line.Credit + amount)
+			b.entry.Lines[i] = journalLine(account, net)
+			return b
+		}
+	}
+	b.entry.Lines = append(b.entry.Lines, journalLine(account, amount))
+	return b
+}
+
+func journalLine(account string, net float64) models.Journal_Line {
+	if net >= 0 {
+		return models.Journal_Line{Account: account, Debit: net}
+	}
+	return models.Journal_Line{Account: account, Credit: -net}
+}
+
+// Entry returns the finished entry, or an error when it does not balance.
+func (b *JournalBuilder) Entry() (models.Journal_Entry, error) {
+	var debit, credit float64
+	lines := b.entry.Lines[:0]
+	for _, line := range b.entry.Lines {
+		if line.Debit == 0 && line.Credit == 0 {
+			continue
+		}
+		debit += line.Debit
+		credit += line.Credit
+		lines = append(lines, line)
+	}
+	b.entry.Lines = lines
+	if math.Abs(roundMoney(debit-credit)) > 0 {
+		return b.entry, fmt.Errorf("journal entry %s does not balance: debit %.2f, credit %.2f", b.entry.Entry_Id, debit, credit)
+	}
+	return b.entry, nil
+}
+
+// JournalCSV writes one row per journal line.
+func JournalCSV(entries []models.Journal_Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write([]string{"Date", "Entry", "Source", "Source ID", "Account", "Debit", "Credit", "Memo"}); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		for _, line := range entry.Lines {
+			err := w.Write([]string{
+				entry.Date, entry.Entry_Id, entry.Source, entry.Source_Id, line.Account,
+				csvAmount(line.Debit), csvAmount(line.Credit), entry.Memo,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// JournalIIF writes the entries as QuickBooks general journal transactions.
+// In IIF debits are positive and credits negative.
+func JournalIIF(entries []models.Journal_Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("!TRNS\tTRNSID\tTRNSTYPE\tDATE\tACCNT\tAMOUNT\tDOCNUM\tMEMO\n")
+	buf.WriteString("!SPL\tSPLID\tTRNSTYPE\tDATE\tACCNT\tAMOUNT\tDOCNUM\tMEMO\n")
+	buf.WriteString("!ENDTRNS\n")
+	for _, entry := range entries {
+		date, err := time.Parse("2006-01-02", entry.Date)
+		if err != nil {
+			return nil, err
+		}
+		for i, line := range entry.Lines {
+			kind := "SPL"
+			if i == 0 {
+				kind = "TRNS"
+			}
+			fmt.Fprintf(&buf, "%s\t\tGENERAL JOURNAL\t%s\t%s\t%.2f\t%s\t%s\n",
+				kind, date.Format("01/02/2006"), iifField(line.Account),
+				roundMoney(line.Debit-line.Credit), iifField(entry.Entry_Id), iifField(entry.Memo))
+		}
+		buf.WriteString("ENDTRNS\n")
+	}
+	return buf.Bytes(), nil
+}
+
+func csvAmount(amount float64) string {
+	if amount == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.2f", amount)
+}
+
+func iifField(value string) string {
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ", `"`, "'").Replace(value)
+}
@@ -0,0 +1,170 @@
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Allergens are the allergens foods and modifier options can declare, the
+// fourteen major allergens of EU labelling rules.
+var Allergens = []string{
+	"gluten", "crustaceans", "eggs", "fish", "peanuts", "soybeans", "milk",
+	"tree_nuts", "celery", "mustard", "sesame", "sulphites", "lupin", "molluscs",
+}
+
+// DietaryTags are the diets a food can be marked as suitable for. "fasting"
+// follows the Orthodox fasting rules (tsom): no meat, dairy or eggs.
+var DietaryTags = []string{"vegetarian", "vegan", "gluten_free", "dairy_free", "halal", "kosher", "fasting"}
+
+// allergenNoteWords lists, for each allergen, the words guests use for it
+// in allergy notes.
+var allergenNoteWords = map[string][]string{
+	"gluten":      {"gluten", "wheat", "barley", "rye", "celiac", "coeliac"},
+	"crustaceans": {"crustacean", "crustaceans", "shellfish", "shrimp", "prawn", "prawns", "crab", "lobster"},
+	"eggs":        {"egg", "eggs"},
+	"fish":        {"fish"},
+	"peanuts":     {"peanut", "peanuts", "groundnut", "groundnuts", "nut", "nuts"},
+	"soybeans":    {"soy", "soya", "soybean", "soybeans"},
+	"milk":        {"milk", "dairy", "lactose", "cheese", "butter", "cream", "ayib", "kibe"},
+	"tree_nuts":   {"nut", "nuts", "almond", "almonds", "cashew", "cashews", "walnut", "walnuts", "hazelnut", "hazelnuts", "pistachio", "pistachios"},
+	"celery":      {"celery"},
+	"mustard":     {"mustard"},
+	"sesame":      {"sesame", "tahini"},
+	"sulphites":   {"sulphite", "sulphites", "sulfite", "sulfites"},
+	"lupin":       {"lupin", "lupine"},
+	"molluscs":    {"mollusc", "molluscs", "mollusk", "mollusks", "shellfish", "squid", "octopus", "mussel", "mussels", "oyster", "oysters", "clam", "clams"},
+}
+
+// noteDietaryWords maps words in allergy notes to the dietary tag a dish
+// must carry to be safe to serve.
+var noteDietaryWords = map[string]string{
+	"vegan":      "vegan",
+	"vegetarian": "vegetarian",
+	"halal":      "halal",
+	"kosher":     "kosher",
+	"fasting":    "fasting",
+	"tsom":       "fasting",
+	"ጾም":         "fasting",
+	"ፆም":         "fasting",
+}
+
+// ItemAllergens is every allergen in a dish as ordered: the food's own plus
+// those of the chosen modifiers. Removal options do not take allergens away,
+// since the kitchen cannot promise there is no cross-contact.
+func ItemAllergens(food models.Food, modifiers []models.Selected_Modifier) []string {
+	seen := map[string]bool{}
+	var allergens []string
+	add := func(list []string) {
+		for _, allergen := range list {
+			if !seen[allergen] {
+				seen[allergen] = true
+				allergens = append(allergens, allergen)
+			}
+		}
+	}
+	add(food.Allergens)
+	for _, modifier := range modifiers {
+		add(modifier.Allergens)
+	}
+	sort.Strings(allergens)
+	return allergens
+}
+
+// NoteRestrictions reads the allergens to avoid and the dietary tags
+// required from a free-text allergy note such as "severe nut allergy, vegan".
+func NoteRestrictions(note string) ([]string, []string) {
+	allergenSet := map[string]bool{}
+	dietarySet := map[string]bool{}
+	for _, word := range SearchTerms(note) {
+		for allergen, words := range allergenNoteWords {
+			if containsString(words, word) {
+				allergenSet[allergen] = true
+			}
+		}
+		if tag, ok := noteDietaryWords[word]; ok {
+			dietarySet[tag] = true
+		}
+	}
+	return sortedKeys(allergenSet), sortedKeys(dietarySet)
+}
+
+// AllergyConflicts lists why a dish as ordered is unsafe for a guest who
+// must avoid allergens and needs the dietary tags required. A food that
+// declares no allergens is flagged too, since it cannot be vouched for. The
+// list is empty when the dish is safe.
+func AllergyConflicts(food models.Food, modifiers []models.Selected_Modifier, avoid, required []string) []string {
+	var conflicts []string
+	allergens := ItemAllergens(food, modifiers)
+	if len(avoid) > 0 && len(allergens) == 0 {
+		conflicts = append(conflicts, "allergens not declared")
+	}
+	for _, allergen := range avoid {
+		if containsString(allergens, allergen) {
+			conflicts = append(conflicts, "contains "+strings.ReplaceAll(allergen, "_", " "))
+		}
+	}
+	for _, tag := range required {
+		if !containsString(food.Dietary_Tags, tag) {
+			conflicts = append(conflicts, fmt.Sprintf("not marked %s", strings.ReplaceAll(tag, "_", " ")))
+		}
+	}
+	return conflicts
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// FoodTagFilter builds a Mongo filter for foods that have every tag in
+// include and none in exclude. Tags may be allergens or dietary tags.
+func FoodTagFilter(include, exclude []string) (bson.D, error) {
+	conditions := map[string]bson.M{}
+	for _, tags := range []struct {
+		list     []string
+		operator string
+	}{
+		{include, "$all"},
+		{exclude, "$nin"},
+	} {
+		for _, tag := range tags.list {
+			field := "allergens"
+			switch {
+			case containsString(Allergens, tag):
+			case containsString(DietaryTags, tag):
+				field = "dietary_tags"
+			default:
+				return nil, fmt.Errorf("unknown allergen or dietary tag %q", tag)
+			}
+			if conditions[field] == nil {
+				conditions[field] = bson.M{}
+			}
+			list, _ := conditions[field][tags.operator].([]string)
+			conditions[field][tags.operator] = append(list, tag)
+		}
+	}
+	filter := bson.D{}
+	for _, field := range []string{"allergens", "dietary_tags"} {
+		if conditions[field] != nil {
+			filter = append(filter, bson.E{Key: field, Value: conditions[field]})
+		}
+	}
+	return filter, nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	var keys []string
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
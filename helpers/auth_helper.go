@@ -0,0 +1,36 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CheckUserRole looks up the authenticated user and returns an error unless
+// their role is one of roles. The token only carries the user_id, so the role
+// is always read from the database.
+func CheckUserRole(c *gin.Context, roles ...string) error {
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userId := c.GetString("user_id")
+	if userId == "" {
+		return errors.New("unauthorized to access this resource")
+	}
+
+	var user models.User
+	if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+		return errors.New("unauthorized to access this resource")
+	}
+
+	for _, role := range roles {
+		if user.Role == role {
+			return nil
+		}
+	}
+	return errors.New("unauthorized to access this resource")
+}
@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"sync"
+	"time"
+)
+
+// Availability statuses for foods and modifier options. A dish is "86'd"
+// when it is sold out; hidden ones are left off menus altogether.
+const (
+	Available = "AVAILABLE"
+	SoldOut   = "SOLD_OUT"
+	Hidden    = "HIDDEN"
+)
+
+// CurrentAvailability resolves a stored status at t. An empty status is
+// available, and a sold out status whose until time has passed is available
+// again.
+func CurrentAvailability(status string, until *time.Time, t time.Time) (string, *time.Time) {
+	switch {
+	case status == "" || status == Available:
+		return Available, nil
+	case status == SoldOut && until != nil && !t.Before(*until):
+		return Available, nil
+	}
+	return status, until
+}
+
+// Event is a message pushed to connected clients.
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// EventBroker fans events out to every subscriber. A subscriber too slow to
+// keep up is unsubscribed and its channel closed rather than holding up the
+// publisher or silently missing events; its client reconnects and re-syncs
+// from a fresh snapshot.
+type EventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// Events is the broker used by the controllers.
+var Events = NewEventBroker()
+
+func NewEventBroker() *EventBroker {
+	return &EventBroker{subscribers: map[chan Event]struct{}{}}
+}
+
+// Subscribe returns a channel of events and a function that unsubscribes
+// and closes it. The channel is also closed if the subscriber falls behind.
+func (b *EventBroker) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, 32)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.drop(ch)
+	}
+}
+
+func (b *EventBroker) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			b.drop(ch)
+		}
+	}
+}
+
+// drop unsubscribes ch and closes it, once. b.mu must be held.
+func (b *EventBroker) drop(ch chan Event) {
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
@@ -0,0 +1,31 @@
+package helpers
+
+import "testing"
+
+func TestEventBrokerClosesSlowSubscriber(t *testing.T) {
+	broker := NewEventBroker()
+	slow, unsubscribeSlow := broker.Subscribe()
+	fast, unsubscribeFast := broker.Subscribe()
+	defer unsubscribeFast()
+
+	for i := 0; i <= cap(slow); i++ {
+		broker.Publish(Event{Type: "food_availability", Data: i})
+		<-fast
+	}
+
+	received := 0
+	for range slow {
+		received++
+	}
+	if received != cap(slow) {
+		t.Errorf("slow subscriber got %d events before its channel closed, want %d", received, cap(slow))
+	}
+
+	// The fast subscriber is unaffected, and unsubscribing a dropped
+	// subscriber does not close its channel twice.
+	unsubscribeSlow()
+	broker.Publish(Event{Type: "food_availability", Data: "after"})
+	if event := <-fast; event.Data != "after" {
+		t.Errorf("fast subscriber got %v", event.Data)
+	}
+}
@@ -0,0 +1,85 @@
+package helpers
+
+import (
+	"fmt"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// ComboPick is a checked combo selection with the upcharge of its choice.
+type ComboPick struct {
+	Slot      models.Combo_Slot
+	Selection models.Combo_Selection
+	Upcharge  float64
+}
+
+// ResolveComboSelections checks one pick per slot against the slot's choices
+// and returns the picks in slot order. Optional slots may be left empty.
+func ResolveComboSelections(combo models.Combo, selections []models.Combo_Selection) ([]ComboPick, error) {
+	bySlot := map[string]models.Combo_Selection{}
+	for _, selection := range selections {
+		if _, ok := bySlot[selection.Slot_Id]; ok {
+			return nil, fmt.Errorf("slot %s is chosen more than once", selection.Slot_Id)
+		}
+		bySlot[selection.Slot_Id] = selection
+	}
+
+	var picks []ComboPick
+	for _, slot := range combo.Slots {
+		selection, ok := bySlot[slot.Slot_Id]
+		if !ok {
+			if slot.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("%q needs a choice for %q", combo.Name, slot.Name)
+		}
+		delete(bySlot, slot.Slot_Id)
+		found := false
+		for _, choice := range slot.Choices {
+			if choice.Food_Id == selection.Food_Id {
+				picks = append(picks, ComboPick{Slot: slot, Selection: selection, Upcharge: choice.Upcharge})
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("food %s is not a choice for %q", selection.Food_Id, slot.Name)
+		}
+	}
+	for slotId := range bySlot {
+		return nil, fmt.Errorf("%q has no slot %s", combo.Name, slotId)
+	}
+	if len(picks) == 0 {
+		return nil, fmt.Errorf("%q needs at least one choice", combo.Name)
+	}
+	return picks, nil
+}
+
+// AllocateComboPrice splits a combo's price across its components in
+// proportion to their list prices, so each dish is credited with its share.
+// Components without list prices split it evenly. Rounding differences go to
+// the last component so the shares always add up to the price.
+func AllocateComboPrice(price float64, listPrices []float64) []float64 {
+	shares := make([]float64, len(listPrices))
+	if len(listPrices) == 0 {
+		return shares
+	}
+	var total float64
+	for _, listPrice := range listPrices {
+		total += listPrice
+	}
+	var allocated float64
+	for i, listPrice := range listPrices {
+		if i == len(listPrices)-1 {
+			shares[i] = roundMoney(price - allocated)
+			break
+		}
+		weight := 1 / float64(len(listPrices))
+		if total > 0 {
+			weight = listPrice / total
+		}
+		shares[i] = roundMoney(price * weight)
+		allocated += shares[i]
+	}
+	return shares
+}
@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"math"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// Reasons a food cost snapshot was stored.
+const (
+	CostIngredientPrice = "INGREDIENT_PRICE"
+	CostRecipe          = "RECIPE"
+	CostFoodPrice       = "FOOD_PRICE"
+	CostRecalculated    = "RECALCULATED"
+)
+
+// RecipeCost prices the lines of a recipe at the ingredients' unit costs.
+// Ingredients that are unknown or have no cost are listed in missing, since
+// the total leaves them out.
+func RecipeCost(recipe models.Recipe, ingredients map[string]models.Ingredient) ([]models.Cost_Line, float64, []string) {
+	lines := []models.Cost_Line{}
+	var missing []string
+	total := 0.0
+	for _, line := range recipe.Lines {
+		ingredient, ok := ingredients[line.Ingredient_Id]
+		if !ok || ingredient.Unit_Cost == 0 {
+			missing = append(missing, line.Ingredient_Id)
+		}
+		cost := roundCost(line.Quantity * ingredient.Unit_Cost)
+		lines = append(lines, models.Cost_Line{
+			Ingredient_Id: line.Ingredient_Id,
+			Name:          ingredient.Name,
+			Unit:          ingredient.Unit,
+			Quantity:      line.Quantity,
+			Unit_Cost:     ingredient.Unit_Cost,
+			Cost:          cost,
+		})
+		total += cost
+	}
+	return lines, roundCost(total), missing
+}
+
+// GrossMargin is the share of price left after cost, as a percentage. A dish
+// given away has no margin.
+func GrossMargin(price, cost float64) float64 {
+	if price <= 0 {
+		return 0
+	}
+	return math.Round((price-cost)/price*10000) / 100
+}
+
+// SameFoodCost reports whether two snapshots have the same price and costs,
+// so storing the second would add nothing to the history.
+func SameFoodCost(a, b models.Food_Cost) bool {
+	if a.Price != b.Price || a.Cost != b.Cost || len(a.Options) != len(b.Options) {
+		return false
+	}
+	for i := range a.Options {
+		if a.Options[i].Option_Id != b.Options[i].Option_Id || a.Options[i].Cost != b.Options[i].Cost || a.Options[i].Price_Delta != b.Options[i].Price_Delta {
+			return false
+		}
+	}
+	return true
+}
+
+// RisenCosts lists the ingredients of after whose unit cost is higher than
+// in before, with their new cost.
+func RisenCosts(before, after []models.Cost_Line) []models.Cost_Line {
+	previous := map[string]float64{}
+	for _, line := range before {
+		previous[line.Ingredient_Id] = line.Unit_Cost
+	}
+	risen := []models.Cost_Line{}
+	for _, line := range after {
+		if cost, ok := previous[line.Ingredient_Id]; ok && line.Unit_Cost > cost {
+			risen = append(risen, line)
+		}
+	}
+	return risen
+}
+
+// roundCost keeps costs to four decimals: dish costs are small sums of many
+// fractions of a unit cost.
+func roundCost(cost float64) float64 {
+	return math.Round(cost*10000) / 10000
+}
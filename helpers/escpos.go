@@ -0,0 +1,185 @@
+package helpers
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ESC/POS control sequences understood by the thermal printers.
+var (
+	escInit      = []byte{0x1B, 0x40}
+	escAlign     = []byte{0x1B, 0x61}
+	escBold      = []byte{0x1B, 0x45}
+	gsSize       = []byte{0x1D, 0x21}
+	gsCut        = []byte{0x1D, 0x56, 0x42, 0x00}
+	escFeedLines = []byte{0x1B, 0x64}
+)
+
+const (
+	AlignLeft   = 0
+	AlignCenter = 1
+	AlignRight  = 2
+)
+
+// EscPos builds an ESC/POS byte stream.
+type EscPos struct {
+	buf bytes.Buffer
+}
+
+// NewEscPos starts a stream with the printer reset sequence.
+func NewEscPos() *EscPos {
+	e := &EscPos{}
+	e.buf.Write(escInit)
+	return e
+}
+
+func (e *EscPos) Align(align int) *EscPos {
+	e.buf.Write(escAlign)
+	e.buf.WriteByte(byte(align))
+	return e
+}
+
+func (e *EscPos) Bold(on bool) *EscPos {
+	e.buf.Write(escBold)
+	if on {
+		e.buf.WriteByte(1)
+	} else {
+		e.buf.WriteByte(0)
+	}
+	return e
+}
+
+// Size sets the character magnification, 1 to 8 in each direction.
+func (e *EscPos) Size(width, height int) *EscPos {
+	width = clamp(width, 1, 8)
+	height = clamp(height, 1, 8)
+	e.buf.Write(gsSize)
+	e.buf.WriteByte(byte((width-1)<<4 | (height - 1)))
+	return e
+}
+
+// Line prints s followed by a line feed. Printers run in a single-byte code
+// page, so anything outside printable ASCII is replaced with '?'.
+func (e *EscPos) Line(s string) *EscPos {
+	for _, r := range s {
+		if r < 0x20 || r > 0x7E {
+			r = '?'
+		}
+		e.buf.WriteByte(byte(r))
+	}
+	e.buf.WriteByte('\n')
+	return e
+}
+
+func (e *EscPos) Feed(lines int) *EscPos {
+	e.buf.Write(escFeedLines)
+	e.buf.WriteByte(byte(clamp(lines, 0, 255)))
+	return e
+}
+
+// QRCode prints data as a native QR code (GS ( k, model 2).
+func (e *EscPos) QRCode(data string, moduleSize int) *EscPos {
+	store := len(data) + 3
+	e.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x04, 0x00, 0x31, 0x41, 0x32, 0x00})
+	e.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x43, byte(clamp(moduleSize, 1, 16))})
+	e.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x45, 0x31})
+	e.buf.Write([]byte{0x1D, 0x28, 0x6B, byte(store % 256), byte(store / 256), 0x31, 0x50, 0x30})
+	e.buf.WriteString(data)
+	e.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x51, 0x30})
+	return e
+}
+
+// Cut feeds past the tear bar and performs a partial cut.
+func (e *EscPos) Cut() *EscPos {
+	e.buf.Write(gsCut)
+	return e
+}
+
+func (e *EscPos) Bytes() []byte {
+	return e.buf.Bytes()
+}
+
+// RenderInvoiceEscPos renders the branch text receipt as an ESC/POS stream,
+// with the restaurant name enlarged and the verification QR code printed
+// natively by the printer.
+func RenderInvoiceEscPos(doc InvoiceDocument) ([]byte, error) {
+	receipt, err := RenderReceiptText(doc)
+	if err != nil {
+		return nil, err
+	}
+	tmpl := DocumentTemplateFor(doc.Branch)
+
+	e := NewEscPos()
+	lines := strings.Split(strings.TrimRight(receipt, "\n"), "\n")
+	for i, line := range lines {
+		if i == 0 {
+			e.Align(AlignCenter).Bold(true).Size(2, 2).Line(strings.TrimSpace(line)).Size(1, 1).Bold(false).Align(AlignLeft)
+			continue
+		}
+		e.Line(line)
+	}
+	if !tmpl.Hide_QR_Code && doc.Verification_URL != "" {
+		e.Feed(1).Align(AlignCenter).QRCode(doc.Verification_URL, 5).Align(AlignLeft)
+	}
+	return e.Feed(4).Cut().Bytes(), nil
+}
+
+// KitchenTicket is the list of dishes one kitchen station has to prepare for
+// an order.
+type KitchenTicket struct {
+	Station      string
+	Order_Id     string
+	Table_Name   string
+	Allergy_Note string
+	Created_At   time.Time
+	Items        []KitchenTicketItem
+}
+
+type KitchenTicketItem struct {
+	Quantity int
+	Name     string
+	Notes    []string
+}
+
+// RenderKitchenTicket renders a kitchen ticket for a printer that fits width
+// characters per line.
+func RenderKitchenTicket(ticket KitchenTicket, width int) []byte {
+	if width <= 0 {
+		width = defaultReceiptWidth
+	}
+	e := NewEscPos()
+	e.Align(AlignCenter).Bold(true).Size(2, 2).Line(strings.ToUpper(ticket.Station)).Size(1, 1).Bold(false)
+	e.Align(AlignLeft)
+	e.Line(padColumns("Order #"+shortId(ticket.Order_Id), "Table "+ticket.Table_Name, width))
+	e.Line(ticket.Created_At.Format("2006-01-02 15:04"))
+	if ticket.Allergy_Note != "" {
+		e.Bold(true).Line(truncate("ALLERGY: "+ticket.Allergy_Note, width)).Bold(false)
+	}
+	e.Line(strings.Repeat("-", width))
+	for _, item := range ticket.Items {
+		e.Bold(true).Size(1, 2).Line(truncate(strconv.Itoa(item.Quantity)+" x "+item.Name, width)).Size(1, 1).Bold(false)
+		for _, note := range item.Notes {
+			e.Line(truncate("   "+note, width))
+		}
+	}
+	e.Line(strings.Repeat("-", width))
+	return e.Feed(4).Cut().Bytes()
+}
+
+func padColumns(left, right string, width int) string {
+	right = truncate(right, width)
+	left = truncate(left, width-displayWidth(right)-1)
+	return left + strings.Repeat(" ", width-displayWidth(left)-displayWidth(right)) + right
+}
+
+func clamp(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
@@ -0,0 +1,74 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// giftCardAlphabet leaves out characters that are easy to misread (0/O, 1/I).
+const giftCardAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const giftCardCodeLength = 16
+
+// NewGiftCardCode returns a random 16-character card code.
+func NewGiftCardCode() (string, error) {
+	code := make([]byte, giftCardCodeLength)
+	max := big.NewInt(int64(len(giftCardAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = giftCardAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// NormalizeGiftCardCode accepts codes as printed (grouped, any case) and
+// returns the stored form.
+func NormalizeGiftCardCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// MaskGiftCardCode shows only the last four characters, for receipts.
+func MaskGiftCardCode(code string) string {
+	if len(code) <= 4 {
+		return code
+	}
+	return strings.Repeat("*", 4) + code[len(code)-4:]
+}
+
+// GiftCardLoad is the value an order loaded onto one gift card, by selling
+// or reloading it, and how much of it earlier refunds already took back.
+type GiftCardLoad struct {
+	Gift_Card_Id string
+	Loaded       float64
+	Taken_Back   float64
+}
+
+// GiftCardClawback returns how much to take back from each card when a
+// refund is made on an order that loaded gift cards. Refunds come out of the
+// rest of the order first; only what goes beyond that is taken from the
+// cards, in order. The amounts line up with loads.
+func GiftCardClawback(orderTotal, refundedBefore, refund float64, loads []GiftCardLoad) []float64 {
+	var loaded float64
+	for _, load := range loads {
+		loaded += load.Loaded
+	}
+	rest := orderTotal - loaded
+	needed := roundMoney(math.Max(refundedBefore+refund-rest, 0) - math.Max(refundedBefore-rest, 0))
+
+	amounts := make([]float64, len(loads))
+	for i, load := range loads {
+		if needed <= 0 {
+			break
+		}
+		take := roundMoney(math.Min(needed, math.Max(load.Loaded-load.Taken_Back, 0)))
+		amounts[i] = take
+		needed = roundMoney(needed - take)
+	}
+	return amounts
+}
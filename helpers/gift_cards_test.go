@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewGiftCardCode(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		code, err := NewGiftCardCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != giftCardCodeLength {
+			t.Errorf("code %q has %d characters, want %d", code, len(code), giftCardCodeLength)
+		}
+		for _, r := range code {
+			if !strings.ContainsRune(giftCardAlphabet, r) {
+				t.Errorf("code %q contains %q", code, r)
+			}
+		}
+		if seen[code] {
+			t.Errorf("code %q issued twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestNormalizeAndMaskGiftCardCode(t *testing.T) {
+	tests := []struct {
+		printed, stored, masked string
+	}{
+		{"abcd-efgh-jkmn-pqrs", "ABCDEFGHJKMNPQRS", "****PQRS"},
+		{"ABCD EFGH JKMN PQRS", "ABCDEFGHJKMNPQRS", "****PQRS"},
+		{"xy9", "XY9", "XY9"},
+	}
+	for _, test := range tests {
+		stored := NormalizeGiftCardCode(test.printed)
+		if stored != test.stored {
+			t.Errorf("NormalizeGiftCardCode(%q) = %q, want %q", test.printed, stored, test.stored)
+		}
+		if masked := MaskGiftCardCode(stored); masked != test.masked {
+			t.Errorf("MaskGiftCardCode(%q) = %q, want %q", stored, masked, test.masked)
+		}
+	}
+}
+
+func TestGiftCardClawback(t *testing.T) {
+	oneCard := []GiftCardLoad{{Gift_Card_Id: "a", Loaded: 100}}
+	tests := []struct {
+		name           string
+		orderTotal     float64
+		refundedBefore float64
+		refund         float64
+		loads          []GiftCardLoad
+		want           []float64
+	}{
+		{"no gift cards", 80, 0, 80, nil, []float64{}},
+		{"refund within the food", 150, 0, 30, oneCard, []float64{0}},
+		{"refund reaches into the card", 150, 0, 70, oneCard, []float64{20}},
+		{"earlier refunds used up the food", 150, 50, 40, []GiftCardLoad{{Gift_Card_Id: "a", Loaded: 100}}, []float64{40}},
+		{"earlier refund already took some back", 150, 60, 40, []GiftCardLoad{{Gift_Card_Id: "a", Loaded: 100, Taken_Back: 10}}, []float64{40}},
+		{"whole order refunded", 150, 0, 150, oneCard, []float64{100}},
+		{"gift card only order", 100, 0, 25.5, oneCard, []float64{25.5}},
+		{"spread over cards in order", 120, 0, 110, []GiftCardLoad{{Gift_Card_Id: "a", Loaded: 50}, {Gift_Card_Id: "b", Loaded: 50}}, []float64{50, 40}},
+		{"first card already taken back", 120, 70, 30, []GiftCardLoad{{Gift_Card_Id: "a", Loaded: 50, Taken_Back: 50}, {Gift_Card_Id: "b", Loaded: 50}}, []float64{0, 30}},
+		{"cents", 10.1, 0, 10.1, []GiftCardLoad{{Gift_Card_Id: "a", Loaded: 0.1}, {Gift_Card_Id: "b", Loaded: 0.2}}, []float64{0.1, 0.2}},
+	}
+	for _, test := range tests {
+		got := GiftCardClawback(test.orderTotal, test.refundedBefore, test.refund, test.loads)
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package helpers
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// Stock movement types in the inventory ledger.
+const (
+	MovementSale       = "SALE"
+	MovementVoid       = "VOID"
+	MovementAdjustment = "ADJUSTMENT"
+	MovementCount      = "COUNT"
+	MovementWaste      = "WASTE"
+	MovementReceipt    = "RECEIPT"
+)
+
+// CheckRecipe makes sure a recipe is for exactly one food or one modifier
+// option and lists each ingredient once.
+func CheckRecipe(recipe models.Recipe) error {
+	forFood := recipe.Food_Id != ""
+	forOption := recipe.Modifier_Group_Id != "" || recipe.Option_Id != ""
+	if forFood == forOption {
+		return errors.New("a recipe needs either food_id or modifier_group_id and option_id")
+	}
+	if forOption && (recipe.Modifier_Group_Id == "" || recipe.Option_Id == "") {
+		return errors.New("a modifier recipe needs both modifier_group_id and option_id")
+	}
+	seen := map[string]bool{}
+	for _, line := range recipe.Lines {
+		if seen[line.Ingredient_Id] {
+			return errors.New("ingredient " + line.Ingredient_Id + " is listed twice")
+		}
+		seen[line.Ingredient_Id] = true
+	}
+	return nil
+}
+
+// OptionRecipeKey is how the recipes of modifier options are keyed in the
+// map passed to ItemUsage.
+func OptionRecipeKey(groupId, optionId string) string {
+	return groupId + "/" + optionId
+}
+
+// ItemUsage is what an order line takes out of stock, keyed by ingredient
+// id: the food's recipe plus the recipes of the options chosen, times the
+// quantity. A removal option's recipe lists what it leaves out, so "no
+// onions" gives the onions back; usage never goes below zero.
+func ItemUsage(food models.Recipe, modifiers []models.Selected_Modifier, optionRecipes map[string]models.Recipe, quantity int) map[string]float64 {
+	usage := map[string]float64{}
+	for _, line := range food.Lines {
+		usage[line.Ingredient_Id] += line.Quantity
+	}
+	for _, modifier := range modifiers {
+		recipe, ok := optionRecipes[OptionRecipeKey(modifier.Modifier_Group_Id, modifier.Option_Id)]
+		if !ok {
+			continue
+		}
+		for _, line := range recipe.Lines {
+			if modifier.Type == "REMOVAL" {
+				usage[line.Ingredient_Id] -= line.Quantity
+			} else {
+				usage[line.Ingredient_Id] += line.Quantity
+			}
+		}
+	}
+	for id, amount := range usage {
+		if amount <= 0 {
+			delete(usage, id)
+			continue
+		}
+		usage[id] = RoundQuantity(amount * float64(quantity))
+	}
+	return usage
+}
+
+// UsageIngredients returns the ingredient ids in usage in a stable order, so
+// ledger entries for one event are always written alike.
+func UsageIngredients(usage map[string]float64) []string {
+	ids := make([]string, 0, len(usage))
+	for id := range usage {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// RoundQuantity rounds a stock quantity to thousandths of its unit, which
+// keeps float sums from drifting without losing grams or millilitres.
+func RoundQuantity(quantity float64) float64 {
+	return math.Round(quantity*1000) / 1000
+}
@@ -0,0 +1,232 @@
+package helpers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+	"golang.org/x/text/width"
+)
+
+// InvoiceDocument is everything a printed invoice or receipt shows. The
+// controllers assemble it from the invoice, order, items and payments so the
+// renderers stay free of database access.
+type InvoiceDocument struct {
+	Branch           models.Branch
+	Invoice_Id       string
+	Order_Id         string
+	Table_Name       string
+	Issued_At        time.Time
+	Due_Date         time.Time
+	Payment_Status   string
+	Lines            []DocumentLine
+	Subtotal         float64
+	Discounts        []DocumentDiscount
+	Service_Charges  []DocumentTax
+	Taxes            []DocumentTax
+	Tax_Inclusive    bool
+	Total            float64
+	Payments         []DocumentPayment
+	Amount_Paid      float64
+	Balance_Due      float64
+	Verification_URL string
+}
+
+type DocumentLine struct {
+	Name       string
+	Quantity   int
+	Unit_Price float64
+	Amount     float64
+}
+
+type DocumentDiscount struct {
+	Name   string
+	Amount float64
+}
+
+type DocumentTax struct {
+	Name   string
+	Rate   float64
+	Amount float64
+}
+
+type DocumentPayment struct {
+	Method  string
+	Amount  float64
+	Paid_At time.Time
+}
+
+const defaultReceiptWidth = 42
+
+const defaultReceiptLayout = `{{center .Branch.Name}}
+{{range .Template.Header_Lines}}{{center .}}
+{{end}}{{center .Branch.Address}}
+{{center (print "Tel: " .Branch.Phone)}}
+{{if .Branch.Tax_Number}}{{center (print "TIN: " .Branch.Tax_Number)}}
+{{end}}{{rule}}
+{{columns "Receipt" (short .Invoice_Id)}}
+{{columns "Date" (date .Issued_At)}}
+{{if .Table_Name}}{{columns "Table" .Table_Name}}
+{{end}}{{rule}}
+{{range .Lines}}{{columns (print .Quantity " x " .Name) (money .Amount)}}
+{{end}}{{rule}}
+{{columns "Subtotal" (money .Subtotal)}}
+{{range .Discounts}}{{columns .Name (printf "-%s" (money .Amount))}}
+{{end}}{{range .Service_Charges}}{{columns .Name (money .Amount)}}
+{{end}}{{range .Taxes}}{{columns .Name (money .Amount)}}
+{{end}}{{columns "TOTAL" (money .Total)}}
+{{range .Payments}}{{columns (print "Paid " .Method) (money .Amount)}}
+{{end}}{{if .Payments}}{{columns "Balance due" (money .Balance_Due)}}
+{{end}}{{rule}}
+{{if .Template.Footer}}{{center .Template.Footer}}
+{{end}}`
+
+// DocumentTemplateFor returns the branch template with defaults filled in.
+func DocumentTemplateFor(branch models.Branch) models.Document_Template {
+	tmpl := branch.Document_Template
+	if tmpl.Invoice_Title == "" {
+		tmpl.Invoice_Title = "INVOICE"
+	}
+	if tmpl.Footer == "" {
+		tmpl.Footer = "Thank you for dining with us!"
+	}
+	if tmpl.Accent_Color == "" {
+		tmpl.Accent_Color = "#1F4E79"
+	}
+	if tmpl.Receipt_Width == 0 {
+		tmpl.Receipt_Width = defaultReceiptWidth
+	}
+	if tmpl.Receipt_Layout == "" {
+		tmpl.Receipt_Layout = defaultReceiptLayout
+	}
+	return tmpl
+}
+
+// RenderReceiptText renders a plain-text receipt using the branch receipt
+// layout, a text/template with center, rule, columns, money, date and short
+// helpers sized to the receipt width.
+func RenderReceiptText(doc InvoiceDocument) (string, error) {
+	tmpl := DocumentTemplateFor(doc.Branch)
+	width := tmpl.Receipt_Width
+	currency := documentCurrency(doc.Branch)
+
+	funcs := template.FuncMap{
+		"center": func(s string) string {
+			s = truncate(s, width)
+			return strings.Repeat(" ", (width-displayWidth(s))/2) + s
+		},
+		"rule": func() string {
+			return strings.Repeat("-", width)
+		},
+		"columns": func(left, right string) string {
+			return padColumns(left, right, width)
+		},
+		"money": func(amount float64) string {
+			return FormatMoney(currency, amount)
+		},
+		"date": func(t time.Time) string {
+			return t.Format("2006-01-02 15:04")
+		},
+		"short": shortId,
+	}
+
+	parsed, err := template.New("receipt").Funcs(funcs).Parse(tmpl.Receipt_Layout)
+	if err != nil {
+		return "", fmt.Errorf("invalid receipt layout: %w", err)
+	}
+
+	var out bytes.Buffer
+	data := struct {
+		InvoiceDocument
+		Template models.Document_Template
+	}{doc, tmpl}
+	if err := parsed.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// FormatMoney formats an amount with two decimals and the currency code.
+func FormatMoney(currency string, amount float64) string {
+	return fmt.Sprintf("%s %.2f", currency, amount)
+}
+
+// InvoiceVerificationURL is the URL encoded in the invoice QR code. The
+// signature lets GET /invoices/:invoice_id/verify confirm the document was
+// issued by us without requiring a login.
+func InvoiceVerificationURL(invoiceId string) string {
+	base := os.Getenv("PUBLIC_BASE_URL")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	return fmt.Sprintf("%s/invoices/%s/verify?sig=%s", strings.TrimRight(base, "/"), invoiceId, InvoiceSignature(invoiceId))
+}
+
+// InvoiceSignature is a short HMAC of the invoice ID keyed with SECRET_KEY.
+func InvoiceSignature(invoiceId string) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(invoiceId))
+	return hex.EncodeToString(mac.Sum(nil))[:20]
+}
+
+// ValidInvoiceSignature reports whether sig was produced by InvoiceSignature.
+func ValidInvoiceSignature(invoiceId, sig string) bool {
+	return hmac.Equal([]byte(InvoiceSignature(invoiceId)), []byte(sig))
+}
+
+func documentCurrency(branch models.Branch) string {
+	if branch.Currency == "" {
+		return "ETB"
+	}
+	return branch.Currency
+}
+
+func shortId(id string) string {
+	if len(id) > 8 {
+		return strings.ToUpper(id[len(id)-8:])
+	}
+	return strings.ToUpper(id)
+}
+
+// truncate cuts s to at most width columns without splitting a character.
+func truncate(s string, width int) string {
+	used := 0
+	for i, r := range s {
+		w := runeWidth(r)
+		if used+w > width {
+			return s[:i]
+		}
+		used += w
+	}
+	return s
+}
+
+// displayWidth is how many columns s takes on a receipt: wide East Asian
+// characters take two, combining marks none and everything else, including
+// Ethiopic, one.
+func displayWidth(s string) int {
+	total := 0
+	for _, r := range s {
+		total += runeWidth(r)
+	}
+	return total
+}
+
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
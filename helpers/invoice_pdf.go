@@ -0,0 +1,190 @@
+package helpers
+
+import (
+	"bytes"
+	_ "embed"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+// The invoice font is embedded as UTF-8 TrueType so names in any script the
+// font covers print as written. DejaVu Sans covers Latin, Greek and
+// Cyrillic. INVOICE_FONT and INVOICE_FONT_BOLD point at other TTF files,
+// e.g. one with Ethiopic for Amharic names.
+var (
+	//go:embed fonts/DejaVuSans.ttf
+	invoiceFontRegular []byte
+	//go:embed fonts/DejaVuSans-Bold.ttf
+	invoiceFontBold []byte
+)
+
+const invoiceFont = "InvoiceSans"
+
+func invoiceFontBytes(env string, embedded []byte) []byte {
+	path := os.Getenv(env)
+	if path == "" {
+		return embedded
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("reading %s %s: %v, using the built-in font", env, path, err)
+		return embedded
+	}
+	return data
+}
+
+// RenderInvoicePDF renders a branded A4 invoice. Both fpdf and go-qrcode are
+// pure Go, so no external binaries are needed on the server.
+func RenderInvoicePDF(doc InvoiceDocument) ([]byte, error) {
+	tmpl := DocumentTemplateFor(doc.Branch)
+	currency := documentCurrency(doc.Branch)
+	red, green, blue := hexColor(tmpl.Accent_Color)
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(invoiceFont, "", invoiceFontBytes("INVOICE_FONT", invoiceFontRegular))
+	pdf.AddUTF8FontFromBytes(invoiceFont, "B", invoiceFontBytes("INVOICE_FONT_BOLD", invoiceFontBold))
+	if err := pdf.Error(); err != nil {
+		return nil, err
+	}
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont(invoiceFont, "", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(0, 5, tmpl.Footer, "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	// Restaurant details on the left, invoice details on the right.
+	pdf.SetTextColor(red, green, blue)
+	pdf.SetFont(invoiceFont, "B", 18)
+	pdf.CellFormat(110, 9, doc.Branch.Name, "", 0, "L", false, 0, "")
+	pdf.CellFormat(0, 9, tmpl.Invoice_Title, "", 1, "R", false, 0, "")
+
+	pdf.SetTextColor(60, 60, 60)
+	pdf.SetFont(invoiceFont, "", 9)
+	details := append([]string{}, tmpl.Header_Lines...)
+	details = append(details, doc.Branch.Address, "Tel: "+doc.Branch.Phone)
+	if doc.Branch.Email != "" {
+		details = append(details, doc.Branch.Email)
+	}
+	if doc.Branch.Tax_Number != "" {
+		details = append(details, "TIN: "+doc.Branch.Tax_Number)
+	}
+	meta := []string{
+		"Invoice #" + shortId(doc.Invoice_Id),
+		"Issued: " + doc.Issued_At.Format("02 Jan 2006"),
+	}
+	if !doc.Due_Date.IsZero() {
+		meta = append(meta, "Due: "+doc.Due_Date.Format("02 Jan 2006"))
+	}
+	if doc.Table_Name != "" {
+		meta = append(meta, "Table: "+doc.Table_Name)
+	}
+	meta = append(meta, "Status: "+doc.Payment_Status)
+	for i := 0; i < len(details) || i < len(meta); i++ {
+		left, right := "", ""
+		if i < len(details) {
+			left = details[i]
+		}
+		if i < len(meta) {
+			right = meta[i]
+		}
+		pdf.CellFormat(110, 5, left, "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 5, right, "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	// Line items.
+	widths := []float64{95, 20, 32, 33}
+	pdf.SetFillColor(red, green, blue)
+	pdf.SetTextColor(255, 255, 255)
+	pdf.SetFont(invoiceFont, "B", 10)
+	for i, heading := range []string{"Item", "Qty", "Unit price", "Amount"} {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], 8, heading, "", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetTextColor(30, 30, 30)
+	pdf.SetFont(invoiceFont, "", 10)
+	pdf.SetDrawColor(220, 220, 220)
+	for _, line := range doc.Lines {
+		pdf.CellFormat(widths[0], 7, line.Name, "B", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 7, strconv.Itoa(line.Quantity), "B", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[2], 7, FormatMoney(currency, line.Unit_Price), "B", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 7, FormatMoney(currency, line.Amount), "B", 1, "R", false, 0, "")
+	}
+	pdf.Ln(3)
+
+	// Totals, discounts, service charges, taxes and payments.
+	totalRow := func(label string, amount float64, bold bool) {
+		formatted := FormatMoney(currency, amount)
+		if amount < 0 {
+			formatted = "-" + FormatMoney(currency, -amount)
+		}
+		style := ""
+		if bold {
+			style = "B"
+		}
+		pdf.SetFont(invoiceFont, style, 10)
+		pdf.CellFormat(widths[0]+widths[1], 6, "", "", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[2], 6, label, "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 6, formatted, "", 1, "R", false, 0, "")
+	}
+	totalRow("Subtotal", doc.Subtotal, false)
+	for _, discount := range doc.Discounts {
+		totalRow(discount.Name, -discount.Amount, false)
+	}
+	for _, service := range doc.Service_Charges {
+		totalRow(service.Name, service.Amount, false)
+	}
+	for _, tax := range doc.Taxes {
+		totalRow(tax.Name, tax.Amount, false)
+	}
+	totalRow("Total", doc.Total, true)
+	for _, payment := range doc.Payments {
+		totalRow("Paid ("+strings.ToLower(payment.Method)+")", payment.Amount, false)
+	}
+	if len(doc.Payments) > 0 {
+		totalRow("Balance due", doc.Balance_Due, true)
+	}
+
+	if !tmpl.Hide_QR_Code && doc.Verification_URL != "" {
+		png, err := qrcode.Encode(doc.Verification_URL, qrcode.Medium, 256)
+		if err != nil {
+			return nil, err
+		}
+		pdf.RegisterImageOptionsReader("verify-qr", fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(png))
+		y := pdf.GetY() + 8
+		pdf.ImageOptions("verify-qr", 15, y, 32, 32, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+		pdf.SetXY(50, y+12)
+		pdf.SetFont(invoiceFont, "", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.MultiCell(0, 4, "Scan to verify this invoice\n"+doc.Verification_URL, "", "L", false)
+	}
+
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// hexColor parses "#RRGGBB", falling back to black on malformed input.
+func hexColor(color string) (int, int, int) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(color, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(color, "#")) != 6 {
+		return 0, 0, 0
+	}
+	return int(value >> 16 & 0xFF), int(value >> 8 & 0xFF), int(value & 0xFF)
+}
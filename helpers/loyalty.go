@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"math"
+	"sort"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// DefaultLoyaltyProgram is used until a program is configured: one point per
+// 10 currency units spent, points worth 0.10 each and expiring after a year.
+func DefaultLoyaltyProgram() models.Loyalty_Program {
+	return models.Loyalty_Program{
+		Points_Per_Unit:   0.1,
+		Point_Value:       0.1,
+		Min_Redeem_Points: 100,
+		Expiry_Days:       365,
+		Tiers: []models.Loyalty_Tier{
+			{Name: "BRONZE", Min_Points: 0, Multiplier: 1},
+			{Name: "SILVER", Min_Points: 1000, Multiplier: 1.25},
+			{Name: "GOLD", Min_Points: 5000, Multiplier: 1.5},
+		},
+	}
+}
+
+// LoyaltyTierFor returns the highest tier the lifetime points qualify for.
+func LoyaltyTierFor(program models.Loyalty_Program, lifetimePoints int) models.Loyalty_Tier {
+	tiers := append([]models.Loyalty_Tier(nil), program.Tiers...)
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].Min_Points < tiers[j].Min_Points })
+	tier := models.Loyalty_Tier{Name: "MEMBER", Multiplier: 1}
+	for _, t := range tiers {
+		if lifetimePoints >= t.Min_Points {
+			tier = t
+		}
+	}
+	return tier
+}
+
+// PointsEarned is what spending amount earns at the given tier, rounded down.
+func PointsEarned(program models.Loyalty_Program, tier models.Loyalty_Tier, amount float64) int {
+	if amount <= 0 {
+		return 0
+	}
+	return int(math.Floor(amount*program.Points_Per_Unit*tier.Multiplier + 1e-9))
+}
+
+// PointsForAmount is how many points pay for amount, rounded up.
+func PointsForAmount(program models.Loyalty_Program, amount float64) int {
+	return int(math.Ceil(amount/program.Point_Value - 1e-9))
+}
+
+// ConsumeCredits picks the credit entries points are taken from: the
+// preferred entry first, then the oldest. credits must be oldest first. It
+// returns the points to take from each entry, by entry ID; anything the
+// credits cannot cover is left out.
+func ConsumeCredits(credits []models.Loyalty_Entry, preferEntryId string, points int) map[string]int {
+	credits = append([]models.Loyalty_Entry(nil), credits...)
+	sort.SliceStable(credits, func(i, j int) bool {
+		return credits[i].Entry_Id == preferEntryId && credits[j].Entry_Id != preferEntryId
+	})
+	used := map[string]int{}
+	for _, credit := range credits {
+		if points <= 0 {
+			break
+		}
+		take := credit.Remaining
+		if take > points {
+			take = points
+		}
+		if take > 0 {
+			used[credit.Entry_Id] = take
+			points -= take
+		}
+	}
+	return used
+}
+
+// RefundedPoints is the share of an EARN entry a refund takes back, capped at
+// what earlier refunds left of it.
+func RefundedPoints(earned models.Loyalty_Entry, refund float64, left int) int {
+	if earned.Amount <= 0 {
+		return 0
+	}
+	points := int(math.Round(float64(earned.Points) * refund / earned.Amount))
+	if points > left {
+		points = left
+	}
+	if points < 0 {
+		return 0
+	}
+	return points
+}
@@ -0,0 +1,130 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+func TestLoyaltyTierFor(t *testing.T) {
+	program := DefaultLoyaltyProgram()
+	tests := []struct {
+		lifetime int
+		want     string
+	}{
+		{0, "BRONZE"},
+		{999, "BRONZE"},
+		{1000, "SILVER"},
+		{4999, "SILVER"},
+		{5000, "GOLD"},
+		{-20, "MEMBER"},
+	}
+	for _, test := range tests {
+		if got := LoyaltyTierFor(program, test.lifetime).Name; got != test.want {
+			t.Errorf("%d lifetime points: got %s, want %s", test.lifetime, got, test.want)
+		}
+	}
+
+	// Tiers may be configured in any order.
+	program.Tiers = []models.Loyalty_Tier{program.Tiers[2], program.Tiers[0], program.Tiers[1]}
+	if got := LoyaltyTierFor(program, 1200).Name; got != "SILVER" {
+		t.Errorf("unsorted tiers: got %s, want SILVER", got)
+	}
+}
+
+func TestPointsEarnedAndSpent(t *testing.T) {
+	program := DefaultLoyaltyProgram()
+	bronze, gold := program.Tiers[0], program.Tiers[2]
+	tests := []struct {
+		tier   models.Loyalty_Tier
+		amount float64
+		earned int
+	}{
+		{bronze, 0, 0},
+		{bronze, -50, 0},
+		{bronze, 9.99, 0},
+		{bronze, 10, 1},
+		{bronze, 123.45, 12},
+		{bronze, 0.3 * 100, 3},
+		{gold, 100, 15},
+		{gold, 10, 1},
+	}
+	for _, test := range tests {
+		if got := PointsEarned(program, test.tier, test.amount); got != test.earned {
+			t.Errorf("%s spending %.2f: earned %d, want %d", test.tier.Name, test.amount, got, test.earned)
+		}
+	}
+
+	spent := []struct {
+		amount float64
+		points int
+	}{
+		{10, 100},
+		{10.01, 101},
+		{0.3, 3},
+		{0, 0},
+	}
+	for _, test := range spent {
+		if got := PointsForAmount(program, test.amount); got != test.points {
+			t.Errorf("paying %.2f: %d points, want %d", test.amount, got, test.points)
+		}
+	}
+}
+
+func TestConsumeCredits(t *testing.T) {
+	credits := []models.Loyalty_Entry{
+		{Entry_Id: "oldest", Remaining: 30},
+		{Entry_Id: "middle", Remaining: 50},
+		{Entry_Id: "newest", Remaining: 20},
+	}
+	tests := []struct {
+		name   string
+		prefer string
+		points int
+		want   map[string]int
+	}{
+		{"oldest first", "", 40, map[string]int{"oldest": 30, "middle": 10}},
+		{"preferred entry first", "newest", 40, map[string]int{"newest": 20, "oldest": 20}},
+		{"more than the credits hold", "", 150, map[string]int{"oldest": 30, "middle": 50, "newest": 20}},
+		{"nothing to take", "", 0, map[string]int{}},
+	}
+	for _, test := range tests {
+		got := ConsumeCredits(credits, test.prefer, test.points)
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+			continue
+		}
+		for id, points := range test.want {
+			if got[id] != points {
+				t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+				break
+			}
+		}
+	}
+	if credits[0].Entry_Id != "oldest" || credits[0].Remaining != 30 {
+		t.Errorf("ConsumeCredits changed the credits passed in: %v", credits)
+	}
+}
+
+func TestRefundedPoints(t *testing.T) {
+	earned := models.Loyalty_Entry{Points: 12, Amount: 120}
+	tests := []struct {
+		name   string
+		earned models.Loyalty_Entry
+		refund float64
+		left   int
+		want   int
+	}{
+		{"full refund", earned, 120, 12, 12},
+		{"half refund", earned, 60, 12, 6},
+		{"rounds to nearest", earned, 15, 12, 2},
+		{"capped at what is left", earned, 60, 4, 4},
+		{"nothing left", earned, 60, 0, 0},
+		{"no amount recorded", models.Loyalty_Entry{Points: 12}, 60, 12, 0},
+	}
+	for _, test := range tests {
+		if got := RefundedPoints(test.earned, test.refund, test.left); got != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// MenuAvailable reports whether the menu can be ordered from at t, which must
+// already be in the branch's timezone.
+func MenuAvailable(menu models.Menu, t time.Time) bool {
+	if !menu.Start_Date.IsZero() && t.Before(menu.Start_Date) {
+		return false
+	}
+	if !menu.End_Date.IsZero() && t.After(menu.End_Date) {
+		return false
+	}
+	date := t.Format("2006-01-02")
+	for _, exception := range menu.Exceptions {
+		if exception.Date != date {
+			continue
+		}
+		if exception.Start_Time == "" && exception.End_Time == "" {
+			return false
+		}
+		return InDailyWindow(nil, exception.Start_Time, exception.End_Time, t)
+	}
+	if len(menu.Service_Windows) == 0 {
+		return true
+	}
+	for _, window := range menu.Service_Windows {
+		if InDailyWindow(window.Days_Of_Week, window.Start_Time, window.End_Time, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// MenuBoardRow is one dish in a menu export, flattened for menu boards and
+// spreadsheets.
+type MenuBoardRow struct {
+	Category     string            `json:"category"`
+	Menu         string            `json:"menu"`
+	Food_Id      string            `json:"food_id"`
+	Food         string            `json:"food"`
+	Price        float64           `json:"price"`
+	Portion      *models.Portion   `json:"portion,omitempty"`
+	Nutrition    *models.Nutrition `json:"nutrition,omitempty"`
+	Allergens    []string          `json:"allergens,omitempty"`
+	Dietary_Tags []string          `json:"dietary_tags,omitempty"`
+}
+
+// MenuBoardCSV writes the rows with one column per nutrition value. Unknown
+// values are left blank rather than written as zero.
+func MenuBoardCSV(rows []MenuBoardRow) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	err := w.Write([]string{
+		"Category", "Menu", "Food ID", "Food", "Price", "Portion", "Serves",
+		"Calories", "Protein (g)", "Carbohydrates (g)", "Fat (g)", "Sodium (mg)",
+		"Allergens", "Dietary Tags",
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		record := []string{row.Category, row.Menu, row.Food_Id, row.Food, strconv.FormatFloat(row.Price, 'f', 2, 64)}
+		if row.Portion != nil {
+			record = append(record, strconv.FormatFloat(row.Portion.Size, 'f', -1, 64)+" "+row.Portion.Unit, optionalInt(row.Portion.Serves))
+		} else {
+			record = append(record, "", "")
+		}
+		nutrition := row.Nutrition
+		if nutrition == nil {
+			nutrition = &models.Nutrition{}
+		}
+		for _, field := range nutritionFields(nutrition) {
+			if *field == nil {
+				record = append(record, "")
+			} else {
+				record = append(record, strconv.FormatFloat(**field, 'f', -1, 64))
+			}
+		}
+		record = append(record, strings.Join(row.Allergens, ";"), strings.Join(row.Dietary_Tags, ";"))
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func optionalInt(value int) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.Itoa(value)
+}
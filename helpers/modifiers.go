@@ -0,0 +1,134 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// ModifierLimits is the number of options that must and may be chosen from a
+// group. A max of 0 means no limit; sizes are always a single choice.
+func ModifierLimits(group models.Modifier_Group) (int, int) {
+	min, max := group.Min_Select, group.Max_Select
+	if group.Required && min < 1 {
+		min = 1
+	}
+	if group.Type == "SIZE" {
+		max = 1
+	}
+	return min, max
+}
+
+// CheckModifierGroup reports configuration mistakes that would make a group
+// impossible to order from.
+func CheckModifierGroup(group models.Modifier_Group) error {
+	min, max := ModifierLimits(group)
+	if max > 0 && min > max {
+		return fmt.Errorf("min_select cannot be more than max_select")
+	}
+	if min > len(group.Options) {
+		return fmt.Errorf("min_select cannot be more than the number of options")
+	}
+	names := map[string]bool{}
+	for _, option := range group.Options {
+		if names[strings.ToLower(option.Name)] {
+			return fmt.Errorf("option %q is listed twice", option.Name)
+		}
+		names[strings.ToLower(option.Name)] = true
+	}
+	return nil
+}
+
+// ResolveModifiers checks the chosen options against the groups a food
+// offers, in the food's order, and copies in their names and prices. It
+// returns the resolved choices grouped in that order and their total price
+// delta per unit.
+func ResolveModifiers(groups []models.Modifier_Group, selected []models.Selected_Modifier) ([]models.Selected_Modifier, float64, error) {
+	offered := map[string]bool{}
+	for _, group := range groups {
+		offered[group.Modifier_Group_Id] = true
+	}
+	seen := map[string]bool{}
+	for _, choice := range selected {
+		if !offered[choice.Modifier_Group_Id] {
+			return nil, 0, fmt.Errorf("modifier group %s is not offered for this food", choice.Modifier_Group_Id)
+		}
+		key := choice.Modifier_Group_Id + "/" + choice.Option_Id
+		if seen[key] {
+			return nil, 0, fmt.Errorf("option %s is chosen more than once", choice.Option_Id)
+		}
+		seen[key] = true
+	}
+
+	var resolved []models.Selected_Modifier
+	var delta float64
+	for _, group := range groups {
+		count := 0
+		for _, choice := range selected {
+			if choice.Modifier_Group_Id != group.Modifier_Group_Id {
+				continue
+			}
+			option, ok := ModifierOption(group, choice.Option_Id)
+			if !ok {
+				return nil, 0, fmt.Errorf("option %s is not part of %q", choice.Option_Id, group.Name)
+			}
+			resolved = append(resolved, models.Selected_Modifier{
+				Modifier_Group_Id: group.Modifier_Group_Id,
+				Option_Id:         option.Option_Id,
+				Group_Name:        group.Name,
+				Type:              group.Type,
+				Name:              option.Name,
+				Price_Delta:       option.Price_Delta,
+				Allergens:         option.Allergens,
+				Nutrition:         option.Nutrition,
+			})
+			delta += option.Price_Delta
+			count++
+		}
+		min, max := ModifierLimits(group)
+		if count < min {
+			return nil, 0, fmt.Errorf("%q needs at least %d choice(s)", group.Name, min)
+		}
+		if max > 0 && count > max {
+			return nil, 0, fmt.Errorf("%q allows at most %d choice(s)", group.Name, max)
+		}
+	}
+	return resolved, roundMoney(delta), nil
+}
+
+// ModifierOption finds an option of a group by id.
+func ModifierOption(group models.Modifier_Group, optionId string) (models.Modifier_Option, bool) {
+	for _, option := range group.Options {
+		if option.Option_Id == optionId {
+			return option, true
+		}
+	}
+	return models.Modifier_Option{}, false
+}
+
+// ModifierNotes describes chosen options the way a kitchen reads them:
+// "Large", "+ Extra cheese", "No onion".
+func ModifierNotes(modifiers []models.Selected_Modifier) []string {
+	var notes []string
+	for _, modifier := range modifiers {
+		switch modifier.Type {
+		case "REMOVAL":
+			notes = append(notes, "No "+modifier.Name)
+		case "ADD_ON":
+			notes = append(notes, "+ "+modifier.Name)
+		default:
+			notes = append(notes, modifier.Name)
+		}
+	}
+	return notes
+}
+
+// ModifiedItemName is a food name followed by its chosen options, for
+// invoices and receipts.
+func ModifiedItemName(name string, modifiers []models.Selected_Modifier) string {
+	if len(modifiers) == 0 {
+		return name
+	}
+	return name + " (" + strings.Join(ModifierNotes(modifiers), ", ") + ")"
+}
@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Reminder is a payment reminder for an overdue invoice.
+type Reminder struct {
+	Invoice_Id    string    `json:"invoice_id"`
+	Account_Name  string    `json:"account_name,omitempty"`
+	Contact_Email string    `json:"contact_email,omitempty"`
+	Contact_Phone string    `json:"contact_phone,omitempty"`
+	Stage         int       `json:"stage"`
+	Stage_Name    string    `json:"stage_name"`
+	Days_Overdue  int       `json:"days_overdue"`
+	Amount_Due    float64   `json:"amount_due"`
+	Due_Date      time.Time `json:"due_date"`
+}
+
+// Notifier delivers reminders. Email, SMS or chat integrations implement it.
+type Notifier interface {
+	Notify(ctx context.Context, reminder Reminder) error
+}
+
+// LogNotifier writes reminders to the application log. It is the default
+// when no other notifier is configured.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, reminder Reminder) error {
+	log.Printf("payment reminder: invoice %s (%s) stage %s, %d days overdue, %.2f due",
+		reminder.Invoice_Id, reminder.Account_Name, reminder.Stage_Name, reminder.Days_Overdue, reminder.Amount_Due)
+	return nil
+}
+
+// WebhookNotifier POSTs each reminder as JSON to URL, leaving delivery to
+// whatever service listens there.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (n WebhookNotifier) Notify(ctx context.Context, reminder Reminder) error {
+	body, err := json.Marshal(reminder)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("reminder webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// NotifierFromEnv returns a WebhookNotifier when REMINDER_WEBHOOK_URL is set
+// and a LogNotifier otherwise.
+func NotifierFromEnv() Notifier {
+	if url := os.Getenv("REMINDER_WEBHOOK_URL"); url != "" {
+		return WebhookNotifier{URL: url}
+	}
+	return LogNotifier{}
+}
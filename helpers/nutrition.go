@@ -0,0 +1,57 @@
+package helpers
+
+import (
+	"errors"
+	"math"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// nutritionFields returns pointers to each value of n so they can be handled
+// alike.
+func nutritionFields(n *models.Nutrition) []**float64 {
+	return []**float64{&n.Calories, &n.Protein_G, &n.Carbohydrates_G, &n.Fat_G, &n.Sodium_Mg}
+}
+
+// CheckFoodNutrition rejects negative values in a food's nutrition. Only
+// modifier options may be negative.
+func CheckFoodNutrition(n *models.Nutrition) error {
+	if n == nil {
+		return nil
+	}
+	for _, field := range nutritionFields(n) {
+		if *field != nil && **field < 0 {
+			return errors.New("nutrition values cannot be negative")
+		}
+	}
+	return nil
+}
+
+// LineNutrition totals the nutrition of an order line: the food's values
+// plus those of each chosen modifier, times the quantity. Only values the
+// food declares are totalled, since a modifier's change to an unknown value
+// is still unknown. It is nil when the food has no nutrition data.
+func LineNutrition(food models.Food, modifiers []models.Selected_Modifier, quantity int) *models.Nutrition {
+	if food.Nutrition == nil {
+		return nil
+	}
+	total := *food.Nutrition
+	totalFields := nutritionFields(&total)
+	for i, field := range totalFields {
+		if *field == nil {
+			continue
+		}
+		value := **field
+		for _, modifier := range modifiers {
+			if modifier.Nutrition == nil {
+				continue
+			}
+			if delta := *nutritionFields(modifier.Nutrition)[i]; delta != nil {
+				value += *delta
+			}
+		}
+		value = math.Round(math.Max(value, 0)*float64(quantity)*10) / 10
+		*totalFields[i] = &value
+	}
+	return &total
+}
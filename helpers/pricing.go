@@ -0,0 +1,128 @@
+package helpers
+
+import (
+	"os"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// BranchLocation is the timezone schedules are evaluated in for a branch:
+// the branch's own timezone, then DEFAULT_TIMEZONE, then the server's.
+func BranchLocation(branch models.Branch) *time.Location {
+	for _, name := range []string{branch.Timezone, os.Getenv("DEFAULT_TIMEZONE")} {
+		if name == "" {
+			continue
+		}
+		if location, err := time.LoadLocation(name); err == nil {
+			return location
+		}
+	}
+	return time.Local
+}
+
+// InDailyWindow reports whether t falls on one of the weekdays (0 is Sunday,
+// none means every day) between start and end ("15:04", empty means open).
+// When end is before start the window runs past midnight and belongs to the
+// day it starts on, so Friday 22:00-02:00 includes early Saturday.
+func InDailyWindow(days []int, start, end string, t time.Time) bool {
+	clock := t.Format("15:04")
+	day := t.Weekday()
+	if start != "" && end != "" && end < start {
+		switch {
+		case clock >= start:
+		case clock < end:
+			day = (day + 6) % 7
+		default:
+			return false
+		}
+	} else {
+		if start != "" && clock < start {
+			return false
+		}
+		if end != "" && clock >= end {
+			return false
+		}
+	}
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if time.Weekday(d) == day {
+			return true
+		}
+	}
+	return false
+}
+
+// PriceRuleApplies reports whether the rule prices the food at t, which must
+// already be in the branch's timezone.
+func PriceRuleApplies(rule models.Price_Rule, food models.Food, branchId string, t time.Time) bool {
+	if !rule.Active {
+		return false
+	}
+	if rule.Branch_Id != "" && rule.Branch_Id != branchId {
+		return false
+	}
+	if !rule.Valid_From.IsZero() && t.Before(rule.Valid_From) {
+		return false
+	}
+	if !rule.Valid_To.IsZero() && t.After(rule.Valid_To) {
+		return false
+	}
+	if rule.Menu_Id != "" && (food.Menu_Id == nil || *food.Menu_Id != rule.Menu_Id) {
+		return false
+	}
+	if len(rule.Food_Ids) > 0 {
+		listed := false
+		for _, id := range rule.Food_Ids {
+			if food.Food_Id != nil && *food.Food_Id == id {
+				listed = true
+			}
+		}
+		if !listed {
+			return false
+		}
+	}
+	return InDailyWindow(rule.Days_Of_Week, rule.Start_Time, rule.End_Time, t)
+}
+
+// EffectivePrice is the food's price at t after price rules. Of the matching
+// rules the highest priority wins, and between equal priorities the lower
+// price. It returns nil when no rule applies.
+func EffectivePrice(food models.Food, rules []models.Price_Rule, branchId string, t time.Time) (float64, *models.Price_Rule) {
+	base := 0.0
+	if food.Food_Price != nil {
+		base = *food.Food_Price
+	}
+	price := base
+	var applied *models.Price_Rule
+	for i, rule := range rules {
+		if !PriceRuleApplies(rule, food, branchId, t) {
+			continue
+		}
+		candidate := ruledPrice(rule, base)
+		if applied == nil || rule.Priority > applied.Priority ||
+			(rule.Priority == applied.Priority && candidate < price) {
+			price = candidate
+			applied = &rules[i]
+		}
+	}
+	return price, applied
+}
+
+func ruledPrice(rule models.Price_Rule, base float64) float64 {
+	price := base
+	switch rule.Type {
+	case "PERCENTAGE":
+		price = base - base*rule.Value/100
+	case "AMOUNT_OFF":
+		price = base - rule.Value
+	case "FIXED_PRICE":
+		price = rule.Value
+	}
+	if price < 0 {
+		price = 0
+	}
+	return roundMoney(price)
+}
@@ -0,0 +1,164 @@
+package helpers
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	PrintQueued   = "QUEUED"
+	PrintRetrying = "RETRYING"
+	PrintPrinted  = "PRINTED"
+	PrintFailed   = "FAILED"
+)
+
+// PrintJob is a byte stream waiting to be sent to a network printer.
+type PrintJob struct {
+	Job_Id      string    `json:"job_id"`
+	Printer_Id  string    `json:"printer_id"`
+	Address     string    `json:"address"`
+	Description string    `json:"description"`
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	Last_Error  string    `json:"last_error,omitempty"`
+	Created_At  time.Time `json:"created_at"`
+	Updated_At  time.Time `json:"updated_at"`
+	data        []byte
+}
+
+// PrintQueue sends jobs to printers in the background. A failed send is
+// retried with a growing delay until MaxAttempts is reached, so a printer
+// that is briefly off or out of paper does not lose tickets.
+type PrintQueue struct {
+	MaxAttempts int
+	RetryDelay  time.Duration
+	Send        func(address string, data []byte) error
+
+	jobs   chan *PrintJob
+	once   sync.Once
+	mu     sync.Mutex
+	recent []*PrintJob
+}
+
+const recentPrintJobs = 200
+
+// Printing is the queue used by the controllers.
+var Printing = NewPrintQueue()
+
+func NewPrintQueue() *PrintQueue {
+	return &PrintQueue{
+		MaxAttempts: 5,
+		RetryDelay:  2 * time.Second,
+		Send:        SendToPrinter,
+		jobs:        make(chan *PrintJob, 100),
+	}
+}
+
+// Enqueue schedules data for printing and returns the queued job.
+func (q *PrintQueue) Enqueue(printerId, address, description string, data []byte) PrintJob {
+	q.once.Do(func() {
+		for i := 0; i < 2; i++ {
+			go q.work()
+		}
+	})
+
+	now := time.Now()
+	job := &PrintJob{
+		Job_Id:      primitive.NewObjectID().Hex(),
+		Printer_Id:  printerId,
+		Address:     address,
+		Description: description,
+		Status:      PrintQueued,
+		Created_At:  now,
+		Updated_At:  now,
+		data:        data,
+	}
+
+	q.mu.Lock()
+	q.recent = append(q.recent, job)
+	if len(q.recent) > recentPrintJobs {
+		q.recent = q.recent[len(q.recent)-recentPrintJobs:]
+	}
+	q.mu.Unlock()
+
+	q.push(job)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return *job
+}
+
+// push hands a job to the workers without waiting. When the queue is full,
+// as when printers have been down long enough for retries to pile up, the
+// job fails instead of blocking the request or retry timer that sent it.
+func (q *PrintQueue) push(job *PrintJob) {
+	select {
+	case q.jobs <- job:
+	default:
+		q.mu.Lock()
+		job.Status = PrintFailed
+		job.Last_Error = "print queue full"
+		job.Updated_At = time.Now()
+		q.mu.Unlock()
+	}
+}
+
+// Jobs returns the most recent jobs, oldest first.
+func (q *PrintQueue) Jobs() []PrintJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]PrintJob, 0, len(q.recent))
+	for _, job := range q.recent {
+		jobs = append(jobs, *job)
+	}
+	return jobs
+}
+
+func (q *PrintQueue) work() {
+	for job := range q.jobs {
+		err := q.Send(job.Address, job.data)
+
+		q.mu.Lock()
+		job.Attempts++
+		job.Updated_At = time.Now()
+		switch {
+		case err == nil:
+			job.Status = PrintPrinted
+			job.Last_Error = ""
+		case job.Attempts >= q.MaxAttempts:
+			job.Status = PrintFailed
+			job.Last_Error = err.Error()
+		default:
+			job.Status = PrintRetrying
+			job.Last_Error = err.Error()
+			delay := q.RetryDelay * time.Duration(job.Attempts)
+			time.AfterFunc(delay, func() { q.push(job) })
+		}
+		q.mu.Unlock()
+	}
+}
+
+// SendToPrinter writes data to a raw TCP printer. Addresses without a port
+// use 9100, the usual ESC/POS port.
+func SendToPrinter(address string, data []byte) error {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "9100")
+	}
+	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("printer %s unreachable: %w", address, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetWriteDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		return err
+	}
+	if _, err := conn.Write(data); err != nil {
+		return fmt.Errorf("printer %s write failed: %w", address, err)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// standInPrinter listens like a raw TCP printer on a free local port and
+// passes on everything each connection writes.
+func standInPrinter(t *testing.T) (string, <-chan []byte) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	printed := make(chan []byte, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			data, _ := io.ReadAll(conn)
+			conn.Close()
+			printed <- data
+		}
+	}()
+	return listener.Addr().String(), printed
+}
+
+// deadAddress is a local address nothing listens on.
+func deadAddress(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	return address
+}
+
+func testQueue() *PrintQueue {
+	q := NewPrintQueue()
+	q.RetryDelay = 10 * time.Millisecond
+	return q
+}
+
+// waitForJob polls the queue until the job reaches a final status.
+func waitForJob(t *testing.T, q *PrintQueue, jobId string) PrintJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, job := range q.Jobs() {
+			if job.Job_Id == jobId && (job.Status == PrintPrinted || job.Status == PrintFailed) {
+				return job
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", jobId)
+	return PrintJob{}
+}
+
+func receive(t *testing.T, printed <-chan []byte) []byte {
+	t.Helper()
+	select {
+	case data := <-printed:
+		return data
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing reached the printer")
+		return nil
+	}
+}
+
+func TestPrintQueueSendsReceiptAndKitchenTicket(t *testing.T) {
+	address, printed := standInPrinter(t)
+	q := testQueue()
+
+	receipt, err := RenderInvoiceEscPos(InvoiceDocument{
+		Branch:           models.Branch{Name: "Habesha House"},
+		Invoice_Id:       "64f0c2a1b2c3d4e5f6a7b8c9",
+		Issued_At:        time.Date(2026, 10, 1, 12, 30, 0, 0, time.UTC),
+		Lines:            []DocumentLine{{Name: "Tibs", Quantity: 2, Unit_Price: 180, Amount: 360}},
+		Subtotal:         360,
+		Total:            360,
+		Verification_URL: "http://localhost:8080/invoices/x/verify?sig=y",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket := RenderKitchenTicket(KitchenTicket{
+		Station:    "grill",
+		Order_Id:   "64f0c2a1b2c3d4e5f6a7b8c9",
+		Table_Name: "T4",
+		Created_At: time.Date(2026, 10, 1, 12, 30, 0, 0, time.UTC),
+		Items:      []KitchenTicketItem{{Quantity: 2, Name: "Tibs", Notes: []string{"no onions"}}},
+	}, 32)
+
+	receiptJob := q.Enqueue("p1", address, "receipt", receipt)
+	ticketJob := q.Enqueue("p2", address, "ticket", ticket)
+
+	got := map[string][]byte{}
+	for i := 0; i < 2; i++ {
+		data := receive(t, printed)
+		if bytes.Contains(data, []byte("GRILL")) {
+			got["ticket"] = data
+		} else {
+			got["receipt"] = data
+		}
+	}
+	if !bytes.Equal(got["receipt"], receipt) {
+		t.Errorf("receipt bytes differ from what was queued")
+	}
+	if !bytes.Equal(got["ticket"], ticket) {
+		t.Errorf("ticket bytes differ from what was queued")
+	}
+
+	for name, data := range got {
+		if !bytes.HasPrefix(data, escInit) {
+			t.Errorf("%s does not start with ESC @", name)
+		}
+		if !bytes.HasSuffix(data, gsCut) {
+			t.Errorf("%s does not end with a cut", name)
+		}
+	}
+	// The restaurant name is printed large and bold, centred.
+	heading := append(append(append([]byte{}, escAlign...), AlignCenter), escBold...)
+	if !bytes.Contains(got["receipt"], heading) || !bytes.Contains(got["receipt"], []byte("Habesha House")) {
+		t.Errorf("receipt heading missing")
+	}
+	if !bytes.Contains(got["receipt"], []byte{0x1D, 0x28, 0x6B}) {
+		t.Errorf("receipt has no QR code command")
+	}
+	if !bytes.Contains(got["ticket"], []byte("2 x Tibs")) || !bytes.Contains(got["ticket"], []byte("no onions")) {
+		t.Errorf("ticket items missing: %q", got["ticket"])
+	}
+
+	for _, id := range []string{receiptJob.Job_Id, ticketJob.Job_Id} {
+		if job := waitForJob(t, q, id); job.Status != PrintPrinted || job.Attempts != 1 {
+			t.Errorf("job %s: status %s after %d attempts, want PRINTED after 1", job.Description, job.Status, job.Attempts)
+		}
+	}
+}
+
+func TestPrintQueueRetriesUntilPrinted(t *testing.T) {
+	address, printed := standInPrinter(t)
+	q := testQueue()
+
+	var mu sync.Mutex
+	calls := 0
+	q.Send = func(address string, data []byte) error {
+		mu.Lock()
+		calls++
+		call := calls
+		mu.Unlock()
+		if call < 3 {
+			return errors.New("out of paper")
+		}
+		return SendToPrinter(address, data)
+	}
+
+	job := q.Enqueue("p1", address, "ticket", []byte("hello"))
+	if data := receive(t, printed); string(data) != "hello" {
+		t.Errorf("printed %q", data)
+	}
+	job = waitForJob(t, q, job.Job_Id)
+	if job.Status != PrintPrinted || job.Attempts != 3 || job.Last_Error != "" {
+		t.Errorf("got %s after %d attempts (%q), want PRINTED after 3", job.Status, job.Attempts, job.Last_Error)
+	}
+}
+
+func TestPrintQueueFailsAfterMaxAttempts(t *testing.T) {
+	q := testQueue()
+	q.MaxAttempts = 3
+
+	job := q.Enqueue("p1", deadAddress(t), "ticket", []byte("hello"))
+	if job.Status != PrintQueued {
+		t.Errorf("new job is %s, want QUEUED", job.Status)
+	}
+	job = waitForJob(t, q, job.Job_Id)
+	if job.Status != PrintFailed || job.Attempts != 3 || job.Last_Error == "" {
+		t.Errorf("got %s after %d attempts (%q), want FAILED after 3 with an error", job.Status, job.Attempts, job.Last_Error)
+	}
+}
+
+func TestPrintQueueFullFailsWithoutBlocking(t *testing.T) {
+	q := testQueue()
+	q.jobs = make(chan *PrintJob, 1)
+	q.once.Do(func() {}) // no workers, so nothing drains the queue
+
+	done := make(chan [2]PrintJob)
+	go func() {
+		first := q.Enqueue("p1", "127.0.0.1:1", "first", nil)
+		second := q.Enqueue("p1", "127.0.0.1:1", "second", nil)
+		done <- [2]PrintJob{first, second}
+	}()
+	select {
+	case jobs := <-done:
+		if jobs[0].Status != PrintQueued {
+			t.Errorf("first job is %s, want QUEUED", jobs[0].Status)
+		}
+		if jobs[1].Status != PrintFailed || jobs[1].Last_Error != "print queue full" {
+			t.Errorf("second job is %s (%q), want FAILED with print queue full", jobs[1].Status, jobs[1].Last_Error)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Enqueue blocked on a full queue")
+	}
+}
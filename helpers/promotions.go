@@ -0,0 +1,163 @@
+package helpers
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// PromotionLine is an order line as seen by the promotion engine.
+type PromotionLine struct {
+	Food_Id    string
+	Category   string
+	Quantity   int
+	Unit_Price float64
+}
+
+// PromotionInWindow reports whether the promotion is active at now: inside
+// its validity dates, on an allowed weekday and within its daily time window.
+func PromotionInWindow(promotion models.Promotion, now time.Time) bool {
+	if !promotion.Active {
+		return false
+	}
+	if !promotion.Valid_From.IsZero() && now.Before(promotion.Valid_From) {
+		return false
+	}
+	if !promotion.Valid_To.IsZero() && now.After(promotion.Valid_To) {
+		return false
+	}
+	return InDailyWindow(promotion.Days_Of_Week, promotion.Start_Time, promotion.End_Time, now)
+}
+
+// PromotionDiscount computes what a single promotion takes off the lines,
+// ignoring codes and usage limits. It never exceeds the eligible amount.
+func PromotionDiscount(promotion models.Promotion, lines []PromotionLine) float64 {
+	var subtotal, eligible float64
+	var units []float64
+	foods := map[string]bool{}
+	for _, id := range promotion.Food_Ids {
+		foods[id] = true
+	}
+
+	for _, line := range lines {
+		amount := line.Unit_Price * float64(line.Quantity)
+		subtotal += amount
+
+		matches := len(foods) == 0 || foods[line.Food_Id]
+		if promotion.Type == "CATEGORY" {
+			matches = strings.EqualFold(line.Category, promotion.Category)
+		}
+		if !matches {
+			continue
+		}
+		eligible += amount
+		for i := 0; i < line.Quantity; i++ {
+			units = append(units, line.Unit_Price)
+		}
+	}
+	if eligible <= 0 || subtotal < promotion.Min_Order_Amount {
+		return 0
+	}
+
+	var discount float64
+	switch promotion.Type {
+	case "PERCENTAGE", "CATEGORY":
+		discount = eligible * promotion.Value / 100
+	case "FIXED":
+		discount = promotion.Value
+	case "BUY_X_GET_Y":
+		// For every Buy+Get units the cheapest Get units are free, or
+		// Value percent off when Value is set.
+		group := promotion.Buy_Quantity + promotion.Get_Quantity
+		if group <= 0 {
+			return 0
+		}
+		free := len(units) / group * promotion.Get_Quantity
+		sort.Float64s(units)
+		percent := 100.0
+		if promotion.Value > 0 {
+			percent = promotion.Value
+		}
+		for i := 0; i < free; i++ {
+			discount += units[i] * percent / 100
+		}
+	}
+
+	if discount > eligible {
+		discount = eligible
+	}
+	return roundMoney(discount)
+}
+
+// ApplyPromotions picks the discounts for an order from promotions that have
+// already passed their usage-limit checks. Promotions with a code only apply
+// when that code was entered. Stackable promotions combine with each other; a
+// non-stackable promotion applies alone. Whichever choice saves the customer
+// more wins, and the total never exceeds the order subtotal.
+func ApplyPromotions(promotions []models.Promotion, lines []PromotionLine, codes []string, now time.Time) []models.Applied_Discount {
+	entered := map[string]bool{}
+	for _, code := range codes {
+		entered[strings.ToUpper(strings.TrimSpace(code))] = true
+	}
+
+	var subtotal float64
+	for _, line := range lines {
+		subtotal += line.Unit_Price * float64(line.Quantity)
+	}
+
+	var stacked []models.Applied_Discount
+	var stackedTotal float64
+	var best models.Applied_Discount
+	for _, promotion := range promotions {
+		if promotion.Code != "" && !entered[strings.ToUpper(promotion.Code)] {
+			continue
+		}
+		if !PromotionInWindow(promotion, now) {
+			continue
+		}
+		amount := PromotionDiscount(promotion, lines)
+		if amount <= 0 {
+			continue
+		}
+		discount := models.Applied_Discount{
+			Promotion_Id: promotion.Promotion_Id,
+			Name:         promotion.Name,
+			Code:         promotion.Code,
+			Type:         promotion.Type,
+			Amount:       amount,
+		}
+		if promotion.Stackable {
+			stacked = append(stacked, discount)
+			stackedTotal += amount
+		} else if amount > best.Amount {
+			best = discount
+		}
+	}
+
+	applied := stacked
+	if best.Amount > stackedTotal {
+		applied = []models.Applied_Discount{best}
+	}
+
+	// Cap the combined discount at the subtotal, trimming the last ones.
+	remaining := roundMoney(subtotal)
+	var capped []models.Applied_Discount
+	for _, discount := range applied {
+		if remaining <= 0 {
+			break
+		}
+		if discount.Amount > remaining {
+			discount.Amount = remaining
+		}
+		remaining = roundMoney(remaining - discount.Amount)
+		capped = append(capped, discount)
+	}
+	return capped
+}
+
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
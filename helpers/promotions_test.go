@@ -0,0 +1,106 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// A Friday evening.
+var promotionNow = time.Date(2026, 10, 16, 19, 30, 0, 0, time.UTC)
+
+func TestPromotionInWindow(t *testing.T) {
+	tests := []struct {
+		name      string
+		promotion models.Promotion
+		want      bool
+	}{
+		{"inactive", models.Promotion{}, false},
+		{"always on", models.Promotion{Active: true}, true},
+		{"not started", models.Promotion{Active: true, Valid_From: promotionNow.Add(time.Hour)}, false},
+		{"ended", models.Promotion{Active: true, Valid_To: promotionNow.Add(-time.Hour)}, false},
+		{"within dates", models.Promotion{Active: true, Valid_From: promotionNow.AddDate(0, 0, -1), Valid_To: promotionNow.AddDate(0, 0, 1)}, true},
+		{"on Fridays", models.Promotion{Active: true, Days_Of_Week: []int{5}}, true},
+		{"weekends only", models.Promotion{Active: true, Days_Of_Week: []int{0, 6}}, false},
+		{"happy hour", models.Promotion{Active: true, Start_Time: "17:00", End_Time: "20:00"}, true},
+		{"lunch only", models.Promotion{Active: true, Start_Time: "11:00", End_Time: "14:00"}, false},
+	}
+	for _, test := range tests {
+		if got := PromotionInWindow(test.promotion, promotionNow); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPromotionDiscount(t *testing.T) {
+	lines := []PromotionLine{
+		{Food_Id: "tibs", Category: "Mains", Quantity: 2, Unit_Price: 180},
+		{Food_Id: "shiro", Category: "Mains", Quantity: 1, Unit_Price: 120},
+		{Food_Id: "tea", Category: "Drinks", Quantity: 3, Unit_Price: 25},
+	}
+	tests := []struct {
+		name      string
+		promotion models.Promotion
+		want      float64
+	}{
+		{"percentage of order", models.Promotion{Type: "PERCENTAGE", Value: 10}, 55.5},
+		{"percentage of listed foods", models.Promotion{Type: "PERCENTAGE", Value: 10, Food_Ids: []string{"tibs"}}, 36},
+		{"fixed", models.Promotion{Type: "FIXED", Value: 50}, 50},
+		{"fixed capped at eligible", models.Promotion{Type: "FIXED", Value: 500, Food_Ids: []string{"tea"}}, 75},
+		{"category", models.Promotion{Type: "CATEGORY", Category: "drinks", Value: 20}, 15},
+		{"category with no match", models.Promotion{Type: "CATEGORY", Category: "Desserts", Value: 20}, 0},
+		{"below minimum order", models.Promotion{Type: "FIXED", Value: 50, Min_Order_Amount: 600}, 0},
+		{"at minimum order", models.Promotion{Type: "FIXED", Value: 50, Min_Order_Amount: 555}, 50},
+		{"buy 2 get 1 free", models.Promotion{Type: "BUY_X_GET_Y", Buy_Quantity: 2, Get_Quantity: 1, Food_Ids: []string{"tea"}}, 25},
+		{"buy 1 get 1 half price, cheapest free", models.Promotion{Type: "BUY_X_GET_Y", Buy_Quantity: 1, Get_Quantity: 1, Value: 50, Food_Ids: []string{"tibs", "shiro"}}, 60},
+		{"buy x get y without a group", models.Promotion{Type: "BUY_X_GET_Y"}, 0},
+		{"rounds to cents", models.Promotion{Type: "PERCENTAGE", Value: 3.333, Food_Ids: []string{"shiro"}}, 4},
+	}
+	for _, test := range tests {
+		if got := PromotionDiscount(test.promotion, lines); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestApplyPromotions(t *testing.T) {
+	lines := []PromotionLine{{Food_Id: "tibs", Quantity: 1, Unit_Price: 100}}
+	tenPercent := models.Promotion{Promotion_Id: "p10", Type: "PERCENTAGE", Value: 10, Stackable: true, Active: true}
+	fiveOff := models.Promotion{Promotion_Id: "f5", Type: "FIXED", Value: 5, Stackable: true, Active: true}
+	twentyOff := models.Promotion{Promotion_Id: "f20", Type: "FIXED", Value: 20, Active: true}
+	coded := models.Promotion{Promotion_Id: "code", Code: "WELCOME", Type: "FIXED", Value: 30, Active: true}
+	huge := models.Promotion{Promotion_Id: "huge", Type: "PERCENTAGE", Value: 90, Stackable: true, Active: true}
+	inactive := models.Promotion{Promotion_Id: "off", Type: "FIXED", Value: 50, Stackable: true}
+
+	tests := []struct {
+		name       string
+		promotions []models.Promotion
+		codes      []string
+		want       map[string]float64
+	}{
+		{"stackable promotions combine", []models.Promotion{tenPercent, fiveOff}, nil, map[string]float64{"p10": 10, "f5": 5}},
+		{"a bigger non-stackable one wins alone", []models.Promotion{tenPercent, fiveOff, twentyOff}, nil, map[string]float64{"f20": 20}},
+		{"the stack wins when it saves more", []models.Promotion{tenPercent, fiveOff, huge, twentyOff}, nil, map[string]float64{"p10": 10, "f5": 5, "huge": 85}},
+		{"codes apply only when entered", []models.Promotion{coded, fiveOff}, nil, map[string]float64{"f5": 5}},
+		{"entered codes ignore case and spaces", []models.Promotion{coded, fiveOff}, []string{" welcome "}, map[string]float64{"code": 30}},
+		{"inactive promotions are skipped", []models.Promotion{inactive}, nil, map[string]float64{}},
+	}
+	for _, test := range tests {
+		applied := ApplyPromotions(test.promotions, lines, test.codes, promotionNow)
+		got := map[string]float64{}
+		for _, discount := range applied {
+			got[discount.Promotion_Id] = discount.Amount
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+			continue
+		}
+		for id, amount := range test.want {
+			if got[id] != amount {
+				t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"math"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// Purchase order statuses.
+const (
+	PurchaseDraft             = "DRAFT"
+	PurchaseApproved          = "APPROVED"
+	PurchasePartiallyReceived = "PARTIALLY_RECEIVED"
+	PurchaseReceived          = "RECEIVED"
+	PurchaseCancelled         = "CANCELLED"
+)
+
+// OpenPurchaseStatuses are the statuses of purchase orders whose goods are
+// still to come, counted as on order when suggesting reorders.
+var OpenPurchaseStatuses = []string{PurchaseDraft, PurchaseApproved, PurchasePartiallyReceived}
+
+// ReorderQuantity is how much to order to bring stock back to par. It is
+// zero until stock plus what is already on order has fallen to the reorder
+// point, and for ingredients without a par level.
+func ReorderQuantity(onHand, onOrder, reorderPoint, parLevel float64) float64 {
+	if parLevel <= 0 || onHand+onOrder > reorderPoint {
+		return 0
+	}
+	return math.Max(RoundQuantity(parLevel-onHand-onOrder), 0)
+}
+
+// AverageCost is the unit cost after receiving quantity at price on top of
+// onHand at cost, weighted by quantity. Stock that is gone or negative
+// carries no weight, so the new price is taken as is.
+func AverageCost(onHand, cost, quantity, price float64) float64 {
+	if onHand <= 0 {
+		return roundCost(price)
+	}
+	return roundCost((onHand*cost + quantity*price) / (onHand + quantity))
+}
+
+// PurchaseOrderTotal is the cost of every line as ordered.
+func PurchaseOrderTotal(lines []models.Purchase_Order_Line) float64 {
+	total := 0.0
+	for _, line := range lines {
+		total += line.Quantity * line.Unit_Cost
+	}
+	return roundMoney(total)
+}
+
+// ReceivedStatus is the status of an approved purchase order after goods
+// have been received against it.
+func ReceivedStatus(lines []models.Purchase_Order_Line) string {
+	received := false
+	complete := true
+	for _, line := range lines {
+		if line.Received_Quantity > 0 {
+			received = true
+		}
+		if line.Received_Quantity < line.Quantity {
+			complete = false
+		}
+	}
+	switch {
+	case complete:
+		return PurchaseReceived
+	case received:
+		return PurchasePartiallyReceived
+	}
+	return PurchaseApproved
+}
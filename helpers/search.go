@@ -0,0 +1,189 @@
+package helpers
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// minTrigramSimilarity is how alike two words must be, as the share of
+// trigrams they have in common, to count as a typo of one another. It is
+// the same threshold PostgreSQL's pg_trgm uses.
+const minTrigramSimilarity = 0.3
+
+// SearchField is one piece of a document's text. Matches in fields with a
+// higher Weight rank the document higher.
+type SearchField struct {
+	Name   string
+	Text   string
+	Weight float64
+}
+
+// SearchHit is a document that matched a query. Matched holds the indexed
+// words that matched, for highlighting.
+type SearchHit struct {
+	Id      string
+	Score   float64
+	Matched map[string]bool
+}
+
+// SearchIndex is an in-memory trigram index that matches query words to
+// indexed words that are spelled alike, so "shiro" finds "shero" and
+// "tibs" finds "tib".
+type SearchIndex struct {
+	ids   []string
+	words map[string]map[int]float64
+	grams map[string][]string
+}
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		words: map[string]map[int]float64{},
+		grams: map[string][]string{},
+	}
+}
+
+// Add indexes a document's fields. Each word keeps the weight of the
+// heaviest field it appears in.
+func (ix *SearchIndex) Add(id string, fields ...SearchField) {
+	doc := len(ix.ids)
+	ix.ids = append(ix.ids, id)
+	for _, field := range fields {
+		for _, word := range SearchTerms(field.Text) {
+			docs, ok := ix.words[word]
+			if !ok {
+				docs = map[int]float64{}
+				ix.words[word] = docs
+				for _, gram := range Trigrams(word) {
+					ix.grams[gram] = append(ix.grams[gram], word)
+				}
+			}
+			if field.Weight > docs[doc] {
+				docs[doc] = field.Weight
+			}
+		}
+	}
+}
+
+// Search scores every document against the words of query. Each query word
+// contributes its best match in the document: an exact word scores 1, a
+// word it is a prefix of 0.8, and a word spelled alike its trigram
+// similarity, times the field weight. Hits are returned best first.
+func (ix *SearchIndex) Search(query string) []SearchHit {
+	scores := map[int]float64{}
+	matched := map[int]map[string]bool{}
+	for _, term := range SearchTerms(query) {
+		best := map[int]float64{}
+		for word, similarity := range ix.similarWords(term) {
+			for doc, weight := range ix.words[word] {
+				if similarity*weight > best[doc] {
+					best[doc] = similarity * weight
+				}
+				if matched[doc] == nil {
+					matched[doc] = map[string]bool{}
+				}
+				matched[doc][word] = true
+			}
+		}
+		for doc, score := range best {
+			scores[doc] += score
+		}
+	}
+
+	hits := []SearchHit{}
+	for doc, score := range scores {
+		hits = append(hits, SearchHit{Id: ix.ids[doc], Score: score, Matched: matched[doc]})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Id < hits[j].Id
+	})
+	return hits
+}
+
+// similarWords finds the indexed words close enough to term, with how
+// close they are.
+func (ix *SearchIndex) similarWords(term string) map[string]float64 {
+	found := map[string]float64{}
+	if _, ok := ix.words[term]; ok {
+		found[term] = 1
+	}
+	termGrams := Trigrams(term)
+	shared := map[string]int{}
+	for _, gram := range termGrams {
+		for _, word := range ix.grams[gram] {
+			shared[word]++
+		}
+	}
+	for word, count := range shared {
+		if word == term {
+			continue
+		}
+		similarity := float64(count) / float64(len(termGrams)+len(Trigrams(word))-count)
+		if len([]rune(term)) >= 2 && strings.HasPrefix(word, term) && similarity < 0.8 {
+			similarity = 0.8
+		}
+		if similarity >= minTrigramSimilarity {
+			found[word] = similarity
+		}
+	}
+	return found
+}
+
+// SearchTerms splits text into lower-case words of letters and digits.
+func SearchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Trigrams returns the distinct three-letter sequences of a word padded with
+// two leading spaces and one trailing space, so short words and word starts
+// still produce trigrams.
+func Trigrams(word string) []string {
+	runes := []rune("  " + word + " ")
+	seen := map[string]bool{}
+	var grams []string
+	for i := 0; i+3 <= len(runes); i++ {
+		gram := string(runes[i : i+3])
+		if !seen[gram] {
+			seen[gram] = true
+			grams = append(grams, gram)
+		}
+	}
+	return grams
+}
+
+// Highlight wraps the words of text found in matched in <mark> tags,
+// escaping the rest as HTML. It returns "" when nothing in text matched.
+func Highlight(text string, matched map[string]bool) string {
+	var b strings.Builder
+	found := false
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			b.WriteString(html.EscapeString(string(runes[i])))
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+			j++
+		}
+		word := string(runes[i:j])
+		if matched[strings.ToLower(word)] {
+			b.WriteString("<mark>" + html.EscapeString(word) + "</mark>")
+			found = true
+		} else {
+			b.WriteString(html.EscapeString(word))
+		}
+		i = j
+	}
+	if !found {
+		return ""
+	}
+	return b.String()
+}
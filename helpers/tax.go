@@ -0,0 +1,181 @@
+package helpers
+
+import (
+	"strings"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// TaxLine is an order line as seen by the tax calculation.
+type TaxLine struct {
+	Category string
+	Amount   float64
+	// Exempt lines, such as gift card sales, carry no tax, service charge or
+	// discount.
+	Exempt bool
+}
+
+// OrderCharges is the full price breakdown of an order.
+type OrderCharges struct {
+	Subtotal        float64
+	Discount_Total  float64
+	Net_Amount      float64
+	Service_Charges []models.Tax_Summary
+	Taxes           []models.Tax_Summary
+	Tax_Total       float64
+	Tax_Inclusive   bool
+	Total           float64
+}
+
+// CalculateCharges works out service charges and taxes for the lines after
+// spreading the order discount over them in proportion to their amounts.
+//
+// With tax-inclusive prices the line amounts already contain the taxes, which
+// are backed out to find the net price; service charges are added on top of
+// the net price either way. Taxes flagged Taxable_Service_Charge also apply to
+// the service charge.
+func CalculateCharges(rates []models.Tax_Rate, lines []TaxLine, discount float64, inclusive bool) OrderCharges {
+	charges := OrderCharges{Tax_Inclusive: inclusive}
+
+	var eligible, exempt float64
+	for _, line := range lines {
+		charges.Subtotal += line.Amount
+		if line.Exempt {
+			exempt += line.Amount
+		} else {
+			eligible += line.Amount
+		}
+	}
+	if discount > eligible {
+		discount = eligible
+	}
+	if discount < 0 {
+		discount = 0
+	}
+	charges.Discount_Total = roundMoney(discount)
+
+	var taxRates, serviceRates []models.Tax_Rate
+	for _, rate := range rates {
+		if rate.Type == "SERVICE_CHARGE" {
+			serviceRates = append(serviceRates, rate)
+		} else {
+			taxRates = append(taxRates, rate)
+		}
+	}
+	taxes := newSummaries(taxRates)
+	services := newSummaries(serviceRates)
+
+	var net float64
+	for _, line := range lines {
+		if line.Exempt || eligible <= 0 {
+			continue
+		}
+		gross := line.Amount - discount*line.Amount/eligible
+
+		var simple, compound float64
+		for _, rate := range taxRates {
+			if rateCovers(rate, line.Category) {
+				if rate.Compound {
+					compound += rate.Rate
+				} else {
+					simple += rate.Rate
+				}
+			}
+		}
+		lineNet := gross
+		if inclusive {
+			lineNet = gross / ((1 + simple/100) * (1 + compound/100))
+		}
+		net += lineNet
+
+		for i, rate := range taxRates {
+			if !rateCovers(rate, line.Category) {
+				continue
+			}
+			base := lineNet
+			if rate.Compound {
+				base = lineNet * (1 + simple/100)
+			}
+			taxes[i].Taxable_Amount += base
+			taxes[i].Amount += base * rate.Rate / 100
+		}
+		for i, rate := range serviceRates {
+			if rateCovers(rate, line.Category) {
+				services[i].Taxable_Amount += lineNet
+				services[i].Amount += lineNet * rate.Rate / 100
+			}
+		}
+	}
+
+	var serviceTotal, serviceTax float64
+	for _, service := range services {
+		serviceTotal += service.Amount
+	}
+	for i, rate := range taxRates {
+		if rate.Taxable_Service_Charge && serviceTotal > 0 {
+			taxes[i].Taxable_Amount += serviceTotal
+			taxes[i].Amount += serviceTotal * rate.Rate / 100
+			serviceTax += serviceTotal * rate.Rate / 100
+		}
+	}
+
+	charges.Service_Charges = roundSummaries(services)
+	charges.Taxes = roundSummaries(taxes)
+	for _, tax := range charges.Taxes {
+		charges.Tax_Total += tax.Amount
+	}
+	serviceTotal = 0
+	for _, service := range charges.Service_Charges {
+		serviceTotal += service.Amount
+	}
+	charges.Subtotal = roundMoney(charges.Subtotal)
+	charges.Net_Amount = roundMoney(net)
+	charges.Tax_Total = roundMoney(charges.Tax_Total)
+	if inclusive {
+		// Line taxes are already inside the prices; only the service charge
+		// and its tax are added.
+		charges.Total = roundMoney(eligible - discount + exempt + serviceTotal + serviceTax)
+	} else {
+		charges.Total = roundMoney(charges.Net_Amount + exempt + serviceTotal + charges.Tax_Total)
+	}
+	return charges
+}
+
+func rateCovers(rate models.Tax_Rate, category string) bool {
+	if len(rate.Categories) == 0 {
+		return true
+	}
+	for _, c := range rate.Categories {
+		if strings.EqualFold(c, category) {
+			return true
+		}
+	}
+	return false
+}
+
+func newSummaries(rates []models.Tax_Rate) []models.Tax_Summary {
+	summaries := make([]models.Tax_Summary, len(rates))
+	for i, rate := range rates {
+		summaries[i] = models.Tax_Summary{
+			Tax_Rate_Id: rate.Tax_Rate_Id,
+			Name:        rate.Name,
+			Code:        rate.Code,
+			Rate:        rate.Rate,
+		}
+	}
+	return summaries
+}
+
+// roundSummaries rounds each summary and drops the rates that charged nothing.
+func roundSummaries(summaries []models.Tax_Summary) []models.Tax_Summary {
+	var rounded []models.Tax_Summary
+	for _, summary := range summaries {
+		if summary.Taxable_Amount <= 0 {
+			continue
+		}
+		summary.Taxable_Amount = roundMoney(summary.Taxable_Amount)
+		summary.Amount = roundMoney(summary.Amount)
+		rounded = append(rounded, summary)
+	}
+	return rounded
+}
@@ -0,0 +1,153 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+var (
+	vat         = models.Tax_Rate{Code: "VAT", Type: "TAX", Rate: 15}
+	vatOnCharge = models.Tax_Rate{Code: "VAT", Type: "TAX", Rate: 15, Taxable_Service_Charge: true}
+	compoundVat = models.Tax_Rate{Code: "VAT", Type: "TAX", Rate: 15, Compound: true}
+	excise      = models.Tax_Rate{Code: "EXCISE", Type: "TAX", Rate: 10}
+	drinksTax   = models.Tax_Rate{Code: "DRINKS", Type: "TAX", Rate: 20, Categories: []string{"Drinks"}}
+	service     = models.Tax_Rate{Code: "SVC", Type: "SERVICE_CHARGE", Rate: 10}
+)
+
+func TestCalculateCharges(t *testing.T) {
+	tests := []struct {
+		name      string
+		rates     []models.Tax_Rate
+		lines     []TaxLine
+		discount  float64
+		inclusive bool
+		net       float64
+		discounts float64
+		taxTotal  float64
+		total     float64
+		taxes     map[string]float64
+		services  map[string]float64
+	}{
+		{
+			name:  "exclusive",
+			rates: []models.Tax_Rate{vat},
+			lines: []TaxLine{{Category: "Mains", Amount: 100}},
+			net:   100, taxTotal: 15, total: 115,
+			taxes: map[string]float64{"VAT": 15},
+		},
+		{
+			name:  "inclusive",
+			rates: []models.Tax_Rate{vat},
+			lines: []TaxLine{{Category: "Mains", Amount: 115}}, inclusive: true,
+			net: 100, taxTotal: 15, total: 115,
+			taxes: map[string]float64{"VAT": 15},
+		},
+		{
+			name:  "compound exclusive",
+			rates: []models.Tax_Rate{excise, compoundVat},
+			lines: []TaxLine{{Category: "Mains", Amount: 100}},
+			net:   100, taxTotal: 26.5, total: 126.5,
+			taxes: map[string]float64{"EXCISE": 10, "VAT": 16.5},
+		},
+		{
+			name:  "compound inclusive",
+			rates: []models.Tax_Rate{excise, compoundVat},
+			lines: []TaxLine{{Category: "Mains", Amount: 126.5}}, inclusive: true,
+			net: 100, taxTotal: 26.5, total: 126.5,
+			taxes: map[string]float64{"EXCISE": 10, "VAT": 16.5},
+		},
+		{
+			name:  "service charge exclusive",
+			rates: []models.Tax_Rate{vatOnCharge, service},
+			lines: []TaxLine{{Category: "Mains", Amount: 200}},
+			net:   200, taxTotal: 33, total: 253,
+			taxes:    map[string]float64{"VAT": 33},
+			services: map[string]float64{"SVC": 20},
+		},
+		{
+			name:  "service charge inclusive",
+			rates: []models.Tax_Rate{vatOnCharge, service},
+			lines: []TaxLine{{Category: "Mains", Amount: 230}}, inclusive: true,
+			net: 200, taxTotal: 33, total: 253,
+			taxes:    map[string]float64{"VAT": 33},
+			services: map[string]float64{"SVC": 20},
+		},
+		{
+			name:  "service charge not taxed",
+			rates: []models.Tax_Rate{vat, service},
+			lines: []TaxLine{{Category: "Mains", Amount: 200}},
+			net:   200, taxTotal: 30, total: 250,
+			taxes:    map[string]float64{"VAT": 30},
+			services: map[string]float64{"SVC": 20},
+		},
+		{
+			name:  "discount spread over lines",
+			rates: []models.Tax_Rate{vat},
+			lines: []TaxLine{{Category: "Mains", Amount: 60}, {Category: "Drinks", Amount: 40}}, discount: 10,
+			net: 90, discounts: 10, taxTotal: 13.5, total: 103.5,
+			taxes: map[string]float64{"VAT": 13.5},
+		},
+		{
+			name:  "category rate",
+			rates: []models.Tax_Rate{vat, drinksTax},
+			lines: []TaxLine{{Category: "Mains", Amount: 100}, {Category: "drinks", Amount: 50}},
+			net:   150, taxTotal: 32.5, total: 182.5,
+			taxes: map[string]float64{"VAT": 22.5, "DRINKS": 10},
+		},
+		{
+			name:  "exempt line and discount capped at the taxable lines",
+			rates: []models.Tax_Rate{vat, service},
+			lines: []TaxLine{{Category: "Mains", Amount: 100}, {Amount: 50, Exempt: true}}, discount: 200,
+			net: 0, discounts: 100, taxTotal: 0, total: 50,
+			taxes: map[string]float64{},
+		},
+		{
+			name:  "exclusive rounding",
+			rates: []models.Tax_Rate{vat},
+			lines: []TaxLine{{Category: "Mains", Amount: 9.99}},
+			net:   9.99, taxTotal: 1.5, total: 11.49,
+			taxes: map[string]float64{"VAT": 1.5},
+		},
+		{
+			name:  "inclusive rounding",
+			rates: []models.Tax_Rate{vat},
+			lines: []TaxLine{{Category: "Mains", Amount: 10}}, inclusive: true,
+			net: 8.7, taxTotal: 1.3, total: 10,
+			taxes: map[string]float64{"VAT": 1.3},
+		},
+		{
+			name:  "no rates",
+			lines: []TaxLine{{Category: "Mains", Amount: 42.5}},
+			net:   42.5, total: 42.5,
+			taxes: map[string]float64{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			charges := CalculateCharges(test.rates, test.lines, test.discount, test.inclusive)
+			if charges.Net_Amount != test.net || charges.Discount_Total != test.discounts ||
+				charges.Tax_Total != test.taxTotal || charges.Total != test.total {
+				t.Errorf("net %v, discount %v, tax %v, total %v; want %v, %v, %v, %v",
+					charges.Net_Amount, charges.Discount_Total, charges.Tax_Total, charges.Total,
+					test.net, test.discounts, test.taxTotal, test.total)
+			}
+			checkSummaries(t, "taxes", charges.Taxes, test.taxes)
+			checkSummaries(t, "service charges", charges.Service_Charges, test.services)
+		})
+	}
+}
+
+func checkSummaries(t *testing.T, kind string, summaries []models.Tax_Summary, want map[string]float64) {
+	t.Helper()
+	if len(summaries) != len(want) {
+		t.Errorf("%s %v, want %v", kind, summaries, want)
+		return
+	}
+	for _, summary := range summaries {
+		if amount, ok := want[summary.Code]; !ok || summary.Amount != amount {
+			t.Errorf("%s %v, want %v", kind, summaries, want)
+			return
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	_ "image/gif" // GIF uploads are decoded through image.Decode
+	"image/jpeg"
+	"image/png"
+)
+
+// ImageExtensions maps the image types accepted for upload to the file
+// extension they are stored under.
+var ImageExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// Thumbnail scales img down so its longer side is at most maxSide, averaging
+// every source pixel that falls under each target pixel. Images already small
+// enough are returned unchanged.
+func Thumbnail(img image.Image, maxSide int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= maxSide && srcH <= maxSide {
+		return img
+	}
+	dstW, dstH := maxSide, srcH*maxSide/srcW
+	if srcH > srcW {
+		dstW, dstH = srcW*maxSide/srcH, maxSide
+	}
+	if dstW < 1 {
+		dstW = 1
+	}
+	if dstH < 1 {
+		dstH = 1
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := bounds.Min.Y + (y+1)*srcH/dstH
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := bounds.Min.X + (x+1)*srcW/dstW
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			// Averaged premultiplied values are converted back to straight
+			// alpha so transparent edges do not darken.
+			pixel := color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)}
+			dst.Set(x, y, color.NRGBAModel.Convert(pixel))
+		}
+	}
+	return dst
+}
+
+// EncodeImage writes img in the given upload type. GIFs are re-encoded as
+// PNG since thumbnails are single frames; the returned type says which.
+func EncodeImage(img image.Image, contentType string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	switch contentType {
+	case "image/jpeg":
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), contentType, nil
+	default:
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/png", nil
+	}
+}
@@ -0,0 +1,66 @@
+package helpers
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestThumbnailSize(t *testing.T) {
+	tests := []struct {
+		width, height, maxSide int
+		wantW, wantH           int
+	}{
+		{400, 200, 100, 100, 50},
+		{30, 300, 100, 10, 100},
+		{1000, 1, 100, 100, 1},
+		{80, 60, 100, 80, 60},
+		{100, 100, 100, 100, 100},
+	}
+	for _, test := range tests {
+		img := image.NewNRGBA(image.Rect(0, 0, test.width, test.height))
+		bounds := Thumbnail(img, test.maxSide).Bounds()
+		if bounds.Dx() != test.wantW || bounds.Dy() != test.wantH {
+			t.Errorf("%dx%d at %d: got %dx%d, want %dx%d", test.width, test.height, test.maxSide,
+				bounds.Dx(), bounds.Dy(), test.wantW, test.wantH)
+		}
+	}
+}
+
+func TestThumbnailAveragesPixels(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			c := color.NRGBA{R: 255, A: 255}
+			if x%2 == 1 {
+				c = color.NRGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	thumb := Thumbnail(img, 2)
+	r, g, b, a := thumb.At(0, 0).RGBA()
+	if r>>8 != 127 || g != 0 || b>>8 != 127 || a>>8 != 255 {
+		t.Errorf("got %d,%d,%d,%d, want an even mix of red and blue", r>>8, g>>8, b>>8, a>>8)
+	}
+}
+
+func TestEncodeImageStoresGIFAsPNG(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	data, contentType, err := EncodeImage(img, "image/gif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "image/png" {
+		t.Errorf("content type %s, want image/png", contentType)
+	}
+	decoded, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Bounds().Dx() != 3 || decoded.Bounds().Dy() != 2 {
+		t.Errorf("decoded %v", decoded.Bounds())
+	}
+}
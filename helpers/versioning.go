@@ -0,0 +1,203 @@
+package helpers
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// Changes recorded in food and menu versions.
+const (
+	VersionBaseline   = "BASELINE"
+	VersionCreated    = "CREATED"
+	VersionUpdated    = "UPDATED"
+	VersionDeleted    = "DELETED"
+	VersionRolledBack = "ROLLED_BACK"
+)
+
+// Fields left out of diffs and rollbacks: bookkeeping, the uploaded image,
+// whose files are removed once it is replaced, and the 86 board, which
+// changes during service.
+var (
+	foodUnversioned = map[string]bool{"id": true, "created_at": true, "updated_at": true, "version": true, "food_image": true, "images": true, "availability": true, "sold_out_until": true}
+	menuUnversioned = map[string]bool{"id": true, "created_at": true, "updated_at": true, "version": true}
+)
+
+// PricePeriod is a price a food was sold at, from the version that set it
+// until the next price or its deletion. Effective_To is nil while it is
+// still the price.
+type PricePeriod struct {
+	Price          float64    `json:"price"`
+	Effective_From time.Time  `json:"effective_from"`
+	Effective_To   *time.Time `json:"effective_to,omitempty"`
+	Version        int        `json:"version"`
+	Changed_By     string     `json:"changed_by,omitempty"`
+}
+
+// FieldChange is one field that differs between two versions.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// FoodDiff is a food added to, removed from or changed on a menu.
+type FoodDiff struct {
+	Food_Id    string        `json:"food_id"`
+	Food_Name  string        `json:"food_name"`
+	Food_Price *float64      `json:"food_price,omitempty"`
+	Changes    []FieldChange `json:"changes,omitempty"`
+}
+
+// MenuDiff is what changed on a menu between two of its versions.
+type MenuDiff struct {
+	From_Version  int           `json:"from_version"`
+	To_Version    int           `json:"to_version"`
+	Menu          []FieldChange `json:"menu"`
+	Added_Foods   []FoodDiff    `json:"added_foods"`
+	Removed_Foods []FoodDiff    `json:"removed_foods"`
+	Changed_Foods []FoodDiff    `json:"changed_foods"`
+}
+
+// FoodPriceHistory turns a food's versions into the prices it had, newest
+// first. Versions that leave the price alone are folded into the period
+// they fall in.
+func FoodPriceHistory(versions []models.Food_Version) []PricePeriod {
+	sorted := append([]models.Food_Version(nil), versions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	periods := []PricePeriod{}
+	open := false
+	for _, version := range sorted {
+		price := 0.0
+		if version.Food.Food_Price != nil {
+			price = *version.Food.Food_Price
+		}
+		if open && version.Change != VersionDeleted && periods[len(periods)-1].Price == price {
+			continue
+		}
+		if open {
+			to := version.Effective_From
+			periods[len(periods)-1].Effective_To = &to
+			open = false
+		}
+		if version.Change == VersionDeleted {
+			continue
+		}
+		periods = append(periods, PricePeriod{
+			Price:          price,
+			Effective_From: version.Effective_From,
+			Version:        version.Version,
+			Changed_By:     version.Changed_By,
+		})
+		open = true
+	}
+	for i, j := 0, len(periods)-1; i < j; i, j = i+1, j-1 {
+		periods[i], periods[j] = periods[j], periods[i]
+	}
+	return periods
+}
+
+// PriceAt finds the period a food's price history was in at t. It reports
+// false when the food did not exist then, or not as far as its history
+// goes.
+func PriceAt(periods []PricePeriod, t time.Time) (PricePeriod, bool) {
+	for _, period := range periods {
+		if !t.Before(period.Effective_From) && (period.Effective_To == nil || t.Before(*period.Effective_To)) {
+			return period, true
+		}
+	}
+	return PricePeriod{}, false
+}
+
+// FoodChanges lists the versioned fields that differ between two foods.
+func FoodChanges(from, to models.Food) []FieldChange {
+	return fieldChanges(from, to, foodUnversioned)
+}
+
+// MenuChanges lists the versioned fields that differ between two menus.
+func MenuChanges(from, to models.Menu) []FieldChange {
+	return fieldChanges(from, to, menuUnversioned)
+}
+
+// DiffMenuVersions compares two versions of a menu: its own fields, and the
+// foods that were added, removed or changed between them.
+func DiffMenuVersions(from, to models.Menu_Version) MenuDiff {
+	diff := MenuDiff{
+		From_Version:  from.Version,
+		To_Version:    to.Version,
+		Menu:          MenuChanges(from.Menu, to.Menu),
+		Added_Foods:   []FoodDiff{},
+		Removed_Foods: []FoodDiff{},
+		Changed_Foods: []FoodDiff{},
+	}
+	before := map[string]models.Food{}
+	for _, food := range from.Foods {
+		before[foodKey(food)] = food
+	}
+	after := map[string]bool{}
+	for _, food := range to.Foods {
+		after[foodKey(food)] = true
+		previous, ok := before[foodKey(food)]
+		if !ok {
+			diff.Added_Foods = append(diff.Added_Foods, foodDiff(food, nil))
+			continue
+		}
+		if changes := FoodChanges(previous, food); len(changes) > 0 {
+			diff.Changed_Foods = append(diff.Changed_Foods, foodDiff(food, changes))
+		}
+	}
+	for _, food := range from.Foods {
+		if !after[foodKey(food)] {
+			diff.Removed_Foods = append(diff.Removed_Foods, foodDiff(food, nil))
+		}
+	}
+	return diff
+}
+
+func foodKey(food models.Food) string {
+	if food.Food_Id == nil {
+		return ""
+	}
+	return *food.Food_Id
+}
+
+func foodDiff(food models.Food, changes []FieldChange) FoodDiff {
+	return FoodDiff{Food_Id: foodKey(food), Food_Name: food.Food_Name, Food_Price: food.Food_Price, Changes: changes}
+}
+
+// fieldChanges compares two documents field by field as they appear in
+// JSON, so nested values such as nutrition compare as a whole.
+func fieldChanges(from, to interface{}, skip map[string]bool) []FieldChange {
+	a, b := jsonFields(from), jsonFields(to)
+	var fields []string
+	for field := range a {
+		fields = append(fields, field)
+	}
+	for field := range b {
+		if _, ok := a[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	var changes []FieldChange
+	for _, field := range fields {
+		if skip[field] || reflect.DeepEqual(a[field], b[field]) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: field, From: a[field], To: b[field]})
+	}
+	return changes
+}
+
+func jsonFields(document interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if data, err := json.Marshal(document); err == nil {
+		json.Unmarshal(data, &fields)
+	}
+	return fields
+}
@@ -0,0 +1,113 @@
+package helpers
+
+import (
+	"sort"
+	"time"
+
+	"github.com/abik1221/Tewanay-Engineering_Intership/models"
+)
+
+// WasteReasons are why food was thrown away. RETURNED is food sent back by
+// a guest.
+var WasteReasons = []string{"SPOILED", "DROPPED", "RETURNED", "OVERPRODUCTION"}
+
+// WasteDay is the waste of one day, in total and by reason.
+type WasteDay struct {
+	Date      string             `json:"date"`
+	Cost      float64            `json:"cost"`
+	By_Reason map[string]float64 `json:"by_reason"`
+}
+
+// WasteItem is how much of one ingredient or dish was wasted.
+type WasteItem struct {
+	Ingredient_Id string  `json:"ingredient_id,omitempty"`
+	Food_Id       string  `json:"food_id,omitempty"`
+	Name          string  `json:"name"`
+	Unit          string  `json:"unit"`
+	Quantity      float64 `json:"quantity"`
+	Cost          float64 `json:"cost"`
+}
+
+// WasteSummary is the cost of waste over a period, by reason, by day and by
+// the items most wasted.
+type WasteSummary struct {
+	Total_Cost float64            `json:"total_cost"`
+	Events     int                `json:"events"`
+	By_Reason  map[string]float64 `json:"by_reason"`
+	By_Day     []WasteDay         `json:"by_day"`
+	Top_Items  []WasteItem        `json:"top_items"`
+}
+
+// RecipeUsage is what portions of a recipe take out of stock, keyed by
+// ingredient id.
+func RecipeUsage(recipe models.Recipe, portions float64) map[string]float64 {
+	usage := map[string]float64{}
+	for _, line := range recipe.Lines {
+		usage[line.Ingredient_Id] = RoundQuantity(usage[line.Ingredient_Id] + line.Quantity*portions)
+	}
+	return usage
+}
+
+// SummarizeWaste adds up waste events. Days are counted in location, and
+// top items are the ten that cost the most.
+func SummarizeWaste(events []models.Waste_Event, location *time.Location) WasteSummary {
+	summary := WasteSummary{Events: len(events), By_Reason: map[string]float64{}, By_Day: []WasteDay{}, Top_Items: []WasteItem{}}
+	for _, reason := range WasteReasons {
+		summary.By_Reason[reason] = 0
+	}
+	days := map[string]*WasteDay{}
+	items := map[string]*WasteItem{}
+	for _, event := range events {
+		summary.Total_Cost += event.Cost
+		summary.By_Reason[event.Reason] += event.Cost
+
+		date := event.Created_At.In(location).Format("2006-01-02")
+		day, ok := days[date]
+		if !ok {
+			day = &WasteDay{Date: date, By_Reason: map[string]float64{}}
+			days[date] = day
+		}
+		day.Cost += event.Cost
+		day.By_Reason[event.Reason] += event.Cost
+
+		key := "ingredient/" + event.Ingredient_Id
+		if event.Food_Id != "" {
+			key = "food/" + event.Food_Id
+		}
+		item, ok := items[key]
+		if !ok {
+			item = &WasteItem{Ingredient_Id: event.Ingredient_Id, Food_Id: event.Food_Id, Name: event.Name, Unit: event.Unit}
+			items[key] = item
+		}
+		item.Quantity += event.Quantity
+		item.Cost += event.Cost
+	}
+
+	summary.Total_Cost = roundMoney(summary.Total_Cost)
+	for reason, cost := range summary.By_Reason {
+		summary.By_Reason[reason] = roundMoney(cost)
+	}
+	for _, day := range days {
+		day.Cost = roundMoney(day.Cost)
+		for reason, cost := range day.By_Reason {
+			day.By_Reason[reason] = roundMoney(cost)
+		}
+		summary.By_Day = append(summary.By_Day, *day)
+	}
+	sort.Slice(summary.By_Day, func(i, j int) bool { return summary.By_Day[i].Date < summary.By_Day[j].Date })
+	for _, item := range items {
+		item.Quantity = RoundQuantity(item.Quantity)
+		item.Cost = roundMoney(item.Cost)
+		summary.Top_Items = append(summary.Top_Items, *item)
+	}
+	sort.Slice(summary.Top_Items, func(i, j int) bool {
+		if summary.Top_Items[i].Cost != summary.Top_Items[j].Cost {
+			return summary.Top_Items[i].Cost > summary.Top_Items[j].Cost
+		}
+		return summary.Top_Items[i].Name < summary.Top_Items[j].Name
+	})
+	if len(summary.Top_Items) > 10 {
+		summary.Top_Items = summary.Top_Items[:10]
+	}
+	return summary
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"os"
+	"time"
+	_ "time/tzdata" // branch timezones must resolve on hosts without zoneinfo
 
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
 	"github.com/abik1221/Tewanay-Engineering_Intership/database"
+	"github.com/abik1221/Tewanay-Engineering_Intership/helpers"
 	"github.com/abik1221/Tewanay-Engineering_Intership/middlewares"
 	"github.com/abik1221/Tewanay-Engineering_Intership/routes"
 	"github.com/gin-gonic/gin"
@@ -27,16 +31,50 @@ func main() {
 
 	// Your existing routes
 	routes.UserRoutes(router)
+	routes.InvoiceVerificationRoutes(router)
+	routes.ImageRoutes(router)
 
 	// Auth middleware applied after user routes
 	router.Use(middlewares.AuthMiddleware())
 
+	routes.UserRoleRoutes(router)
 	routes.FoodRoutes(router)
 	routes.MenuRoutes(router)
 	routes.InvoiceRoutes(router)
 	routes.OrderRoutes(router)
 	routes.TableRoutes(router)
 	routes.OrderItemRoutes(router)
+	routes.ReversalRoutes(router)
+	routes.BranchRoutes(router)
+	routes.PrinterRoutes(router)
+	routes.ShiftRoutes(router)
+	routes.PromotionRoutes(router)
+	routes.PriceRuleRoutes(router)
+	routes.CustomerRoutes(router)
+	routes.GiftCardRoutes(router)
+	routes.TaxRoutes(router)
+	routes.AccountingRoutes(router)
+	routes.ModifierRoutes(router)
+	routes.ComboRoutes(router)
+	routes.SearchRoutes(router)
+	routes.AvailabilityRoutes(router)
+	routes.InventoryRoutes(router)
+	routes.PurchasingRoutes(router)
+	routes.CostingRoutes(router)
+	routes.WasteRoutes(router)
+	routes.VersionRoutes(router)
+
+	overdueInterval, err := time.ParseDuration(os.Getenv("OVERDUE_CHECK_INTERVAL"))
+	if err != nil || overdueInterval <= 0 {
+		overdueInterval = time.Hour
+	}
+	controllers.StartOverdueInvoiceJob(overdueInterval, helpers.NotifierFromEnv())
+
+	reorderInterval, err := time.ParseDuration(os.Getenv("REORDER_CHECK_INTERVAL"))
+	if err != nil || reorderInterval <= 0 {
+		reorderInterval = time.Hour
+	}
+	controllers.StartReorderJob(reorderInterval)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Account_Mapping is the chart of accounts the journal export posts to. The
+// defaults in helpers apply until a mapping is saved.
+type Account_Mapping struct {
+	ID                  primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Cash                string             `json:"cash" validate:"required"`
+	Card                string             `json:"card" validate:"required"`
+	Mobile_Money        string             `json:"mobile_money" validate:"required"`
+	Accounts_Receivable string             `json:"accounts_receivable" validate:"required"`
+	Sales               string             `json:"sales" validate:"required"`
+	Sales_Discounts     string             `json:"sales_discounts" validate:"required"`
+	Sales_Returns       string             `json:"sales_returns" validate:"required"`
+	Service_Charges     string             `json:"service_charges" validate:"required"`
+	Tax_Payable         string             `json:"tax_payable" validate:"required"`
+	Tax_Accounts        map[string]string  `json:"tax_accounts,omitempty"`
+	Tips_Payable        string             `json:"tips_payable" validate:"required"`
+	Gift_Card_Liability string             `json:"gift_card_liability" validate:"required"`
+	Loyalty_Redemptions string             `json:"loyalty_redemptions" validate:"required"`
+	Updated_By          string             `json:"updated_by,omitempty"`
+	Updated_At          time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// Journal_Entry is one balanced double-entry posting.
+type Journal_Entry struct {
+	Entry_Id  string         `json:"entry_id"`
+	Date      string         `json:"date"`
+	Source    string         `json:"source"`
+	Source_Id string         `json:"source_id"`
+	Memo      string         `json:"memo"`
+	Lines     []Journal_Line `json:"lines"`
+}
+
+type Journal_Line struct {
+	Account string  `json:"account"`
+	Debit   float64 `json:"debit"`
+	Credit  float64 `json:"credit"`
+}
+
+// Accounting_Period is a day that has been exported and locked. The exported
+// entries are kept so later downloads match what the accountant received.
+type Accounting_Period struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Period_Id   string             `json:"period_id"`
+	Date        string             `json:"date"`
+	Branch_Id   string             `json:"branch_id,omitempty"`
+	Entries     []Journal_Entry    `json:"entries"`
+	Total_Debit float64            `json:"total_debit"`
+	Locked_By   string             `json:"locked_by"`
+	Locked_At   time.Time          `bson:"locked_at,omitempty" json:"locked_at,omitempty"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Branch struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Branch_Id         string             `json:"branch_id"`
+	Name              string             `json:"name" validate:"required,min=2,max=100"`
+	Address           string             `json:"address" validate:"required"`
+	Phone             string             `json:"phone" validate:"required"`
+	Email             string             `json:"email,omitempty" validate:"omitempty,email"`
+	Tax_Number        string             `json:"tax_number,omitempty"`
+	Currency          string             `json:"currency,omitempty"`
+	Timezone          string             `json:"timezone,omitempty"`
+	Tax_Inclusive     *bool              `bson:"tax_inclusive,omitempty" json:"tax_inclusive,omitempty"`
+	Document_Template Document_Template  `json:"document_template"`
+	Created_At        time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At        time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// Document_Template customises the invoices and receipts printed for a
+// branch. Empty fields fall back to the defaults in helpers.
+type Document_Template struct {
+	Invoice_Title  string   `json:"invoice_title,omitempty"`
+	Header_Lines   []string `json:"header_lines,omitempty"`
+	Footer         string   `json:"footer,omitempty"`
+	Accent_Color   string   `json:"accent_color,omitempty" validate:"omitempty,hexcolor"`
+	Hide_QR_Code   bool     `json:"hide_qr_code,omitempty"`
+	Receipt_Width  int      `json:"receipt_width,omitempty" validate:"omitempty,min=24,max=64"`
+	Receipt_Layout string   `json:"receipt_layout,omitempty"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Combo is a set meal sold at one price, such as a main, a side and a drink.
+// Each slot takes one food from its choices; some choices cost extra.
+type Combo struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Combo_Id      string             `json:"combo_id"`
+	Name          string             `json:"name" validate:"required,min=2,max=50"`
+	Description   string             `json:"description,omitempty"`
+	Price         *float64           `json:"price" validate:"required,gte=0"`
+	Slots         []Combo_Slot       `json:"slots" validate:"required,min=1,dive"`
+	Active        bool               `json:"active"`
+	Display_Order *int               `bson:"display_order,omitempty" json:"display_order,omitempty"`
+	Created_At    time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At    time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+type Combo_Slot struct {
+	Slot_Id  string         `json:"slot_id"`
+	Name     string         `json:"name" validate:"required,max=50"`
+	Optional bool           `json:"optional"`
+	Choices  []Combo_Choice `json:"choices" validate:"required,min=1,dive"`
+}
+
+type Combo_Choice struct {
+	Food_Id  string  `json:"food_id" validate:"required"`
+	Upcharge float64 `json:"upcharge" validate:"gte=0"`
+}
+
+// Combo_Selection is the food a guest picked for one slot when ordering.
+type Combo_Selection struct {
+	Slot_Id   string              `json:"slot_id" validate:"required"`
+	Food_Id   string              `json:"food_id" validate:"required"`
+	Modifiers []Selected_Modifier `json:"modifiers,omitempty"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Food_Cost is the theoretical cost of a food at a point in time, worked
+// out from its recipe and the ingredient costs of the day. A new one is
+// stored whenever the cost or the price changes, so the history shows how
+// the dish's margin moved and why. Margin is the gross margin as a
+// percentage of the price.
+type Food_Cost struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Food_Cost_Id  string             `json:"food_cost_id,omitempty"`
+	Food_Id       string             `json:"food_id"`
+	Food_Name     string             `json:"food_name"`
+	Price         float64            `json:"price"`
+	Cost          float64            `json:"cost"`
+	Margin        float64            `json:"margin"`
+	Lines         []Cost_Line        `json:"lines"`
+	Options       []Option_Cost      `bson:"options,omitempty" json:"options,omitempty"`
+	Missing_Costs []string           `bson:"missing_costs,omitempty" json:"missing_costs,omitempty"`
+	Reason        string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	Created_At    time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+}
+
+// Cost_Line is what one ingredient of a recipe costs.
+type Cost_Line struct {
+	Ingredient_Id string  `json:"ingredient_id"`
+	Name          string  `json:"name"`
+	Unit          string  `json:"unit"`
+	Quantity      float64 `json:"quantity"`
+	Unit_Cost     float64 `json:"unit_cost"`
+	Cost          float64 `json:"cost"`
+}
+
+// Option_Cost is what choosing a modifier option adds to the cost of a
+// food, negative for removals, and the margin of the dish with it.
+type Option_Cost struct {
+	Modifier_Group_Id string  `json:"modifier_group_id"`
+	Option_Id         string  `json:"option_id"`
+	Name              string  `json:"name"`
+	Price_Delta       float64 `json:"price_delta"`
+	Cost              float64 `json:"cost"`
+	Margin            float64 `json:"margin"`
+}
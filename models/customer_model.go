@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Customer is a guest account for the loyalty program. It is separate from
+// User, which is for staff logins.
+type Customer struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Customer_Id     string             `json:"customer_id"`
+	First_Name      string             `bson:"first_name" json:"first_name" validate:"required,max=50"`
+	Last_Name       string             `bson:"last_name" json:"last_name" validate:"max=50"`
+	Phone           string             `json:"phone" validate:"required,min=7,max=20"`
+	Email           string             `json:"email,omitempty" validate:"omitempty,email"`
+	Tier            string             `json:"tier"`
+	Lifetime_Points int                `json:"lifetime_points"`
+	Created_At      time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At      time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// Loyalty_Entry is one line of a customer's points ledger. The balance is the
+// sum of Points; Remaining tracks how much of a credit is still unspent so
+// redemptions and expiry consume the oldest points first.
+type Loyalty_Entry struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Entry_Id          string             `json:"entry_id"`
+	Customer_Id       string             `json:"customer_id"`
+	Type              string             `json:"type"`
+	Points            int                `json:"points"`
+	Remaining         int                `json:"remaining,omitempty"`
+	Amount            float64            `json:"amount,omitempty"`
+	Invoice_Id        string             `json:"invoice_id,omitempty"`
+	Payment_Id        string             `json:"payment_id,omitempty"`
+	Reversal_Id       string             `json:"reversal_id,omitempty"`
+	Reverses_Entry_Id string             `json:"reverses_entry_id,omitempty"`
+	Description       string             `json:"description,omitempty"`
+	Expires_At        time.Time          `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	Created_By        string             `json:"created_by,omitempty"`
+	Created_At        time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+}
+
+// Loyalty_Program holds the earn, redeem and expiry rules. There is a single
+// program; the defaults in helpers apply until one is saved.
+type Loyalty_Program struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Points_Per_Unit   float64            `json:"points_per_unit" validate:"gt=0"`
+	Point_Value       float64            `json:"point_value" validate:"gt=0"`
+	Min_Redeem_Points int                `json:"min_redeem_points" validate:"gte=0"`
+	Expiry_Days       int                `json:"expiry_days" validate:"gte=0"`
+	Tiers             []Loyalty_Tier     `json:"tiers" validate:"required,min=1,dive"`
+	Updated_By        string             `json:"updated_by,omitempty"`
+	Updated_At        time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// Loyalty_Tier multiplies earned points once a customer's lifetime points
+// reach Min_Points.
+type Loyalty_Tier struct {
+	Name       string  `json:"name" validate:"required"`
+	Min_Points int     `json:"min_points" validate:"gte=0"`
+	Multiplier float64 `json:"multiplier" validate:"gt=0"`
+}
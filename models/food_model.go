@@ -6,14 +6,60 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Food is a dish on a menu. A SOLD_OUT food with a Sold_Out_Until becomes
+// available again at that time; HIDDEN foods are left off menus. Version
+// is the number of the food's latest entry in its history.
 type Food struct {
 	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	Food_Name        string             `json:"food_name" validate:"required,min=2,max=50"`
 	Food_Price       *float64           `json:"food_price" validate:"required"`
 	Food_Description string             `json:"food_description" validate:"required"`
-	Food_Image       string             `json:"food_image" validate:"required"`
+	Food_Image       string             `json:"food_image"`
 	Created_AT       time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
 	Updated_AT       time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
 	Food_Id          *string            `json:"food_id" validate:"required"`
 	Menu_Id          *string            `json:"menu_id" validate:"required"`
-}
\ No newline at end of file
+	Station          string             `json:"station,omitempty"`
+	Display_Order    *int               `bson:"display_order,omitempty" json:"display_order,omitempty"`
+	Modifier_Groups  []string           `bson:"modifier_groups,omitempty" json:"modifier_groups,omitempty"`
+	Images           *Food_Images       `bson:"images,omitempty" json:"images,omitempty"`
+	Allergens        []string           `bson:"allergens,omitempty" json:"allergens,omitempty" validate:"omitempty,dive,oneof=gluten crustaceans eggs fish peanuts soybeans milk tree_nuts celery mustard sesame sulphites lupin molluscs"`
+	Dietary_Tags     []string           `bson:"dietary_tags,omitempty" json:"dietary_tags,omitempty" validate:"omitempty,dive,oneof=vegetarian vegan gluten_free dairy_free halal kosher fasting"`
+	Nutrition        *Nutrition         `bson:"nutrition,omitempty" json:"nutrition,omitempty"`
+	Portion          *Portion           `bson:"portion,omitempty" json:"portion,omitempty"`
+	Availability     string             `bson:"availability,omitempty" json:"availability,omitempty" validate:"omitempty,oneof=AVAILABLE SOLD_OUT HIDDEN"`
+	Sold_Out_Until   *time.Time         `bson:"sold_out_until,omitempty" json:"sold_out_until,omitempty"`
+	Version          int                `bson:"version,omitempty" json:"version,omitempty"`
+}
+
+// Nutrition is per serving. Every value is optional so partly known facts
+// can still be published. On a modifier option the values are added to the
+// food's and may be negative, e.g. for a removal.
+type Nutrition struct {
+	Calories        *float64 `bson:"calories,omitempty" json:"calories,omitempty"`
+	Protein_G       *float64 `bson:"protein_g,omitempty" json:"protein_g,omitempty"`
+	Carbohydrates_G *float64 `bson:"carbohydrates_g,omitempty" json:"carbohydrates_g,omitempty"`
+	Fat_G           *float64 `bson:"fat_g,omitempty" json:"fat_g,omitempty"`
+	Sodium_Mg       *float64 `bson:"sodium_mg,omitempty" json:"sodium_mg,omitempty"`
+}
+
+// Portion describes the serving a food's nutrition refers to, such as
+// 350 g serving 1 or 6 pieces serving 2.
+type Portion struct {
+	Size   float64 `json:"size" validate:"gt=0"`
+	Unit   string  `json:"unit" validate:"required,oneof=g ml piece"`
+	Serves int     `bson:"serves,omitempty" json:"serves,omitempty" validate:"gte=0"`
+}
+
+// Food_Images are the URLs of an uploaded food image and its thumbnails.
+// Keys are the stored objects, kept so a replaced image can be removed.
+type Food_Images struct {
+	Original     string    `json:"original"`
+	Medium       string    `json:"medium"`
+	Small        string    `json:"small"`
+	Content_Type string    `json:"content_type"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Keys         []string  `json:"-"`
+	Uploaded_At  time.Time `bson:"uploaded_at,omitempty" json:"uploaded_at,omitempty"`
+}
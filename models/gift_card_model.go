@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Gift_Card is a stored-value card. Cards are sold as an order line and stay
+// PENDING until that order's invoice is paid. Balance is kept in step with
+// the card's transactions, which are the audit trail.
+type Gift_Card struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Gift_Card_Id    string             `json:"gift_card_id"`
+	Code            string             `json:"code"`
+	Balance         float64            `json:"balance"`
+	Status          string             `json:"status"`
+	Customer_Id     string             `json:"customer_id,omitempty"`
+	Issued_Order_Id string             `json:"issued_order_id"`
+	Expires_At      time.Time          `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	Deactivated_By  string             `json:"deactivated_by,omitempty"`
+	Created_By      string             `json:"created_by"`
+	Created_At      time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At      time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// Gift_Card_Transaction is one movement on a gift card's balance.
+type Gift_Card_Transaction struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Transaction_Id string             `json:"transaction_id"`
+	Gift_Card_Id   string             `json:"gift_card_id"`
+	Type           string             `json:"type"`
+	Amount         float64            `json:"amount"`
+	Balance_After  float64            `json:"balance_after"`
+	Order_Id       string             `json:"order_id,omitempty"`
+	Invoice_Id     string             `json:"invoice_id,omitempty"`
+	Payment_Id     string             `json:"payment_id,omitempty"`
+	Reversal_Id    string             `json:"reversal_id,omitempty"`
+	Created_By     string             `json:"created_by,omitempty"`
+	Created_At     time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+}
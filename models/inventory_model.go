@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Ingredient is something the kitchen keeps in stock. Stock, recipes and the
+// ledger are all counted in its Unit. Ingredients are deactivated rather
+// than deleted so the ledger keeps its names. Supplier_Id is the supplier
+// reorders are suggested from, and Unit_Cost follows the prices received.
+type Ingredient struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Ingredient_Id string             `json:"ingredient_id"`
+	Name          string             `json:"name" validate:"required,min=2,max=100"`
+	Unit          string             `json:"unit" validate:"required,oneof=g kg ml l piece"`
+	Unit_Cost     float64            `json:"unit_cost" validate:"gte=0"`
+	Supplier_Id   string             `bson:"supplier_id,omitempty" json:"supplier_id,omitempty"`
+	Active        bool               `json:"active"`
+	Created_At    time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At    time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// Stock_Level is how much of an ingredient a branch has on hand. It is the
+// running total of the branch's movements and can go negative when sales
+// outrun the last count. When stock plus what is on order falls to the
+// Reorder_Point, a purchase order is suggested to bring it back to the
+// Par_Level.
+type Stock_Level struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Ingredient_Id string             `json:"ingredient_id"`
+	Branch_Id     string             `json:"branch_id"`
+	Quantity      float64            `json:"quantity"`
+	Reorder_Point float64            `bson:"reorder_point,omitempty" json:"reorder_point,omitempty"`
+	Par_Level     float64            `bson:"par_level,omitempty" json:"par_level,omitempty"`
+	Updated_At    time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// Recipe lists the ingredients one portion of a food uses, or that choosing
+// a modifier option adds. It has either a Food_Id or a Modifier_Group_Id and
+// Option_Id.
+type Recipe struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Recipe_Id         string             `json:"recipe_id"`
+	Food_Id           string             `bson:"food_id,omitempty" json:"food_id,omitempty"`
+	Modifier_Group_Id string             `bson:"modifier_group_id,omitempty" json:"modifier_group_id,omitempty"`
+	Option_Id         string             `bson:"option_id,omitempty" json:"option_id,omitempty"`
+	Lines             []Recipe_Line      `json:"lines" validate:"required,min=1,dive"`
+	Created_At        time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At        time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+type Recipe_Line struct {
+	Ingredient_Id string  `json:"ingredient_id" validate:"required"`
+	Quantity      float64 `json:"quantity" validate:"gt=0"`
+}
+
+// Stock_Movement is one entry in the inventory ledger. Quantity is positive
+// for stock coming in and negative for stock going out; Balance is the
+// branch's stock of the ingredient after it.
+type Stock_Movement struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Movement_Id       string             `json:"movement_id"`
+	Ingredient_Id     string             `json:"ingredient_id"`
+	Branch_Id         string             `json:"branch_id"`
+	Type              string             `json:"type"`
+	Quantity          float64            `json:"quantity"`
+	Balance           float64            `json:"balance"`
+	Order_Id          string             `bson:"order_id,omitempty" json:"order_id,omitempty"`
+	Order_Item_Id     string             `bson:"order_item_id,omitempty" json:"order_item_id,omitempty"`
+	Purchase_Order_Id string             `bson:"purchase_order_id,omitempty" json:"purchase_order_id,omitempty"`
+	Waste_Id          string             `bson:"waste_id,omitempty" json:"waste_id,omitempty"`
+	Reason            string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	Created_By        string             `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	Created_At        time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+}
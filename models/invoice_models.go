@@ -7,12 +7,27 @@ import (
 )
 
 type Invoice struct {
-	ID primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Invoice_Id   string   `json:"invoice_id" validate:"required"`
-	Order_Id   string  `json:"order_id" validate:"required"`
-	Payment_Method  *string `json:"payment_method" validate:"required"`
-	Payment_Status  *string `json:"payment_status" validate:"required"`
-	Payment_Due_Date  time.Time `json:"payment_due_date" validate:"required"`
-	Created_At  time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
-	Updated_At time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
-}
\ No newline at end of file
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Invoice_Id       string             `json:"invoice_id" validate:"required"`
+	Order_Id         string             `json:"order_id" validate:"required"`
+	Payment_Method   *string            `json:"payment_method" validate:"required"`
+	Payment_Status   *string            `json:"payment_status" validate:"required"`
+	Payment_Due_Date time.Time          `json:"payment_due_date" validate:"required"`
+	Branch_Id        string             `bson:"branch_id,omitempty" json:"branch_id,omitempty"`
+	Subtotal         float64            `bson:"subtotal,omitempty" json:"subtotal"`
+	Discounts        []Applied_Discount `bson:"discounts,omitempty" json:"discounts,omitempty"`
+	Discount_Total   float64            `bson:"discount_total,omitempty" json:"discount_total"`
+	Service_Charges  []Tax_Summary      `bson:"service_charges,omitempty" json:"service_charges,omitempty"`
+	Taxes            []Tax_Summary      `bson:"taxes,omitempty" json:"taxes,omitempty"`
+	Tax_Total        float64            `bson:"tax_total,omitempty" json:"tax_total"`
+	Tax_Inclusive    bool               `bson:"tax_inclusive,omitempty" json:"tax_inclusive"`
+	Total_Amount     float64            `bson:"total_amount,omitempty" json:"total_amount"`
+	Account_Name     string             `json:"account_name,omitempty"`
+	Contact_Email    string             `json:"contact_email,omitempty" validate:"omitempty,email"`
+	Contact_Phone    string             `json:"contact_phone,omitempty"`
+	Overdue          bool               `bson:"overdue,omitempty" json:"overdue"`
+	Overdue_Stage    int                `bson:"overdue_stage,omitempty" json:"overdue_stage"`
+	Last_Reminder_At time.Time          `bson:"last_reminder_at,omitempty" json:"last_reminder_at,omitempty"`
+	Created_At       time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At       time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
@@ -6,13 +6,38 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Menu is orderable between Start_Date and End_Date, during any of its
+// Service_Windows (every hour when it has none). An Exception replaces the
+// windows on its date: with no times the menu is not served that day.
+// Times are wall-clock times in the branch's timezone. Version is the number
+// of the menu's latest entry in its history.
 type Menu struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Name       string             `json:"name" validate:"required,min=2,max=50"`
-	Catagory   string             `json:"catagory" validate:"required"`
-	Start_Date time.Time          `json:"start_date" validate:"required"`
-	End_Date   time.Time          `json:"end_date" validate:"required"`
-	Created_At time.Time          `json:"created_at" validate:"required"`
-	Updated_At time.Time          `json:"updated_at" validate:"required"`
-	Menu_Id    string            `json:"menu_id" validate:"required"`
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name            string             `json:"name" validate:"required,min=2,max=50"`
+	Catagory        string             `json:"catagory" validate:"required"`
+	Start_Date      time.Time          `json:"start_date" validate:"required"`
+	End_Date        time.Time          `json:"end_date" validate:"required"`
+	Display_Order   *int               `bson:"display_order,omitempty" json:"display_order,omitempty"`
+	Service_Windows []Service_Window   `bson:"service_windows,omitempty" json:"service_windows,omitempty" validate:"dive"`
+	Exceptions      []Menu_Exception   `bson:"exceptions,omitempty" json:"exceptions,omitempty" validate:"dive"`
+	Created_At      time.Time          `json:"created_at" validate:"required"`
+	Updated_At      time.Time          `json:"updated_at" validate:"required"`
+	Menu_Id         string             `json:"menu_id" validate:"required"`
+	Version         int                `bson:"version,omitempty" json:"version,omitempty"`
+}
+
+// Service_Window is a recurring weekly serving time, e.g. breakfast 07:00-11:00
+// on weekdays. An End_Time before Start_Time runs past midnight.
+type Service_Window struct {
+	Days_Of_Week []int  `json:"days_of_week,omitempty" validate:"dive,min=0,max=6"`
+	Start_Time   string `json:"start_time" validate:"required,datetime=15:04"`
+	End_Time     string `json:"end_time" validate:"required,datetime=15:04"`
+}
+
+// Menu_Exception overrides the service windows on one date, such as a holiday.
+type Menu_Exception struct {
+	Date       string `json:"date" validate:"required,datetime=2006-01-02"`
+	Name       string `json:"name,omitempty"`
+	Start_Time string `json:"start_time,omitempty" validate:"omitempty,datetime=15:04"`
+	End_Time   string `json:"end_time,omitempty" validate:"omitempty,datetime=15:04"`
 }
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Modifier_Group is a set of options a guest picks from for a food, such as a
+// size, add-ons or removals. Foods list the groups they offer. Required groups
+// need at least one pick (or Min_Select if higher); a Max_Select of 0 means
+// no limit.
+type Modifier_Group struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Modifier_Group_Id string             `json:"modifier_group_id"`
+	Name              string             `json:"name" validate:"required,min=1,max=50"`
+	Type              string             `json:"type" validate:"required,oneof=SIZE ADD_ON REMOVAL"`
+	Required          bool               `json:"required"`
+	Min_Select        int                `json:"min_select" validate:"gte=0"`
+	Max_Select        int                `json:"max_select" validate:"gte=0"`
+	Options           []Modifier_Option  `json:"options" validate:"required,min=1,dive"`
+	Display_Order     *int               `bson:"display_order,omitempty" json:"display_order,omitempty"`
+	Created_At        time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At        time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// Modifier_Option is one choice in a group. Price_Delta is added to the food's
+// price and may be negative. Allergens are those the option adds to the dish,
+// and Nutrition is added to the food's. Availability works as on foods.
+type Modifier_Option struct {
+	Option_Id      string     `json:"option_id"`
+	Name           string     `json:"name" validate:"required,min=1,max=50"`
+	Price_Delta    float64    `json:"price_delta"`
+	Allergens      []string   `bson:"allergens,omitempty" json:"allergens,omitempty" validate:"omitempty,dive,oneof=gluten crustaceans eggs fish peanuts soybeans milk tree_nuts celery mustard sesame sulphites lupin molluscs"`
+	Nutrition      *Nutrition `bson:"nutrition,omitempty" json:"nutrition,omitempty"`
+	Availability   string     `bson:"availability,omitempty" json:"availability,omitempty" validate:"omitempty,oneof=AVAILABLE SOLD_OUT HIDDEN"`
+	Sold_Out_Until *time.Time `bson:"sold_out_until,omitempty" json:"sold_out_until,omitempty"`
+}
+
+// Selected_Modifier is an option chosen on an order item. Orders send the
+// group and option ids; the names and price are copied from the group when
+// the item is priced.
+type Selected_Modifier struct {
+	Modifier_Group_Id string     `json:"modifier_group_id" validate:"required"`
+	Option_Id         string     `json:"option_id" validate:"required"`
+	Group_Name        string     `json:"group_name,omitempty"`
+	Type              string     `json:"type,omitempty"`
+	Name              string     `json:"name,omitempty"`
+	Price_Delta       float64    `json:"price_delta"`
+	Allergens         []string   `bson:"allergens,omitempty" json:"allergens,omitempty"`
+	Nutrition         *Nutrition `bson:"nutrition,omitempty" json:"nutrition,omitempty"`
+}
@@ -7,10 +7,15 @@ import (
 )
 
 type Order struct {
-	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Order_Id     string             `json:"order_id" validate:"required"`
-	Table_Id     string             `json:"table_id" validate:"required"`
-	Order_Status string             `json:"order_status" validate:"required"`
-	Created_At   time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
-	Updated_At   time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Order_Id       string             `json:"order_id" validate:"required"`
+	Table_Id       string             `json:"table_id" validate:"required"`
+	Order_Status   string             `json:"order_status" validate:"required"`
+	Customer_Id    string             `bson:"customer_id,omitempty" json:"customer_id,omitempty"`
+	Promo_Codes    []string           `bson:"promo_codes,omitempty" json:"promo_codes,omitempty"`
+	Discounts      []Applied_Discount `bson:"discounts,omitempty" json:"discounts,omitempty"`
+	Discount_Total float64            `bson:"discount_total,omitempty" json:"discount_total"`
+	Allergy_Note   string             `bson:"allergy_note,omitempty" json:"allergy_note,omitempty" validate:"max=500"`
+	Created_At     time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At     time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
 }
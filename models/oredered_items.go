@@ -6,13 +6,36 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Ordered_Item is one line of an order. A combo is stored as a parent item
+// carrying Combo_Id and Combo_Price with a price of zero, and one child item
+// per slot carrying Parent_Item_Id. The combo price is split across the
+// children so each dish is credited with its share of the revenue.
+// Nutrition is the total for the line: the food and its modifiers times the
+// quantity. Note is a free-text request printed on the kitchen ticket.
+// Fired_At is when the item was sent to the kitchen and its
+// ingredients were taken out of stock.
 type Ordered_Item struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Menu_Id    string             `json:"menu_id" validate:"required"`
-	Food_Id    string             `json:"food_id" validate:"required"`
-	Order_Id   string             `json:"order_id" validate:"required"`
-	Quantity   int                `json:"quantity" validate:"required"`
-	Price      float64            `json:"price" validate:"required"`
-	Created_At time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
-	Updated_At time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	ID               primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	Order_Item_Id    string              `json:"order_item_id"`
+	Menu_Id          string              `json:"menu_id" validate:"required"`
+	Food_Id          string              `json:"food_id" validate:"required"`
+	Order_Id         string              `json:"order_id" validate:"required"`
+	Quantity         int                 `json:"quantity" validate:"required"`
+	Price            float64             `json:"price" validate:"required"`
+	Base_Price       float64             `bson:"base_price,omitempty" json:"base_price,omitempty"`
+	Modifiers        []Selected_Modifier `bson:"modifiers,omitempty" json:"modifiers,omitempty"`
+	Note             string              `bson:"note,omitempty" json:"note,omitempty" validate:"max=200"`
+	Nutrition        *Nutrition          `bson:"nutrition,omitempty" json:"nutrition,omitempty"`
+	Price_Rule_Id    string              `bson:"price_rule_id,omitempty" json:"price_rule_id,omitempty"`
+	Combo_Id         string              `bson:"combo_id,omitempty" json:"combo_id,omitempty"`
+	Combo_Price      float64             `bson:"combo_price,omitempty" json:"combo_price,omitempty"`
+	Combo_Selections []Combo_Selection   `bson:"-" json:"combo_selections,omitempty"`
+	Parent_Item_Id   string              `bson:"parent_item_id,omitempty" json:"parent_item_id,omitempty"`
+	Slot_Id          string              `bson:"slot_id,omitempty" json:"slot_id,omitempty"`
+	Gift_Card_Id     string              `bson:"gift_card_id,omitempty" json:"gift_card_id,omitempty"`
+	Gift_Card_Op     string              `bson:"gift_card_op,omitempty" json:"gift_card_op,omitempty"`
+	Fulfilled        bool                `bson:"fulfilled,omitempty" json:"fulfilled,omitempty"`
+	Fired_At         time.Time           `bson:"fired_at,omitempty" json:"fired_at,omitempty"`
+	Created_At       time.Time           `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At       time.Time           `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
 }
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Payment struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Payment_Id      string             `json:"payment_id"`
+	Invoice_Id      string             `json:"invoice_id"`
+	Amount          *float64           `json:"amount" validate:"required,gt=0"`
+	Payment_Method  *string            `json:"payment_method" validate:"required,oneof=CASH CARD MOBILE_MONEY LOYALTY_POINTS GIFT_CARD"`
+	Tip_Amount      float64            `json:"tip_amount" validate:"gte=0"`
+	Refunded_Amount float64            `json:"refunded_amount"`
+	Loyalty_Points  int                `bson:"loyalty_points,omitempty" json:"loyalty_points,omitempty"`
+	Gift_Card_Code  string             `bson:"gift_card_code,omitempty" json:"gift_card_code,omitempty"`
+	Shift_Id        string             `json:"shift_id,omitempty"`
+	Recorded_By     string             `json:"recorded_by"`
+	Created_At      time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At      time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Price_Rule changes food prices on a weekly schedule, e.g. happy hour or
+// late-night pricing. Times are wall-clock times in the branch's timezone; an
+// End_Time before Start_Time runs past midnight.
+type Price_Rule struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Price_Rule_Id string             `json:"price_rule_id"`
+	Name          string             `json:"name" validate:"required,min=2,max=100"`
+	Branch_Id     string             `json:"branch_id,omitempty"`
+	Food_Ids      []string           `json:"food_ids,omitempty"`
+	Menu_Id       string             `json:"menu_id,omitempty"`
+	Type          string             `json:"type" validate:"required,oneof=PERCENTAGE AMOUNT_OFF FIXED_PRICE"`
+	Value         float64            `json:"value" validate:"gte=0"`
+	Days_Of_Week  []int              `json:"days_of_week,omitempty" validate:"dive,min=0,max=6"`
+	Start_Time    string             `json:"start_time,omitempty" validate:"omitempty,datetime=15:04"`
+	End_Time      string             `json:"end_time,omitempty" validate:"omitempty,datetime=15:04"`
+	Valid_From    time.Time          `json:"valid_from"`
+	Valid_To      time.Time          `json:"valid_to"`
+	Priority      int                `json:"priority"`
+	Active        bool               `json:"active"`
+	Created_At    time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At    time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Printer is a network ESC/POS printer assigned to a station of a branch,
+// e.g. "receipt", "kitchen", "grill" or "bar".
+type Printer struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Printer_Id  string             `json:"printer_id"`
+	Branch_Id   string             `json:"branch_id" validate:"required"`
+	Station     string             `json:"station" validate:"required"`
+	Name        string             `json:"name" validate:"required"`
+	Address     string             `json:"address" validate:"required"`
+	Paper_Width int                `json:"paper_width,omitempty" validate:"omitempty,min=24,max=64"`
+	Disabled    bool               `json:"disabled"`
+	Created_At  time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At  time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Promotion is a discount rule. Promotions with a Code only apply when the
+// code is entered; those without one apply automatically (e.g. lunch deals).
+type Promotion struct {
+	ID                    primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Promotion_Id          string             `json:"promotion_id"`
+	Name                  string             `json:"name" validate:"required,min=2,max=100"`
+	Code                  string             `json:"code,omitempty" validate:"omitempty,alphanum,max=32"`
+	Type                  string             `json:"type" validate:"required,oneof=PERCENTAGE FIXED BUY_X_GET_Y CATEGORY"`
+	Value                 float64            `json:"value" validate:"gte=0"`
+	Buy_Quantity          int                `json:"buy_quantity,omitempty" validate:"required_if=Type BUY_X_GET_Y,omitempty,min=1"`
+	Get_Quantity          int                `json:"get_quantity,omitempty" validate:"required_if=Type BUY_X_GET_Y,omitempty,min=1"`
+	Food_Ids              []string           `json:"food_ids,omitempty"`
+	Category              string             `json:"category,omitempty" validate:"required_if=Type CATEGORY"`
+	Min_Order_Amount      float64            `json:"min_order_amount,omitempty" validate:"gte=0"`
+	Valid_From            time.Time          `json:"valid_from"`
+	Valid_To              time.Time          `json:"valid_to"`
+	Days_Of_Week          []int              `json:"days_of_week,omitempty" validate:"dive,min=0,max=6"`
+	Start_Time            string             `json:"start_time,omitempty" validate:"omitempty,datetime=15:04"`
+	End_Time              string             `json:"end_time,omitempty" validate:"omitempty,datetime=15:04"`
+	Max_Uses              int                `json:"max_uses,omitempty" validate:"gte=0"`
+	Max_Uses_Per_Customer int                `json:"max_uses_per_customer,omitempty" validate:"gte=0"`
+	Times_Used            int                `json:"times_used"`
+	Stackable             bool               `json:"stackable"`
+	Active                bool               `json:"active"`
+	Created_At            time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At            time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// Applied_Discount is a promotion applied to an order, copied onto its
+// invoices.
+type Applied_Discount struct {
+	Promotion_Id string  `json:"promotion_id"`
+	Name         string  `json:"name"`
+	Code         string  `json:"code,omitempty"`
+	Type         string  `json:"type"`
+	Amount       float64 `json:"amount"`
+}
+
+// Promotion_Redemption records each use of a promotion so usage limits per
+// code and per customer can be enforced.
+type Promotion_Redemption struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Promotion_Id string             `json:"promotion_id"`
+	Order_Id     string             `json:"order_id"`
+	Customer_Id  string             `json:"customer_id,omitempty"`
+	Amount       float64            `json:"amount"`
+	Created_At   time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+}
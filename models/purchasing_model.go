@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Supplier is a business ingredients are bought from. Lead_Time_Days is how
+// long its deliveries usually take, used to date suggested purchase orders.
+type Supplier struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Supplier_Id    string             `json:"supplier_id"`
+	Name           string             `json:"name" validate:"required,min=2,max=100"`
+	Contact_Name   string             `bson:"contact_name,omitempty" json:"contact_name,omitempty" validate:"max=100"`
+	Phone          string             `bson:"phone,omitempty" json:"phone,omitempty" validate:"max=30"`
+	Email          string             `bson:"email,omitempty" json:"email,omitempty" validate:"omitempty,email"`
+	Address        string             `bson:"address,omitempty" json:"address,omitempty" validate:"max=300"`
+	Lead_Time_Days int                `json:"lead_time_days" validate:"gte=0,lte=365"`
+	Active         bool               `json:"active"`
+	Created_At     time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At     time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// Purchase_Order is an order of ingredients from a supplier for a branch.
+// It starts as a DRAFT, must be APPROVED by a manager before goods can be
+// received against it, and is PARTIALLY_RECEIVED until every line has
+// arrived. Suggested marks drafts raised from par levels.
+type Purchase_Order struct {
+	ID                primitive.ObjectID    `bson:"_id,omitempty" json:"id,omitempty"`
+	Purchase_Order_Id string                `json:"purchase_order_id"`
+	Supplier_Id       string                `json:"supplier_id" validate:"required"`
+	Branch_Id         string                `json:"branch_id"`
+	Status            string                `json:"status"`
+	Lines             []Purchase_Order_Line `json:"lines" validate:"required,min=1,dive"`
+	Expected_At       time.Time             `json:"expected_at" validate:"required"`
+	Total             float64               `json:"total"`
+	Notes             string                `bson:"notes,omitempty" json:"notes,omitempty" validate:"max=500"`
+	Suggested         bool                  `bson:"suggested,omitempty" json:"suggested,omitempty"`
+	Receipts          []Goods_Receipt       `bson:"receipts,omitempty" json:"receipts,omitempty"`
+	Created_By        string                `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	Approved_By       string                `bson:"approved_by,omitempty" json:"approved_by,omitempty"`
+	Approved_At       *time.Time            `bson:"approved_at,omitempty" json:"approved_at,omitempty"`
+	Created_At        time.Time             `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At        time.Time             `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// Purchase_Order_Line is an ingredient ordered, in the ingredient's unit, at
+// the agreed Unit_Cost.
+type Purchase_Order_Line struct {
+	Ingredient_Id     string  `json:"ingredient_id" validate:"required"`
+	Quantity          float64 `json:"quantity" validate:"gt=0"`
+	Unit_Cost         float64 `json:"unit_cost" validate:"gte=0"`
+	Received_Quantity float64 `json:"received_quantity"`
+}
+
+// Goods_Receipt records one delivery against a purchase order.
+type Goods_Receipt struct {
+	Receipt_Id  string                `json:"receipt_id"`
+	Lines       []Goods_Received_Line `json:"lines"`
+	Note        string                `bson:"note,omitempty" json:"note,omitempty"`
+	Received_By string                `bson:"received_by,omitempty" json:"received_by,omitempty"`
+	Received_At time.Time             `json:"received_at"`
+}
+
+// Goods_Received_Line is how much of an ingredient arrived and what it was
+// invoiced at.
+type Goods_Received_Line struct {
+	Ingredient_Id string  `json:"ingredient_id" validate:"required"`
+	Quantity      float64 `json:"quantity" validate:"gt=0"`
+	Unit_Cost     float64 `json:"unit_cost"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reversal is a refund or void request. Requests above the configured
+// approval threshold stay PENDING_APPROVAL until a manager approves them.
+type Reversal struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Reversal_Id    string             `json:"reversal_id"`
+	Type           string             `json:"type"`
+	Invoice_Id     string             `json:"invoice_id,omitempty"`
+	Order_Id       string             `json:"order_id,omitempty"`
+	Payment_Id     string             `json:"payment_id,omitempty"`
+	Amount         float64            `json:"amount"`
+	Reason_Code    string             `json:"reason_code"`
+	Reason         string             `json:"reason,omitempty"`
+	Status         string             `json:"status"`
+	Requested_By   string             `json:"requested_by"`
+	Approved_By    string             `json:"approved_by,omitempty"`
+	Credit_Note_Id string             `json:"credit_note_id,omitempty"`
+	Shift_Id       string             `json:"shift_id,omitempty"`
+	Created_At     time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At     time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+type Credit_Note struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Credit_Note_Id string             `json:"credit_note_id"`
+	Invoice_Id     string             `json:"invoice_id"`
+	Reversal_Id    string             `json:"reversal_id"`
+	Amount         float64            `json:"amount"`
+	Reason_Code    string             `json:"reason_code"`
+	Created_At     time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+}
+
+type Audit_Log struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Audit_Id   string             `json:"audit_id"`
+	Action     string             `json:"action"`
+	Entity     string             `json:"entity"`
+	Entity_Id  string             `json:"entity_id"`
+	User_Id    string             `json:"user_id"`
+	Amount     float64            `json:"amount,omitempty"`
+	Details    string             `json:"details,omitempty"`
+	Created_At time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+}
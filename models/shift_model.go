@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Shift is a cashier's session on a cash drawer, from the opening float to
+// the counted cash at close.
+type Shift struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Shift_Id       string             `json:"shift_id"`
+	Branch_Id      string             `json:"branch_id,omitempty"`
+	Cashier_Id     string             `json:"cashier_id"`
+	Status         string             `json:"status"`
+	Opening_Float  *float64           `json:"opening_float" validate:"required,gte=0"`
+	Cash_Movements []Cash_Movement    `json:"cash_movements"`
+	Expected_Cash  float64            `json:"expected_cash,omitempty"`
+	Counted_Cash   float64            `json:"counted_cash,omitempty"`
+	Variance       float64            `json:"variance,omitempty"`
+	Closed_By      string             `json:"closed_by,omitempty"`
+	Opened_At      time.Time          `bson:"opened_at,omitempty" json:"opened_at,omitempty"`
+	Closed_At      time.Time          `bson:"closed_at,omitempty" json:"closed_at,omitempty"`
+	Updated_At     time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+type Cash_Movement struct {
+	Movement_Id string    `json:"movement_id"`
+	Type        string    `json:"type" validate:"required,oneof=CASH_IN CASH_OUT"`
+	Amount      *float64  `json:"amount" validate:"required,gt=0"`
+	Reason      string    `json:"reason" validate:"required"`
+	Recorded_By string    `json:"recorded_by"`
+	Created_At  time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
+}
+
+// Shift_Report holds the totals printed on X-reports (mid-shift) and
+// Z-reports (end of day).
+type Shift_Report struct {
+	Report_Type       string             `json:"report_type"`
+	Branch_Id         string             `json:"branch_id,omitempty"`
+	Shift_Ids         []string           `json:"shift_ids"`
+	From              time.Time          `json:"from"`
+	To                time.Time          `json:"to"`
+	Payment_Count     int                `json:"payment_count"`
+	Sales_By_Method   map[string]float64 `json:"sales_by_method"`
+	Tips_By_Method    map[string]float64 `json:"tips_by_method"`
+	Gross_Sales       float64            `json:"gross_sales"`
+	Total_Tips        float64            `json:"total_tips"`
+	Refund_Count      int                `json:"refund_count"`
+	Refunds_By_Method map[string]float64 `json:"refunds_by_method"`
+	Total_Refunds     float64            `json:"total_refunds"`
+	Void_Count        int                `json:"void_count"`
+	Total_Voids       float64            `json:"total_voids"`
+	Net_Sales         float64            `json:"net_sales"`
+	Opening_Float     float64            `json:"opening_float"`
+	Cash_In           float64            `json:"cash_in"`
+	Cash_Out          float64            `json:"cash_out"`
+	Expected_Cash     float64            `json:"expected_cash"`
+	Counted_Cash      float64            `json:"counted_cash,omitempty"`
+	Variance          float64            `json:"variance,omitempty"`
+	Generated_At      time.Time          `json:"generated_at"`
+}
+
+// Z_Report is the stored end-of-day report. Z numbers increase per branch.
+type Z_Report struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Z_Report_Id   string             `json:"z_report_id"`
+	Z_Number      int                `json:"z_number"`
+	Branch_Id     string             `json:"branch_id,omitempty"`
+	Business_Date string             `json:"business_date"`
+	Report        Shift_Report       `json:"report"`
+	Created_By    string             `json:"created_by"`
+	Created_At    time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+}
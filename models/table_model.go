@@ -10,6 +10,7 @@ type Table struct {
 	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	Table_Id   string             `json:"table_id" validate:"required"`
 	Table_Name string             `json:"table_name" validate:"required"`
+	Branch_Id  string             `json:"branch_id,omitempty"`
 	Created_At time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
 	Updated_At time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
 }
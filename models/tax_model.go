@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Tax_Rate is a tax or service charge. Without a Branch_Id it applies to every
+// branch, and without Categories to every menu category. Compound taxes are
+// charged on the price plus the non-compound taxes (e.g. VAT on top of
+// excise). Taxable_Service_Charge taxes apply to the service charge as well.
+type Tax_Rate struct {
+	ID                     primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Tax_Rate_Id            string             `json:"tax_rate_id"`
+	Name                   string             `json:"name" validate:"required,max=50"`
+	Code                   string             `json:"code" validate:"required,alphanum,max=20"`
+	Type                   string             `json:"type" validate:"required,oneof=TAX SERVICE_CHARGE"`
+	Rate                   float64            `json:"rate" validate:"gte=0,lte=100"`
+	Branch_Id              string             `json:"branch_id,omitempty"`
+	Categories             []string           `json:"categories,omitempty"`
+	Compound               bool               `json:"compound"`
+	Taxable_Service_Charge bool               `json:"taxable_service_charge"`
+	Active                 bool               `json:"active"`
+	Created_At             time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Updated_At             time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// Tax_Summary is the total for one rate on an invoice.
+type Tax_Summary struct {
+	Tax_Rate_Id    string  `json:"tax_rate_id"`
+	Name           string  `json:"name"`
+	Code           string  `json:"code"`
+	Rate           float64 `json:"rate"`
+	Taxable_Amount float64 `json:"taxable_amount"`
+	Amount         float64 `json:"amount"`
+}
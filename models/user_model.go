@@ -13,7 +13,7 @@ type User struct {
 	Password      string             `json:"password" validate:"required"`
 	Email         string             `json:"email" validate:"required,email"`
 	Phone         string             `json:"phone" validate:"required"`
-	Role          string             `json:"role" validate:"required,oneof=admin user"`
+	Role          string             `json:"role" validate:"required,oneof=admin manager user"`
 	CreatedAt     time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
 	UpdatedAt     time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
 	User_id       string             `bson:"user_id,omitempty" json:"user_id,omitempty"`
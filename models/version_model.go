@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Food_Version is a food as it stood from Effective_From until its next
+// version. Change says what made it: creating, updating, deleting or
+// rolling back the food. A BASELINE version records a food as it was
+// before its first recorded change.
+type Food_Version struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Food_Id        string             `json:"food_id"`
+	Version        int                `json:"version"`
+	Change         string             `json:"change"`
+	Food           Food               `json:"food"`
+	Effective_From time.Time          `json:"effective_from"`
+	Changed_By     string             `bson:"changed_by,omitempty" json:"changed_by,omitempty"`
+}
+
+// Menu_Version is a menu and the foods shown on it from Effective_From
+// until its next version. Changes to one of its foods add a version with
+// that Food_Id. Hidden foods are left out, as they are off the menu.
+type Menu_Version struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Menu_Id        string             `json:"menu_id"`
+	Version        int                `json:"version"`
+	Change         string             `json:"change"`
+	Food_Id        string             `bson:"food_id,omitempty" json:"food_id,omitempty"`
+	Menu           Menu               `json:"menu"`
+	Foods          []Food             `bson:"foods,omitempty" json:"foods,omitempty"`
+	Effective_From time.Time          `json:"effective_from"`
+	Changed_By     string             `bson:"changed_by,omitempty" json:"changed_by,omitempty"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Waste_Event is food thrown away: an ingredient in its unit, or portions
+// of a dish. Dish waste takes the dish's recipe out of stock. Cost is what
+// the waste was worth at the ingredient costs of the day. Staff_Id is who
+// wasted it, which need not be who logged it.
+type Waste_Event struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Waste_Id      string             `json:"waste_id"`
+	Branch_Id     string             `json:"branch_id"`
+	Ingredient_Id string             `bson:"ingredient_id,omitempty" json:"ingredient_id,omitempty"`
+	Food_Id       string             `bson:"food_id,omitempty" json:"food_id,omitempty"`
+	Name          string             `json:"name"`
+	Unit          string             `json:"unit"`
+	Reason        string             `json:"reason" validate:"required,oneof=SPOILED DROPPED RETURNED OVERPRODUCTION"`
+	Quantity      float64            `json:"quantity" validate:"gt=0"`
+	Cost          float64            `json:"cost"`
+	Staff_Id      string             `json:"staff_id"`
+	Order_Id      string             `bson:"order_id,omitempty" json:"order_id,omitempty"`
+	Note          string             `bson:"note,omitempty" json:"note,omitempty" validate:"max=300"`
+	Created_By    string             `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	Created_At    time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+}
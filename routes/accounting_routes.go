@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func AccountingRoutes(r *gin.Engine) {
+	r.GET("/accounting/accounts", controllers.GetAccountMapping())
+	r.PUT("/accounting/accounts", controllers.UpdateAccountMapping())
+	r.GET("/accounting/journal", controllers.GetJournal())
+	r.GET("/accounting/export", controllers.ExportJournal())
+	r.GET("/accounting/periods", controllers.GetAccountingPeriods())
+	r.DELETE("/accounting/periods/:period_id", controllers.UnlockAccountingPeriod())
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func AvailabilityRoutes(r *gin.Engine) {
+	r.GET("/availability", controllers.GetAvailability())
+	r.GET("/availability/stream", controllers.StreamAvailability())
+	r.PATCH("/foods/:food_id/availability", controllers.SetFoodAvailability())
+	r.PATCH("/modifier_groups/:modifier_group_id/options/:option_id/availability", controllers.SetModifierOptionAvailability())
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func BranchRoutes(r *gin.Engine) {
+	r.GET("/branches", controllers.GetBranches())
+	r.GET("/branches/:branch_id", controllers.GetBranch())
+	r.POST("/branches", controllers.CreateBranch())
+	r.PATCH("/branches/:branch_id", controllers.UpdateBranch())
+	r.PUT("/branches/:branch_id/template", controllers.UpdateBranchTemplate())
+}
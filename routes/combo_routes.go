@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func ComboRoutes(r *gin.Engine) {
+	r.GET("/combos", controllers.GetCombos())
+	r.GET("/combos/:combo_id", controllers.GetCombo())
+	r.POST("/combos", controllers.CreateCombo())
+	r.PUT("/combos/:combo_id", controllers.UpdateCombo())
+	r.DELETE("/combos/:combo_id", controllers.DeleteCombo())
+}
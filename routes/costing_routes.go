@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func CostingRoutes(r *gin.Engine) {
+	r.GET("/foods/:food_id/cost", controllers.GetFoodCost())
+	r.GET("/foods/:food_id/cost_history", controllers.GetFoodCostHistory())
+	r.GET("/costing/foods", controllers.GetFoodCosts())
+	r.POST("/costing/recalculate", controllers.RecalculateFoodCosts())
+	r.GET("/reports/margins", controllers.GetMarginReport())
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func CustomerRoutes(r *gin.Engine) {
+	r.GET("/customers", controllers.GetCustomers())
+	r.GET("/customers/:customer_id", controllers.GetCustomer())
+	r.POST("/customers", controllers.CreateCustomer())
+	r.PATCH("/customers/:customer_id", controllers.UpdateCustomer())
+	r.GET("/customers/:customer_id/loyalty", controllers.GetCustomerLoyalty())
+	r.POST("/customers/:customer_id/loyalty/adjust", controllers.AdjustCustomerPoints())
+	r.GET("/loyalty/program", controllers.GetLoyaltyProgram())
+	r.PUT("/loyalty/program", controllers.UpdateLoyaltyProgram())
+	r.POST("/loyalty/expire", controllers.ExpireLoyaltyPoints())
+}
@@ -6,9 +6,10 @@ import (
 )
 
 func FoodRoutes(r *gin.Engine) {
-	r.GET("/foods", controllers.GetFoods())
-	r.GET("/foods/:food_id", controllers.GetFood())
+	r.GET("/foods", controllers.GetFood())
+	r.GET("/foods/:food_id", controllers.GetFoods())
 	r.POST("/foods", controllers.CreateFood())
 	r.PATCH("/foods/:food_id", controllers.UpdateFood())
 	r.DELETE("/foods/:food_id", controllers.DeleteFood())
+	r.POST("/foods/:food_id/image", controllers.UploadFoodImage())
 }
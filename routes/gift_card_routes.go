@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func GiftCardRoutes(r *gin.Engine) {
+	r.GET("/gift_cards", controllers.GetGiftCards())
+	r.POST("/gift_cards", controllers.IssueGiftCard())
+	r.GET("/gift_cards/:code", controllers.GetGiftCard())
+	r.GET("/gift_cards/:code/transactions", controllers.GetGiftCardTransactions())
+	r.POST("/gift_cards/:code/reload", controllers.ReloadGiftCard())
+	r.POST("/gift_cards/:code/deactivate", controllers.DeactivateGiftCard())
+}
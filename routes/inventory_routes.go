@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func InventoryRoutes(r *gin.Engine) {
+	r.GET("/ingredients", controllers.GetIngredients())
+	r.GET("/ingredients/:ingredient_id", controllers.GetIngredient())
+	r.POST("/ingredients", controllers.CreateIngredient())
+	r.PUT("/ingredients/:ingredient_id", controllers.UpdateIngredient())
+	r.DELETE("/ingredients/:ingredient_id", controllers.DeleteIngredient())
+	r.GET("/recipes", controllers.GetRecipes())
+	r.POST("/recipes", controllers.SaveRecipe())
+	r.DELETE("/recipes/:recipe_id", controllers.DeleteRecipe())
+	r.GET("/inventory/stock", controllers.GetStockLevels())
+	r.GET("/inventory/movements", controllers.GetStockMovements())
+	r.POST("/inventory/adjustments", controllers.AdjustStock())
+	r.POST("/inventory/counts", controllers.CountStock())
+	r.PUT("/inventory/par_levels", controllers.SetParLevel())
+	r.POST("/orders/:order_id/fire", controllers.FireOrder())
+}
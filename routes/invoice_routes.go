@@ -7,8 +7,18 @@ import (
 
 func InvoiceRoutes(r *gin.Engine) {
 	r.GET("/invoices", controllers.GetInvoices())
+	r.GET("/invoices/aging", controllers.GetInvoiceAging())
+	r.POST("/invoices/overdue/check", controllers.CheckOverdueInvoices())
 	r.GET("/invoices/:invoice_id", controllers.GetInvoice())
 	r.POST("/invoices", controllers.CreateInvoice())
 	r.PATCH("/invoices/:invoice_id", controllers.UpdateInvoice())
 	r.DELETE("/invoices/:invoice_id", controllers.DeleteInvoice())
+	r.GET("/invoices/:invoice_id/pdf", controllers.GetInvoicePDF())
+	r.GET("/invoices/:invoice_id/receipt", controllers.GetInvoiceReceipt())
+}
+
+// InvoiceVerificationRoutes are public so the QR code on a printed invoice
+// can be checked without logging in.
+func InvoiceVerificationRoutes(r *gin.Engine) {
+	r.GET("/invoices/:invoice_id/verify", controllers.VerifyInvoice())
 }
@@ -7,7 +7,11 @@ import (
 
 func MenuRoutes(r *gin.Engine) {
 	r.GET("/menus", controllers.GetMenus())
+	r.GET("/menus/active", controllers.GetActiveMenus())
+	r.GET("/menus/full", controllers.GetFullMenus())
+	r.GET("/menus/export", controllers.ExportMenus())
 	r.GET("/menus/:menu_id", controllers.GetMenu())
+	r.GET("/menus/:menu_id/full", controllers.GetFullMenu())
 	r.POST("/menus", controllers.CreateMenu())
 	r.PATCH("/menus/:menu_id", controllers.UpdateMenu())
 	r.DELETE("/menus/:menu_id", controllers.DeleteMenu())
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func ModifierRoutes(r *gin.Engine) {
+	r.GET("/modifier_groups", controllers.GetModifierGroups())
+	r.GET("/modifier_groups/:modifier_group_id", controllers.GetModifierGroup())
+	r.POST("/modifier_groups", controllers.CreateModifierGroup())
+	r.PUT("/modifier_groups/:modifier_group_id", controllers.UpdateModifierGroup())
+	r.DELETE("/modifier_groups/:modifier_group_id", controllers.DeleteModifierGroup())
+}
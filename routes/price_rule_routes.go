@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func PriceRuleRoutes(r *gin.Engine) {
+	r.GET("/price_rules", controllers.GetPriceRules())
+	r.POST("/price_rules", controllers.CreatePriceRule())
+	r.PUT("/price_rules/:price_rule_id", controllers.UpdatePriceRule())
+	r.DELETE("/price_rules/:price_rule_id", controllers.DeletePriceRule())
+}
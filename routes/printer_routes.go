@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func PrinterRoutes(r *gin.Engine) {
+	r.GET("/printers", controllers.GetPrinters())
+	r.POST("/printers", controllers.CreatePrinter())
+	r.PATCH("/printers/:printer_id", controllers.UpdatePrinter())
+	r.DELETE("/printers/:printer_id", controllers.DeletePrinter())
+	r.GET("/print_jobs", controllers.GetPrintJobs())
+	r.POST("/invoices/:invoice_id/print", controllers.PrintInvoice())
+	r.POST("/orders/:order_id/print", controllers.PrintOrderTickets())
+}
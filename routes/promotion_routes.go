@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func PromotionRoutes(r *gin.Engine) {
+	r.GET("/promotions", controllers.GetPromotions())
+	r.GET("/promotions/:promotion_id", controllers.GetPromotion())
+	r.POST("/promotions", controllers.CreatePromotion())
+	r.PUT("/promotions/:promotion_id", controllers.UpdatePromotion())
+	r.DELETE("/promotions/:promotion_id", controllers.DeletePromotion())
+}
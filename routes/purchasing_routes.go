@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func PurchasingRoutes(r *gin.Engine) {
+	r.GET("/suppliers", controllers.GetSuppliers())
+	r.GET("/suppliers/:supplier_id", controllers.GetSupplier())
+	r.POST("/suppliers", controllers.CreateSupplier())
+	r.PUT("/suppliers/:supplier_id", controllers.UpdateSupplier())
+	r.DELETE("/suppliers/:supplier_id", controllers.DeleteSupplier())
+	r.GET("/purchase_orders", controllers.GetPurchaseOrders())
+	r.GET("/purchase_orders/suggestions", controllers.GetReorderSuggestions())
+	r.POST("/purchase_orders/suggestions", controllers.CreateSuggestedPurchaseOrders())
+	r.GET("/purchase_orders/:purchase_order_id", controllers.GetPurchaseOrder())
+	r.POST("/purchase_orders", controllers.CreatePurchaseOrder())
+	r.PUT("/purchase_orders/:purchase_order_id", controllers.UpdatePurchaseOrder())
+	r.POST("/purchase_orders/:purchase_order_id/approve", controllers.ApprovePurchaseOrder())
+	r.POST("/purchase_orders/:purchase_order_id/cancel", controllers.CancelPurchaseOrder())
+	r.POST("/purchase_orders/:purchase_order_id/receive", controllers.ReceivePurchaseOrder())
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func ReversalRoutes(r *gin.Engine) {
+	r.GET("/invoices/:invoice_id/payments", controllers.GetInvoicePayments())
+	r.POST("/invoices/:invoice_id/payments", controllers.CreatePayment())
+	r.POST("/invoices/:invoice_id/refunds", controllers.CreateRefund())
+	r.POST("/orders/:order_id/void", controllers.VoidOrder())
+	r.GET("/reversals", controllers.GetReversals())
+	r.POST("/reversals/:reversal_id/approve", controllers.ApproveReversal())
+	r.POST("/reversals/:reversal_id/reject", controllers.RejectReversal())
+	r.GET("/credit_notes", controllers.GetCreditNotes())
+	r.GET("/audit_logs", controllers.GetAuditLogs())
+}
@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func SearchRoutes(r *gin.Engine) {
+	r.GET("/search", controllers.SearchFoods())
+}
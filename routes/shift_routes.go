@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func ShiftRoutes(r *gin.Engine) {
+	r.GET("/shifts", controllers.GetShifts())
+	r.GET("/shifts/current", controllers.GetCurrentShift())
+	r.GET("/shifts/:shift_id", controllers.GetShift())
+	r.POST("/shifts/open", controllers.OpenShift())
+	r.POST("/shifts/:shift_id/cash", controllers.RecordCashMovement())
+	r.POST("/shifts/:shift_id/close", controllers.CloseShift())
+	r.GET("/shifts/:shift_id/x-report", controllers.GetShiftXReport())
+	r.GET("/z_reports", controllers.GetZReports())
+	r.POST("/z_reports", controllers.CreateZReport())
+}
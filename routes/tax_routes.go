@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func TaxRoutes(r *gin.Engine) {
+	r.GET("/tax_rates", controllers.GetTaxRates())
+	r.POST("/tax_rates", controllers.CreateTaxRate())
+	r.PUT("/tax_rates/:tax_rate_id", controllers.UpdateTaxRate())
+	r.DELETE("/tax_rates/:tax_rate_id", controllers.DeleteTaxRate())
+	r.GET("/reports/tax", controllers.GetTaxReport())
+}
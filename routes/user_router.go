@@ -11,3 +11,9 @@ func UserRoutes(r *gin.Engine) {
 	r.POST("/users/signup", controllers.Signup())
 	r.POST("/users/login", controllers.Login())
 }
+
+// UserRoleRoutes needs the authenticated user, so it is registered after the
+// auth middleware.
+func UserRoleRoutes(r *gin.Engine) {
+	r.PATCH("/users/:user_id/role", controllers.SetUserRole())
+}
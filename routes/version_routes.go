@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func VersionRoutes(r *gin.Engine) {
+	r.GET("/foods/:food_id/versions", controllers.GetFoodVersions())
+	r.GET("/foods/:food_id/price_history", controllers.GetFoodPriceHistory())
+	r.POST("/foods/:food_id/versions/:version/rollback", controllers.RollbackFood())
+	r.GET("/menus/:menu_id/versions", controllers.GetMenuVersions())
+	r.GET("/menus/:menu_id/versions/:version", controllers.GetMenuVersion())
+	r.GET("/menus/:menu_id/diff", controllers.DiffMenuVersions())
+	r.POST("/menus/:menu_id/versions/:version/rollback", controllers.RollbackMenu())
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/abik1221/Tewanay-Engineering_Intership/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func WasteRoutes(r *gin.Engine) {
+	r.GET("/waste", controllers.GetWasteEvents())
+	r.POST("/waste", controllers.LogWaste())
+	r.GET("/reports/waste", controllers.GetWasteReport())
+}